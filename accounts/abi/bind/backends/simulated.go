@@ -817,8 +817,9 @@ type filterBackend struct {
 	bc *core.BlockChain
 }
 
-func (fb *filterBackend) ChainDb() ethdb.Database  { return fb.db }
-func (fb *filterBackend) EventMux() *event.TypeMux { panic("not supported") }
+func (fb *filterBackend) ChainDb() ethdb.Database          { return fb.db }
+func (fb *filterBackend) ChainConfig() *params.ChainConfig { return fb.bc.Config() }
+func (fb *filterBackend) EventMux() *event.TypeMux         { panic("not supported") }
 
 func (fb *filterBackend) HeaderByNumber(ctx context.Context, block rpc.BlockNumber) (*types.Header, error) {
 	if block == rpc.LatestBlockNumber {
@@ -877,6 +878,9 @@ func (fb *filterBackend) SubscribePendingLogsEvent(ch chan<- []*types.Log) event
 
 func (fb *filterBackend) BloomStatus() (uint64, uint64) { return 4096, 0 }
 
+func (fb *filterBackend) RPCLogsRangeCap() uint64  { return 0 }
+func (fb *filterBackend) RPCLogsResultCap() uint64 { return 0 }
+
 func (fb *filterBackend) ServiceFilter(ctx context.Context, ms *bloombits.MatcherSession) {
 	panic("not supported")
 }
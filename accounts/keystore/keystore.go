@@ -87,6 +87,16 @@ func NewKeyStore(keydir string, scryptN, scryptP int) *KeyStore {
 	return ks
 }
 
+// NewKeyStoreWithArgon2 creates a keystore for the given directory which stores
+// keys in the version 4 format, using argon2id with the given parameters. Key
+// files of all older versions can still be read.
+func NewKeyStoreWithArgon2(keydir string, config Argon2Config) *KeyStore {
+	keydir, _ = filepath.Abs(keydir)
+	ks := &KeyStore{storage: &keyStorePassphraseV4{keyStorePassphrase{keydir, StandardScryptN, StandardScryptP, false}, config}}
+	ks.init(keydir)
+	return ks
+}
+
 // NewPlaintextKeyStore creates a keystore for the given directory.
 // Deprecated: Use NewKeyStore.
 func NewPlaintextKeyStore(keydir string) *KeyStore {
@@ -425,9 +435,12 @@ func (ks *KeyStore) Export(a accounts.Account, passphrase, newPassphrase string)
 		return nil, err
 	}
 	var N, P int
-	if store, ok := ks.storage.(*keyStorePassphrase); ok {
+	switch store := ks.storage.(type) {
+	case *keyStorePassphraseV4:
+		return EncryptKeyV4(key, newPassphrase, store.config)
+	case *keyStorePassphrase:
 		N, P = store.scryptN, store.scryptP
-	} else {
+	default:
 		N, P = StandardScryptN, StandardScryptP
 	}
 	return EncryptKey(key, newPassphrase, N, P)
@@ -477,7 +490,9 @@ func (ks *KeyStore) importKey(key *Key, passphrase string) (accounts.Account, er
 	return a, nil
 }
 
-// Update changes the passphrase of an existing account.
+// Update changes the passphrase of an existing account. The key file is written
+// with the current encryption parameters of the keystore, so it can also be used
+// to re-encrypt keys under stronger key derivation settings.
 func (ks *KeyStore) Update(a accounts.Account, passphrase, newPassphrase string) error {
 	a, key, err := ks.getDecryptedKey(a, passphrase)
 	if err != nil {
@@ -17,6 +17,7 @@
 package keystore
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"math/rand"
 	"os"
@@ -392,6 +393,46 @@ func TestImportExport(t *testing.T) {
 
 }
 
+// Tests that a version 3 key can be re-encrypted into the version 4 format and
+// that both keystore flavours keep reading it.
+func TestRekeyV4(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	acc, err := ks.NewAccount("old")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	ks4 := NewKeyStoreWithArgon2(dir, veryLightArgon2Config)
+	if err := ks4.Update(acc, "old", "new"); err != nil {
+		t.Fatalf("failed to rekey account: %v", err)
+	}
+	keyjson, err := ioutil.ReadFile(acc.URL.Path)
+	if err != nil {
+		t.Fatalf("failed to read key file: %v", err)
+	}
+	var header struct {
+		Version int `json:"version"`
+		Crypto  struct {
+			KDF string `json:"kdf"`
+		} `json:"crypto"`
+	}
+	if err := json.Unmarshal(keyjson, &header); err != nil {
+		t.Fatalf("failed to parse key file: %v", err)
+	}
+	if header.Version != versionV4 || header.Crypto.KDF != keyHeaderKDFArgon2id {
+		t.Errorf("key file format mismatch: have version %d kdf %s, want version %d kdf %s", header.Version, header.Crypto.KDF, versionV4, keyHeaderKDFArgon2id)
+	}
+	for i, store := range []*KeyStore{ks, ks4} {
+		if err := store.Unlock(acc, "old"); err != ErrDecrypt {
+			t.Errorf("keystore %d: old password error mismatch: have %v, want %v", i, err, ErrDecrypt)
+		}
+		if err := store.Unlock(acc, "new"); err != nil {
+			t.Errorf("keystore %d: failed to unlock rekeyed account: %v", i, err)
+		}
+	}
+}
+
 // TestImportRace tests the keystore on races.
 // This test should fail under -race if importing races.
 func TestImportRace(t *testing.T) {
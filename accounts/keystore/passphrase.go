@@ -43,6 +43,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/pbkdf2"
 	"golang.org/x/crypto/scrypt"
 )
@@ -103,11 +104,24 @@ func StoreKey(dir, auth string, scryptN, scryptP int) (accounts.Account, error)
 	return a, err
 }
 
+// StoreKeyWithArgon2 generates a key, encrypts with 'auth' into the version 4
+// format and stores in the given directory
+func StoreKeyWithArgon2(dir, auth string, config Argon2Config) (accounts.Account, error) {
+	_, a, err := storeNewKey(&keyStorePassphraseV4{keyStorePassphrase{dir, StandardScryptN, StandardScryptP, false}, config}, rand.Reader, auth)
+	return a, err
+}
+
 func (ks keyStorePassphrase) StoreKey(filename string, key *Key, auth string) error {
 	keyjson, err := EncryptKey(key, auth, ks.scryptN, ks.scryptP)
 	if err != nil {
 		return err
 	}
+	return ks.storeKeyJSON(filename, key.Address, keyjson, auth)
+}
+
+// storeKeyJSON atomically writes an encrypted key file, verifying beforehand
+// that it can be decrypted with the given password.
+func (ks keyStorePassphrase) storeKeyJSON(filename string, addr common.Address, keyjson []byte, auth string) error {
 	// Write into temporary file
 	tmpName, err := writeTemporaryKeyFile(filename, keyjson)
 	if err != nil {
@@ -115,7 +129,7 @@ func (ks keyStorePassphrase) StoreKey(filename string, key *Key, auth string) er
 	}
 	if !ks.skipKeyFileVerification {
 		// Verify that we can decrypt the file with the given password.
-		_, err = ks.GetKey(key.Address, tmpName, auth)
+		_, err = ks.GetKey(addr, tmpName, auth)
 		if err != nil {
 			msg := "An error was encountered when saving and verifying the keystore file. \n" +
 				"This indicates that the keystore is corrupted. \n" +
@@ -216,6 +230,12 @@ func DecryptKey(keyjson []byte, auth string) (*Key, error) {
 			return nil, err
 		}
 		keyBytes, keyId, err = decryptKeyV1(k, auth)
+	} else if version, ok := m["version"].(float64); ok && version == versionV4 {
+		k := new(encryptedKeyJSONV3)
+		if err := json.Unmarshal(keyjson, k); err != nil {
+			return nil, err
+		}
+		keyBytes, keyId, err = decryptKeyV4(k, auth)
 	} else {
 		k := new(encryptedKeyJSONV3)
 		if err := json.Unmarshal(keyjson, k); err != nil {
@@ -351,6 +371,15 @@ func getKDFKey(cryptoJSON CryptoJSON, auth string) ([]byte, error) {
 		}
 		key := pbkdf2.Key(authArray, salt, c, dkLen, sha256.New)
 		return key, nil
+
+	} else if cryptoJSON.KDF == keyHeaderKDFArgon2id {
+		t := ensureInt(cryptoJSON.KDFParams["t"])
+		m := ensureInt(cryptoJSON.KDFParams["m"])
+		p := ensureInt(cryptoJSON.KDFParams["p"])
+		if t <= 0 || m <= 0 || p <= 0 || p > 255 {
+			return nil, fmt.Errorf("invalid argon2id parameters: t %d, m %d, p %d", t, m, p)
+		}
+		return argon2.IDKey(authArray, salt, uint32(t), uint32(m), uint8(p), uint32(dkLen)), nil
 	}
 
 	return nil, fmt.Errorf("unsupported KDF: %s", cryptoJSON.KDF)
@@ -17,6 +17,7 @@
 package keystore
 
 import (
+	"crypto/rand"
 	"io/ioutil"
 	"testing"
 
@@ -58,3 +59,59 @@ func TestKeyEncryptDecrypt(t *testing.T) {
 		}
 	}
 }
+
+// veryLightArgon2Config is the cheapest argon2id configuration, for tests only.
+var veryLightArgon2Config = Argon2Config{Time: 1, Memory: 8, Threads: 1, Cipher: CipherAES256GCM}
+
+// Tests that version 4 keys can be decrypted and encrypted in multiple rounds,
+// with all the supported ciphers.
+func TestKeyEncryptDecryptV4(t *testing.T) {
+	keyjson, err := ioutil.ReadFile("testdata/very-light-scrypt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := DecryptKey(keyjson, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cipher := range []string{CipherAES128CTR, CipherAES256GCM} {
+		config := veryLightArgon2Config
+		config.Cipher = cipher
+
+		password := ""
+		for i := 0; i < 3; i++ {
+			if keyjson, err = EncryptKeyV4(key, password, config); err != nil {
+				t.Fatalf("%s, test %d: failed to encrypt key: %v", cipher, i, err)
+			}
+			if _, err := DecryptKey(keyjson, password+"bad"); err != ErrDecrypt {
+				t.Errorf("%s, test %d: bad password error mismatch: have %v, want %v", cipher, i, err, ErrDecrypt)
+			}
+			decrypted, err := DecryptKey(keyjson, password)
+			if err != nil {
+				t.Fatalf("%s, test %d: json key failed to decrypt: %v", cipher, i, err)
+			}
+			if decrypted.Address != key.Address || decrypted.Id != key.Id {
+				t.Errorf("%s, test %d: key mismatch: have %x, want %x", cipher, i, decrypted.Address, key.Address)
+			}
+			password += "new data appended"
+		}
+	}
+}
+
+// Tests that unknown ciphers and invalid argon2id parameters are rejected.
+func TestKeyEncryptV4Invalid(t *testing.T) {
+	key, err := newKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := veryLightArgon2Config
+	config.Cipher = "aes-128-cbc"
+	if _, err := EncryptKeyV4(key, "", config); err == nil {
+		t.Errorf("encrypted key with unsupported cipher")
+	}
+	config = veryLightArgon2Config
+	config.Threads = 0
+	if _, err := EncryptKeyV4(key, "", config); err == nil {
+		t.Errorf("encrypted key with zero argon2id threads")
+	}
+}
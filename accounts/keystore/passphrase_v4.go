@@ -0,0 +1,225 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+/*
+
+Version 4 key files use the same JSON layout as version 3, but derive the
+encryption key with argon2id instead of scrypt and allow the cipher to be chosen:
+
+ - aes-128-ctr: as in version 3, the first half of the derived key encrypts and
+   the second half authenticates the ciphertext with a keccak256 MAC.
+ - aes-256-gcm: the full derived key is used with AES-GCM, which authenticates
+   the ciphertext on its own; the MAC field is left empty.
+
+*/
+
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	versionV4 = 4
+
+	keyHeaderKDFArgon2id = "argon2id"
+
+	// CipherAES128CTR is the version 3 compatible cipher, authenticated with a
+	// separate keccak256 MAC.
+	CipherAES128CTR = "aes-128-ctr"
+
+	// CipherAES256GCM is an authenticated cipher using the full derived key.
+	CipherAES256GCM = "aes-256-gcm"
+
+	argon2DKLen = 32
+)
+
+// Argon2Config contains the key derivation and encryption parameters of
+// version 4 key files.
+type Argon2Config struct {
+	Time    uint32 // Number of passes over the memory
+	Memory  uint32 // Memory to use, in KiB
+	Threads uint8  // Number of parallel lanes
+	Cipher  string // Cipher encrypting the private key
+}
+
+var (
+	// StandardArgon2Config uses 256MB memory and takes approximately 1s CPU
+	// time on a modern processor.
+	StandardArgon2Config = Argon2Config{Time: 4, Memory: 256 * 1024, Threads: 4, Cipher: CipherAES256GCM}
+
+	// LightArgon2Config uses 4MB memory and takes approximately 10ms CPU time
+	// on a modern processor.
+	LightArgon2Config = Argon2Config{Time: 1, Memory: 4 * 1024, Threads: 1, Cipher: CipherAES256GCM}
+)
+
+// keyStorePassphraseV4 is a passphrase protected key store writing version 4
+// key files. It reads all key file versions.
+type keyStorePassphraseV4 struct {
+	keyStorePassphrase
+	config Argon2Config
+}
+
+func (ks keyStorePassphraseV4) StoreKey(filename string, key *Key, auth string) error {
+	keyjson, err := EncryptKeyV4(key, auth, ks.config)
+	if err != nil {
+		return err
+	}
+	return ks.storeKeyJSON(filename, key.Address, keyjson, auth)
+}
+
+// EncryptDataV4 encrypts the data given as 'data' with the password 'auth',
+// deriving the encryption key with argon2id.
+func EncryptDataV4(data, auth []byte, config Argon2Config) (CryptoJSON, error) {
+	if config.Time == 0 || config.Memory == 0 || config.Threads == 0 {
+		return CryptoJSON{}, fmt.Errorf("invalid argon2id parameters: time %d, memory %d, threads %d", config.Time, config.Memory, config.Threads)
+	}
+	salt := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		panic("reading from crypto/rand failed: " + err.Error())
+	}
+	derivedKey := argon2.IDKey(auth, salt, config.Time, config.Memory, config.Threads, argon2DKLen)
+
+	var iv, cipherText, mac []byte
+	switch config.Cipher {
+	case CipherAES128CTR:
+		iv = make([]byte, aes.BlockSize)
+		if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+			panic("reading from crypto/rand failed: " + err.Error())
+		}
+		var err error
+		if cipherText, err = aesCTRXOR(derivedKey[:16], data, iv); err != nil {
+			return CryptoJSON{}, err
+		}
+		mac = crypto.Keccak256(derivedKey[16:32], cipherText)
+
+	case CipherAES256GCM:
+		aead, err := newAESGCM(derivedKey)
+		if err != nil {
+			return CryptoJSON{}, err
+		}
+		iv = make([]byte, aead.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+			panic("reading from crypto/rand failed: " + err.Error())
+		}
+		cipherText = aead.Seal(nil, iv, data, nil)
+
+	default:
+		return CryptoJSON{}, fmt.Errorf("cipher not supported: %v", config.Cipher)
+	}
+	argon2ParamsJSON := make(map[string]interface{}, 5)
+	argon2ParamsJSON["t"] = int(config.Time)
+	argon2ParamsJSON["m"] = int(config.Memory)
+	argon2ParamsJSON["p"] = int(config.Threads)
+	argon2ParamsJSON["dklen"] = argon2DKLen
+	argon2ParamsJSON["salt"] = hex.EncodeToString(salt)
+
+	return CryptoJSON{
+		Cipher:       config.Cipher,
+		CipherText:   hex.EncodeToString(cipherText),
+		CipherParams: cipherparamsJSON{IV: hex.EncodeToString(iv)},
+		KDF:          keyHeaderKDFArgon2id,
+		KDFParams:    argon2ParamsJSON,
+		MAC:          hex.EncodeToString(mac),
+	}, nil
+}
+
+// DecryptDataV4 decrypts data encrypted with EncryptDataV4. For the aes-128-ctr
+// cipher, version 3 key derivation functions are accepted too.
+func DecryptDataV4(cryptoJson CryptoJSON, auth string) ([]byte, error) {
+	if cryptoJson.Cipher == CipherAES128CTR {
+		return DecryptDataV3(cryptoJson, auth)
+	}
+	if cryptoJson.Cipher != CipherAES256GCM {
+		return nil, fmt.Errorf("cipher not supported: %v", cryptoJson.Cipher)
+	}
+	iv, err := hex.DecodeString(cryptoJson.CipherParams.IV)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := hex.DecodeString(cryptoJson.CipherText)
+	if err != nil {
+		return nil, err
+	}
+	derivedKey, err := getKDFKey(cryptoJson, auth)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAESGCM(derivedKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length %d", len(iv))
+	}
+	plainText, err := aead.Open(nil, iv, cipherText, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plainText, nil
+}
+
+// EncryptKeyV4 encrypts a key using the specified argon2id parameters into a
+// version 4 json blob that can be decrypted later on.
+func EncryptKeyV4(key *Key, auth string, config Argon2Config) ([]byte, error) {
+	keyBytes := math.PaddedBigBytes(key.PrivateKey.D, 32)
+	cryptoStruct, err := EncryptDataV4(keyBytes, []byte(auth), config)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encryptedKeyJSONV3{
+		hex.EncodeToString(key.Address[:]),
+		cryptoStruct,
+		key.Id.String(),
+		versionV4,
+	})
+}
+
+// decryptKeyV4 decrypts the private key of a version 4 key file.
+func decryptKeyV4(keyProtected *encryptedKeyJSONV3, auth string) (keyBytes []byte, keyId []byte, err error) {
+	if keyProtected.Version != versionV4 {
+		return nil, nil, fmt.Errorf("version not supported: %v", keyProtected.Version)
+	}
+	keyUUID, err := uuid.Parse(keyProtected.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+	plainText, err := DecryptDataV4(keyProtected.Crypto, auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plainText, keyUUID[:], nil
+}
+
+// newAESGCM creates an AES-GCM AEAD cipher with the given key.
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package kms
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// requestTimeout is the maximum time a single request to the key manager may
+// take before it is aborted.
+const requestTimeout = 10 * time.Second
+
+// Backend is an accounts.Backend exposing every key of a KeyManager as a
+// single-account wallet.
+type Backend struct {
+	scheme string     // Protocol scheme prefixing account and wallet URLs
+	km     KeyManager // Key manager holding the private keys
+
+	wallets    []accounts.Wallet       // List of wallets currently tracked, sorted by URL
+	updateFeed event.Feed              // Event feed to notify wallet additions/removals
+	updateSubs event.SubscriptionScope // Subscription scope tracking current live listeners
+	byID       map[string]*wallet      // Wallets indexed by key identifier
+	lock       sync.RWMutex            // Protects the wallet list and index
+}
+
+// NewBackend creates an account backend on top of the given key manager, using
+// scheme as the URL prefix of the exposed accounts. The list of keys is loaded
+// eagerly, so an unreachable key manager is reported immediately.
+func NewBackend(scheme string, km KeyManager) (*Backend, error) {
+	b := &Backend{
+		scheme: scheme,
+		km:     km,
+		byID:   make(map[string]*wallet),
+	}
+	if err := b.Refresh(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Wallets implements accounts.Backend, returning all the keys of the key manager
+// that were found during the last refresh.
+func (b *Backend) Wallets() []accounts.Wallet {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	cpy := make([]accounts.Wallet, len(b.wallets))
+	copy(cpy, b.wallets)
+	return cpy
+}
+
+// Subscribe implements accounts.Backend, creating an async subscription to
+// receive notifications on the addition or removal of key manager wallets.
+func (b *Backend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return b.updateSubs.Track(b.updateFeed.Subscribe(sink))
+}
+
+// Refresh reloads the list of keys from the key manager, firing wallet events
+// for every key that appeared or disappeared since the last refresh.
+func (b *Backend) Refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	ids, err := b.km.Keys(ctx)
+	if err != nil {
+		return err
+	}
+	// Resolve the addresses of any new keys before touching the wallet list
+	b.lock.RLock()
+	fresh := make(map[string]*wallet, len(ids))
+	for _, id := range ids {
+		if w, ok := b.byID[id]; ok {
+			fresh[id] = w
+			continue
+		}
+		pub, err := b.km.PublicKey(ctx, id)
+		if err != nil {
+			b.lock.RUnlock()
+			return err
+		}
+		url := accounts.URL{Scheme: b.scheme, Path: id}
+		fresh[id] = &wallet{
+			backend: b,
+			id:      id,
+			pubkey:  pub,
+			account: accounts.Account{Address: crypto.PubkeyToAddress(*pub), URL: url},
+		}
+	}
+	b.lock.RUnlock()
+
+	// Swap in the new wallet list and gather the events to fire
+	var events []accounts.WalletEvent
+
+	b.lock.Lock()
+	for id, w := range b.byID {
+		if _, ok := fresh[id]; !ok {
+			events = append(events, accounts.WalletEvent{Wallet: w, Kind: accounts.WalletDropped})
+		}
+	}
+	wallets := make([]accounts.Wallet, 0, len(fresh))
+	for id, w := range fresh {
+		if _, ok := b.byID[id]; !ok {
+			events = append(events, accounts.WalletEvent{Wallet: w, Kind: accounts.WalletArrived})
+		}
+		wallets = append(wallets, w)
+	}
+	sort.Slice(wallets, func(i, j int) bool {
+		return wallets[i].URL().Cmp(wallets[j].URL()) < 0
+	})
+	b.byID, b.wallets = fresh, wallets
+	b.lock.Unlock()
+
+	for _, event := range events {
+		b.updateFeed.Send(event)
+	}
+	log.Debug("Refreshed key manager wallets", "scheme", b.scheme, "keys", len(wallets))
+	return nil
+}
+
+// Close terminates all the live wallet event subscriptions and releases the key
+// manager if it holds any resources, e.g. a session to a hardware module.
+func (b *Backend) Close() error {
+	b.updateSubs.Close()
+	if closer, ok := b.km.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
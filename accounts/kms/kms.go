@@ -0,0 +1,55 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package kms implements an accounts.Backend for secp256k1 keys held by a
+// remote key management service or hardware security module.
+//
+// The private keys never leave the key manager. The backend only asks it for
+// public keys and raw digest signatures, and takes care of turning those into
+// Ethereum signatures: the S value is normalized into the lower half of the
+// curve order and the recovery id is derived by public key recovery, so the
+// produced signatures are accepted by types.Signer and clef alike.
+package kms
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+)
+
+// Scheme is the URI prefix for keys held by a generic remote key manager.
+const Scheme = "kms"
+
+// ErrKeyNotFound is returned if a key manager does not know the requested key.
+var ErrKeyNotFound = errors.New("key not found")
+
+// KeyManager is the interface a remote key management service or hardware
+// security module needs to implement to be used as an account backend.
+//
+// Implementations must only expose secp256k1 keys and must be safe for
+// concurrent use.
+type KeyManager interface {
+	// Keys returns the identifiers of all the signing keys available.
+	Keys(ctx context.Context) ([]string, error)
+
+	// PublicKey retrieves the public half of the key with the given identifier.
+	PublicKey(ctx context.Context, id string) (*ecdsa.PublicKey, error)
+
+	// Sign signs the 32 byte digest with the key with the given identifier. The
+	// returned signature may either be ASN.1 DER encoded or the 64 byte R || S
+	// concatenation, and its S value does not need to be canonical.
+	Sign(ctx context.Context, id string, digest []byte) ([]byte, error)
+}
@@ -0,0 +1,220 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package kms
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tests that signatures in all the encodings key managers may return are
+// normalized into the exact signature a local key would produce.
+func TestNormalizeSignature(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	for i := 0; i < 16; i++ {
+		digest := crypto.Keccak256([]byte{byte(i)})
+		want, err := crypto.Sign(digest, key)
+		if err != nil {
+			t.Fatalf("failed to sign digest: %v", err)
+		}
+		r, s := new(big.Int).SetBytes(want[:32]), new(big.Int).SetBytes(want[32:64])
+		highS := new(big.Int).Sub(secp256k1N, s)
+
+		der, _ := asn1.Marshal(ecdsaSignature{R: r, S: s})
+		derHigh, _ := asn1.Marshal(ecdsaSignature{R: r, S: highS})
+		rawHigh := append(common.LeftPadBytes(r.Bytes(), 32), common.LeftPadBytes(highS.Bytes(), 32)...)
+
+		for name, sig := range map[string][]byte{"raw": want[:64], "raw-high": rawHigh, "der": der, "der-high": derHigh} {
+			have, err := NormalizeSignature(digest, sig, &key.PublicKey)
+			if err != nil {
+				t.Fatalf("digest %d, %s: failed to normalize: %v", i, name, err)
+			}
+			if !bytes.Equal(have, want) {
+				t.Errorf("digest %d, %s: signature mismatch: have %x, want %x", i, name, have, want)
+			}
+		}
+	}
+}
+
+// Tests that invalid or foreign signatures are rejected.
+func TestNormalizeSignatureInvalid(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	digest := crypto.Keccak256([]byte("digest"))
+
+	sig, _ := crypto.Sign(digest, other)
+	if _, err := NormalizeSignature(digest, sig[:64], &key.PublicKey); err != ErrSignatureMismatch {
+		t.Errorf("foreign signature: error mismatch: have %v, want %v", err, ErrSignatureMismatch)
+	}
+	if _, err := NormalizeSignature(digest, []byte{0x30, 0x01}, &key.PublicKey); err != ErrInvalidSignature {
+		t.Errorf("garbage signature: error mismatch: have %v, want %v", err, ErrInvalidSignature)
+	}
+	zero := make([]byte, 64)
+	if _, err := NormalizeSignature(digest, zero, &key.PublicKey); err != ErrInvalidSignature {
+		t.Errorf("zero signature: error mismatch: have %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+// Tests that public keys are decoded from all the supported encodings.
+func TestParsePublicKey(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	point := crypto.FromECDSAPub(&key.PublicKey)
+
+	octets, _ := asn1.Marshal(point)
+	var info subjectPublicKeyInfo
+	info.Algorithm.Algorithm = oidPublicKeyECDSA
+	info.Algorithm.Parameters = oidSecp256k1
+	info.PublicKey = asn1.BitString{Bytes: point, BitLength: len(point) * 8}
+	spki, _ := asn1.Marshal(info)
+
+	for name, data := range map[string][]byte{
+		"uncompressed": point,
+		"compressed":   crypto.CompressPubkey(&key.PublicKey),
+		"octet-string": octets,
+		"spki":         spki,
+	} {
+		pub, err := ParsePublicKey(data)
+		if err != nil {
+			t.Fatalf("%s: failed to parse public key: %v", name, err)
+		}
+		if !bytes.Equal(crypto.FromECDSAPub(pub), point) {
+			t.Errorf("%s: public key mismatch", name)
+		}
+	}
+	info.Algorithm.Parameters = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7} // P-256
+	spki, _ = asn1.Marshal(info)
+	if _, err := ParsePublicKey(spki); err == nil {
+		t.Errorf("accepted public key on foreign curve")
+	}
+}
+
+// Tests that transactions and texts signed through the backend are accepted
+// by the regular signature verification.
+func TestBackendSigning(t *testing.T) {
+	km := NewMockKeyManager()
+	pub, _ := km.GenerateKey("validator")
+
+	backend, err := NewBackend(Scheme, km)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	wallets := backend.Wallets()
+	if len(wallets) != 1 {
+		t.Fatalf("wallet count mismatch: have %d, want 1", len(wallets))
+	}
+	wallet := wallets[0]
+	account := accounts.Account{Address: crypto.PubkeyToAddress(*pub)}
+	if !wallet.Contains(account) {
+		t.Fatalf("wallet does not contain key account")
+	}
+	if url := wallet.URL().String(); url != "kms://validator" {
+		t.Errorf("wallet url mismatch: have %s, want kms://validator", url)
+	}
+	// Sign a batch of transactions so both low and high S mock outputs are hit
+	chainID := big.NewInt(1337)
+	signer := types.LatestSignerForChainID(chainID)
+	for nonce := uint64(0); nonce < 8; nonce++ {
+		tx := types.NewTx(&types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     nonce,
+			GasTipCap: big.NewInt(1),
+			GasFeeCap: big.NewInt(10),
+			Gas:       21000,
+			To:        &common.Address{0xaa},
+			Value:     big.NewInt(1),
+		})
+		signed, err := wallet.SignTx(account, tx, chainID)
+		if err != nil {
+			t.Fatalf("nonce %d: failed to sign transaction: %v", nonce, err)
+		}
+		sender, err := types.Sender(signer, signed)
+		if err != nil {
+			t.Fatalf("nonce %d: failed to recover sender: %v", nonce, err)
+		}
+		if sender != account.Address {
+			t.Errorf("nonce %d: sender mismatch: have %x, want %x", nonce, sender, account.Address)
+		}
+	}
+	text := []byte("hello kms")
+	sig, err := wallet.SignText(account, text)
+	if err != nil {
+		t.Fatalf("failed to sign text: %v", err)
+	}
+	recovered, err := crypto.SigToPub(accounts.TextHash(text), sig)
+	if err != nil {
+		t.Fatalf("failed to recover text signer: %v", err)
+	}
+	if crypto.PubkeyToAddress(*recovered) != account.Address {
+		t.Errorf("text signer mismatch")
+	}
+	// Signing with an unknown account must be refused
+	if _, err := wallet.SignText(accounts.Account{Address: common.Address{0x01}}, text); err != accounts.ErrUnknownAccount {
+		t.Errorf("foreign account: error mismatch: have %v, want %v", err, accounts.ErrUnknownAccount)
+	}
+}
+
+// Tests that refreshing the backend fires wallet arrival and departure events.
+func TestBackendRefresh(t *testing.T) {
+	km := NewMockKeyManager()
+	km.GenerateKey("a")
+
+	backend, err := NewBackend(Scheme, km)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	events := make(chan accounts.WalletEvent, 4)
+	sub := backend.Subscribe(events)
+	defer sub.Unsubscribe()
+
+	km.GenerateKey("b")
+	km.DeleteKey("a")
+	if err := backend.Refresh(); err != nil {
+		t.Fatalf("failed to refresh backend: %v", err)
+	}
+	arrived, dropped := 0, 0
+	for i := 0; i < 2; i++ {
+		select {
+		case ev := <-events:
+			switch {
+			case ev.Kind == accounts.WalletArrived && ev.Wallet.URL().Path == "b":
+				arrived++
+			case ev.Kind == accounts.WalletDropped && ev.Wallet.URL().Path == "a":
+				dropped++
+			default:
+				t.Errorf("unexpected event: %v %v", ev.Kind, ev.Wallet.URL())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for wallet events")
+		}
+	}
+	if arrived != 1 || dropped != 1 {
+		t.Errorf("event mismatch: arrived %d, dropped %d", arrived, dropped)
+	}
+	if wallets := backend.Wallets(); len(wallets) != 1 || wallets[0].URL().Path != "b" {
+		t.Errorf("wallet list mismatch after refresh")
+	}
+}
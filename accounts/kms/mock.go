@@ -0,0 +1,114 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package kms
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// MockKeyManager is an in-memory KeyManager for tests and local development.
+//
+// To exercise the same code paths as real key management services, it returns
+// DER encoded signatures and does not canonicalize S: odd digests are signed
+// with the high-S form of the signature.
+type MockKeyManager struct {
+	keys map[string]*ecdsa.PrivateKey
+	lock sync.RWMutex
+}
+
+// NewMockKeyManager creates an empty in-memory key manager.
+func NewMockKeyManager() *MockKeyManager {
+	return &MockKeyManager{keys: make(map[string]*ecdsa.PrivateKey)}
+}
+
+// GenerateKey creates a new random key with the given identifier.
+func (m *MockKeyManager) GenerateKey(id string) (*ecdsa.PublicKey, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	m.ImportKey(id, key)
+	return &key.PublicKey, nil
+}
+
+// ImportKey stores an existing private key under the given identifier.
+func (m *MockKeyManager) ImportKey(id string, key *ecdsa.PrivateKey) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.keys[id] = key
+}
+
+// DeleteKey removes the key with the given identifier.
+func (m *MockKeyManager) DeleteKey(id string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.keys, id)
+}
+
+// Keys implements KeyManager, returning the identifiers of all stored keys.
+func (m *MockKeyManager) Keys(ctx context.Context) ([]string, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	ids := make([]string, 0, len(m.keys))
+	for id := range m.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// PublicKey implements KeyManager, returning the public half of a stored key.
+func (m *MockKeyManager) PublicKey(ctx context.Context, id string) (*ecdsa.PublicKey, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	key, ok := m.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return &key.PublicKey, nil
+}
+
+// Sign implements KeyManager, returning a DER encoded signature of the digest.
+func (m *MockKeyManager) Sign(ctx context.Context, id string, digest []byte) ([]byte, error) {
+	m.lock.RLock()
+	key, ok := m.keys[id]
+	m.lock.RUnlock()
+
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	sig, err := crypto.Sign(digest, key)
+	if err != nil {
+		return nil, err
+	}
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64])
+	if digest[len(digest)-1]&1 == 1 {
+		s.Sub(secp256k1N, s)
+	}
+	return asn1.Marshal(ecdsaSignature{R: r, S: s})
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package kms
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	secp256k1N     = crypto.S256().Params().N
+	secp256k1halfN = new(big.Int).Rsh(secp256k1N, 1)
+
+	// oidSecp256k1 is the ASN.1 object identifier of the secp256k1 curve.
+	oidSecp256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+
+	// oidPublicKeyECDSA is the ASN.1 object identifier of elliptic curve keys.
+	oidPublicKeyECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+)
+
+var (
+	// ErrInvalidSignature is returned if a key manager produced a signature that
+	// cannot be decoded or is outside the valid range of the curve.
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrSignatureMismatch is returned if a signature does not recover to the
+	// public key it was supposedly created with.
+	ErrSignatureMismatch = errors.New("signature does not match public key")
+)
+
+// ecdsaSignature is the ASN.1 structure of a DER encoded ECDSA signature.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// subjectPublicKeyInfo is the ASN.1 structure of a DER encoded public key as
+// returned by most cloud key management services.
+type subjectPublicKeyInfo struct {
+	Algorithm struct {
+		Algorithm  asn1.ObjectIdentifier
+		Parameters asn1.ObjectIdentifier
+	}
+	PublicKey asn1.BitString
+}
+
+// NormalizeSignature converts a signature produced by a key manager into the
+// 65 byte [R || S || V] format used by Ethereum, where V is 0 or 1.
+//
+// The input may be ASN.1 DER encoded or the raw 64 byte R || S concatenation.
+// If S is in the upper half of the curve order it is replaced by N - S, and
+// the recovery id is found by recovering the public key from the signature
+// and comparing it against the expected one.
+func NormalizeSignature(digest []byte, sig []byte, pub *ecdsa.PublicKey) ([]byte, error) {
+	if len(digest) != 32 {
+		return nil, fmt.Errorf("invalid digest length %d", len(digest))
+	}
+	r, s, err := parseSignature(sig)
+	if err != nil {
+		return nil, err
+	}
+	if r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(secp256k1N) >= 0 || s.Cmp(secp256k1N) >= 0 {
+		return nil, ErrInvalidSignature
+	}
+	if s.Cmp(secp256k1halfN) > 0 {
+		s = new(big.Int).Sub(secp256k1N, s)
+	}
+	out := make([]byte, crypto.SignatureLength)
+	math.ReadBits(r, out[:32])
+	math.ReadBits(s, out[32:64])
+
+	want := crypto.FromECDSAPub(pub)
+	for v := byte(0); v < 2; v++ {
+		out[64] = v
+		have, err := crypto.Ecrecover(digest, out)
+		if err == nil && bytes.Equal(have, want) {
+			return out, nil
+		}
+	}
+	return nil, ErrSignatureMismatch
+}
+
+// parseSignature decodes the R and S values out of a DER encoded or raw
+// concatenated signature.
+func parseSignature(sig []byte) (*big.Int, *big.Int, error) {
+	if len(sig) == 64 {
+		return new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]), nil
+	}
+	var decoded ecdsaSignature
+	rest, err := asn1.Unmarshal(sig, &decoded)
+	if err != nil || len(rest) != 0 || decoded.R == nil || decoded.S == nil {
+		return nil, nil, ErrInvalidSignature
+	}
+	return decoded.R, decoded.S, nil
+}
+
+// ParsePublicKey decodes a secp256k1 public key in any of the encodings key
+// managers commonly return: a 65 byte uncompressed point, a 33 byte compressed
+// point, a DER octet string wrapping a point (PKCS#11 CKA_EC_POINT) or a DER
+// encoded SubjectPublicKeyInfo structure.
+func ParsePublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	switch {
+	case len(data) == 65 && data[0] == 0x04:
+		return crypto.UnmarshalPubkey(data)
+	case len(data) == 33 && (data[0] == 0x02 || data[0] == 0x03):
+		return crypto.DecompressPubkey(data)
+	}
+	// Not a bare point, try a DER encoded octet string
+	var point []byte
+	if rest, err := asn1.Unmarshal(data, &point); err == nil && len(rest) == 0 {
+		return ParsePublicKey(point)
+	}
+	// Not an octet string either, try a full public key info structure
+	var info subjectPublicKeyInfo
+	if rest, err := asn1.Unmarshal(data, &info); err != nil || len(rest) != 0 {
+		return nil, errors.New("unrecognized public key encoding")
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPublicKeyECDSA) {
+		return nil, fmt.Errorf("unsupported public key algorithm %v", info.Algorithm.Algorithm)
+	}
+	if !info.Algorithm.Parameters.Equal(oidSecp256k1) {
+		return nil, fmt.Errorf("unsupported elliptic curve %v", info.Algorithm.Parameters)
+	}
+	return crypto.UnmarshalPubkey(info.PublicKey.RightAlign())
+}
@@ -0,0 +1,137 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package kms
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// wallet implements the accounts.Wallet interface for a single key held by a
+// remote key manager.
+type wallet struct {
+	backend *Backend         // Backend owning the key manager connection
+	id      string           // Identifier of the key within the key manager
+	pubkey  *ecdsa.PublicKey // Public key used to derive recovery ids
+	account accounts.Account // Single account backed by the key
+}
+
+// URL implements accounts.Wallet, returning the URL of the key within the
+// key manager.
+func (w *wallet) URL() accounts.URL {
+	return w.account.URL
+}
+
+// Status implements accounts.Wallet. Keys held by a key manager are always
+// ready for signing, access control is up to the key manager itself.
+func (w *wallet) Status() (string, error) {
+	return "Online", nil
+}
+
+// Open implements accounts.Wallet, but is a noop since the connection to the
+// key manager is established by the backend.
+func (w *wallet) Open(passphrase string) error { return nil }
+
+// Close implements accounts.Wallet, but is a noop since the connection to the
+// key manager is shared by all wallets of the backend.
+func (w *wallet) Close() error { return nil }
+
+// Accounts implements accounts.Wallet, returning the single account backed by
+// the key manager key.
+func (w *wallet) Accounts() []accounts.Account {
+	return []accounts.Account{w.account}
+}
+
+// Contains implements accounts.Wallet, returning whether a particular account is
+// or is not wrapped by this wallet instance.
+func (w *wallet) Contains(account accounts.Account) bool {
+	return account.Address == w.account.Address && (account.URL == (accounts.URL{}) || account.URL == w.account.URL)
+}
+
+// Derive implements accounts.Wallet, but is not supported since key managers
+// hold individual keys, not hierarchical deterministic seeds.
+func (w *wallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, accounts.ErrNotSupported
+}
+
+// SelfDerive implements accounts.Wallet, but is a noop since key managers hold
+// individual keys, not hierarchical deterministic seeds.
+func (w *wallet) SelfDerive(bases []accounts.DerivationPath, chain ethereum.ChainStateReader) {
+}
+
+// signHash requests a signature over the hash from the key manager and converts
+// it into the canonical [R || S || V] format.
+func (w *wallet) signHash(account accounts.Account, hash []byte) ([]byte, error) {
+	if !w.Contains(account) {
+		return nil, accounts.ErrUnknownAccount
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	sig, err := w.backend.km.Sign(ctx, w.id, hash)
+	if err != nil {
+		return nil, err
+	}
+	return NormalizeSignature(hash, sig, w.pubkey)
+}
+
+// SignData signs keccak256(data). The mimetype parameter describes the type of data being signed.
+func (w *wallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	return w.signHash(account, crypto.Keccak256(data))
+}
+
+// SignDataWithPassphrase implements accounts.Wallet. The passphrase is ignored
+// since authentication is up to the key manager.
+func (w *wallet) SignDataWithPassphrase(account accounts.Account, passphrase, mimeType string, data []byte) ([]byte, error) {
+	return w.SignData(account, mimeType, data)
+}
+
+// SignText implements accounts.Wallet, attempting to sign the hash of
+// the given text with the given account.
+func (w *wallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	return w.signHash(account, accounts.TextHash(text))
+}
+
+// SignTextWithPassphrase implements accounts.Wallet. The passphrase is ignored
+// since authentication is up to the key manager.
+func (w *wallet) SignTextWithPassphrase(account accounts.Account, passphrase string, text []byte) ([]byte, error) {
+	return w.SignText(account, text)
+}
+
+// SignTx implements accounts.Wallet, signing the transaction with the remote key.
+// Depending on the presence of the chain ID, it is signed with EIP-2718 or
+// homestead semantics.
+func (w *wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	signer := types.LatestSignerForChainID(chainID)
+	sig, err := w.signHash(account, signer.Hash(tx).Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSignature(signer, sig)
+}
+
+// SignTxWithPassphrase implements accounts.Wallet. The passphrase is ignored
+// since authentication is up to the key manager.
+func (w *wallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return w.SignTx(account, tx, chainID)
+}
@@ -17,6 +17,7 @@
 package accounts
 
 import (
+	"io"
 	"reflect"
 	"sort"
 	"sync"
@@ -156,8 +157,8 @@ func (am *Manager) update() {
 			am.lock.Unlock()
 			close(event.processed)
 		case errc := <-am.quit:
-			// Manager terminating, return
-			errc <- nil
+			// Manager terminating, release the backends holding resources
+			errc <- am.closeBackends()
 			// Signals event emitters the loop is not receiving values
 			// to prevent them from getting stuck.
 			close(am.term)
@@ -166,6 +167,25 @@ func (am *Manager) update() {
 	}
 }
 
+// closeBackends closes all backends which need to release resources, e.g. open
+// sessions to a hardware security module, returning the first error.
+func (am *Manager) closeBackends() error {
+	am.lock.RLock()
+	defer am.lock.RUnlock()
+
+	var err error
+	for _, backends := range am.backends {
+		for _, backend := range backends {
+			if closer, ok := backend.(io.Closer); ok {
+				if cerr := closer.Close(); cerr != nil && err == nil {
+					err = cerr
+				}
+			}
+		}
+	}
+	return err
+}
+
 // Backends retrieves the backend(s) with the given type from the account manager.
 func (am *Manager) Backends(kind reflect.Type) []Backend {
 	am.lock.RLock()
@@ -0,0 +1,215 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pkcs11 implements an accounts.Backend for secp256k1 keys stored in a
+// hardware security module reachable through a PKCS#11 module.
+//
+// Every EC private key on the selected token whose curve is secp256k1 and that
+// has a matching public key object (same CKA_ID) is exposed as an account. The
+// private keys never leave the token; signing is done with CKM_ECDSA and the
+// results are normalized by the kms package.
+//
+// The native binding is only compiled with the "pkcs11" build tag and cgo. It
+// can be tested against SoftHSM:
+//
+//	softhsm2-util --init-token --free --label geth --pin 1234 --so-pin 1234
+//	pkcs11-tool --module /usr/lib/softhsm/libsofthsm2.so --login --pin 1234 \
+//	  --token-label geth --keypairgen --key-type EC:secp256k1 --id 01
+package pkcs11
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/kms"
+)
+
+// Scheme is the URI prefix for keys stored in PKCS#11 tokens.
+const Scheme = "pkcs11"
+
+// PKCS#11 constants needed to locate and use secp256k1 keys.
+const (
+	ckoPublicKey  = 0x02
+	ckoPrivateKey = 0x03
+
+	ckaClass    = 0x000
+	ckaID       = 0x102
+	ckaECParams = 0x180
+	ckaECPoint  = 0x181
+
+	ckmECDSA = 0x1041
+)
+
+// secp256k1Params is the DER encoded object identifier of the secp256k1 curve,
+// as stored in the CKA_EC_PARAMS attribute of the token keys.
+var secp256k1Params = []byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x0a}
+
+// errNotCompiled is returned if the native PKCS#11 binding was not compiled in.
+var errNotCompiled = errors.New("pkcs11 support not compiled in (build with cgo and the pkcs11 tag)")
+
+// Config contains the settings needed to access a token through PKCS#11.
+type Config struct {
+	Module string // Path to the PKCS#11 shared library of the HSM vendor
+	Token  string // Label of the token holding the signing keys
+	PIN    string // User PIN to log into the token
+}
+
+// objectHandle is a PKCS#11 object handle within a session.
+type objectHandle uint
+
+// session is the subset of a logged in PKCS#11 session needed for signing.
+// Implementations do not need to be safe for concurrent use.
+type session interface {
+	// findObjects returns the handles of all objects of the given class. If id is
+	// non-nil, only objects with a matching CKA_ID are returned.
+	findObjects(class uint, id []byte) ([]objectHandle, error)
+
+	// attribute retrieves the value of a single attribute of an object.
+	attribute(obj objectHandle, typ uint) ([]byte, error)
+
+	// sign creates a signature over the data with the given key and mechanism.
+	sign(key objectHandle, mechanism uint, data []byte) ([]byte, error)
+
+	// close logs out of the token and releases the session.
+	close() error
+}
+
+// NewBackend opens a session to the configured token and creates an account
+// backend exposing its secp256k1 keys.
+func NewBackend(config Config) (*kms.Backend, error) {
+	sess, err := openSession(config)
+	if err != nil {
+		return nil, err
+	}
+	backend, err := kms.NewBackend(Scheme, newKeyManager(sess))
+	if err != nil {
+		sess.close()
+		return nil, err
+	}
+	return backend, nil
+}
+
+// keyManager implements kms.KeyManager on top of a PKCS#11 session. Keys are
+// identified by the hex encoding of their CKA_ID attribute.
+type keyManager struct {
+	sess session
+	lock sync.Mutex // PKCS#11 sessions must not be used concurrently
+}
+
+// errClosed is returned if the key manager is used after its session was closed.
+var errClosed = errors.New("pkcs11 session closed")
+
+// newKeyManager wraps a logged in PKCS#11 session into a key manager.
+func newKeyManager(sess session) *keyManager {
+	return &keyManager{sess: sess}
+}
+
+// Keys implements kms.KeyManager, returning the identifiers of all secp256k1
+// private keys on the token.
+func (km *keyManager) Keys(ctx context.Context) ([]string, error) {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+
+	if km.sess == nil {
+		return nil, errClosed
+	}
+	handles, err := km.sess.findObjects(ckoPrivateKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, handle := range handles {
+		// Skip anything that's not on the secp256k1 curve
+		params, err := km.sess.attribute(handle, ckaECParams)
+		if err != nil || !bytes.Equal(params, secp256k1Params) {
+			continue
+		}
+		// Skip keys without an identifier, we can't look up their public half
+		id, err := km.sess.attribute(handle, ckaID)
+		if err != nil || len(id) == 0 {
+			continue
+		}
+		ids = append(ids, hex.EncodeToString(id))
+	}
+	return ids, nil
+}
+
+// PublicKey implements kms.KeyManager, retrieving the public key object paired
+// with the given private key.
+func (km *keyManager) PublicKey(ctx context.Context, id string) (*ecdsa.PublicKey, error) {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+
+	if km.sess == nil {
+		return nil, errClosed
+	}
+	blob, err := hex.DecodeString(id)
+	if err != nil {
+		return nil, kms.ErrKeyNotFound
+	}
+	handles, err := km.sess.findObjects(ckoPublicKey, blob)
+	if err != nil {
+		return nil, err
+	}
+	if len(handles) == 0 {
+		return nil, kms.ErrKeyNotFound
+	}
+	point, err := km.sess.attribute(handles[0], ckaECPoint)
+	if err != nil {
+		return nil, err
+	}
+	return kms.ParsePublicKey(point)
+}
+
+// Sign implements kms.KeyManager, signing the digest on the token with CKM_ECDSA.
+func (km *keyManager) Sign(ctx context.Context, id string, digest []byte) ([]byte, error) {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+
+	if km.sess == nil {
+		return nil, errClosed
+	}
+	blob, err := hex.DecodeString(id)
+	if err != nil {
+		return nil, kms.ErrKeyNotFound
+	}
+	handles, err := km.sess.findObjects(ckoPrivateKey, blob)
+	if err != nil {
+		return nil, err
+	}
+	if len(handles) == 0 {
+		return nil, kms.ErrKeyNotFound
+	}
+	return km.sess.sign(handles[0], ckmECDSA, digest)
+}
+
+// Close logs out of the token and ends the PKCS#11 session. The key manager can't
+// be used afterwards.
+func (km *keyManager) Close() error {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+
+	if km.sess == nil {
+		return nil
+	}
+	err := km.sess.close()
+	km.sess = nil
+	return err
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pkcs11
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/kms"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testObject is an object stored on the in-memory test token.
+type testObject struct {
+	class uint
+	attrs map[uint][]byte
+	key   *ecdsa.PrivateKey
+}
+
+// testSession is an in-memory PKCS#11 session emulating a token which, like
+// real hardware, returns raw R || S signatures without normalizing S.
+type testSession struct {
+	objects []*testObject
+	closed  int
+}
+
+// addKey stores a key pair on the test token with the given identifier and
+// curve parameters.
+func (s *testSession) addKey(id []byte, params []byte) *ecdsa.PrivateKey {
+	key, _ := crypto.GenerateKey()
+	point, _ := asn1.Marshal(crypto.FromECDSAPub(&key.PublicKey))
+
+	s.objects = append(s.objects,
+		&testObject{class: ckoPrivateKey, key: key, attrs: map[uint][]byte{ckaID: id, ckaECParams: params}},
+		&testObject{class: ckoPublicKey, attrs: map[uint][]byte{ckaID: id, ckaECParams: params, ckaECPoint: point}},
+	)
+	return key
+}
+
+func (s *testSession) findObjects(class uint, id []byte) ([]objectHandle, error) {
+	var handles []objectHandle
+	for i, obj := range s.objects {
+		if obj.class == class && (id == nil || bytes.Equal(obj.attrs[ckaID], id)) {
+			handles = append(handles, objectHandle(i))
+		}
+	}
+	return handles, nil
+}
+
+func (s *testSession) attribute(obj objectHandle, typ uint) ([]byte, error) {
+	return s.objects[obj].attrs[typ], nil
+}
+
+func (s *testSession) sign(key objectHandle, mechanism uint, data []byte) ([]byte, error) {
+	if mechanism != ckmECDSA {
+		return nil, errors.New("unsupported mechanism")
+	}
+	sig, err := crypto.Sign(data, s.objects[key].key)
+	if err != nil {
+		return nil, err
+	}
+	s256 := new(big.Int).SetBytes(sig[32:64])
+	s256.Sub(crypto.S256().Params().N, s256)
+	return append(sig[:32:32], common.LeftPadBytes(s256.Bytes(), 32)...), nil
+}
+
+func (s *testSession) close() error { s.closed++; return nil }
+
+// Tests that only secp256k1 keys of a token are exposed and that they can be
+// used to sign transactions.
+func TestTokenSigning(t *testing.T) {
+	sess := new(testSession)
+	key := sess.addKey([]byte{0x01}, secp256k1Params)
+	sess.addKey([]byte{0x02}, []byte{0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07}) // P-256
+
+	backend, err := kms.NewBackend(Scheme, newKeyManager(sess))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	wallets := backend.Wallets()
+	if len(wallets) != 1 {
+		t.Fatalf("wallet count mismatch: have %d, want 1", len(wallets))
+	}
+	if url := wallets[0].URL().String(); url != "pkcs11://01" {
+		t.Errorf("wallet url mismatch: have %s, want pkcs11://01", url)
+	}
+	account := accounts.Account{Address: crypto.PubkeyToAddress(key.PublicKey)}
+
+	chainID := big.NewInt(1)
+	tx := types.NewTransaction(0, common.Address{0xbb}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	signed, err := wallets[0].SignTx(account, tx, chainID)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	if err != nil {
+		t.Fatalf("failed to recover sender: %v", err)
+	}
+	if sender != account.Address {
+		t.Errorf("sender mismatch: have %x, want %x", sender, account.Address)
+	}
+}
+
+// Tests that closing the backend ends the session and that the key manager
+// can't be used afterwards.
+func TestBackendClose(t *testing.T) {
+	sess := new(testSession)
+	sess.addKey([]byte{0x01}, secp256k1Params)
+
+	backend, err := kms.NewBackend(Scheme, newKeyManager(sess))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	wallet := backend.Wallets()[0]
+	account := wallet.Accounts()[0]
+
+	am := accounts.NewManager(&accounts.Config{}, backend)
+	if err := am.Close(); err != nil {
+		t.Fatalf("failed to close account manager: %v", err)
+	}
+	if sess.closed != 1 {
+		t.Fatalf("session closed %d times, want 1", sess.closed)
+	}
+	if _, err := wallet.SignText(account, []byte("hello")); err == nil {
+		t.Fatalf("signed with closed session")
+	}
+	if err := backend.Close(); err != nil || sess.closed != 1 {
+		t.Fatalf("second close failed: err %v, closed %d times", err, sess.closed)
+	}
+}
+
+// Tests signing against a real PKCS#11 module (e.g. SoftHSM) if one is
+// configured through the environment.
+func TestModuleSigning(t *testing.T) {
+	module := os.Getenv("PKCS11_MODULE")
+	if module == "" {
+		t.Skip("PKCS11_MODULE not set")
+	}
+	backend, err := NewBackend(Config{Module: module, Token: os.Getenv("PKCS11_TOKEN"), PIN: os.Getenv("PKCS11_PIN")})
+	if err != nil {
+		t.Fatalf("failed to open token: %v", err)
+	}
+	defer backend.Close()
+
+	wallets := backend.Wallets()
+	if len(wallets) == 0 {
+		t.Fatalf("no secp256k1 keys found on token")
+	}
+	account := wallets[0].Accounts()[0]
+
+	text := []byte("hello pkcs11")
+	sig, err := wallets[0].SignText(account, text)
+	if err != nil {
+		t.Fatalf("failed to sign text: %v", err)
+	}
+	pub, err := crypto.SigToPub(accounts.TextHash(text), sig)
+	if err != nil {
+		t.Fatalf("failed to recover signer: %v", err)
+	}
+	if crypto.PubkeyToAddress(*pub) != account.Address {
+		t.Errorf("signer mismatch")
+	}
+}
@@ -0,0 +1,362 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build cgo && pkcs11 && !windows
+// +build cgo,pkcs11,!windows
+
+package pkcs11
+
+/*
+#cgo linux LDFLAGS: -ldl
+
+#include <dlfcn.h>
+#include <stdlib.h>
+#include <string.h>
+
+// The declarations below mirror the parts of the PKCS#11 v2.40 headers needed
+// for signing, so that no vendor headers are required to build.
+
+typedef unsigned long ck_ulong;
+typedef ck_ulong ck_rv;
+
+typedef struct {
+	unsigned char major;
+	unsigned char minor;
+} ck_version;
+
+typedef struct {
+	unsigned char label[32];
+	unsigned char manufacturer_id[32];
+	unsigned char model[16];
+	unsigned char serial_number[16];
+	ck_ulong      flags;
+	ck_ulong      counters[10];
+	ck_version    hardware_version;
+	ck_version    firmware_version;
+	unsigned char utc_time[16];
+} ck_token_info;
+
+typedef struct {
+	ck_ulong type;
+	void    *value;
+	ck_ulong value_len;
+} ck_attribute;
+
+typedef struct {
+	ck_ulong mechanism;
+	void    *parameter;
+	ck_ulong parameter_len;
+} ck_mechanism;
+
+typedef struct {
+	void    *create_mutex;
+	void    *destroy_mutex;
+	void    *lock_mutex;
+	void    *unlock_mutex;
+	ck_ulong flags;
+	void    *reserved;
+} ck_initialize_args;
+
+typedef struct {
+	ck_version version;
+	ck_rv (*C_Initialize)(void *);
+	ck_rv (*C_Finalize)(void *);
+	void  *C_GetInfo;
+	void  *C_GetFunctionList;
+	ck_rv (*C_GetSlotList)(unsigned char, ck_ulong *, ck_ulong *);
+	void  *C_GetSlotInfo;
+	ck_rv (*C_GetTokenInfo)(ck_ulong, ck_token_info *);
+	void  *C_GetMechanismList;
+	void  *C_GetMechanismInfo;
+	void  *C_InitToken;
+	void  *C_InitPIN;
+	void  *C_SetPIN;
+	ck_rv (*C_OpenSession)(ck_ulong, ck_ulong, void *, void *, ck_ulong *);
+	ck_rv (*C_CloseSession)(ck_ulong);
+	void  *C_CloseAllSessions;
+	void  *C_GetSessionInfo;
+	void  *C_GetOperationState;
+	void  *C_SetOperationState;
+	ck_rv (*C_Login)(ck_ulong, ck_ulong, unsigned char *, ck_ulong);
+	ck_rv (*C_Logout)(ck_ulong);
+	void  *C_CreateObject;
+	void  *C_CopyObject;
+	void  *C_DestroyObject;
+	void  *C_GetObjectSize;
+	ck_rv (*C_GetAttributeValue)(ck_ulong, ck_ulong, ck_attribute *, ck_ulong);
+	void  *C_SetAttributeValue;
+	ck_rv (*C_FindObjectsInit)(ck_ulong, ck_attribute *, ck_ulong);
+	ck_rv (*C_FindObjects)(ck_ulong, ck_ulong *, ck_ulong, ck_ulong *);
+	ck_rv (*C_FindObjectsFinal)(ck_ulong);
+	void  *C_EncryptToDigest[13];
+	ck_rv (*C_SignInit)(ck_ulong, ck_mechanism *, ck_ulong);
+	ck_rv (*C_Sign)(ck_ulong, unsigned char *, ck_ulong, unsigned char *, ck_ulong *);
+} ck_function_list;
+
+static void *p11_open(const char *path, ck_function_list **funcs, ck_rv *rv) {
+	void *lib = dlopen(path, RTLD_NOW | RTLD_LOCAL);
+	if (lib == NULL) {
+		return NULL;
+	}
+	ck_rv (*get)(ck_function_list **) = (ck_rv (*)(ck_function_list **))dlsym(lib, "C_GetFunctionList");
+	if (get == NULL) {
+		dlclose(lib);
+		return NULL;
+	}
+	*rv = get(funcs);
+	return lib;
+}
+
+static void p11_close(void *lib) { dlclose(lib); }
+
+static ck_rv p11_initialize(ck_function_list *f) {
+	ck_initialize_args args;
+	memset(&args, 0, sizeof(args));
+	args.flags = 0x2; // CKF_OS_LOCKING_OK
+	return f->C_Initialize(&args);
+}
+static ck_rv p11_finalize(ck_function_list *f) { return f->C_Finalize(NULL); }
+
+static ck_rv p11_get_slot_list(ck_function_list *f, ck_ulong *slots, ck_ulong *count) {
+	return f->C_GetSlotList(1, slots, count);
+}
+static ck_rv p11_get_token_info(ck_function_list *f, ck_ulong slot, ck_token_info *info) {
+	return f->C_GetTokenInfo(slot, info);
+}
+static ck_rv p11_open_session(ck_function_list *f, ck_ulong slot, ck_ulong *session) {
+	return f->C_OpenSession(slot, 0x4, NULL, NULL, session); // CKF_SERIAL_SESSION
+}
+static ck_rv p11_close_session(ck_function_list *f, ck_ulong session) { return f->C_CloseSession(session); }
+
+static ck_rv p11_login(ck_function_list *f, ck_ulong session, unsigned char *pin, ck_ulong len) {
+	return f->C_Login(session, 1, pin, len); // CKU_USER
+}
+static ck_rv p11_logout(ck_function_list *f, ck_ulong session) { return f->C_Logout(session); }
+
+static ck_rv p11_get_attribute(ck_function_list *f, ck_ulong session, ck_ulong obj, ck_attribute *attr) {
+	return f->C_GetAttributeValue(session, obj, attr, 1);
+}
+static ck_rv p11_find_objects(ck_function_list *f, ck_ulong session, ck_attribute *tmpl, ck_ulong n, ck_ulong *objs, ck_ulong max, ck_ulong *count) {
+	ck_rv rv = f->C_FindObjectsInit(session, tmpl, n);
+	if (rv != 0) {
+		return rv;
+	}
+	rv = f->C_FindObjects(session, objs, max, count);
+	f->C_FindObjectsFinal(session);
+	return rv;
+}
+static ck_rv p11_sign(ck_function_list *f, ck_ulong session, ck_ulong key, ck_ulong mech, unsigned char *data, ck_ulong len, unsigned char *sig, ck_ulong *siglen) {
+	ck_mechanism m = {mech, NULL, 0};
+	ck_rv rv = f->C_SignInit(session, &m, key);
+	if (rv != 0) {
+		return rv;
+	}
+	return f->C_Sign(session, data, len, sig, siglen);
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"fmt"
+	"unsafe"
+)
+
+// maxObjects is the maximum number of objects retrieved by a single search.
+const maxObjects = 256
+
+// PKCS#11 return values handled explicitly.
+const (
+	ckrOK                         = 0x000
+	ckrUserAlreadyLoggedIn        = 0x100
+	ckrCryptokiAlreadyInitialized = 0x191
+)
+
+// ckError is a PKCS#11 return value signalling a failure.
+type ckError struct {
+	op string
+	rv C.ck_rv
+}
+
+// Error implements the standard error interface.
+func (err *ckError) Error() string {
+	return fmt.Sprintf("pkcs11: %s failed: CKR 0x%x", err.op, uint64(err.rv))
+}
+
+// nativeSession is a session to a token of a dynamically loaded PKCS#11 module.
+type nativeSession struct {
+	lib    unsafe.Pointer
+	funcs  *C.ck_function_list
+	handle C.ck_ulong
+}
+
+// openSession loads the configured PKCS#11 module, looks up the token with the
+// configured label and logs into it.
+func openSession(config Config) (session, error) {
+	path := C.CString(config.Module)
+	defer C.free(unsafe.Pointer(path))
+
+	var (
+		funcs *C.ck_function_list
+		rv    C.ck_rv
+	)
+	lib := C.p11_open(path, &funcs, &rv)
+	if lib == nil {
+		return nil, fmt.Errorf("pkcs11: failed to load module %s", config.Module)
+	}
+	if rv != ckrOK {
+		C.p11_close(lib)
+		return nil, &ckError{"C_GetFunctionList", rv}
+	}
+	if rv = C.p11_initialize(funcs); rv != ckrOK && rv != ckrCryptokiAlreadyInitialized {
+		C.p11_close(lib)
+		return nil, &ckError{"C_Initialize", rv}
+	}
+	s := &nativeSession{lib: lib, funcs: funcs}
+	slot, err := s.findToken(config.Token)
+	if err == nil {
+		err = s.login(slot, config.PIN)
+	}
+	if err != nil {
+		C.p11_finalize(funcs)
+		C.p11_close(lib)
+		return nil, err
+	}
+	return s, nil
+}
+
+// findToken returns the slot holding the token with the given label.
+func (s *nativeSession) findToken(label string) (C.ck_ulong, error) {
+	var count C.ck_ulong
+	if rv := C.p11_get_slot_list(s.funcs, nil, &count); rv != ckrOK {
+		return 0, &ckError{"C_GetSlotList", rv}
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("pkcs11: no tokens present")
+	}
+	slots := (*C.ck_ulong)(C.calloc(C.size_t(count), C.size_t(unsafe.Sizeof(C.ck_ulong(0)))))
+	defer C.free(unsafe.Pointer(slots))
+
+	if rv := C.p11_get_slot_list(s.funcs, slots, &count); rv != ckrOK {
+		return 0, &ckError{"C_GetSlotList", rv}
+	}
+	for _, slot := range (*[1 << 20]C.ck_ulong)(unsafe.Pointer(slots))[:count:count] {
+		var info C.ck_token_info
+		if rv := C.p11_get_token_info(s.funcs, slot, &info); rv != ckrOK {
+			continue
+		}
+		have := C.GoBytes(unsafe.Pointer(&info.label[0]), C.int(len(info.label)))
+		if string(bytes.TrimRight(have, " \x00")) == label {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("pkcs11: token %q not found", label)
+}
+
+// login opens a session on the given slot and authenticates with the user PIN.
+func (s *nativeSession) login(slot C.ck_ulong, pin string) error {
+	if rv := C.p11_open_session(s.funcs, slot, &s.handle); rv != ckrOK {
+		return &ckError{"C_OpenSession", rv}
+	}
+	cpin := C.CString(pin)
+	defer C.free(unsafe.Pointer(cpin))
+
+	if rv := C.p11_login(s.funcs, s.handle, (*C.uchar)(unsafe.Pointer(cpin)), C.ck_ulong(len(pin))); rv != ckrOK && rv != ckrUserAlreadyLoggedIn {
+		C.p11_close_session(s.funcs, s.handle)
+		return &ckError{"C_Login", rv}
+	}
+	return nil
+}
+
+// findObjects implements session, searching for objects of the given class and
+// optionally with the given identifier.
+func (s *nativeSession) findObjects(class uint, id []byte) ([]objectHandle, error) {
+	// Assemble the search template in C memory, it may not reference Go memory
+	tmpl := (*[2]C.ck_attribute)(C.calloc(2, C.size_t(unsafe.Sizeof(C.ck_attribute{}))))
+	defer C.free(unsafe.Pointer(tmpl))
+
+	cclass := (*C.ck_ulong)(C.malloc(C.size_t(unsafe.Sizeof(C.ck_ulong(0)))))
+	defer C.free(unsafe.Pointer(cclass))
+	*cclass = C.ck_ulong(class)
+
+	tmpl[0] = C.ck_attribute{_type: ckaClass, value: unsafe.Pointer(cclass), value_len: C.ck_ulong(unsafe.Sizeof(*cclass))}
+	n := C.ck_ulong(1)
+	if id != nil {
+		cid := C.CBytes(id)
+		defer C.free(cid)
+		tmpl[1] = C.ck_attribute{_type: ckaID, value: cid, value_len: C.ck_ulong(len(id))}
+		n++
+	}
+	objs := (*[maxObjects]C.ck_ulong)(C.calloc(maxObjects, C.size_t(unsafe.Sizeof(C.ck_ulong(0)))))
+	defer C.free(unsafe.Pointer(objs))
+
+	var count C.ck_ulong
+	if rv := C.p11_find_objects(s.funcs, s.handle, &tmpl[0], n, &objs[0], maxObjects, &count); rv != ckrOK {
+		return nil, &ckError{"C_FindObjects", rv}
+	}
+	handles := make([]objectHandle, count)
+	for i := range handles {
+		handles[i] = objectHandle(objs[i])
+	}
+	return handles, nil
+}
+
+// attribute implements session, retrieving a single attribute of an object.
+func (s *nativeSession) attribute(obj objectHandle, typ uint) ([]byte, error) {
+	attr := (*C.ck_attribute)(C.calloc(1, C.size_t(unsafe.Sizeof(C.ck_attribute{}))))
+	defer C.free(unsafe.Pointer(attr))
+
+	// Query the size of the attribute first, then retrieve its value
+	attr._type = C.ck_ulong(typ)
+	if rv := C.p11_get_attribute(s.funcs, s.handle, C.ck_ulong(obj), attr); rv != ckrOK {
+		return nil, &ckError{"C_GetAttributeValue", rv}
+	}
+	if attr.value_len == 0 {
+		return nil, nil
+	}
+	attr.value = C.malloc(C.size_t(attr.value_len))
+	defer C.free(attr.value)
+
+	if rv := C.p11_get_attribute(s.funcs, s.handle, C.ck_ulong(obj), attr); rv != ckrOK {
+		return nil, &ckError{"C_GetAttributeValue", rv}
+	}
+	return C.GoBytes(attr.value, C.int(attr.value_len)), nil
+}
+
+// sign implements session, signing the data with the given key and mechanism.
+func (s *nativeSession) sign(key objectHandle, mechanism uint, data []byte) ([]byte, error) {
+	cdata := C.CBytes(data)
+	defer C.free(cdata)
+
+	// ECDSA signatures are at most twice the size of the curve order
+	siglen := C.ck_ulong(128)
+	sig := C.malloc(C.size_t(siglen))
+	defer C.free(sig)
+
+	if rv := C.p11_sign(s.funcs, s.handle, C.ck_ulong(key), C.ck_ulong(mechanism), (*C.uchar)(cdata), C.ck_ulong(len(data)), (*C.uchar)(sig), &siglen); rv != ckrOK {
+		return nil, &ckError{"C_Sign", rv}
+	}
+	return C.GoBytes(sig, C.int(siglen)), nil
+}
+
+// close implements session, logging out and unloading the module.
+func (s *nativeSession) close() error {
+	C.p11_logout(s.funcs, s.handle)
+	C.p11_close_session(s.funcs, s.handle)
+	C.p11_finalize(s.funcs)
+	C.p11_close(s.lib)
+	return nil
+}
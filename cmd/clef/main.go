@@ -37,6 +37,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/accounts/pkcs11"
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -53,6 +54,7 @@ import (
 	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 	"github.com/ethereum/go-ethereum/signer/fourbyte"
 	"github.com/ethereum/go-ethereum/signer/rules"
+	"github.com/ethereum/go-ethereum/signer/simulation"
 	"github.com/ethereum/go-ethereum/signer/storage"
 	"github.com/mattn/go-colorable"
 	"github.com/mattn/go-isatty"
@@ -125,6 +127,32 @@ var (
 		Name:  "rules",
 		Usage: "Path to the rule file to auto-authorize requests with",
 	}
+	policyFlag = cli.StringFlag{
+		Name:  "policy",
+		Usage: "Path to a declarative YAML or JSON policy file to auto-authorize requests with",
+	}
+	simulateFlag = cli.StringFlag{
+		Name:  "simulate",
+		Usage: "URL of a node exposing the debug API, to simulate transactions on before approval",
+	}
+	quorumApproversFlag = cli.StringFlag{
+		Name:  "quorum.approvers",
+		Usage: "Comma separated addresses of the approvers that need to sign off requests (enables M-of-N approval)",
+	}
+	quorumThresholdFlag = cli.IntFlag{
+		Name:  "quorum.threshold",
+		Usage: "Number of approvers needed to grant a request",
+		Value: 1,
+	}
+	quorumTimeoutFlag = cli.DurationFlag{
+		Name:  "quorum.timeout",
+		Usage: "Time after which a request without enough approvals is rejected",
+		Value: time.Hour,
+	}
+	attestPolicyFlag = cli.BoolFlag{
+		Name:  "policy",
+		Usage: "Attest a policy file instead of a rule file",
+	}
 	stdiouiFlag = cli.BoolFlag{
 		Name: "stdio-ui",
 		Usage: "Use STDIN/STDOUT as a channel for an external UI. " +
@@ -158,12 +186,13 @@ the rule-engine to work.`,
 			logLevelFlag,
 			configdirFlag,
 			signerSecretFlag,
+			attestPolicyFlag,
 		},
 		Description: `
 The attest command stores the sha256 of the rule.js-file that you want to use for automatic processing of
-incoming requests.
+incoming requests. With --policy, the sha256 of the declarative policy file is stored instead.
 
-Whenever you make an edit to the rule file, you need to use attestation to tell
+Whenever you make an edit to the rule or policy file, you need to use attestation to tell
 Clef that the file is 'safe' to execute.`,
 	}
 	setCredentialCommand = cli.Command{
@@ -229,6 +258,9 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.LightKDFFlag,
 			utils.NoUSBFlag,
 			utils.SmartCardDaemonPathFlag,
+			utils.PKCS11ModuleFlag,
+			utils.PKCS11TokenFlag,
+			utils.PKCS11PINFileFlag,
 			utils.HTTPListenAddrFlag,
 			utils.HTTPVirtualHostsFlag,
 			utils.IPCDisabledFlag,
@@ -239,6 +271,11 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			customDBFlag,
 			auditLogFlag,
 			ruleFlag,
+			policyFlag,
+			simulateFlag,
+			quorumApproversFlag,
+			quorumThresholdFlag,
+			quorumTimeoutFlag,
 			stdiouiFlag,
 			testFlag,
 			advancedMode,
@@ -258,6 +295,9 @@ func init() {
 		utils.LightKDFFlag,
 		utils.NoUSBFlag,
 		utils.SmartCardDaemonPathFlag,
+		utils.PKCS11ModuleFlag,
+		utils.PKCS11TokenFlag,
+		utils.PKCS11PINFileFlag,
 		utils.HTTPListenAddrFlag,
 		utils.HTTPVirtualHostsFlag,
 		utils.IPCDisabledFlag,
@@ -268,6 +308,11 @@ func init() {
 		customDBFlag,
 		auditLogFlag,
 		ruleFlag,
+		policyFlag,
+		simulateFlag,
+		quorumApproversFlag,
+		quorumThresholdFlag,
+		quorumTimeoutFlag,
 		stdiouiFlag,
 		testFlag,
 		advancedMode,
@@ -410,6 +455,11 @@ func attestFile(ctx *cli.Context) error {
 	// Initialize the encrypted storages
 	configStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "config.json"), confKey)
 	val := ctx.Args().First()
+	if ctx.Bool(attestPolicyFlag.Name) {
+		configStorage.Put("policy_sha256", val)
+		log.Info("Policy attestation updated", "sha256", val)
+		return nil
+	}
 	configStorage.Put("ruleset_sha256", val)
 	log.Info("Ruleset attestation updated", "sha256", val)
 	return nil
@@ -574,9 +624,13 @@ func signer(c *cli.Context) error {
 	var (
 		api       core.ExternalAPI
 		pwStorage storage.Storage = &storage.NoStorage{}
+		quorum    *core.QuorumUI
 	)
 	configDir := c.GlobalString(configdirFlag.Name)
 	if stretchedKey, err := readMasterKey(c, ui); err != nil {
+		if c.GlobalIsSet(quorumApproversFlag.Name) {
+			utils.Fatalf("Quorum approval needs the master seed to persist requests: %v", err)
+		}
 		log.Warn("Failed to open master, rules disabled", "err", err)
 	} else {
 		vaultLocation := filepath.Join(configDir, common.Bytes2Hex(crypto.Keccak256([]byte("vault"), stretchedKey)[:10]))
@@ -585,12 +639,35 @@ func signer(c *cli.Context) error {
 		pwkey := crypto.Keccak256([]byte("credentials"), stretchedKey)
 		jskey := crypto.Keccak256([]byte("jsstorage"), stretchedKey)
 		confkey := crypto.Keccak256([]byte("config"), stretchedKey)
+		policykey := crypto.Keccak256([]byte("policystorage"), stretchedKey)
+		quorumkey := crypto.Keccak256([]byte("quorum"), stretchedKey)
 
 		// Initialize the encrypted storages
 		pwStorage = storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "credentials.json"), pwkey)
 		jsStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "jsstorage.json"), jskey)
 		configStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "config.json"), confkey)
 
+		// Do requests need to be approved by a quorum instead of the local user?
+		if approvers := c.GlobalString(quorumApproversFlag.Name); approvers != "" {
+			config := core.QuorumConfig{
+				Threshold: c.GlobalInt(quorumThresholdFlag.Name),
+				Timeout:   c.GlobalDuration(quorumTimeoutFlag.Name),
+			}
+			for _, addr := range utils.SplitAndTrim(approvers) {
+				if !common.IsHexAddress(addr) {
+					utils.Fatalf("Invalid approver address %q", addr)
+				}
+				config.Approvers = append(config.Approvers, common.HexToAddress(addr))
+			}
+			quorumStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "quorum.json"), quorumkey)
+			if quorum, err = core.NewQuorumUI(ui, quorumStorage, config); err != nil {
+				utils.Fatalf("Failed to set up quorum approval: %v", err)
+			}
+			// Rules and policies can only reject or narrow down requests, the
+			// ones they approve still go to the vote
+			ui = quorum.Filter()
+			log.Info("Quorum approval configured", "approvers", len(config.Approvers), "threshold", config.Threshold)
+		}
 		// Do we have a rule-file?
 		if ruleFile := c.GlobalString(ruleFlag.Name); ruleFile != "" {
 			ruleJS, err := ioutil.ReadFile(ruleFile)
@@ -614,6 +691,32 @@ func signer(c *cli.Context) error {
 				}
 			}
 		}
+		// Do we have a policy file? It is evaluated before the rule-file.
+		if policyFile := c.GlobalString(policyFlag.Name); policyFile != "" {
+			blob, err := ioutil.ReadFile(policyFile)
+			if err != nil {
+				log.Warn("Could not load policy, disabling", "file", policyFile, "err", err)
+			} else {
+				shasum := sha256.Sum256(blob)
+				foundShaSum := hex.EncodeToString(shasum[:])
+				storedShasum, _ := configStorage.Get("policy_sha256")
+				if storedShasum != foundShaSum {
+					log.Warn("Policy hash not attested, disabling", "hash", foundShaSum, "attested", storedShasum)
+				} else {
+					policy, err := rules.ParsePolicy(blob)
+					if err != nil {
+						utils.Fatalf("Invalid policy file: %v", err)
+					}
+					policyStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "policystorage.json"), policykey)
+					ui = rules.NewPolicyEvaluator(ui, policyStorage, policy)
+					log.Info("Policy engine configured", "file", policyFile)
+				}
+			}
+		}
+		if quorum != nil {
+			quorum.SetFilter(ui)
+			ui = quorum
+		}
 	}
 	var (
 		chainId  = c.GlobalInt64(chainIdFlag.Name)
@@ -626,7 +729,33 @@ func signer(c *cli.Context) error {
 	log.Info("Starting signer", "chainid", chainId, "keystore", ksLoc,
 		"light-kdf", lightKdf, "advanced", advanced)
 	am := core.StartClefAccountManager(ksLoc, nousb, lightKdf, scpath)
+	if module := c.GlobalString(utils.PKCS11ModuleFlag.Name); module != "" {
+		config := pkcs11.Config{Module: module, Token: c.GlobalString(utils.PKCS11TokenFlag.Name)}
+		if pinfile := c.GlobalString(utils.PKCS11PINFileFlag.Name); pinfile != "" {
+			pin, err := ioutil.ReadFile(pinfile)
+			if err != nil {
+				utils.Fatalf("Failed to read PKCS#11 PIN file: %v", err)
+			}
+			config.PIN = strings.TrimRight(string(pin), "\r\n")
+		}
+		if hsm, err := pkcs11.NewBackend(config); err != nil {
+			log.Warn("Failed to start PKCS#11 backend, disabling", "err", err)
+		} else {
+			am.AddBackend(hsm)
+		}
+	}
+	// Closing the account manager logs out of any hardware security module
+	defer am.Close()
+
 	apiImpl := core.NewSignerAPI(am, chainId, nousb, ui, db, advanced, pwStorage)
+	if url := c.GlobalString(simulateFlag.Name); url != "" {
+		client, err := rpc.Dial(url)
+		if err != nil {
+			utils.Fatalf("Could not connect to simulation node: %v", err)
+		}
+		apiImpl.SetSimulator(simulation.New(client))
+		log.Info("Transaction simulation enabled", "node", url)
+	}
 
 	// Establish the bidirectional communication, by creating a new UI backend and registering
 	// it with the UI.
@@ -634,10 +763,14 @@ func signer(c *cli.Context) error {
 	api = apiImpl
 	// Audit logging
 	if logfile := c.GlobalString(auditLogFlag.Name); logfile != "" {
-		api, err = core.NewAuditLogger(logfile, api)
+		auditLogger, err := core.NewAuditLogger(logfile, api)
 		if err != nil {
 			utils.Fatalf(err.Error())
 		}
+		if quorum != nil {
+			quorum.SetAuditLog(auditLogger.Log())
+		}
+		api = auditLogger
 		log.Info("Audit logs configured", "file", logfile)
 	}
 	// register signer API with server
@@ -652,6 +785,19 @@ func signer(c *cli.Context) error {
 			Service:   api,
 			Version:   "1.0"},
 	}
+	// The approvers vote through the local IPC endpoint only, the external
+	// HTTP endpoint never serves the quorum API
+	ipcAPI := rpcAPI
+	if quorum != nil {
+		if c.GlobalBool(utils.IPCDisabledFlag.Name) {
+			utils.Fatalf("Quorum approval needs the IPC endpoint to collect votes")
+		}
+		ipcAPI = append(ipcAPI, rpc.API{
+			Namespace: "quorum",
+			Service:   core.NewQuorumAPI(quorum),
+			Version:   "1.0",
+		})
+	}
 	if c.GlobalBool(utils.HTTPEnabledFlag.Name) {
 		vhosts := utils.SplitAndTrim(c.GlobalString(utils.HTTPVirtualHostsFlag.Name))
 		cors := utils.SplitAndTrim(c.GlobalString(utils.HTTPCORSDomainFlag.Name))
@@ -684,7 +830,7 @@ func signer(c *cli.Context) error {
 	if !c.GlobalBool(utils.IPCDisabledFlag.Name) {
 		givenPath := c.GlobalString(utils.IPCPathFlag.Name)
 		ipcapiURL = ipcEndpoint(filepath.Join(givenPath, "clef.ipc"), configDir)
-		listener, _, err := rpc.StartIPCEndpoint(ipcapiURL, rpcAPI)
+		listener, _, err := rpc.StartIPCEndpoint(ipcapiURL, ipcAPI)
 		if err != nil {
 			utils.Fatalf("Could not start IPC api: %v", err)
 		}
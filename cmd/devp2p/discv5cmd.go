@@ -36,6 +36,7 @@ var (
 			discv5CrawlCommand,
 			discv5TestCommand,
 			discv5ListenCommand,
+			discv5TopicCommand,
 		},
 	}
 	discv5PingCommand = cli.Command{
@@ -77,8 +78,26 @@ var (
 			listenAddrFlag,
 		},
 	}
+	discv5TopicCommand = cli.Command{
+		Name:      "topic",
+		Usage:     "Finds nodes advertising a topic",
+		ArgsUsage: "<topic>",
+		Action:    discv5Topic,
+		Flags: []cli.Flag{
+			bootnodesFlag,
+			nodekeyFlag,
+			nodedbFlag,
+			listenAddrFlag,
+			topicRegisterFlag,
+		},
+	}
 )
 
+var topicRegisterFlag = cli.BoolFlag{
+	Name:  "register",
+	Usage: "Advertise the local node under the topic",
+}
+
 func discv5Ping(ctx *cli.Context) error {
 	n := getNodeArg(ctx)
 	disc := startV5(ctx)
@@ -134,6 +153,26 @@ func discv5Listen(ctx *cli.Context) error {
 	select {}
 }
 
+func discv5Topic(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("need topic as argument")
+	}
+	topic := discover.NewTopic(ctx.Args().First())
+	disc := startV5(ctx)
+	defer disc.Close()
+
+	if ctx.Bool(topicRegisterFlag.Name) {
+		fmt.Println(disc.Self())
+		disc.RegisterTopic(topic)
+	}
+	it := disc.TopicNodes(topic)
+	defer it.Close()
+	for it.Next() {
+		fmt.Println(it.Node())
+	}
+	return nil
+}
+
 // startV5 starts an ephemeral discovery v5 node.
 func startV5(ctx *cli.Context) *discover.UDPv5 {
 	ln, config := makeDiscoveryConfig(ctx)
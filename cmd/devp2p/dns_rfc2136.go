@@ -0,0 +1,338 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
+	"github.com/miekg/dns"
+	"gopkg.in/urfave/cli.v1"
+)
+
+const (
+	// Updates are sent over TCP, where messages are limited to 64k. Stay well
+	// below the limit, some servers reject large updates.
+	rfc2136UpdateSizeLimit = 32000
+
+	rfc2136Timeout = 30 * time.Second
+	tsigFudge      = 300
+)
+
+var (
+	rfc2136ServerFlag = cli.StringFlag{
+		Name:  "server",
+		Usage: "Address of the primary DNS server (host:port)",
+	}
+	rfc2136ZoneFlag = cli.StringFlag{
+		Name:  "zone",
+		Usage: "Name of the zone containing the tree (optional)",
+	}
+	rfc2136TSIGKeyFlag = cli.StringFlag{
+		Name:  "tsig-key",
+		Usage: "Name of the TSIG key",
+	}
+	rfc2136TSIGSecretFlag = cli.StringFlag{
+		Name:   "tsig-secret",
+		Usage:  "TSIG key secret (base64)",
+		EnvVar: "DNS_TSIG_SECRET",
+	}
+	rfc2136TSIGAlgorithmFlag = cli.StringFlag{
+		Name:  "tsig-algorithm",
+		Usage: "TSIG algorithm (hmac-sha256, hmac-sha512, hmac-sha1)",
+		Value: "hmac-sha256",
+	}
+)
+
+// rfc2136Client deploys trees to a DNS server using dynamic updates (RFC 2136).
+// Existing records are read by zone transfer. Requests are authenticated with TSIG
+// (RFC 8945) if a key is configured.
+type rfc2136Client struct {
+	server  string
+	zone    string
+	keyName string
+	keyAlg  string
+	secret  map[string]string
+}
+
+// newRFC2136Client sets up a dynamic update client from command line flags.
+func newRFC2136Client(ctx *cli.Context) *rfc2136Client {
+	server := ctx.String(rfc2136ServerFlag.Name)
+	if server == "" {
+		exit(fmt.Errorf("need DNS server address to proceed"))
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	c := &rfc2136Client{server: server}
+	if zone := ctx.String(rfc2136ZoneFlag.Name); zone != "" {
+		c.zone = dns.CanonicalName(zone)
+	}
+	if key := ctx.String(rfc2136TSIGKeyFlag.Name); key != "" {
+		secret := ctx.String(rfc2136TSIGSecretFlag.Name)
+		if secret == "" {
+			exit(fmt.Errorf("need secret of TSIG key %s", key))
+		}
+		alg, err := tsigAlgorithm(ctx.String(rfc2136TSIGAlgorithmFlag.Name))
+		if err != nil {
+			exit(err)
+		}
+		c.setKey(key, alg, secret)
+	}
+	return c
+}
+
+// tsigAlgorithm returns the name of a supported TSIG algorithm.
+func tsigAlgorithm(name string) (string, error) {
+	switch alg := dns.Fqdn(strings.ToLower(name)); alg {
+	case dns.HmacSHA1, dns.HmacSHA256, dns.HmacSHA512:
+		return alg, nil
+	default:
+		return "", fmt.Errorf("unsupported TSIG algorithm %q", name)
+	}
+}
+
+func (c *rfc2136Client) setKey(name, alg, secret string) {
+	c.keyName = dns.CanonicalName(name)
+	c.keyAlg = alg
+	c.secret = map[string]string{c.keyName: secret}
+}
+
+// deploy uploads the given tree to the DNS server.
+func (c *rfc2136Client) deploy(name string, t *dnsdisc.Tree) error {
+	if err := c.checkZone(name); err != nil {
+		return err
+	}
+	existing, err := c.collectRecords(name)
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Found %d TXT records", len(existing)))
+	updates := c.computeUpdates(name, t.ToTXT(name), existing)
+	return c.submitUpdates(updates)
+}
+
+// checkZone finds the zone containing name if it isn't configured.
+func (c *rfc2136Client) checkZone(name string) error {
+	if c.zone != "" {
+		if !isSubdomain(name, c.zone) {
+			return fmt.Errorf("name %s is not in zone %s", name, c.zone)
+		}
+		return nil
+	}
+	log.Info(fmt.Sprintf("Finding zone of %s", name))
+	m := new(dns.Msg)
+	m.SetQuestion(dns.CanonicalName(name), dns.TypeSOA)
+	r, err := c.exchange(m)
+	if err != nil {
+		return fmt.Errorf("can't find zone of %s: %v", name, err)
+	}
+	for _, rr := range append(r.Answer, r.Ns...) {
+		if soa, ok := rr.(*dns.SOA); ok {
+			c.zone = dns.CanonicalName(soa.Hdr.Name)
+			log.Info(fmt.Sprintf("Found zone %s", c.zone))
+			return nil
+		}
+	}
+	return errors.New("can't find zone of " + name)
+}
+
+// collectRecords transfers the zone and returns the TXT records below name.
+func (c *rfc2136Client) collectRecords(name string) (map[string]recordSet, error) {
+	log.Info("Loading existing TXT records", "name", name, "zone", c.zone)
+	m := new(dns.Msg)
+	m.SetAxfr(c.zone)
+	c.sign(m)
+	tr := &dns.Transfer{
+		DialTimeout:  rfc2136Timeout,
+		ReadTimeout:  rfc2136Timeout,
+		WriteTimeout: rfc2136Timeout,
+		TsigSecret:   c.secret,
+	}
+	envs, err := tr.In(m, c.server)
+	if err != nil {
+		return nil, fmt.Errorf("zone transfer failed: %v", err)
+	}
+	existing := make(map[string]recordSet)
+	for env := range envs {
+		if env.Error != nil {
+			return nil, fmt.Errorf("zone transfer failed: %v", env.Error)
+		}
+		for _, rr := range env.RR {
+			txt, ok := rr.(*dns.TXT)
+			if !ok || !isSubdomain(txt.Hdr.Name, name) {
+				continue
+			}
+			// Several TXT records of the same name aren't valid in a tree, they
+			// are collected only so that all of them can be replaced.
+			path := strings.ToLower(strings.TrimSuffix(txt.Hdr.Name, "."))
+			set := existing[path]
+			set.ttl = int64(txt.Hdr.Ttl)
+			set.values = append(set.values, strings.Join(txt.Txt, ""))
+			existing[path] = set
+		}
+	}
+	return existing, nil
+}
+
+// rfc2136Change is a change of the TXT record at a name.
+type rfc2136Change struct {
+	action string // CREATE, UPSERT or DELETE
+	name   string
+	ttl    uint32
+	value  string
+}
+
+// computeUpdates creates the changes needed to turn the existing records into the
+// given records, in leaf-added -> root-changed -> leaf-deleted order.
+func (c *rfc2136Client) computeUpdates(name string, records map[string]string, existing map[string]recordSet) []rfc2136Change {
+	// Convert all names to lowercase.
+	lrecords := make(map[string]string, len(records))
+	for name, r := range records {
+		lrecords[strings.ToLower(name)] = r
+	}
+	records = lrecords
+	name = strings.ToLower(name)
+
+	var changes []rfc2136Change
+	for path, newValue := range records {
+		ttl := uint32(rootTTL)
+		if path != name {
+			ttl = treeNodeTTL
+		}
+		prev, exists := existing[path]
+		switch {
+		case !exists:
+			log.Info(fmt.Sprintf("Creating %s = %q", path, newValue))
+			changes = append(changes, rfc2136Change{"CREATE", path, ttl, newValue})
+		case len(prev.values) != 1 || prev.values[0] != newValue || prev.ttl != int64(ttl):
+			log.Info(fmt.Sprintf("Updating %s from %q to %q", path, strings.Join(prev.values, ""), newValue))
+			changes = append(changes, rfc2136Change{"UPSERT", path, ttl, newValue})
+		default:
+			log.Debug(fmt.Sprintf("Skipping %s = %q", path, newValue))
+		}
+	}
+	for path, set := range existing {
+		if _, ok := records[path]; ok {
+			continue
+		}
+		log.Info(fmt.Sprintf("Deleting %s = %q", path, strings.Join(set.values, "")))
+		changes = append(changes, rfc2136Change{action: "DELETE", name: path})
+	}
+
+	score := map[string]int{"CREATE": 1, "UPSERT": 2, "DELETE": 3}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].action == changes[j].action {
+			return changes[i].name < changes[j].name
+		}
+		return score[changes[i].action] < score[changes[j].action]
+	})
+	return changes
+}
+
+// submitUpdates sends the changes to the server. Each update message is applied
+// atomically by the server, changes are split into several messages only if they
+// don't fit into one.
+func (c *rfc2136Client) submitUpdates(changes []rfc2136Change) error {
+	if len(changes) == 0 {
+		log.Info("No DNS changes needed")
+		return nil
+	}
+	batches := c.makeUpdates(changes)
+	for i, m := range batches {
+		log.Info(fmt.Sprintf("Submitting update %d/%d with %d changes", i+1, len(batches), len(m.Ns)))
+		c.sign(m)
+		if _, err := c.exchange(m); err != nil {
+			return fmt.Errorf("update failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// makeUpdates creates update messages for the given changes.
+func (c *rfc2136Client) makeUpdates(changes []rfc2136Change) []*dns.Msg {
+	var (
+		batches []*dns.Msg
+		size    int
+	)
+	for _, ch := range changes {
+		var remove, insert []dns.RR
+		if ch.action != "CREATE" {
+			remove = []dns.RR{&dns.TXT{Hdr: dns.RR_Header{Name: dns.Fqdn(ch.name), Rrtype: dns.TypeTXT}}}
+		}
+		if ch.action != "DELETE" {
+			insert = []dns.RR{newTXTRecord(ch.name, ch.ttl, ch.value)}
+		}
+		chSize := 0
+		for _, rr := range append(remove, insert...) {
+			chSize += dns.Len(rr)
+		}
+		if len(batches) == 0 || size+chSize > rfc2136UpdateSizeLimit {
+			m := new(dns.Msg)
+			m.SetUpdate(c.zone)
+			batches = append(batches, m)
+			size = 0
+		}
+		m := batches[len(batches)-1]
+		if remove != nil {
+			m.RemoveRRset(remove)
+		}
+		if insert != nil {
+			m.Insert(insert)
+		}
+		size += chSize
+	}
+	return batches
+}
+
+// sign adds a TSIG record to m if a key is configured.
+func (c *rfc2136Client) sign(m *dns.Msg) {
+	if c.keyName != "" {
+		m.SetTsig(c.keyName, c.keyAlg, tsigFudge, time.Now().Unix())
+	}
+}
+
+// exchange sends m to the server and checks the response code.
+func (c *rfc2136Client) exchange(m *dns.Msg) (*dns.Msg, error) {
+	client := &dns.Client{Net: "tcp", Timeout: rfc2136Timeout, TsigSecret: c.secret}
+	r, _, err := client.Exchange(m, c.server)
+	if err != nil {
+		return nil, err
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("server responded with %s", dns.RcodeToString[r.Rcode])
+	}
+	return r, nil
+}
+
+// newTXTRecord creates a TXT record, splitting the value into strings of at most
+// 255 characters.
+func newTXTRecord(name string, ttl uint32, value string) *dns.TXT {
+	rr := &dns.TXT{Hdr: dns.RR_Header{Name: dns.Fqdn(name), Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: ttl}}
+	for len(value) > 255 {
+		rr.Txt = append(rr.Txt, value[:255])
+		value = value[255:]
+	}
+	rr.Txt = append(rr.Txt, value)
+	return rr
+}
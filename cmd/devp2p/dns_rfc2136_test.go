@@ -0,0 +1,356 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/miekg/dns"
+)
+
+const (
+	testTSIGKey    = "enrtree-key."
+	testTSIGSecret = "so6ZGir4GPAqINNh9U5c3A=="
+)
+
+// This test deploys trees to an in-process DNS server.
+func TestRFC2136Deploy(t *testing.T) {
+	srv := newTestDNSServer(t, "example.org.")
+	defer srv.close()
+	srv.records["other.example.org."] = []*dns.TXT{newTXTRecord("other.example.org", 3600, "unrelated")}
+
+	c := &rfc2136Client{server: srv.addr}
+	c.setKey(testTSIGKey, dns.HmacSHA256, testTSIGSecret)
+
+	// Initial deployment.
+	tree1 := makeTestTree(t, 1, 5)
+	if err := c.deploy("nodes.example.org", tree1); err != nil {
+		t.Fatal(err)
+	}
+	if c.zone != "example.org." {
+		t.Fatalf("wrong zone %q", c.zone)
+	}
+	srv.checkRecords(t, "nodes.example.org", tree1)
+
+	// Updating the tree replaces the root and deletes stale records.
+	tree2 := makeTestTree(t, 2, 3)
+	if err := c.deploy("nodes.example.org", tree2); err != nil {
+		t.Fatal(err)
+	}
+	srv.checkRecords(t, "nodes.example.org", tree2)
+	if !srv.hasRecord("other.example.org.") {
+		t.Fatal("record outside of tree was deleted")
+	}
+
+	// Nothing is sent when the tree is up to date.
+	updates := srv.updateCount()
+	if err := c.deploy("nodes.example.org", tree2); err != nil {
+		t.Fatal(err)
+	}
+	if srv.updateCount() != updates {
+		t.Fatal("update sent for unchanged tree")
+	}
+}
+
+func TestRFC2136BadKey(t *testing.T) {
+	srv := newTestDNSServer(t, "example.org.")
+	defer srv.close()
+
+	c := &rfc2136Client{server: srv.addr, zone: "example.org."}
+	c.setKey(testTSIGKey, dns.HmacSHA256, "AAAAAAAAAAAAAAAAAAAAAA==")
+	if err := c.deploy("nodes.example.org", makeTestTree(t, 1, 2)); err == nil {
+		t.Fatal("deploy succeeded with wrong TSIG secret")
+	}
+	c.setKey(testTSIGKey, dns.HmacSHA256, testTSIGSecret)
+	if err := c.deploy("nodes.example.com", makeTestTree(t, 1, 2)); err == nil {
+		t.Fatal("deploy succeeded outside of zone")
+	}
+	if srv.updateCount() != 0 {
+		t.Fatal("records changed")
+	}
+}
+
+// This test checks that a generated zone file contains all records of the tree.
+func TestZoneFile(t *testing.T) {
+	tree := makeTestTree(t, 1, 5)
+	records := tree.ToTXT("nodes.example.org")
+	file := filepath.Join(t.TempDir(), "zone")
+	writeZoneFile(file, "nodes.example.org", records)
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	parsed := make(map[string]string)
+	zp := dns.NewZoneParser(f, "", file)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		txt := rr.(*dns.TXT)
+		name := strings.TrimSuffix(txt.Hdr.Name, ".")
+		ttl := uint32(treeNodeTTL)
+		if name == "nodes.example.org" {
+			ttl = rootTTL
+		}
+		if txt.Hdr.Ttl != ttl {
+			t.Errorf("wrong TTL %d for %s", txt.Hdr.Ttl, name)
+		}
+		parsed[name] = strings.Join(txt.Txt, "")
+	}
+	if err := zp.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, records) {
+		t.Fatalf("wrong records in zone file:\n%v\nwant:\n%v", parsed, records)
+	}
+}
+
+func makeTestTree(t *testing.T, seq uint, nodeCount int) *dnsdisc.Tree {
+	t.Helper()
+	nodes := make([]*enode.Node, nodeCount)
+	for i := range nodes {
+		var r enr.Record
+		r.Set(enr.IP(net.IP{127, 0, 0, byte(i + 1)}))
+		if err := enode.SignV4(&r, testKey(t)); err != nil {
+			t.Fatal(err)
+		}
+		n, err := enode.New(enode.ValidSchemes, &r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes[i] = n
+	}
+	tree, err := dnsdisc.MakeTree(seq, nodes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Sign(testKey(t), "nodes.example.org"); err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func testKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// testDNSServer is an authoritative server for a single zone. It supports zone
+// transfers and dynamic updates of TXT records, which require the test TSIG key.
+type testDNSServer struct {
+	srv  *dns.Server
+	addr string
+	zone string
+
+	mu      sync.Mutex
+	records map[string][]*dns.TXT
+	updates int
+}
+
+func newTestDNSServer(t *testing.T, zone string) *testDNSServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testDNSServer{
+		addr:    ln.Addr().String(),
+		zone:    zone,
+		records: make(map[string][]*dns.TXT),
+	}
+	started := make(chan struct{})
+	s.srv = &dns.Server{
+		Listener:          ln,
+		Handler:           s,
+		TsigSecret:        map[string]string{testTSIGKey: testTSIGSecret},
+		NotifyStartedFunc: func() { close(started) },
+		// The default accepts only queries and notifies.
+		MsgAcceptFunc: func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+	}
+	go s.srv.ActivateAndServe()
+	<-started
+	return s
+}
+
+func (s *testDNSServer) close() {
+	s.srv.Shutdown()
+}
+
+func (s *testDNSServer) updateCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updates
+}
+
+func (s *testDNSServer) hasRecord(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records[name]) > 0
+}
+
+func (s *testDNSServer) soa() *dns.SOA {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: s.zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600},
+		Ns:      "ns." + s.zone,
+		Mbox:    "admin." + s.zone,
+		Serial:  uint32(s.updates),
+		Refresh: 3600, Retry: 600, Expire: 86400, Minttl: 60,
+	}
+}
+
+func (s *testDNSServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	authorized := r.IsTsig() != nil && w.TsigStatus() == nil
+	switch {
+	case r.Opcode == dns.OpcodeUpdate:
+		if !authorized {
+			m.Rcode = dns.RcodeRefused
+			break
+		}
+		s.applyUpdate(r.Ns)
+	case len(r.Question) == 1 && r.Question[0].Qtype == dns.TypeAXFR:
+		if !authorized {
+			m.Rcode = dns.RcodeRefused
+			break
+		}
+		rrs := []dns.RR{s.soa()}
+		for _, set := range s.records {
+			for _, rr := range set {
+				rrs = append(rrs, rr)
+			}
+		}
+		ch := make(chan *dns.Envelope, 1)
+		ch <- &dns.Envelope{RR: append(rrs, s.soa())}
+		close(ch)
+		new(dns.Transfer).Out(w, r, ch)
+		return
+	case len(r.Question) == 1 && r.Question[0].Qtype == dns.TypeSOA:
+		if dns.CanonicalName(r.Question[0].Name) == s.zone {
+			m.Answer = []dns.RR{s.soa()}
+		} else {
+			m.Ns = []dns.RR{s.soa()}
+		}
+	default:
+		m.Rcode = dns.RcodeNotImplemented
+	}
+	if tsig := r.IsTsig(); tsig != nil && w.TsigStatus() == nil {
+		m.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsigFudge, time.Now().Unix())
+	}
+	w.WriteMsg(m)
+}
+
+func (s *testDNSServer) applyUpdate(rrs []dns.RR) {
+	s.updates++
+	for _, rr := range rrs {
+		name := dns.CanonicalName(rr.Header().Name)
+		switch {
+		case rr.Header().Class == dns.ClassANY && rr.Header().Rrtype == dns.TypeTXT:
+			delete(s.records, name)
+		case rr.Header().Class == dns.ClassINET:
+			if txt, ok := rr.(*dns.TXT); ok {
+				txt.Hdr.Name = name
+				s.records[name] = append(s.records[name], txt)
+			}
+		}
+	}
+}
+
+// checkRecords verifies that the server has the records of the tree.
+func (s *testDNSServer) checkRecords(t *testing.T, name string, tree *dnsdisc.Tree) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	want := make(map[string]string)
+	for k, v := range tree.ToTXT(name) {
+		want[strings.ToLower(k)] = v
+	}
+	have := make(map[string]string)
+	for k, set := range s.records {
+		k = strings.TrimSuffix(k, ".")
+		if !isSubdomain(k, name) {
+			continue
+		}
+		if len(set) != 1 {
+			t.Fatalf("%d records at %s", len(set), k)
+		}
+		ttl := uint32(treeNodeTTL)
+		if k == name {
+			ttl = rootTTL
+		}
+		if set[0].Hdr.Ttl != ttl {
+			t.Errorf("wrong TTL %d at %s", set[0].Hdr.Ttl, k)
+		}
+		have[k] = strings.Join(set[0].Txt, "")
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong records on server:\n%v\nwant:\n%v", have, want)
+	}
+}
+
+// This test checks that large changes are split into several updates which keep the
+// order of the changes.
+func TestRFC2136SplitUpdates(t *testing.T) {
+	var (
+		c       = &rfc2136Client{zone: "example.org."}
+		value   = strings.Repeat("x", 300)
+		changes []rfc2136Change
+	)
+	for i := 0; i < 300; i++ {
+		changes = append(changes, rfc2136Change{"CREATE", fmt.Sprintf("%03d.nodes.example.org", i), treeNodeTTL, value})
+	}
+	changes = append(changes, rfc2136Change{"UPSERT", "nodes.example.org", rootTTL, value})
+
+	var names []string
+	batches := c.makeUpdates(changes)
+	for _, m := range batches {
+		if size := m.Len(); size > rfc2136UpdateSizeLimit {
+			t.Errorf("update too large: %d bytes", size)
+		}
+		for _, rr := range m.Ns {
+			if rr.Header().Class == dns.ClassINET {
+				names = append(names, strings.TrimSuffix(rr.Header().Name, "."))
+			}
+		}
+	}
+	if len(batches) < 2 {
+		t.Fatalf("changes not split, got %d updates", len(batches))
+	}
+	for i, ch := range changes {
+		if names[i] != ch.name {
+			t.Fatalf("change %d is %s, want %s", i, names[i], ch.name)
+		}
+	}
+}
@@ -17,12 +17,14 @@
 package main
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/keystore"
@@ -41,9 +43,11 @@ var (
 			dnsSyncCommand,
 			dnsSignCommand,
 			dnsTXTCommand,
+			dnsZoneFileCommand,
 			dnsCloudflareCommand,
 			dnsRoute53Command,
 			dnsRoute53NukeCommand,
+			dnsRFC2136Command,
 		},
 	}
 	dnsSyncCommand = cli.Command{
@@ -66,6 +70,12 @@ var (
 		ArgsUsage: "<tree-directory> <output-file>",
 		Action:    dnsToTXT,
 	}
+	dnsZoneFileCommand = cli.Command{
+		Name:      "to-zonefile",
+		Usage:     "Create a zone file with the DNS TXT records of a discovery tree",
+		ArgsUsage: "<tree-directory> <output-file>",
+		Action:    dnsToZoneFile,
+	}
 	dnsCloudflareCommand = cli.Command{
 		Name:      "to-cloudflare",
 		Usage:     "Deploy DNS TXT records to CloudFlare",
@@ -85,6 +95,19 @@ var (
 			route53RegionFlag,
 		},
 	}
+	dnsRFC2136Command = cli.Command{
+		Name:      "to-rfc2136",
+		Usage:     "Deploy DNS TXT records to a DNS server using dynamic updates (RFC 2136)",
+		ArgsUsage: "<tree-directory>",
+		Action:    dnsToRFC2136,
+		Flags: []cli.Flag{
+			rfc2136ServerFlag,
+			rfc2136ZoneFlag,
+			rfc2136TSIGKeyFlag,
+			rfc2136TSIGSecretFlag,
+			rfc2136TSIGAlgorithmFlag,
+		},
+	}
 	dnsRoute53NukeCommand = cli.Command{
 		Name:      "nuke-route53",
 		Usage:     "Deletes DNS TXT records of a subdomain on Amazon Route53",
@@ -216,21 +239,32 @@ func dnsToTXT(ctx *cli.Context) error {
 	return nil
 }
 
-// dnsToCloudflare performs dnsCloudflareCommand.
-func dnsToCloudflare(ctx *cli.Context) error {
-	if ctx.NArg() != 1 {
+// dnsToZoneFile performs dnsZoneFileCommand.
+func dnsToZoneFile(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
 		return fmt.Errorf("need tree definition directory as argument")
 	}
+	output := ctx.Args().Get(1)
+	if output == "" {
+		output = "-" // default to stdout
+	}
 	domain, t, err := loadTreeDefinitionForExport(ctx.Args().Get(0))
 	if err != nil {
 		return err
 	}
-	client := newCloudflareClient(ctx)
-	return client.deploy(domain, t)
+	writeZoneFile(output, domain, t.ToTXT(domain))
+	return nil
 }
 
-// dnsToRoute53 performs dnsRoute53Command.
-func dnsToRoute53(ctx *cli.Context) error {
+// dnsPublisher is implemented by the DNS providers trees can be deployed to.
+type dnsPublisher interface {
+	// deploy replaces the TXT records below name with the records of the tree.
+	deploy(name string, t *dnsdisc.Tree) error
+}
+
+// dnsDeploy loads the tree given as argument and deploys it to the publisher
+// created by newPublisher.
+func dnsDeploy(ctx *cli.Context, newPublisher func(*cli.Context) dnsPublisher) error {
 	if ctx.NArg() != 1 {
 		return fmt.Errorf("need tree definition directory as argument")
 	}
@@ -238,8 +272,22 @@ func dnsToRoute53(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	client := newRoute53Client(ctx)
-	return client.deploy(domain, t)
+	return newPublisher(ctx).deploy(domain, t)
+}
+
+// dnsToCloudflare performs dnsCloudflareCommand.
+func dnsToCloudflare(ctx *cli.Context) error {
+	return dnsDeploy(ctx, func(ctx *cli.Context) dnsPublisher { return newCloudflareClient(ctx) })
+}
+
+// dnsToRoute53 performs dnsRoute53Command.
+func dnsToRoute53(ctx *cli.Context) error {
+	return dnsDeploy(ctx, func(ctx *cli.Context) dnsPublisher { return newRoute53Client(ctx) })
+}
+
+// dnsToRFC2136 performs dnsRFC2136Command.
+func dnsToRFC2136(ctx *cli.Context) error {
+	return dnsDeploy(ctx, func(ctx *cli.Context) dnsPublisher { return newRFC2136Client(ctx) })
 }
 
 // dnsNukeRoute53 performs dnsRoute53NukeCommand.
@@ -400,6 +448,34 @@ func treeDefinitionFiles(directory string) (string, string) {
 	return meta, nodes
 }
 
+// writeZoneFile writes TXT records in zone file format (RFC 1035). Record names
+// are absolute, so the file can be included into any zone file.
+func writeZoneFile(file, domain string, txt map[string]string) {
+	names := make([]string, 0, len(txt))
+	for name := range txt {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "; enrtree %s\n", domain)
+	for _, name := range names {
+		ttl := uint32(treeNodeTTL)
+		if name == domain {
+			ttl = rootTTL
+		}
+		buf.WriteString(newTXTRecord(name, ttl, txt[name]).String())
+		buf.WriteByte('\n')
+	}
+	if file == "-" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		exit(err)
+	}
+}
+
 // writeTXTJSON writes TXT records in JSON format.
 func writeTXTJSON(file string, txt map[string]string) {
 	txtJSON, err := json.MarshalIndent(txt, "", jsonIndent)
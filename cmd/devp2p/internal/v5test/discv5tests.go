@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/internal/utesting"
+	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/discover/v5wire"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
@@ -55,6 +56,8 @@ func (s *Suite) AllTests() []utesting.Test {
 		{Name: "TalkRequest", Fn: s.TestTalkRequest},
 		{Name: "FindnodeZeroDistance", Fn: s.TestFindnodeZeroDistance},
 		{Name: "FindnodeResults", Fn: s.TestFindnodeResults},
+		{Name: "TopicRegistration", Fn: s.TestTopicRegistration},
+		{Name: "TopicInvalidTicket", Fn: s.TestTopicInvalidTicket},
 	}
 }
 
@@ -291,6 +294,73 @@ func (s *Suite) TestFindnodeResults(t *utesting.T) {
 	}
 }
 
+// This test registers a topic using a ticket and checks that the node under test
+// returns the registration for TOPICQUERY.
+func (s *Suite) TestTopicRegistration(t *utesting.T) {
+	conn, l1 := s.listen1(t)
+	defer conn.close()
+	conn.setEndpoint(l1)
+	topic := discover.NewTopic("v5test")
+
+	// Get a ticket.
+	var ticket *v5wire.Ticket
+	switch resp := conn.reqresp(l1, &v5wire.RequestTicket{ReqID: conn.nextReqID(), Topic: topic[:]}).(type) {
+	case *v5wire.Ticket:
+		ticket = resp
+	default:
+		t.Fatal("expected TICKET, got", resp.Name())
+	}
+	wait := time.Duration(ticket.WaitTime) * time.Millisecond
+	if wait > time.Minute {
+		t.Fatalf("ticket wait time %v too long", wait)
+	}
+	t.Logf("waiting %v before registration", wait)
+	time.Sleep(wait)
+
+	// Register.
+	reg := &v5wire.Regtopic{ReqID: conn.nextReqID(), Ticket: ticket.Ticket, ENR: conn.localNode.Node().Record()}
+	switch resp := conn.reqresp(l1, reg).(type) {
+	case *v5wire.Regconfirmation:
+		if !bytes.Equal(resp.ReqID, reg.ReqID) {
+			t.Fatalf("wrong request ID %x in REGCONFIRMATION, want %x", resp.ReqID, reg.ReqID)
+		}
+		if !resp.Registered {
+			t.Fatal("registration refused")
+		}
+	default:
+		t.Fatal("expected REGCONFIRMATION, got", resp.Name())
+	}
+
+	// Check the registration is returned.
+	nodes, err := conn.topicQuery(l1, topic[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range nodes {
+		if n.ID() == conn.localNode.ID() {
+			return
+		}
+	}
+	t.Fatalf("registered node missing in TOPICQUERY result (%d nodes)", len(nodes))
+}
+
+// This test checks that REGTOPIC with an invalid ticket is refused.
+func (s *Suite) TestTopicInvalidTicket(t *utesting.T) {
+	conn, l1 := s.listen1(t)
+	defer conn.close()
+	conn.setEndpoint(l1)
+
+	reg := &v5wire.Regtopic{ReqID: conn.nextReqID(), Ticket: []byte("invalid"), ENR: conn.localNode.Node().Record()}
+	switch resp := conn.reqresp(l1, reg).(type) {
+	case *v5wire.Regconfirmation:
+		if resp.Registered {
+			t.Fatal("registration with invalid ticket accepted")
+		}
+	default:
+		t.Fatal("expected REGCONFIRMATION, got", resp.Name())
+	}
+}
+
 // A bystander is a node whose only purpose is filling a spot in the remote table.
 type bystander struct {
 	dest *enode.Node
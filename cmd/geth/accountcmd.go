@@ -109,6 +109,8 @@ Print a short summary of all accounts`,
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					utils.KeyStoreKDFFlag,
+					utils.KeyStoreCipherFlag,
 				},
 				Description: `
     geth account new
@@ -134,6 +136,8 @@ password to file or expose in any other way.
 					utils.DataDirFlag,
 					utils.KeyStoreDirFlag,
 					utils.LightKDFFlag,
+					utils.KeyStoreKDFFlag,
+					utils.KeyStoreCipherFlag,
 				},
 				Description: `
     geth account update <address>
@@ -152,6 +156,36 @@ For non-interactive use the password can be specified with the --password flag:
 
 Since only one password can be given, only format update can be performed,
 changing your password is only possible interactively.
+`,
+			},
+			{
+				Name:      "rekey",
+				Usage:     "Re-encrypt existing accounts with new key derivation settings",
+				Action:    utils.MigrateFlags(accountRekey),
+				ArgsUsage: "<address> [<address>...]",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+					utils.KeyStoreKDFFlag,
+					utils.KeyStoreCipherFlag,
+				},
+				Description: `
+    geth account rekey --keystore.kdf argon2id <address>
+
+Re-encrypts existing accounts with the key derivation function and cipher given
+by the --keystore.kdf and --keystore.cipher flags, optionally changing the
+password at the same time.
+
+The key is only decrypted in memory. The re-encrypted key file is written next
+to the original one, verified and then atomically moved in its place, so the
+plaintext key never touches the disk.
+
+You are prompted for the current password and a new one; leave the new password
+empty to keep the current one. For non-interactive use the passwords can be
+specified with the --password flag: the first line of the file is the current
+password and the second line, if present, the new one.
 `,
 			},
 			{
@@ -163,6 +197,8 @@ changing your password is only possible interactively.
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					utils.KeyStoreKDFFlag,
+					utils.KeyStoreCipherFlag,
 				},
 				ArgsUsage: "<keyFile>",
 				Description: `
@@ -281,7 +317,12 @@ func accountCreate(ctx *cli.Context) error {
 
 	password := utils.GetPassPhraseWithList("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
 
-	account, err := keystore.StoreKey(keydir, password, scryptN, scryptP)
+	var account accounts.Account
+	if config := keyStoreArgon2Config(&cfg.Node); config != nil {
+		account, err = keystore.StoreKeyWithArgon2(keydir, password, *config)
+	} else {
+		account, err = keystore.StoreKey(keydir, password, scryptN, scryptP)
+	}
 
 	if err != nil {
 		utils.Fatalf("Failed to create account: %v", err)
@@ -315,6 +356,31 @@ func accountUpdate(ctx *cli.Context) error {
 	return nil
 }
 
+// accountRekey re-encrypts accounts with the key derivation settings of the
+// keystore, optionally changing the pass-phrase.
+func accountRekey(ctx *cli.Context) error {
+	if len(ctx.Args()) == 0 {
+		utils.Fatalf("No accounts specified to rekey")
+	}
+	stack, _ := makeConfigNode(ctx)
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+
+	passwords := utils.MakePasswordList(ctx)
+	for _, addr := range ctx.Args() {
+		account, oldPassword := unlockAccount(ks, addr, 0, passwords)
+		newPassword := utils.GetPassPhraseWithList("Please give a new password, or leave it empty to keep the current one.", true, 1, passwords)
+		if newPassword == "" {
+			newPassword = oldPassword
+		}
+		if err := ks.Update(account, oldPassword, newPassword); err != nil {
+			utils.Fatalf("Could not rekey the account: %v", err)
+		}
+		ks.Lock(account.Address)
+		log.Info("Rekeyed account", "address", account.Address.Hex(), "file", account.URL.Path)
+	}
+	return nil
+}
+
 func importWallet(ctx *cli.Context) error {
 	keyfile := ctx.Args().First()
 	if len(keyfile) == 0 {
@@ -24,6 +24,8 @@ import (
 	"testing"
 
 	"github.com/cespare/cp"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
 )
 
 // These tests are 'smoke tests' for the account related
@@ -153,6 +155,37 @@ Repeat password: {{.InputLine "foobar2"}}
 `)
 }
 
+func TestAccountRekey(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	geth := runGeth(t, "account", "rekey",
+		"--datadir", datadir, "--lightkdf", "--keystore.kdf", "argon2id",
+		"f466859ead1932d743d622cb74fc058882e8648a")
+	geth.Expect(`
+Unlocking account f466859ead1932d743d622cb74fc058882e8648a | Attempt 1/3
+!! Unsupported terminal, password will be echoed.
+Password: {{.InputLine "foobar"}}
+Please give a new password, or leave it empty to keep the current one.
+Password: {{.InputLine ""}}
+Repeat password: {{.InputLine ""}}
+`)
+	geth.ExpectExit()
+
+	keyjson, err := ioutil.ReadFile(filepath.Join(datadir, "keystore", "aaa"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := keystore.DecryptKey(keyjson, "foobar")
+	if err != nil {
+		t.Fatalf("failed to decrypt rekeyed key: %v", err)
+	}
+	if key.Address != common.HexToAddress("f466859ead1932d743d622cb74fc058882e8648a") {
+		t.Errorf("rekeyed key address mismatch: %x", key.Address)
+	}
+	if !strings.Contains(string(keyjson), `"kdf":"argon2id"`) {
+		t.Errorf("key file not rekeyed with argon2id: %s", keyjson)
+	}
+}
+
 func TestWalletImport(t *testing.T) {
 	geth := runGeth(t, "wallet", "import", "--lightkdf", "testdata/guswallet.json")
 	defer geth.ExpectExit()
@@ -29,6 +29,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts/external"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/accounts/pkcs11"
 	"github.com/ethereum/go-ethereum/accounts/scwallet"
 	"github.com/ethereum/go-ethereum/accounts/usbwallet"
 	"github.com/ethereum/go-ethereum/cmd/utils"
@@ -259,6 +260,22 @@ func deprecated(field string) bool {
 	}
 }
 
+// keyStoreArgon2Config returns the argon2id parameters new key files should be
+// encrypted with, or nil if the node is configured to use scrypt.
+func keyStoreArgon2Config(conf *node.Config) *keystore.Argon2Config {
+	if conf.KeyStoreKDF != "argon2id" {
+		return nil
+	}
+	config := keystore.StandardArgon2Config
+	if conf.UseLightweightKDF {
+		config = keystore.LightArgon2Config
+	}
+	if conf.KeyStoreCipher != "" {
+		config.Cipher = conf.KeyStoreCipher
+	}
+	return &config
+}
+
 func setAccountManagerBackends(stack *node.Node) error {
 	conf := stack.Config()
 	am := stack.AccountManager()
@@ -285,7 +302,11 @@ func setAccountManagerBackends(stack *node.Node) error {
 	// If/when we implement some form of lockfile for USB and keystore wallets,
 	// we can have both, but it's very confusing for the user to see the same
 	// accounts in both externally and locally, plus very racey.
-	am.AddBackend(keystore.NewKeyStore(keydir, scryptN, scryptP))
+	if config := keyStoreArgon2Config(conf); config != nil {
+		am.AddBackend(keystore.NewKeyStoreWithArgon2(keydir, *config))
+	} else {
+		am.AddBackend(keystore.NewKeyStore(keydir, scryptN, scryptP))
+	}
 	if conf.USB {
 		// Start a USB hub for Ledger hardware wallets
 		if ledgerhub, err := usbwallet.NewLedgerHub(); err != nil {
@@ -314,6 +335,15 @@ func setAccountManagerBackends(stack *node.Node) error {
 			am.AddBackend(schub)
 		}
 	}
+	if len(conf.PKCS11Module) > 0 {
+		// Start a hardware security module backend
+		config := pkcs11.Config{Module: conf.PKCS11Module, Token: conf.PKCS11Token, PIN: conf.PKCS11PIN}
+		if hsm, err := pkcs11.NewBackend(config); err != nil {
+			log.Warn(fmt.Sprintf("Failed to start PKCS#11 backend, disabling: %v", err))
+		} else {
+			am.AddBackend(hsm)
+		}
+	}
 
 	return nil
 }
@@ -72,6 +72,7 @@ Remove blockchain and state databases`,
 			dbImportCmd,
 			dbExportCmd,
 			dbMetadataCmd,
+			dbLogIndexCmd,
 		},
 	}
 	dbInspectCmd = cli.Command{
@@ -251,6 +252,27 @@ WARNING: This is a low-level operation which may cause database corruption!`,
 		},
 		Description: "Shows metadata about the chain status.",
 	}
+	dbLogIndexCmd = cli.Command{
+		Action:    utils.MigrateFlags(dbLogIndex),
+		Name:      "logindex",
+		Usage:     "Manage the log index used to serve eth_getLogs",
+		ArgsUsage: "<enable|disable|rebuild>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.RopstenFlag,
+			utils.SepoliaFlag,
+			utils.RinkebyFlag,
+			utils.GoerliFlag,
+		},
+		Description: `This command manages the log index, which maps every log address and topic
+to the blocks containing it. Once enabled, the index is built in the background on
+the next start and is used in favour of the bloom bits to answer log queries.
+
+'disable' stops maintaining the index and deletes it, 'rebuild' deletes it and
+starts indexing from scratch.`,
+	}
 )
 
 func removeDB(ctx *cli.Context) error {
@@ -704,6 +726,35 @@ func exportChaindata(ctx *cli.Context) error {
 	return utils.ExportChaindata(ctx.Args().Get(1), kind, exporter(db), stop)
 }
 
+// dbLogIndex enables, disables or rebuilds the log index.
+func dbLogIndex(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	switch ctx.Args().Get(0) {
+	case "enable":
+		rawdb.WriteLogIndexEnabled(db)
+		log.Info("Log index enabled, indexing will start on the next launch")
+	case "disable":
+		rawdb.DeleteLogIndexEnabled(db)
+		rawdb.DeleteLogIndex(db)
+		log.Info("Log index disabled and deleted")
+	case "rebuild":
+		rawdb.DeleteLogIndex(db)
+		rawdb.WriteLogIndexEnabled(db)
+		log.Info("Log index deleted, reindexing will start on the next launch")
+	default:
+		return fmt.Errorf("unknown action %q, required arguments: %v", ctx.Args().Get(0), ctx.Command.ArgsUsage)
+	}
+	return nil
+}
+
 func showMetaData(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 	defer stack.Close()
@@ -743,6 +794,7 @@ func showMetaData(ctx *cli.Context) error {
 		{"snapshotRoot", fmt.Sprintf("%v", rawdb.ReadSnapshotRoot(db))},
 		{"txIndexTail", pp(rawdb.ReadTxIndexTail(db))},
 		{"fastTxLookupLimit", pp(rawdb.ReadFastTxLookupLimit(db))},
+		{"logIndexEnabled", fmt.Sprintf("%v", rawdb.ReadLogIndexEnabled(db))},
 	}...)
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader([]string{"Field", "Value"})
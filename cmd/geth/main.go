@@ -36,6 +36,7 @@ import (
 	"github.com/ethereum/go-ethereum/internal/debug"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/internal/tracing"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/node"
@@ -71,6 +72,9 @@ var (
 		utils.NoUSBFlag,
 		utils.USBFlag,
 		utils.SmartCardDaemonPathFlag,
+		utils.PKCS11ModuleFlag,
+		utils.PKCS11TokenFlag,
+		utils.PKCS11PINFileFlag,
 		utils.OverrideArrowGlacierFlag,
 		utils.OverrideTerminalTotalDifficulty,
 		utils.EthashCacheDirFlag,
@@ -103,6 +107,8 @@ var (
 		utils.LightMaxPeersFlag,
 		utils.LightNoPruneFlag,
 		utils.LightKDFFlag,
+		utils.KeyStoreKDFFlag,
+		utils.KeyStoreCipherFlag,
 		utils.UltraLightServersFlag,
 		utils.UltraLightFractionFlag,
 		utils.UltraLightOnlyAnnounceFlag,
@@ -121,6 +127,8 @@ var (
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
 		utils.MaxPendingPeersFlag,
+		utils.MaxEgressFlag,
+		utils.MaxPeerEgressFlag,
 		utils.MiningEnabledFlag,
 		utils.MinerThreadsFlag,
 		utils.MinerNotifyFlag,
@@ -132,12 +140,21 @@ var (
 		utils.MinerRecommitIntervalFlag,
 		utils.MinerNoVerifyFlag,
 		utils.NATFlag,
+		utils.NATRelayFlag,
+		utils.NATRelayServiceFlag,
+		utils.NATRelaysFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
+		utils.QUICFlag,
+		utils.QUICPortFlag,
 		utils.NetrestrictFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
 		utils.DNSDiscoveryFlag,
+		utils.SentryFlag,
+		utils.PrivatePeersFlag,
+		utils.SentriesFlag,
+		utils.ActiveSentriesFlag,
 		utils.MainnetFlag,
 		utils.DeveloperFlag,
 		utils.DeveloperPeriodFlag,
@@ -169,8 +186,14 @@ var (
 		utils.GraphQLEnabledFlag,
 		utils.GraphQLCORSDomainFlag,
 		utils.GraphQLVirtualHostsFlag,
+		utils.GraphQLMaxDepthFlag,
+		utils.GraphQLMaxComplexityFlag,
+		utils.GraphQLPersistedQueriesFlag,
+		utils.GraphQLPersistedOnlyFlag,
+		utils.GraphQLTracingFlag,
 		utils.HTTPApiFlag,
 		utils.HTTPPathPrefixFlag,
+		utils.HTTPGRPCEnabledFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
@@ -183,6 +206,16 @@ var (
 		utils.RPCGlobalGasCapFlag,
 		utils.RPCGlobalEVMTimeoutFlag,
 		utils.RPCGlobalTxFeeCapFlag,
+		utils.RPCLogsRangeCapFlag,
+		utils.RPCLogsResultCapFlag,
+		utils.RPCBatchRequestLimitFlag,
+		utils.RPCBatchResponseMaxSizeFlag,
+		utils.RPCRateLimitFlag,
+		utils.RPCRateBurstFlag,
+		utils.RPCRateCostsFlag,
+		utils.RPCJWTSecretFlag,
+		utils.RPCRequireCredentialsFlag,
+		utils.RPCAccessLogFlag,
 		utils.AllowUnprotectedTxs,
 	}
 
@@ -202,6 +235,12 @@ var (
 		utils.MetricsInfluxDBBucketFlag,
 		utils.MetricsInfluxDBOrganizationFlag,
 	}
+
+	tracingFlags = []cli.Flag{
+		utils.TracingEnabledFlag,
+		utils.TracingEndpointFlag,
+		utils.TracingSampleRatioFlag,
+	}
 )
 
 func init() {
@@ -248,11 +287,13 @@ func init() {
 	app.Flags = append(app.Flags, consoleFlags...)
 	app.Flags = append(app.Flags, debug.Flags...)
 	app.Flags = append(app.Flags, metricsFlags...)
+	app.Flags = append(app.Flags, tracingFlags...)
 
 	app.Before = func(ctx *cli.Context) error {
 		return debug.Setup(ctx)
 	}
 	app.After = func(ctx *cli.Context) error {
+		tracing.Stop() // Exports the spans still queued.
 		debug.Exit()
 		prompt.Stdin.Close() // Resets terminal mode.
 		return nil
@@ -311,6 +352,9 @@ func prepare(ctx *cli.Context) {
 	// Start metrics export if enabled
 	utils.SetupMetrics(ctx)
 
+	// Start trace export if enabled
+	utils.SetupTracing(ctx)
+
 	// Start system runtime metrics collection
 	go metrics.CollectProcessMetrics(3 * time.Second)
 }
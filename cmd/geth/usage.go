@@ -40,6 +40,9 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.KeyStoreDirFlag,
 			utils.USBFlag,
 			utils.SmartCardDaemonPathFlag,
+			utils.PKCS11ModuleFlag,
+			utils.PKCS11TokenFlag,
+			utils.PKCS11PINFileFlag,
 			utils.NetworkIdFlag,
 			utils.MainnetFlag,
 			utils.GoerliFlag,
@@ -53,6 +56,8 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.EthStatsURLFlag,
 			utils.IdentityFlag,
 			utils.LightKDFFlag,
+			utils.KeyStoreKDFFlag,
+			utils.KeyStoreCipherFlag,
 			utils.WhitelistFlag,
 		},
 	},
@@ -140,6 +145,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.HTTPPortFlag,
 			utils.HTTPApiFlag,
 			utils.HTTPPathPrefixFlag,
+			utils.HTTPGRPCEnabledFlag,
 			utils.HTTPCORSDomainFlag,
 			utils.HTTPVirtualHostsFlag,
 			utils.WSEnabledFlag,
@@ -151,9 +157,24 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.GraphQLEnabledFlag,
 			utils.GraphQLCORSDomainFlag,
 			utils.GraphQLVirtualHostsFlag,
+			utils.GraphQLMaxDepthFlag,
+			utils.GraphQLMaxComplexityFlag,
+			utils.GraphQLPersistedQueriesFlag,
+			utils.GraphQLPersistedOnlyFlag,
+			utils.GraphQLTracingFlag,
 			utils.RPCGlobalGasCapFlag,
 			utils.RPCGlobalEVMTimeoutFlag,
 			utils.RPCGlobalTxFeeCapFlag,
+			utils.RPCLogsRangeCapFlag,
+			utils.RPCLogsResultCapFlag,
+			utils.RPCBatchRequestLimitFlag,
+			utils.RPCBatchResponseMaxSizeFlag,
+			utils.RPCRateLimitFlag,
+			utils.RPCRateBurstFlag,
+			utils.RPCRateCostsFlag,
+			utils.RPCJWTSecretFlag,
+			utils.RPCRequireCredentialsFlag,
+			utils.RPCAccessLogFlag,
 			utils.AllowUnprotectedTxs,
 			utils.JSpathFlag,
 			utils.ExecFlag,
@@ -165,12 +186,23 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 		Flags: []cli.Flag{
 			utils.BootnodesFlag,
 			utils.DNSDiscoveryFlag,
+			utils.SentryFlag,
+			utils.PrivatePeersFlag,
+			utils.SentriesFlag,
+			utils.ActiveSentriesFlag,
 			utils.ListenPortFlag,
 			utils.MaxPeersFlag,
 			utils.MaxPendingPeersFlag,
+			utils.MaxEgressFlag,
+			utils.MaxPeerEgressFlag,
 			utils.NATFlag,
+			utils.NATRelayFlag,
+			utils.NATRelayServiceFlag,
+			utils.NATRelaysFlag,
 			utils.NoDiscoverFlag,
 			utils.DiscoveryV5Flag,
+			utils.QUICFlag,
+			utils.QUICPortFlag,
 			utils.NetrestrictFlag,
 			utils.NodeKeyFileFlag,
 			utils.NodeKeyHexFlag,
@@ -217,6 +249,10 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 		Name:  "METRICS AND STATS",
 		Flags: metricsFlags,
 	},
+	{
+		Name:  "TRACING",
+		Flags: tracingFlags,
+	},
 	{
 		Name: "ALIASED (deprecated)",
 		Flags: []cli.Flag{
@@ -55,6 +55,7 @@ import (
 	"github.com/ethereum/go-ethereum/graphql"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/internal/tracing"
 	"github.com/ethereum/go-ethereum/les"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
@@ -66,6 +67,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
+	"github.com/ethereum/go-ethereum/p2p/quic"
 	"github.com/ethereum/go-ethereum/params"
 	pcsclite "github.com/gballet/go-libpcsclite"
 	gopsutil "github.com/shirou/gopsutil/mem"
@@ -135,6 +137,18 @@ var (
 		Usage: "Path to the smartcard daemon (pcscd) socket file",
 		Value: pcsclite.PCSCDSockName,
 	}
+	PKCS11ModuleFlag = cli.StringFlag{
+		Name:  "pkcs11.module",
+		Usage: "Path to the PKCS#11 module of a hardware security module holding signing keys",
+	}
+	PKCS11TokenFlag = cli.StringFlag{
+		Name:  "pkcs11.token",
+		Usage: "Label of the PKCS#11 token holding the signing keys",
+	}
+	PKCS11PINFileFlag = cli.StringFlag{
+		Name:  "pkcs11.pinfile",
+		Usage: "File containing the user PIN of the PKCS#11 token",
+	}
 	NetworkIdFlag = cli.Uint64Flag{
 		Name:  "networkid",
 		Usage: "Explicitly set network id (integer)(For testnets: use --ropsten, --rinkeby, --goerli instead)",
@@ -236,6 +250,16 @@ var (
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
 	}
+	KeyStoreKDFFlag = cli.StringFlag{
+		Name:  "keystore.kdf",
+		Usage: `Key derivation function for new key files ("scrypt" or "argon2id")`,
+		Value: "scrypt",
+	}
+	KeyStoreCipherFlag = cli.StringFlag{
+		Name:  "keystore.cipher",
+		Usage: `Cipher for new argon2id key files ("aes-256-gcm" or "aes-128-ctr")`,
+		Value: keystore.CipherAES256GCM,
+	}
 	WhitelistFlag = cli.StringFlag{
 		Name:  "whitelist",
 		Usage: "Comma separated block number-to-hash mappings to enforce (<number>=<hash>)",
@@ -517,6 +541,48 @@ var (
 		Usage: "Sets a cap on transaction fee (in ether) that can be sent via the RPC APIs (0 = no cap)",
 		Value: ethconfig.Defaults.RPCTxFeeCap,
 	}
+	RPCLogsRangeCapFlag = cli.Uint64Flag{
+		Name:  "rpc.logs.maxrange",
+		Usage: "Sets a cap on the number of blocks eth_getLogs can query (0=infinite)",
+		Value: ethconfig.Defaults.RPCLogsRangeCap,
+	}
+	RPCLogsResultCapFlag = cli.Uint64Flag{
+		Name:  "rpc.logs.maxresults",
+		Usage: "Sets a cap on the number of logs eth_getLogs can return (0=infinite)",
+		Value: ethconfig.Defaults.RPCLogsResultCap,
+	}
+	RPCBatchRequestLimitFlag = cli.IntFlag{
+		Name:  "rpc.batch.maxitems",
+		Usage: "Maximum number of calls in a batch request over HTTP and WebSocket (0=infinite)",
+	}
+	RPCBatchResponseMaxSizeFlag = cli.IntFlag{
+		Name:  "rpc.batch.maxresponse",
+		Usage: "Maximum number of response bytes of a request over HTTP and WebSocket, summed across a batch (0=infinite)",
+	}
+	RPCRateLimitFlag = cli.Float64Flag{
+		Name:  "rpc.ratelimit",
+		Usage: "Request tokens credited to each HTTP and WebSocket client per second (0=no rate limit)",
+	}
+	RPCRateBurstFlag = cli.Float64Flag{
+		Name:  "rpc.ratelimit.burst",
+		Usage: "Maximum number of request tokens a client can accumulate (default = one second worth of tokens)",
+	}
+	RPCRateCostsFlag = cli.StringFlag{
+		Name:  "rpc.ratelimit.costs",
+		Usage: "Comma separated request token costs per API namespace, e.g. debug=10,eth=1 (default = 1)",
+	}
+	RPCJWTSecretFlag = cli.StringFlag{
+		Name:  "rpc.jwtsecret",
+		Usage: "Path to a hex encoded secret verifying HS256 bearer tokens, identifying clients by token subject instead of IP",
+	}
+	RPCRequireCredentialsFlag = cli.BoolFlag{
+		Name:  "rpc.requirecredentials",
+		Usage: "Reject HTTP, WebSocket and gRPC calls without an API key or valid bearer token",
+	}
+	RPCAccessLogFlag = cli.BoolFlag{
+		Name:  "rpc.accesslog",
+		Usage: "Log every call served over HTTP, WebSocket and gRPC with its duration, response size and error code",
+	}
 	// Logging and debug settings
 	EthStatsURLFlag = cli.StringFlag{
 		Name:  "ethstats",
@@ -568,6 +634,10 @@ var (
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: "",
 	}
+	HTTPGRPCEnabledFlag = cli.BoolFlag{
+		Name:  "http.grpc",
+		Usage: "Enable JSON-RPC over gRPC-compatible HTTP/2 streams on the HTTP-RPC server",
+	}
 	HTTPPathPrefixFlag = cli.StringFlag{
 		Name:  "http.rpcprefix",
 		Usage: "HTTP path path prefix on which JSON-RPC is served. Use '/' to serve on all paths.",
@@ -587,6 +657,28 @@ var (
 		Usage: "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
 		Value: strings.Join(node.DefaultConfig.GraphQLVirtualHosts, ","),
 	}
+	GraphQLMaxDepthFlag = cli.IntFlag{
+		Name:  "graphql.maxdepth",
+		Usage: "Maximum nesting of fields in a GraphQL query (0 = unlimited)",
+		Value: node.DefaultConfig.GraphQLMaxDepth,
+	}
+	GraphQLMaxComplexityFlag = cli.IntFlag{
+		Name:  "graphql.maxcomplexity",
+		Usage: "Maximum estimated cost of a GraphQL query, counting list elements (0 = unlimited)",
+		Value: node.DefaultConfig.GraphQLMaxComplexity,
+	}
+	GraphQLPersistedQueriesFlag = cli.StringFlag{
+		Name:  "graphql.persistedqueries",
+		Usage: "JSON file mapping IDs to GraphQL queries which clients can execute by ID or SHA-256 hash",
+	}
+	GraphQLPersistedOnlyFlag = cli.BoolFlag{
+		Name:  "graphql.persistedonly",
+		Usage: "Reject GraphQL queries which aren't in the persisted queries file",
+	}
+	GraphQLTracingFlag = cli.BoolFlag{
+		Name:  "graphql.tracing",
+		Usage: "Enable the GraphQL trace field, which re-executes transactions with arbitrary tracers",
+	}
 	WSEnabledFlag = cli.BoolFlag{
 		Name:  "ws",
 		Usage: "Enable the WS-RPC server",
@@ -640,6 +732,14 @@ var (
 		Usage: "Maximum number of pending connection attempts (defaults used if set to 0)",
 		Value: node.DefaultConfig.P2P.MaxPendingPeers,
 	}
+	MaxEgressFlag = cli.IntFlag{
+		Name:  "maxegress",
+		Usage: "Maximum outbound bandwidth of protocol messages to all peers in kB/s (0 = unlimited)",
+	}
+	MaxPeerEgressFlag = cli.IntFlag{
+		Name:  "maxpeeregress",
+		Usage: "Maximum outbound bandwidth of protocol messages to each peer in kB/s (0 = unlimited)",
+	}
 	ListenPortFlag = cli.IntFlag{
 		Name:  "port",
 		Usage: "Network listening port",
@@ -663,6 +763,18 @@ var (
 		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>)",
 		Value: "any",
 	}
+	NATRelayFlag = cli.BoolFlag{
+		Name:  "nat.relay",
+		Usage: "Dials nodes behind NAT through the relays in their node records, trying hole punching first if --v5disc and --quic are set",
+	}
+	NATRelayServiceFlag = cli.BoolFlag{
+		Name:  "nat.relayservice",
+		Usage: "Relays connections to nodes behind NAT which are connected to this node",
+	}
+	NATRelaysFlag = cli.StringFlag{
+		Name:  "nat.relays",
+		Usage: "Comma separated enode URLs of relays through which this node can be reached",
+	}
 	NoDiscoverFlag = cli.BoolFlag{
 		Name:  "nodiscover",
 		Usage: "Disables the peer discovery mechanism (manual peer addition)",
@@ -671,6 +783,15 @@ var (
 		Name:  "v5disc",
 		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
 	}
+	QUICFlag = cli.BoolFlag{
+		Name:  "quic",
+		Usage: "Enables the experimental QUIC transport",
+	}
+	QUICPortFlag = cli.IntFlag{
+		Name:  "quic.port",
+		Usage: "QUIC listening port (UDP)",
+		Value: 30304,
+	}
 	NetrestrictFlag = cli.StringFlag{
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
@@ -679,6 +800,22 @@ var (
 		Name:  "discovery.dns",
 		Usage: "Sets DNS discovery entry points (use \"\" to disable DNS)",
 	}
+	SentryFlag = cli.BoolFlag{
+		Name:  "p2p.sentry",
+		Usage: "Runs the node as a sentry relaying for the peers given by --p2p.privatepeers",
+	}
+	PrivatePeersFlag = cli.StringFlag{
+		Name:  "p2p.privatepeers",
+		Usage: "Comma separated enode URLs of the private peers of a sentry, which are never advertised",
+	}
+	SentriesFlag = cli.StringFlag{
+		Name:  "p2p.sentries",
+		Usage: "Comma separated enode URLs of sentries; the node connects only to these (validator mode)",
+	}
+	ActiveSentriesFlag = cli.IntFlag{
+		Name:  "p2p.activesentries",
+		Usage: "Number of sentries to stay connected to, the others are standby (0 = all)",
+	}
 
 	// ATM the url is left to the user and deployment to
 	JSpathFlag = DirectoryFlag{
@@ -790,6 +927,22 @@ var (
 		Value: metrics.DefaultConfig.InfluxDBOrganization,
 	}
 
+	// Tracing flags
+	TracingEnabledFlag = cli.BoolFlag{
+		Name:  "tracing",
+		Usage: "Enable recording of OpenTelemetry trace spans for RPC calls, EVM execution and block import",
+	}
+	TracingEndpointFlag = cli.StringFlag{
+		Name:  "tracing.endpoint",
+		Usage: "OTLP/HTTP collector endpoint to export trace spans to",
+		Value: tracing.DefaultConfig.Endpoint,
+	}
+	TracingSampleRatioFlag = cli.Float64Flag{
+		Name:  "tracing.sampleratio",
+		Usage: "Fraction of traces started by this node which are recorded (0-1)",
+		Value: tracing.DefaultConfig.SampleRatio,
+	}
+
 	CatalystFlag = cli.BoolFlag{
 		Name:  "catalyst",
 		Usage: "Catalyst mode (eth2 integration testing)",
@@ -886,6 +1039,52 @@ func setBootstrapNodes(ctx *cli.Context, cfg *p2p.Config) {
 	}
 }
 
+// setSentryMode configures the sentry or validator mode from the command line flags.
+func setSentryMode(ctx *cli.Context, cfg *p2p.Config) {
+	CheckExclusive(ctx, SentryFlag, SentriesFlag)
+	if ctx.GlobalBool(SentryFlag.Name) {
+		urls := SplitAndTrim(ctx.GlobalString(PrivatePeersFlag.Name))
+		if len(urls) == 0 {
+			Fatalf("Option %q requires %q", SentryFlag.Name, PrivatePeersFlag.Name)
+		}
+		cfg.PrivatePeers = parseNodes(PrivatePeersFlag.Name, urls)
+	}
+	if ctx.GlobalIsSet(SentriesFlag.Name) {
+		cfg.Sentries = parseNodes(SentriesFlag.Name, SplitAndTrim(ctx.GlobalString(SentriesFlag.Name)))
+		cfg.ActiveSentries = ctx.GlobalInt(ActiveSentriesFlag.Name)
+		// Validators must not reveal themselves through discovery.
+		cfg.NoDiscovery = true
+		cfg.DiscoveryV5 = false
+		cfg.QUIC = nil
+	}
+}
+
+// setQUIC enables the QUIC transport if requested by the user.
+func setQUIC(ctx *cli.Context, cfg *p2p.Config) {
+	if !ctx.GlobalBool(QUICFlag.Name) {
+		return
+	}
+	transport, err := quic.NewTransport()
+	if err != nil {
+		Fatalf("Option %q: %v", QUICFlag.Name, err)
+	}
+	cfg.QUIC = transport
+	cfg.QUICListenAddr = fmt.Sprintf(":%d", ctx.GlobalInt(QUICPortFlag.Name))
+}
+
+// parseNodes parses the enode URLs given to a command line flag.
+func parseNodes(flag string, urls []string) []*enode.Node {
+	nodes := make([]*enode.Node, 0, len(urls))
+	for _, url := range urls {
+		node, err := enode.Parse(enode.ValidSchemes, url)
+		if err != nil {
+			Fatalf("Option %q: invalid enode %q: %v", flag, url, err)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
 // setBootstrapNodesV5 creates a list of bootstrap nodes from the command line
 // flags, reverting to pre-configured ones if none have been specified.
 func setBootstrapNodesV5(ctx *cli.Context, cfg *p2p.Config) {
@@ -927,6 +1126,11 @@ func setNAT(ctx *cli.Context, cfg *p2p.Config) {
 		}
 		cfg.NAT = natif
 	}
+	cfg.Relay = ctx.GlobalBool(NATRelayFlag.Name)
+	cfg.RelayService = ctx.GlobalBool(NATRelayServiceFlag.Name)
+	if ctx.GlobalIsSet(NATRelaysFlag.Name) {
+		cfg.Relays = parseNodes(NATRelaysFlag.Name, SplitAndTrim(ctx.GlobalString(NATRelaysFlag.Name)))
+	}
 }
 
 // SplitAndTrim splits input separated by a comma
@@ -970,11 +1174,54 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(HTTPPathPrefixFlag.Name) {
 		cfg.HTTPPathPrefix = ctx.GlobalString(HTTPPathPrefixFlag.Name)
 	}
+	if ctx.GlobalIsSet(HTTPGRPCEnabledFlag.Name) {
+		cfg.HTTPGRPCEnabled = ctx.GlobalBool(HTTPGRPCEnabledFlag.Name)
+	}
 	if ctx.GlobalIsSet(AllowUnprotectedTxs.Name) {
 		cfg.AllowUnprotectedTxs = ctx.GlobalBool(AllowUnprotectedTxs.Name)
 	}
 }
 
+// setRPCLimits configures the request limits of the HTTP and WebSocket RPC
+// endpoints from the set command line flags.
+func setRPCLimits(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(RPCBatchRequestLimitFlag.Name) {
+		cfg.BatchRequestLimit = ctx.GlobalInt(RPCBatchRequestLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCBatchResponseMaxSizeFlag.Name) {
+		cfg.BatchResponseMaxSize = ctx.GlobalInt(RPCBatchResponseMaxSizeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCRateLimitFlag.Name) {
+		cfg.RPCRateLimit = ctx.GlobalFloat64(RPCRateLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCRateBurstFlag.Name) {
+		cfg.RPCRateBurst = ctx.GlobalFloat64(RPCRateBurstFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCRateCostsFlag.Name) {
+		cfg.RPCRateCosts = make(map[string]float64)
+		for _, entry := range SplitAndTrim(ctx.GlobalString(RPCRateCostsFlag.Name)) {
+			parts := strings.SplitN(entry, "=", 2)
+			if len(parts) != 2 {
+				Fatalf("Invalid --%s entry %q, want namespace=cost", RPCRateCostsFlag.Name, entry)
+			}
+			cost, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+			if err != nil {
+				Fatalf("Invalid --%s cost for %q: %v", RPCRateCostsFlag.Name, parts[0], err)
+			}
+			cfg.RPCRateCosts[strings.TrimSpace(parts[0])] = cost
+		}
+	}
+	if ctx.GlobalIsSet(RPCJWTSecretFlag.Name) {
+		cfg.RPCJWTSecret = ctx.GlobalString(RPCJWTSecretFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCRequireCredentialsFlag.Name) {
+		cfg.RPCRequireCredentials = ctx.GlobalBool(RPCRequireCredentialsFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCAccessLogFlag.Name) {
+		cfg.RPCAccessLog = ctx.GlobalBool(RPCAccessLogFlag.Name)
+	}
+}
+
 // setGraphQL creates the GraphQL listener interface string from the set
 // command line flags, returning empty if the GraphQL endpoint is disabled.
 func setGraphQL(ctx *cli.Context, cfg *node.Config) {
@@ -984,6 +1231,21 @@ func setGraphQL(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(GraphQLVirtualHostsFlag.Name) {
 		cfg.GraphQLVirtualHosts = SplitAndTrim(ctx.GlobalString(GraphQLVirtualHostsFlag.Name))
 	}
+	if ctx.GlobalIsSet(GraphQLMaxDepthFlag.Name) {
+		cfg.GraphQLMaxDepth = ctx.GlobalInt(GraphQLMaxDepthFlag.Name)
+	}
+	if ctx.GlobalIsSet(GraphQLMaxComplexityFlag.Name) {
+		cfg.GraphQLMaxComplexity = ctx.GlobalInt(GraphQLMaxComplexityFlag.Name)
+	}
+	if ctx.GlobalIsSet(GraphQLPersistedQueriesFlag.Name) {
+		cfg.GraphQLPersistedQueries = ctx.GlobalString(GraphQLPersistedQueriesFlag.Name)
+	}
+	if ctx.GlobalIsSet(GraphQLPersistedOnlyFlag.Name) {
+		cfg.GraphQLPersistedOnly = ctx.GlobalBool(GraphQLPersistedOnlyFlag.Name)
+	}
+	if ctx.GlobalIsSet(GraphQLTracingFlag.Name) {
+		cfg.GraphQLTracing = ctx.GlobalBool(GraphQLTracingFlag.Name)
+	}
 }
 
 // setWS creates the WebSocket RPC listener interface string from the set
@@ -1179,6 +1441,12 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	if ctx.GlobalIsSet(MaxPendingPeersFlag.Name) {
 		cfg.MaxPendingPeers = ctx.GlobalInt(MaxPendingPeersFlag.Name)
 	}
+	if ctx.GlobalIsSet(MaxEgressFlag.Name) {
+		cfg.MaxEgress = ctx.GlobalInt(MaxEgressFlag.Name) * 1024
+	}
+	if ctx.GlobalIsSet(MaxPeerEgressFlag.Name) {
+		cfg.MaxPeerEgress = ctx.GlobalInt(MaxPeerEgressFlag.Name) * 1024
+	}
 	if ctx.GlobalIsSet(NoDiscoverFlag.Name) || lightClient {
 		cfg.NoDiscovery = true
 	}
@@ -1200,6 +1468,8 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		}
 		cfg.NetRestrict = list
 	}
+	setSentryMode(ctx, cfg)
+	setQUIC(ctx, cfg)
 
 	if ctx.GlobalBool(DeveloperFlag.Name) {
 		// --dev mode can't use p2p networking.
@@ -1218,9 +1488,11 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setHTTP(ctx, cfg)
 	setGraphQL(ctx, cfg)
 	setWS(ctx, cfg)
+	setRPCLimits(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 	setDataDir(ctx, cfg)
 	setSmartCard(ctx, cfg)
+	setPKCS11(ctx, cfg)
 
 	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
 		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
@@ -1235,6 +1507,22 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(LightKDFFlag.Name) {
 		cfg.UseLightweightKDF = ctx.GlobalBool(LightKDFFlag.Name)
 	}
+	if ctx.GlobalIsSet(KeyStoreKDFFlag.Name) {
+		cfg.KeyStoreKDF = ctx.GlobalString(KeyStoreKDFFlag.Name)
+	}
+	if ctx.GlobalIsSet(KeyStoreCipherFlag.Name) {
+		cfg.KeyStoreCipher = ctx.GlobalString(KeyStoreCipherFlag.Name)
+	}
+	switch cfg.KeyStoreKDF {
+	case "", "scrypt", "argon2id":
+	default:
+		Fatalf("Invalid keystore KDF %q, must be scrypt or argon2id", cfg.KeyStoreKDF)
+	}
+	switch cfg.KeyStoreCipher {
+	case "", keystore.CipherAES128CTR, keystore.CipherAES256GCM:
+	default:
+		Fatalf("Invalid keystore cipher %q, must be %s or %s", cfg.KeyStoreCipher, keystore.CipherAES256GCM, keystore.CipherAES128CTR)
+	}
 	if ctx.GlobalIsSet(NoUSBFlag.Name) || cfg.NoUSB {
 		log.Warn("Option nousb is deprecated and USB is deactivated by default. Use --usb to enable")
 	}
@@ -1266,6 +1554,24 @@ func setSmartCard(ctx *cli.Context, cfg *node.Config) {
 	cfg.SmartCardDaemonPath = path
 }
 
+// setPKCS11 configures the hardware security module backend from the command
+// line flags.
+func setPKCS11(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(PKCS11ModuleFlag.Name) {
+		cfg.PKCS11Module = ctx.GlobalString(PKCS11ModuleFlag.Name)
+	}
+	if ctx.GlobalIsSet(PKCS11TokenFlag.Name) {
+		cfg.PKCS11Token = ctx.GlobalString(PKCS11TokenFlag.Name)
+	}
+	if ctx.GlobalIsSet(PKCS11PINFileFlag.Name) {
+		pin, err := ioutil.ReadFile(ctx.GlobalString(PKCS11PINFileFlag.Name))
+		if err != nil {
+			Fatalf("Failed to read PKCS#11 PIN file: %v", err)
+		}
+		cfg.PKCS11PIN = strings.TrimRight(string(pin), "\r\n")
+	}
+}
+
 func setDataDir(ctx *cli.Context, cfg *node.Config) {
 	switch {
 	case ctx.GlobalIsSet(DataDirFlag.Name):
@@ -1595,7 +1901,13 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(RPCGlobalTxFeeCapFlag.Name) {
 		cfg.RPCTxFeeCap = ctx.GlobalFloat64(RPCGlobalTxFeeCapFlag.Name)
 	}
-	if ctx.GlobalIsSet(NoDiscoverFlag.Name) {
+	if ctx.GlobalIsSet(RPCLogsRangeCapFlag.Name) {
+		cfg.RPCLogsRangeCap = ctx.GlobalUint64(RPCLogsRangeCapFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCLogsResultCapFlag.Name) {
+		cfg.RPCLogsResultCap = ctx.GlobalUint64(RPCLogsResultCapFlag.Name)
+	}
+	if ctx.GlobalIsSet(NoDiscoverFlag.Name) || ctx.GlobalIsSet(SentriesFlag.Name) {
 		cfg.EthDiscoveryURLs, cfg.SnapDiscoveryURLs = []string{}, []string{}
 	} else if ctx.GlobalIsSet(DNSDiscoveryFlag.Name) {
 		urls := ctx.GlobalString(DNSDiscoveryFlag.Name)
@@ -1753,7 +2065,14 @@ func RegisterEthStatsService(stack *node.Node, backend ethapi.Backend, url strin
 
 // RegisterGraphQLService is a utility function to construct a new service and register it against a node.
 func RegisterGraphQLService(stack *node.Node, backend ethapi.Backend, cfg node.Config) {
-	if err := graphql.New(stack, backend, cfg.GraphQLCors, cfg.GraphQLVirtualHosts); err != nil {
+	limits := graphql.Config{
+		MaxDepth:         cfg.GraphQLMaxDepth,
+		MaxComplexity:    cfg.GraphQLMaxComplexity,
+		PersistedQueries: cfg.GraphQLPersistedQueries,
+		PersistedOnly:    cfg.GraphQLPersistedOnly,
+		Tracing:          cfg.GraphQLTracing,
+	}
+	if err := graphql.New(stack, backend, cfg.GraphQLCors, cfg.GraphQLVirtualHosts, limits); err != nil {
 		Fatalf("Failed to register the GraphQL service: %v", err)
 	}
 }
@@ -1817,6 +2136,20 @@ func SetupMetrics(ctx *cli.Context) {
 	}
 }
 
+// SetupTracing starts recording trace spans if enabled.
+func SetupTracing(ctx *cli.Context) {
+	if !ctx.GlobalBool(TracingEnabledFlag.Name) {
+		return
+	}
+	config := tracing.DefaultConfig
+	config.Endpoint = ctx.GlobalString(TracingEndpointFlag.Name)
+	config.SampleRatio = ctx.GlobalFloat64(TracingSampleRatioFlag.Name)
+	if err := tracing.Setup(config); err != nil {
+		Fatalf("Failed to enable tracing: %v", err)
+	}
+	log.Info("Enabling trace export", "endpoint", config.Endpoint, "sampleratio", config.SampleRatio)
+}
+
 func SplitTagsFlag(tagsFlag string) map[string]string {
 	tags := strings.Split(tagsFlag, ",")
 	tagsMap := map[string]string{}
@@ -18,6 +18,7 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -39,6 +40,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/internal/syncx"
+	"github.com/ethereum/go-ethereum/internal/tracing"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/params"
@@ -1589,14 +1591,31 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 			}
 		}
 
+		// Trace the import of the block, with the processing, validation and write
+		// phases as child spans.
+		blockCtx, blockSpan := tracing.Start(context.Background(), "core.InsertBlock",
+			"number", block.NumberU64(), "hash", block.Hash(), "txs", len(block.Transactions()))
+		if blockSpan.Recording() {
+			statedb.EnableReadTimers()
+		}
 		// Process block using the parent state as reference point
 		substart := time.Now()
+		_, span := tracing.Start(blockCtx, "core.ProcessBlock")
 		receipts, logs, usedGas, err := bc.processor.Process(block, statedb, bc.vmConfig)
+		span.SetError(err)
+		span.End()
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			atomic.StoreUint32(&followupInterrupt, 1)
+			blockSpan.SetError(err)
+			blockSpan.End()
 			return it.index, err
 		}
+		blockSpan.SetAttributes(
+			"gas_used", usedGas,
+			"state.account_reads", statedb.AccountReads+statedb.SnapshotAccountReads,
+			"state.storage_reads", statedb.StorageReads+statedb.SnapshotStorageReads,
+		)
 
 		// Update the metrics touched during block processing
 		accountReadTimer.Update(statedb.AccountReads)                 // Account reads are complete, we can mark them
@@ -1613,11 +1632,17 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 
 		// Validate the state using the default validator
 		substart = time.Now()
+		_, span = tracing.Start(blockCtx, "core.ValidateState")
 		if err := bc.validator.ValidateState(block, statedb, receipts, usedGas); err != nil {
 			bc.reportBlock(block, receipts, err)
 			atomic.StoreUint32(&followupInterrupt, 1)
+			span.SetError(err)
+			span.End()
+			blockSpan.SetError(err)
+			blockSpan.End()
 			return it.index, err
 		}
+		span.End()
 		proctime := time.Since(start)
 
 		// Update the metrics touched during block validation
@@ -1627,6 +1652,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 
 		// Write the block to the chain and get the status.
 		substart = time.Now()
+		_, span = tracing.Start(blockCtx, "core.WriteBlock")
 		var status WriteStatus
 		if !setHead {
 			// Don't set the head, only insert the block
@@ -1635,6 +1661,10 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 			status, err = bc.writeBlockAndSetHead(block, receipts, logs, statedb, false)
 		}
 		atomic.StoreUint32(&followupInterrupt, 1)
+		span.SetError(err)
+		span.End()
+		blockSpan.SetError(err)
+		blockSpan.End()
 		if err != nil {
 			return it.index, err
 		}
@@ -0,0 +1,110 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// LogIndexer implements a core.ChainIndexer, building up an index of the blocks
+// containing logs of each address and topic (by position). Contrary to the bloom
+// bits, the index has no false positives, so wide range log queries only need to
+// look at the blocks that actually contain matching logs.
+type LogIndexer struct {
+	size    uint64              // section size to generate the index for
+	db      ethdb.Database      // database instance to read receipts from and write the index into
+	section uint64              // Section is the section number being processed currently
+	head    common.Hash         // Head is the hash of the last header processed
+	entries map[string][]uint64 // Block numbers containing each address or topic, keyed by kind and value
+}
+
+// NewLogIndexer returns a chain indexer that generates the log index for the
+// canonical chain. Sections rolled back by a reorg are reprocessed by the chain
+// indexer, and entries of stale sections are told apart by their section head.
+func NewLogIndexer(db ethdb.Database, size, confirms uint64) *ChainIndexer {
+	backend := &LogIndexer{
+		db:   db,
+		size: size,
+	}
+	table := rawdb.NewTable(db, string(rawdb.LogIndexIndexPrefix))
+
+	return NewChainIndexer(db, table, backend, size, confirms, bloomThrottling, "logindex")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new log index section.
+func (l *LogIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
+	l.section, l.head, l.entries = section, common.Hash{}, make(map[string][]uint64)
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the logs of a new header
+// into the index.
+func (l *LogIndexer) Process(ctx context.Context, header *types.Header) error {
+	l.head = header.Hash()
+	if header.Bloom == (types.Bloom{}) {
+		return nil
+	}
+	number := header.Number.Uint64()
+	receipts := rawdb.ReadRawReceipts(l.db, l.head, number)
+	if receipts == nil {
+		return fmt.Errorf("receipts of block #%d [%x…] missing", number, l.head[:4])
+	}
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			l.add(rawdb.LogIndexAddress, log.Address[:], number)
+			for i, topic := range log.Topics {
+				l.add(rawdb.LogIndexTopic+byte(i), topic[:], number)
+			}
+		}
+	}
+	return nil
+}
+
+// add records that the block contains a log with the given address or topic.
+func (l *LogIndexer) add(kind byte, value []byte, number uint64) {
+	key := string(append([]byte{kind}, value...))
+	if numbers := l.entries[key]; len(numbers) == 0 || numbers[len(numbers)-1] != number {
+		l.entries[key] = append(numbers, number)
+	}
+}
+
+// Commit implements core.ChainIndexerBackend, finalizing the log index section
+// and writing it out into the database.
+func (l *LogIndexer) Commit() error {
+	batch := l.db.NewBatch()
+	for key, numbers := range l.entries {
+		rawdb.WriteLogIndex(batch, key[0], []byte(key[1:]), l.section, l.head, numbers)
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	return batch.Write()
+}
+
+// Prune returns an empty error since we don't support pruning here.
+func (l *LogIndexer) Prune(threshold uint64) error {
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the log indexer records the blocks containing each address and
+// positional topic of a section.
+func TestLogIndexer(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		genesis = (&Genesis{BaseFee: big.NewInt(params.InitialBaseFee)}).MustCommit(db)
+		addr    = common.BytesToAddress([]byte("address"))
+		topic1  = common.BytesToHash([]byte("topic1"))
+		topic2  = common.BytesToHash([]byte("topic2"))
+	)
+	blocks, receipts := GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 7, func(i int, gen *BlockGen) {
+		if i%3 != 0 {
+			return
+		}
+		receipt := types.NewReceipt(nil, false, 0)
+		receipt.Logs = []*types.Log{
+			{Address: addr, Topics: []common.Hash{topic1}},
+			{Address: addr, Topics: []common.Hash{topic2, topic1}},
+		}
+		gen.AddUncheckedReceipt(receipt)
+		gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.Address{}, big.NewInt(1), 1, gen.BaseFee(), nil))
+	})
+	indexer := &LogIndexer{db: db, size: 8}
+	if err := indexer.Reset(context.Background(), 0, common.Hash{}); err != nil {
+		t.Fatalf("failed to reset indexer: %v", err)
+	}
+	if err := indexer.Process(context.Background(), genesis.Header()); err != nil {
+		t.Fatalf("failed to index genesis: %v", err)
+	}
+	for i, block := range blocks {
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+		if err := indexer.Process(context.Background(), block.Header()); err != nil {
+			t.Fatalf("failed to index block %d: %v", block.NumberU64(), err)
+		}
+	}
+	if err := indexer.Commit(); err != nil {
+		t.Fatalf("failed to commit section: %v", err)
+	}
+	head := blocks[len(blocks)-1].Hash()
+
+	tests := []struct {
+		kind  byte
+		value []byte
+		want  []uint64
+	}{
+		{rawdb.LogIndexAddress, addr[:], []uint64{1, 4, 7}},
+		{rawdb.LogIndexTopic, topic1[:], []uint64{1, 4, 7}},
+		{rawdb.LogIndexTopic, topic2[:], []uint64{1, 4, 7}},
+		{rawdb.LogIndexTopic + 1, topic1[:], []uint64{1, 4, 7}},
+		{rawdb.LogIndexTopic + 1, topic2[:], nil},
+	}
+	for i, tt := range tests {
+		if have := rawdb.ReadLogIndex(db, tt.kind, tt.value, 0, head); !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: index mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+	// Ensure missing receipts are reported instead of indexed as empty
+	indexer.Reset(context.Background(), 1, common.Hash{})
+	rawdb.DeleteReceipts(db, blocks[3].Hash(), blocks[3].NumberU64())
+	if err := indexer.Process(context.Background(), blocks[3].Header()); err == nil {
+		t.Fatalf("indexed block with missing receipts")
+	}
+}
@@ -18,6 +18,7 @@ package rawdb
 
 import (
 	"bytes"
+	"encoding/binary"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -175,3 +176,103 @@ func DeleteBloombits(db ethdb.Database, bit uint, from uint64, to uint64) {
 		log.Crit("Failed to delete bloom bits", "err", it.Error())
 	}
 }
+
+// Kinds of values tracked by the log index. Topics are indexed by their position
+// in the log, so the kind of the i-th topic is LogIndexTopic + i.
+const (
+	LogIndexAddress byte = 0
+	LogIndexTopic   byte = 1
+)
+
+// ReadLogIndexEnabled retrieves whether the log index should be maintained.
+func ReadLogIndexEnabled(db ethdb.KeyValueReader) bool {
+	enabled, _ := db.Has(logIndexEnabledKey)
+	return enabled
+}
+
+// WriteLogIndexEnabled stores the flag requesting the log index to be maintained.
+func WriteLogIndexEnabled(db ethdb.KeyValueWriter) {
+	if err := db.Put(logIndexEnabledKey, []byte("42")); err != nil {
+		log.Crit("Failed to store log index enabled flag", "err", err)
+	}
+}
+
+// DeleteLogIndexEnabled deletes the flag requesting the log index to be maintained.
+func DeleteLogIndexEnabled(db ethdb.KeyValueWriter) {
+	if err := db.Delete(logIndexEnabledKey); err != nil {
+		log.Crit("Failed to remove log index enabled flag", "err", err)
+	}
+}
+
+// ReadLogIndex retrieves the numbers of the blocks within a section that contain
+// logs with the given address or topic. Entries written for a different section
+// head, i.e. before a reorg, are ignored.
+func ReadLogIndex(db ethdb.KeyValueReader, kind byte, value []byte, section uint64, head common.Hash) []uint64 {
+	data, _ := db.Get(logIndexKey(kind, value, section))
+	if len(data) < common.HashLength || !bytes.Equal(data[:common.HashLength], head[:]) {
+		return nil
+	}
+	var (
+		numbers []uint64
+		number  uint64
+	)
+	for data = data[common.HashLength:]; len(data) > 0; {
+		delta, n := binary.Uvarint(data)
+		if n <= 0 {
+			log.Error("Corrupt log index entry", "kind", kind, "value", common.Bytes2Hex(value), "section", section)
+			return nil
+		}
+		number += delta
+		numbers = append(numbers, number)
+		data = data[n:]
+	}
+	return numbers
+}
+
+// WriteLogIndex stores the ascending numbers of the blocks within a section that
+// contain logs with the given address or topic.
+func WriteLogIndex(db ethdb.KeyValueWriter, kind byte, value []byte, section uint64, head common.Hash, numbers []uint64) {
+	data := make([]byte, common.HashLength+len(numbers)*binary.MaxVarintLen64)
+	copy(data, head[:])
+
+	var (
+		prev uint64
+		size = common.HashLength
+	)
+	for _, number := range numbers {
+		size += binary.PutUvarint(data[size:], number-prev)
+		prev = number
+	}
+	if err := db.Put(logIndexKey(kind, value, section), data[:size]); err != nil {
+		log.Crit("Failed to store log index", "err", err)
+	}
+}
+
+// DeleteLogIndex removes the entire log index, along with the progress markers
+// of the indexer maintaining it.
+func DeleteLogIndex(db ethdb.Database) {
+	for _, prefix := range [][]byte{logIndexPrefix, LogIndexIndexPrefix} {
+		it := db.NewIterator(prefix, nil)
+		batch := db.NewBatch()
+		for it.Next() {
+			// Skip trie nodes, their hash keys may start with any byte
+			if bytes.Equal(prefix, logIndexPrefix) && len(it.Key()) == common.HashLength {
+				continue
+			}
+			batch.Delete(it.Key())
+			if batch.ValueSize() > ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					log.Crit("Failed to delete log index", "err", err)
+				}
+				batch.Reset()
+			}
+		}
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to delete log index", "err", err)
+		}
+		if it.Error() != nil {
+			log.Crit("Failed to delete log index", "err", it.Error())
+		}
+		it.Release()
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"hash"
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -177,3 +178,49 @@ func TestDeleteBloomBits(t *testing.T) {
 	check(1, 1, params.MainnetGenesisHash, true)
 	check(1, 1, params.RinkebyGenesisHash, true)
 }
+
+func TestLogIndexStorage(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	var (
+		addr    = common.BytesToAddress([]byte{0x01})
+		topic   = common.BytesToHash([]byte{0x02})
+		head    = common.BytesToHash([]byte{0x03})
+		numbers = []uint64{4096, 4097, 4200, 8191}
+	)
+	WriteLogIndex(db, LogIndexAddress, addr[:], 1, head, numbers)
+	WriteLogIndex(db, LogIndexTopic+2, topic[:], 1, head, numbers[:1])
+
+	if have := ReadLogIndex(db, LogIndexAddress, addr[:], 1, head); !reflect.DeepEqual(have, numbers) {
+		t.Fatalf("address entry mismatch: have %v, want %v", have, numbers)
+	}
+	if have := ReadLogIndex(db, LogIndexTopic+2, topic[:], 1, head); !reflect.DeepEqual(have, numbers[:1]) {
+		t.Fatalf("topic entry mismatch: have %v, want %v", have, numbers[:1])
+	}
+	if have := ReadLogIndex(db, LogIndexTopic, topic[:], 1, head); have != nil {
+		t.Fatalf("topic entry found at wrong position: %v", have)
+	}
+	if have := ReadLogIndex(db, LogIndexAddress, addr[:], 1, common.Hash{}); have != nil {
+		t.Fatalf("stale entry returned: %v", have)
+	}
+	// Ensure deleting the index leaves the metadata and the trie nodes sharing
+	// the prefix intact
+	nodehash := common.BytesToHash(append(common.CopyBytes(logIndexPrefix), make([]byte, 31)...))
+	WriteTrieNode(db, nodehash, []byte{0x01})
+	WriteLogIndexEnabled(db)
+	WriteHeadHeaderHash(db, head)
+	DeleteLogIndex(db)
+
+	if have := ReadLogIndex(db, LogIndexAddress, addr[:], 1, head); have != nil {
+		t.Fatalf("entry not deleted: %v", have)
+	}
+	if !ReadLogIndexEnabled(db) {
+		t.Fatalf("log index enabled flag deleted")
+	}
+	if have := ReadHeadHeaderHash(db); have != head {
+		t.Fatalf("head header hash deleted")
+	}
+	if !HasTrieNode(db, nodehash) {
+		t.Fatalf("trie node deleted")
+	}
+}
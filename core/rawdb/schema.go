@@ -78,6 +78,9 @@ var (
 	// transitionStatusKey tracks the eth2 transition status.
 	transitionStatusKey = []byte("eth2-transition")
 
+	// logIndexEnabledKey tracks whether the log index should be maintained.
+	logIndexEnabledKey = []byte("LogIndexEnabled")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -92,12 +95,14 @@ var (
 	SnapshotAccountPrefix = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
 	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
 	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
+	logIndexPrefix        = []byte("x") // logIndexPrefix + kind (byte) + address/topic + section (uint64 big endian) -> section head + block list
 
 	PreimagePrefix = []byte("secure-key-")      // PreimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	LogIndexIndexPrefix  = []byte("iL") // LogIndexIndexPrefix is the data table of the log indexer to track its progress
 
 	preimageCounter    = metrics.NewRegisteredCounter("db/preimage/total", nil)
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
@@ -210,6 +215,16 @@ func bloomBitsKey(bit uint, section uint64, hash common.Hash) []byte {
 	return key
 }
 
+// logIndexKey = logIndexPrefix + kind (byte) + value + section (uint64 big endian)
+func logIndexKey(kind byte, value []byte, section uint64) []byte {
+	key := make([]byte, len(logIndexPrefix)+1+len(value)+8)
+	copy(key, logIndexPrefix)
+	key[len(logIndexPrefix)] = kind
+	copy(key[len(logIndexPrefix)+1:], value)
+	binary.BigEndian.PutUint64(key[len(key)-8:], section)
+	return key
+}
+
 // preimageKey = PreimagePrefix + hash
 func preimageKey(hash common.Hash) []byte {
 	return append(PreimagePrefix, hash.Bytes()...)
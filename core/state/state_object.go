@@ -203,7 +203,7 @@ func (s *stateObject) GetCommittedState(db Database, key common.Hash) common.Has
 		meter *time.Duration
 	)
 	readStart := time.Now()
-	if metrics.EnabledExpensive {
+	if s.db.timeReads() {
 		// If the snap is 'under construction', the first lookup may fail. If that
 		// happens, we don't want to double-count the time elapsed. Thus this
 		// dance with the metering.
@@ -214,7 +214,7 @@ func (s *stateObject) GetCommittedState(db Database, key common.Hash) common.Has
 		}()
 	}
 	if s.db.snap != nil {
-		if metrics.EnabledExpensive {
+		if s.db.timeReads() {
 			meter = &s.db.SnapshotStorageReads
 		}
 		// If the object was destructed in *this* block (and potentially resurrected),
@@ -236,7 +236,7 @@ func (s *stateObject) GetCommittedState(db Database, key common.Hash) common.Has
 			*meter += time.Since(readStart)
 			readStart = time.Now()
 		}
-		if metrics.EnabledExpensive {
+		if s.db.timeReads() {
 			meter = &s.db.StorageReads
 		}
 		if enc, err = s.getTrie(db).TryGet(key.Bytes()); err != nil {
@@ -117,6 +117,7 @@ type StateDB struct {
 	SnapshotAccountReads time.Duration
 	SnapshotStorageReads time.Duration
 	SnapshotCommits      time.Duration
+	readTimers           bool // measure reads even if expensive metrics are disabled
 
 	AccountUpdated int
 	StorageUpdated int
@@ -154,6 +155,17 @@ func New(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error)
 	return sdb, nil
 }
 
+// EnableReadTimers makes the state measure the time spent reading accounts and
+// storage slots, which is otherwise only done if expensive metrics are enabled.
+func (s *StateDB) EnableReadTimers() {
+	s.readTimers = true
+}
+
+// timeReads reports whether account and storage reads are measured.
+func (s *StateDB) timeReads() bool {
+	return s.readTimers || metrics.EnabledExpensive
+}
+
 // StartPrefetcher initializes a new trie prefetcher to pull in nodes from the
 // state trie concurrently while the state is mutated so that when we reach the
 // commit phase, most of the needed data is already hot.
@@ -511,7 +523,7 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 		err  error
 	)
 	if s.snap != nil {
-		if metrics.EnabledExpensive {
+		if s.timeReads() {
 			defer func(start time.Time) { s.SnapshotAccountReads += time.Since(start) }(time.Now())
 		}
 		var acc *snapshot.Account
@@ -535,7 +547,7 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 	}
 	// If snapshot unavailable or reading from it failed, load from the database
 	if s.snap == nil || err != nil {
-		if metrics.EnabledExpensive {
+		if s.timeReads() {
 			defer func(start time.Time) { s.AccountReads += time.Since(start) }(time.Now())
 		}
 		enc, err := s.trie.TryGet(addr.Bytes())
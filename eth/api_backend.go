@@ -325,11 +325,29 @@ func (b *EthAPIBackend) RPCTxFeeCap() float64 {
 	return b.eth.config.RPCTxFeeCap
 }
 
+func (b *EthAPIBackend) RPCLogsRangeCap() uint64 {
+	return b.eth.config.RPCLogsRangeCap
+}
+
+func (b *EthAPIBackend) RPCLogsResultCap() uint64 {
+	return b.eth.config.RPCLogsResultCap
+}
+
 func (b *EthAPIBackend) BloomStatus() (uint64, uint64) {
 	sections, _, _ := b.eth.bloomIndexer.Sections()
 	return params.BloomBitsBlocks, sections
 }
 
+// LogIndexStatus implements filters.LogIndexBackend, reporting the section size
+// and the number of sections covered by the log index, if enabled.
+func (b *EthAPIBackend) LogIndexStatus() (uint64, uint64) {
+	if b.eth.logIndexer == nil {
+		return 0, 0
+	}
+	sections, _, _ := b.eth.logIndexer.Sections()
+	return params.BloomBitsBlocks, sections
+}
+
 func (b *EthAPIBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	for i := 0; i < bloomFilterThreads; i++ {
 		go session.Multiplex(bloomRetrievalBatch, bloomRetrievalWait, b.eth.bloomRequests)
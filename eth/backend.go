@@ -84,6 +84,7 @@ type Ethereum struct {
 
 	bloomRequests     chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
+	logIndexer        *core.ChainIndexer             // Log indexer operating during block imports, if enabled
 	closeBloomHandler chan struct{}
 
 	APIBackend *EthAPIBackend
@@ -207,6 +208,10 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
+	if rawdb.ReadLogIndexEnabled(chainDb) {
+		eth.logIndexer = core.NewLogIndexer(chainDb, params.BloomBitsBlocks, params.BloomConfirms)
+		eth.logIndexer.Start(eth.blockchain)
+	}
 
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
@@ -569,6 +574,9 @@ func (s *Ethereum) Stop() error {
 
 	// Then stop everything else.
 	s.bloomIndexer.Close()
+	if s.logIndexer != nil {
+		s.logIndexer.Close()
+	}
 	close(s.closeBloomHandler)
 	s.txPool.Stop()
 	s.miner.Close()
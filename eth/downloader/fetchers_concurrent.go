@@ -312,6 +312,7 @@ func (d *Downloader) concurrentFetch(queue typedQueue) error {
 				log.Error("Delivery timeout from unknown peer", "peer", req.Peer)
 				continue
 			}
+			peer.reportTimeout()
 			if fails > 2 {
 				queue.updateCapacity(peer, 0, 0)
 			} else {
@@ -368,6 +369,9 @@ func (d *Downloader) concurrentFetch(queue typedQueue) error {
 				// idle. If the delivery's stale, the peer should have already been idled.
 				if !errors.Is(err, errStaleDelivery) {
 					queue.updateCapacity(peer, accepted, res.Time)
+					if accepted > 0 {
+						peer.reportLatency(d.peers.rates.MedianRoundTrip())
+					}
 				}
 			}
 
@@ -29,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/msgrate"
 )
 
@@ -69,6 +70,13 @@ type Peer interface {
 	RequestReceipts([]common.Hash, chan *eth.Response) (*eth.Request, error)
 }
 
+// reputationPeer is implemented by peers keeping a reputation, which their
+// responsiveness during sync contributes to.
+type reputationPeer interface {
+	Report(event p2p.ReputationEvent)
+	ReportLatency(rtt, reference time.Duration)
+}
+
 // lightPeerWrapper wraps a LightPeer struct, stubbing out the Peer-only methods.
 type lightPeerWrapper struct {
 	peer LightPeer
@@ -125,6 +133,21 @@ func (p *peerConnection) UpdateReceiptRate(delivered int, elapsed time.Duration)
 	p.rates.Update(eth.ReceiptsMsg, elapsed, delivered)
 }
 
+// reportTimeout lowers the reputation of the peer after a request timed out.
+func (p *peerConnection) reportTimeout() {
+	if peer, ok := p.peer.(reputationPeer); ok {
+		peer.Report(p2p.RequestTimeout)
+	}
+}
+
+// reportLatency rates the estimated round trip time of the peer against the
+// reference of all peers.
+func (p *peerConnection) reportLatency(reference time.Duration) {
+	if peer, ok := p.peer.(reputationPeer); ok {
+		peer.ReportLatency(p.rates.RoundTrip(), reference)
+	}
+}
+
 // HeaderCapacity retrieves the peer's header download allowance based on its
 // previously discovered throughput.
 func (p *peerConnection) HeaderCapacity(targetRTT time.Duration) int {
@@ -197,6 +197,12 @@ type Config struct {
 	// send-transction variants. The unit is ether.
 	RPCTxFeeCap float64
 
+	// RPCLogsRangeCap is the maximum number of blocks eth_getLogs may query.
+	RPCLogsRangeCap uint64
+
+	// RPCLogsResultCap is the maximum number of logs eth_getLogs may return.
+	RPCLogsResultCap uint64
+
 	// Checkpoint is a hardcoded checkpoint which can be nil.
 	Checkpoint *params.TrustedCheckpoint `toml:",omitempty"`
 
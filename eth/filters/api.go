@@ -30,6 +30,8 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
@@ -108,7 +110,7 @@ func (api *PublicFilterAPI) timeoutLoop(timeout time.Duration) {
 // https://eth.wiki/json-rpc/API#eth_newpendingtransactionfilter
 func (api *PublicFilterAPI) NewPendingTransactionFilter() rpc.ID {
 	var (
-		pendingTxs   = make(chan []common.Hash)
+		pendingTxs   = make(chan []*types.Transaction)
 		pendingTxSub = api.events.SubscribePendingTxs(pendingTxs)
 	)
 
@@ -119,10 +121,12 @@ func (api *PublicFilterAPI) NewPendingTransactionFilter() rpc.ID {
 	go func() {
 		for {
 			select {
-			case ph := <-pendingTxs:
+			case pTx := <-pendingTxs:
 				api.filtersMu.Lock()
 				if f, found := api.filters[pendingTxSub.ID]; found {
-					f.hashes = append(f.hashes, ph...)
+					for _, tx := range pTx {
+						f.hashes = append(f.hashes, tx.Hash())
+					}
 				}
 				api.filtersMu.Unlock()
 			case <-pendingTxSub.Err():
@@ -137,27 +141,54 @@ func (api *PublicFilterAPI) NewPendingTransactionFilter() rpc.ID {
 	return pendingTxSub.ID
 }
 
+// PendingTxOptions configures a pending transaction subscription. The sender
+// and recipient filters are ignored if empty, a transaction needs to match both.
+type PendingTxOptions struct {
+	FullTx bool             `json:"fullTx"` // Whether to deliver full transactions instead of hashes
+	From   []common.Address `json:"from"`   // Senders of the transactions to deliver
+	To     []common.Address `json:"to"`     // Recipients of the transactions to deliver
+}
+
 // NewPendingTransactions creates a subscription that is triggered each time a transaction
 // enters the transaction pool and was signed from one of the transactions this nodes manages.
-func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+//
+// By default the hash of the transaction is delivered, optionally the full transaction.
+func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context, opts *PendingTxOptions) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
-
-	rpcSub := notifier.CreateSubscription()
-
+	if opts == nil {
+		opts = new(PendingTxOptions)
+	}
+	var (
+		rpcSub       = notifier.CreateSubscription()
+		txs          = make(chan []*types.Transaction, 128)
+		pendingTxSub = api.events.SubscribePendingTxs(txs)
+	)
 	go func() {
-		txHashes := make(chan []common.Hash, 128)
-		pendingTxSub := api.events.SubscribePendingTxs(txHashes)
-
+		var (
+			config = api.backend.ChainConfig()
+			signer = types.LatestSigner(config)
+		)
 		for {
 			select {
-			case hashes := <-txHashes:
+			case batch := <-txs:
 				// To keep the original behaviour, send a single tx hash in one notification.
 				// TODO(rjl493456442) Send a batch of tx hashes in one notification
-				for _, h := range hashes {
-					notifier.Notify(rpcSub.ID, h)
+				var head *types.Header
+				if opts.FullTx {
+					head, _ = api.backend.HeaderByNumber(context.Background(), rpc.LatestBlockNumber)
+				}
+				for _, tx := range batch {
+					if !opts.matches(signer, tx) {
+						continue
+					}
+					if opts.FullTx {
+						notifier.Notify(rpcSub.ID, ethapi.NewRPCPendingTransaction(tx, head, config))
+					} else {
+						notifier.Notify(rpcSub.ID, tx.Hash())
+					}
 				}
 			case <-rpcSub.Err():
 				pendingTxSub.Unsubscribe()
@@ -172,6 +203,22 @@ func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Su
 	return rpcSub, nil
 }
 
+// matches reports whether the transaction passes the sender and recipient filters.
+func (opts *PendingTxOptions) matches(signer types.Signer, tx *types.Transaction) bool {
+	if len(opts.From) > 0 {
+		from, err := types.Sender(signer, tx)
+		if err != nil || !includes(opts.From, from) {
+			return false
+		}
+	}
+	if len(opts.To) > 0 {
+		if tx.To() == nil || !includes(opts.To, *tx.To()) {
+			return false
+		}
+	}
+	return true
+}
+
 // NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
 // It is part of the filter package since polling goes with eth_getFilterChanges.
 //
@@ -237,6 +284,69 @@ func (api *PublicFilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, er
 	return rpcSub, nil
 }
 
+// NewBlocks sends a notification with the full block, including its transactions
+// and receipts, each time a block becomes part of the canonical chain.
+func (api *PublicFilterAPI) NewBlocks(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if api.events.lightMode {
+		return nil, errors.New("block subscriptions not supported in light mode")
+	}
+	var (
+		rpcSub    = notifier.CreateSubscription()
+		blocks    = make(chan *types.Block)
+		blocksSub = api.events.SubscribeNewBlocks(blocks)
+	)
+	go func() {
+		for {
+			select {
+			case block := <-blocks:
+				fields, err := api.marshalBlock(block)
+				if err != nil {
+					log.Warn("Failed to assemble block notification", "number", block.Number(), "hash", block.Hash(), "err", err)
+					continue
+				}
+				notifier.Notify(rpcSub.ID, fields)
+			case <-rpcSub.Err():
+				blocksSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				blocksSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// marshalBlock converts a block into the RPC representation with full transactions,
+// extended with the receipts of the transactions.
+func (api *PublicFilterAPI) marshalBlock(block *types.Block) (map[string]interface{}, error) {
+	config := api.backend.ChainConfig()
+
+	fields, err := ethapi.RPCMarshalBlock(block, true, true, config)
+	if err != nil {
+		return nil, err
+	}
+	receipts, err := api.backend.GetReceipts(context.Background(), block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	txs := block.Transactions()
+	if len(receipts) != len(txs) {
+		return nil, fmt.Errorf("receipt count mismatch: have %d, want %d", len(receipts), len(txs))
+	}
+	marshalled := make([]map[string]interface{}, len(receipts))
+	for i, receipt := range receipts {
+		marshalled[i] = ethapi.RPCMarshalReceipt(receipt, txs[i], block.Hash(), block.NumberU64(), uint64(i), block.BaseFee(), config)
+	}
+	fields["receipts"] = marshalled
+	return fields, nil
+}
+
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
 func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
@@ -324,11 +434,51 @@ func (api *PublicFilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
 	return logsSub.ID, nil
 }
 
+// LimitExceededError is returned by eth_getLogs if a query spans more blocks or
+// matches more logs than allowed. It reports a narrower block range which can be
+// served, starting at the beginning of the queried range.
+type LimitExceededError struct {
+	Message   string
+	FromBlock uint64
+	ToBlock   uint64
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s, try with this block range [0x%x, 0x%x]", e.Message, e.FromBlock, e.ToBlock)
+}
+
+// ErrorCode returns the JSON error code for an exceeded limit.
+// See: https://github.com/ethereum/wiki/wiki/JSON-RPC-Error-Codes-Improvement-Proposal
+func (e *LimitExceededError) ErrorCode() int {
+	return -32005
+}
+
+// ErrorData returns the suggested block range.
+func (e *LimitExceededError) ErrorData() interface{} {
+	return map[string]hexutil.Uint64{
+		"from": hexutil.Uint64(e.FromBlock),
+		"to":   hexutil.Uint64(e.ToBlock),
+	}
+}
+
 // GetLogs returns logs matching the given argument that are stored within the state.
 //
+// If a limit is given, at most that many logs are returned. The next page can be
+// retrieved by repeating the query with fromBlock set to the block of the last
+// log returned and fromLogIndex to its log index plus one.
+//
 // https://eth.wiki/json-rpc/API#eth_getlogs
 func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
-	var filter *Filter
+	return api.logs(ctx, crit)
+}
+
+// logs runs a one-shot filter with the given criteria, enforcing the block range
+// and result caps of the backend.
+func (api *PublicFilterAPI) logs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
+	var (
+		filter *Filter
+		first  uint64 // Number of the first block queried, for suggesting narrower ranges
+	)
 	if crit.BlockHash != nil {
 		// Block filter requested, construct a single-shot filter
 		filter = NewBlockFilter(api.backend, *crit.BlockHash, crit.Addresses, crit.Topics)
@@ -342,17 +492,71 @@ func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([
 		if crit.ToBlock != nil {
 			end = crit.ToBlock.Int64()
 		}
+		// Reject ranges spanning more blocks than allowed
+		first = uint64(begin)
+		if rangeCap := api.backend.RPCLogsRangeCap(); rangeCap > 0 || begin < 0 {
+			header, err := api.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+			if err != nil {
+				return nil, err
+			}
+			if header != nil {
+				first = resolveBlockNumber(begin, header.Number.Uint64())
+				last := resolveBlockNumber(end, header.Number.Uint64())
+
+				if rangeCap > 0 && last >= first && last-first >= rangeCap {
+					return nil, &LimitExceededError{
+						Message:   fmt.Sprintf("query exceeds max block range %d", rangeCap),
+						FromBlock: first,
+						ToBlock:   first + rangeCap - 1,
+					}
+				}
+			}
+		}
 		// Construct the range filter
 		filter = NewRangeFilter(api.backend, begin, end, crit.Addresses, crit.Topics)
+		filter.cursorBlock = first
+	}
+	filter.fromLogIndex = crit.FromLogIndex
+
+	// Gather one log above the result cap to detect overflowing queries
+	resultCap := api.backend.RPCLogsResultCap()
+	switch {
+	case resultCap > 0 && (crit.Limit == 0 || crit.Limit > resultCap):
+		filter.limit = int(resultCap + 1)
+	case crit.Limit > 0:
+		filter.limit = int(crit.Limit)
 	}
 	// Run the filter and return all the logs
 	logs, err := filter.Logs(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if resultCap > 0 && uint64(len(logs)) > resultCap {
+		if crit.BlockHash != nil {
+			first = logs[0].BlockNumber
+		}
+		last := first
+		if overflow := logs[resultCap].BlockNumber; overflow > first {
+			last = overflow - 1
+		}
+		return nil, &LimitExceededError{
+			Message:   fmt.Sprintf("query returned more than %d results", resultCap),
+			FromBlock: first,
+			ToBlock:   last,
+		}
+	}
 	return returnLogs(logs), err
 }
 
+// resolveBlockNumber converts an RPC block number into an actual one, mapping
+// the latest and pending blocks to the current head.
+func resolveBlockNumber(number int64, head uint64) uint64 {
+	if number < 0 {
+		return head
+	}
+	return uint64(number)
+}
+
 // UninstallFilter removes the filter with the given filter id.
 //
 // https://eth.wiki/json-rpc/API#eth_uninstallfilter
@@ -383,29 +587,7 @@ func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*ty
 		return nil, fmt.Errorf("filter not found")
 	}
 
-	var filter *Filter
-	if f.crit.BlockHash != nil {
-		// Block filter requested, construct a single-shot filter
-		filter = NewBlockFilter(api.backend, *f.crit.BlockHash, f.crit.Addresses, f.crit.Topics)
-	} else {
-		// Convert the RPC block numbers into internal representations
-		begin := rpc.LatestBlockNumber.Int64()
-		if f.crit.FromBlock != nil {
-			begin = f.crit.FromBlock.Int64()
-		}
-		end := rpc.LatestBlockNumber.Int64()
-		if f.crit.ToBlock != nil {
-			end = f.crit.ToBlock.Int64()
-		}
-		// Construct the range filter
-		filter = NewRangeFilter(api.backend, begin, end, f.crit.Addresses, f.crit.Topics)
-	}
-	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return returnLogs(logs), nil
+	return api.logs(ctx, f.crit)
 }
 
 // GetFilterChanges returns the logs for the filter with the given id since
@@ -463,11 +645,13 @@ func returnLogs(logs []*types.Log) []*types.Log {
 // UnmarshalJSON sets *args fields with given data.
 func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 	type input struct {
-		BlockHash *common.Hash     `json:"blockHash"`
-		FromBlock *rpc.BlockNumber `json:"fromBlock"`
-		ToBlock   *rpc.BlockNumber `json:"toBlock"`
-		Addresses interface{}      `json:"address"`
-		Topics    []interface{}    `json:"topics"`
+		BlockHash    *common.Hash     `json:"blockHash"`
+		FromBlock    *rpc.BlockNumber `json:"fromBlock"`
+		ToBlock      *rpc.BlockNumber `json:"toBlock"`
+		Addresses    interface{}      `json:"address"`
+		Topics       []interface{}    `json:"topics"`
+		Limit        *hexutil.Uint64  `json:"limit"`
+		FromLogIndex *hexutil.Uint    `json:"fromLogIndex"`
 	}
 
 	var raw input
@@ -491,6 +675,17 @@ func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 		}
 	}
 
+	if raw.Limit != nil {
+		args.Limit = uint64(*raw.Limit)
+	}
+	if raw.FromLogIndex != nil {
+		// The cursor is relative to the first block, which needs to be fixed
+		if raw.BlockHash == nil && (raw.FromBlock == nil || raw.FromBlock.Int64() < 0) {
+			return errors.New("fromLogIndex requires blockHash or a numeric fromBlock")
+		}
+		args.FromLogIndex = uint(*raw.FromLogIndex)
+	}
+
 	args.Addresses = []common.Address{}
 
 	if raw.Addresses != nil {
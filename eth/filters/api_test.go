@@ -182,4 +182,22 @@ func TestUnmarshalJSONNewFilterArgs(t *testing.T) {
 	if len(test7.Topics[2]) != 0 {
 		t.Fatalf("expected 0 topics, got %d topics", len(test7.Topics[2]))
 	}
+
+	// test pagination
+	var test8 FilterCriteria
+	vector = fmt.Sprintf(`{"fromBlock":"0x%x","limit":"0x64","fromLogIndex":"0x3"}`, fromBlock)
+	if err := json.Unmarshal([]byte(vector), &test8); err != nil {
+		t.Fatal(err)
+	}
+	if test8.Limit != 100 {
+		t.Fatalf("expected limit 100, got %d", test8.Limit)
+	}
+	if test8.FromLogIndex != 3 {
+		t.Fatalf("expected log index cursor 3, got %d", test8.FromLogIndex)
+	}
+	// the log index cursor needs a fixed first block
+	var test9 FilterCriteria
+	if err := json.Unmarshal([]byte(`{"fromBlock":"latest","fromLogIndex":"0x3"}`), &test9); err == nil {
+		t.Fatal("expected error for cursor relative to latest block")
+	}
 }
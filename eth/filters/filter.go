@@ -24,14 +24,17 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
 type Backend interface {
 	ChainDb() ethdb.Database
+	ChainConfig() *params.ChainConfig
 	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
 	HeaderByHash(ctx context.Context, blockHash common.Hash) (*types.Header, error)
 	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
@@ -45,6 +48,16 @@ type Backend interface {
 
 	BloomStatus() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
+
+	RPCLogsRangeCap() uint64  // block range cap of eth_getLogs, 0 if unlimited
+	RPCLogsResultCap() uint64 // result count cap of eth_getLogs, 0 if unlimited
+}
+
+// LogIndexBackend is implemented by backends maintaining an exact index of the
+// blocks containing the logs of each address and topic. If available, it is used
+// in favour of the bloom bits for the sections it covers.
+type LogIndexBackend interface {
+	LogIndexStatus() (uint64, uint64)
 }
 
 // Filter can be used to retrieve and filter logs.
@@ -58,6 +71,11 @@ type Filter struct {
 	block      common.Hash // Block hash if filtering a single block
 	begin, end int64       // Range interval if filtering multiple blocks
 
+	limit        int    // Maximum number of logs to gather, 0 if unlimited
+	found        int    // Number of logs gathered so far
+	cursorBlock  uint64 // Number of the block the log index cursor applies to in range filters
+	fromLogIndex uint   // Index of the first log to gather from the cursor block
+
 	matcher *bloombits.Matcher
 }
 
@@ -147,14 +165,29 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		logs []*types.Log
 		err  error
 	)
+	if backend, ok := f.backend.(LogIndexBackend); ok && f.selective() && uint64(f.begin) <= end {
+		size, sections := backend.LogIndexStatus()
+		if indexed := sections * size; indexed > uint64(f.begin) {
+			if indexed > end {
+				logs, err = f.logIndexLogs(ctx, size, end)
+			} else {
+				logs, err = f.logIndexLogs(ctx, size, indexed-1)
+			}
+			if err != nil || f.exhausted() {
+				return logs, err
+			}
+		}
+	}
 	size, sections := f.backend.BloomStatus()
 	if indexed := sections * size; indexed > uint64(f.begin) {
+		var found []*types.Log
 		if indexed > end {
-			logs, err = f.indexedLogs(ctx, end)
+			found, err = f.indexedLogs(ctx, end)
 		} else {
-			logs, err = f.indexedLogs(ctx, indexed-1)
+			found, err = f.indexedLogs(ctx, indexed-1)
 		}
-		if err != nil {
+		logs = append(logs, found...)
+		if err != nil || f.exhausted() {
 			return logs, err
 		}
 	}
@@ -203,6 +236,9 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 				return logs, err
 			}
 			logs = append(logs, found...)
+			if f.exhausted() {
+				return logs, nil
+			}
 
 		case <-ctx.Done():
 			return logs, ctx.Err()
@@ -210,6 +246,121 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 	}
 }
 
+// selective reports whether the filter has any address or topic criteria which
+// the log index can look up.
+func (f *Filter) selective() bool {
+	if len(f.addresses) > 0 {
+		return true
+	}
+	for i, sub := range f.topics {
+		if i <= 3 && len(sub) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// logIndexLogs returns the logs matching the filter criteria based on the log
+// index maintained locally.
+func (f *Filter) logIndexLogs(ctx context.Context, size uint64, end uint64) ([]*types.Log, error) {
+	var logs []*types.Log
+
+	for section := uint64(f.begin) / size; section*size <= end; section++ {
+		// Resolve the section head the index entries need to be built on
+		head := rawdb.ReadCanonicalHash(f.db, (section+1)*size-1)
+		if head == (common.Hash{}) {
+			return logs, nil
+		}
+		for _, number := range f.sectionMatches(section, head) {
+			if number < uint64(f.begin) || number > end {
+				continue
+			}
+			header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+			if header == nil || err != nil {
+				return logs, err
+			}
+			found, err := f.checkMatches(ctx, header)
+			if err != nil {
+				return logs, err
+			}
+			logs = append(logs, found...)
+			if f.exhausted() {
+				return logs, nil
+			}
+			if err := ctx.Err(); err != nil {
+				return logs, err
+			}
+		}
+		f.begin = int64((section + 1) * size)
+	}
+	if f.begin > int64(end) {
+		f.begin = int64(end) + 1
+	}
+	return logs, nil
+}
+
+// sectionMatches returns the ascending numbers of the blocks within a section
+// containing logs that satisfy every address and topic criterion. Alternatives
+// within a criterion are merged, the criteria themselves intersected.
+func (f *Filter) sectionMatches(section uint64, head common.Hash) []uint64 {
+	var groups [][]uint64
+	if len(f.addresses) > 0 {
+		var group []uint64
+		for _, address := range f.addresses {
+			group = mergeNumbers(group, rawdb.ReadLogIndex(f.db, rawdb.LogIndexAddress, address[:], section, head))
+		}
+		groups = append(groups, group)
+	}
+	for i, sub := range f.topics {
+		if i > 3 || len(sub) == 0 {
+			continue
+		}
+		var group []uint64
+		for _, topic := range sub {
+			group = mergeNumbers(group, rawdb.ReadLogIndex(f.db, rawdb.LogIndexTopic+byte(i), topic[:], section, head))
+		}
+		groups = append(groups, group)
+	}
+	matches := groups[0]
+	for _, group := range groups[1:] {
+		matches = intersectNumbers(matches, group)
+	}
+	return matches
+}
+
+// mergeNumbers returns the union of two ascending lists of block numbers.
+func mergeNumbers(a, b []uint64) []uint64 {
+	merged := make([]uint64, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] < b[0]:
+			merged, a = append(merged, a[0]), a[1:]
+		case a[0] > b[0]:
+			merged, b = append(merged, b[0]), b[1:]
+		default:
+			merged, a, b = append(merged, a[0]), a[1:], b[1:]
+		}
+	}
+	merged = append(merged, a...)
+	return append(merged, b...)
+}
+
+// intersectNumbers returns the intersection of two ascending lists of block numbers.
+func intersectNumbers(a, b []uint64) []uint64 {
+	var shared []uint64
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] < b[0]:
+			a = a[1:]
+		case a[0] > b[0]:
+			b = b[1:]
+		default:
+			shared, a, b = append(shared, a[0]), a[1:], b[1:]
+		}
+	}
+	return shared
+}
+
 // unindexedLogs returns the logs matching the filter criteria based on raw block
 // iteration and bloom matching.
 func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
@@ -225,6 +376,9 @@ func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, e
 			return logs, err
 		}
 		logs = append(logs, found...)
+		if f.exhausted() {
+			break
+		}
 	}
 	return logs, nil
 }
@@ -267,11 +421,43 @@ func (f *Filter) checkMatches(ctx context.Context, header *types.Header) (logs [
 			}
 			logs = filterLogs(unfiltered, nil, nil, f.addresses, f.topics)
 		}
-		return logs, nil
+		return f.paginate(header, logs), nil
 	}
 	return nil, nil
 }
 
+// Paginate limits the number of logs gathered by the filter, zero meaning no
+// limit, and skips the logs preceding the given log index in the first block
+// of the filter. The cursor only applies to range filters starting at a block
+// number, not at the latest or pending block.
+func (f *Filter) Paginate(limit int, fromLogIndex uint) {
+	f.limit = limit
+	f.fromLogIndex = fromLogIndex
+	if f.begin >= 0 {
+		f.cursorBlock = uint64(f.begin)
+	}
+}
+
+// paginate drops the logs of a block preceding the log index cursor, and the ones
+// exceeding the limit of the filter.
+func (f *Filter) paginate(header *types.Header, logs []*types.Log) []*types.Log {
+	if f.fromLogIndex > 0 && (f.block != (common.Hash{}) || header.Number.Uint64() == f.cursorBlock) {
+		for len(logs) > 0 && logs[0].Index < f.fromLogIndex {
+			logs = logs[1:]
+		}
+	}
+	if f.limit > 0 && f.found+len(logs) > f.limit {
+		logs = logs[:f.limit-f.found]
+	}
+	f.found += len(logs)
+	return logs
+}
+
+// exhausted reports whether the filter gathered as many logs as it is limited to.
+func (f *Filter) exhausted() bool {
+	return f.limit > 0 && f.found >= f.limit
+}
+
 func includes(addresses []common.Address, a common.Address) bool {
 	for _, addr := range addresses {
 		if addr == a {
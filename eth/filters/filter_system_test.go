@@ -23,6 +23,7 @@ import (
 	"math/rand"
 	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -33,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/bloombits"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/params"
@@ -47,6 +49,8 @@ type testBackend struct {
 	mux             *event.TypeMux
 	db              ethdb.Database
 	sections        uint64
+	rangeCap        uint64
+	resultCap       uint64
 	txFeed          event.Feed
 	logsFeed        event.Feed
 	rmLogsFeed      event.Feed
@@ -58,6 +62,10 @@ func (b *testBackend) ChainDb() ethdb.Database {
 	return b.db
 }
 
+func (b *testBackend) ChainConfig() *params.ChainConfig {
+	return params.TestChainConfig
+}
+
 func (b *testBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
 	var (
 		hash common.Hash
@@ -126,6 +134,14 @@ func (b *testBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subsc
 	return b.chainFeed.Subscribe(ch)
 }
 
+func (b *testBackend) RPCLogsRangeCap() uint64 {
+	return b.rangeCap
+}
+
+func (b *testBackend) RPCLogsResultCap() uint64 {
+	return b.resultCap
+}
+
 func (b *testBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, b.sections
 }
@@ -269,6 +285,153 @@ func TestPendingTxFilter(t *testing.T) {
 	}
 }
 
+// TestPendingTxSubscriptionOptions tests whether pending transaction subscriptions
+// deliver full transactions and apply the sender and recipient filters.
+func TestPendingTxSubscriptionOptions(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		api     = NewPublicFilterAPI(backend, false, deadline)
+		signer  = types.LatestSigner(params.TestChainConfig)
+
+		key1, _ = crypto.GenerateKey()
+		key2, _ = crypto.GenerateKey()
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		to1     = common.HexToAddress("0x1111")
+		to2     = common.HexToAddress("0x2222")
+
+		transactions = []*types.Transaction{
+			types.MustSignNewTx(key1, signer, &types.LegacyTx{Nonce: 0, To: &to1, Gas: 21000, GasPrice: big.NewInt(1)}),
+			types.MustSignNewTx(key2, signer, &types.LegacyTx{Nonce: 0, To: &to1, Gas: 21000, GasPrice: big.NewInt(1)}),
+			types.MustSignNewTx(key1, signer, &types.LegacyTx{Nonce: 1, To: &to2, Gas: 21000, GasPrice: big.NewInt(1)}),
+			types.MustSignNewTx(key1, signer, &types.LegacyTx{Nonce: 2, Gas: 53000, GasPrice: big.NewInt(1)}),
+		}
+	)
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	var (
+		all    = make(chan common.Hash)
+		toOne  = make(chan common.Hash)
+		fromMe = make(chan map[string]interface{})
+	)
+	if _, err := client.EthSubscribe(context.Background(), all, "newPendingTransactions"); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	if _, err := client.EthSubscribe(context.Background(), toOne, "newPendingTransactions", map[string]interface{}{"to": []common.Address{to1}}); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	if _, err := client.EthSubscribe(context.Background(), fromMe, "newPendingTransactions", map[string]interface{}{"fullTx": true, "from": []common.Address{addr1}}); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	backend.txFeed.Send(core.NewTxsEvent{Txs: transactions})
+
+	expect := func(name string, want []int, recv func() common.Hash) {
+		for _, i := range want {
+			if have := recv(); have != transactions[i].Hash() {
+				t.Errorf("%s: transaction mismatch: have %x, want %x", name, have, transactions[i].Hash())
+			}
+		}
+	}
+	timeout := time.After(5 * time.Second)
+	expect("all", []int{0, 1, 2, 3}, func() common.Hash {
+		select {
+		case hash := <-all:
+			return hash
+		case <-timeout:
+			t.Fatalf("all: timeout")
+		}
+		return common.Hash{}
+	})
+	expect("to", []int{0, 1}, func() common.Hash {
+		select {
+		case hash := <-toOne:
+			return hash
+		case <-timeout:
+			t.Fatalf("to: timeout")
+		}
+		return common.Hash{}
+	})
+	expect("from", []int{0, 2, 3}, func() common.Hash {
+		select {
+		case tx := <-fromMe:
+			if tx["from"] != strings.ToLower(addr1.Hex()) {
+				t.Errorf("from: sender mismatch: have %v, want %x", tx["from"], addr1)
+			}
+			return common.HexToHash(tx["hash"].(string))
+		case <-timeout:
+			t.Fatalf("from: timeout")
+		}
+		return common.Hash{}
+	})
+}
+
+// TestNewBlocksSubscription tests whether block subscriptions deliver the full
+// blocks along with their receipts.
+func TestNewBlocksSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		api     = NewPublicFilterAPI(backend, false, deadline)
+		key, _  = crypto.GenerateKey()
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		genesis = core.GenesisBlockForTesting(db, addr, big.NewInt(1000000000000000000))
+		signer  = types.LatestSigner(params.TestChainConfig)
+	)
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 2, func(i int, gen *core.BlockGen) {
+		tx := types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: uint64(i), To: &common.Address{0x01}, Gas: 21000, GasPrice: gen.BaseFee()})
+		gen.AddTx(tx)
+	})
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	blocks := make(chan map[string]interface{})
+	if _, err := client.EthSubscribe(context.Background(), blocks, "newBlocks"); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+		backend.chainFeed.Send(core.ChainEvent{Block: block, Hash: block.Hash()})
+	}
+	for i, block := range chain {
+		select {
+		case fields := <-blocks:
+			if fields["hash"] != block.Hash().Hex() {
+				t.Fatalf("block %d: hash mismatch: have %v, want %x", i, fields["hash"], block.Hash())
+			}
+			txs := fields["transactions"].([]interface{})
+			if len(txs) != 1 || txs[0].(map[string]interface{})["hash"] != block.Transactions()[0].Hash().Hex() {
+				t.Fatalf("block %d: transactions mismatch: %v", i, txs)
+			}
+			rs := fields["receipts"].([]interface{})
+			if len(rs) != 1 {
+				t.Fatalf("block %d: receipt count mismatch: have %d, want 1", i, len(rs))
+			}
+			receipt := rs[0].(map[string]interface{})
+			if receipt["transactionHash"] != block.Transactions()[0].Hash().Hex() || receipt["status"] != "0x1" || receipt["gasUsed"] != "0x5208" {
+				t.Fatalf("block %d: receipt mismatch: %v", i, receipt)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("block %d: timeout", i)
+		}
+	}
+}
+
 // TestLogFilterCreation test whether a given filter criteria makes sense.
 // If not it must return an error.
 func TestLogFilterCreation(t *testing.T) {
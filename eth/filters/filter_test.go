@@ -227,3 +227,185 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 }
+
+// logIndexBackend extends the test backend with a log index covering the given
+// number of sections.
+type logIndexBackend struct {
+	*testBackend
+	size     uint64
+	sections uint64
+}
+
+func (b *logIndexBackend) LogIndexStatus() (uint64, uint64) {
+	return b.size, b.sections
+}
+
+func TestLogIndexFilters(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &logIndexBackend{testBackend: &testBackend{db: db}, size: 8, sections: 2}
+		addr1   = common.BytesToAddress([]byte("address1"))
+		addr2   = common.BytesToAddress([]byte("address2"))
+		hash1   = common.BytesToHash([]byte("topic1"))
+		hash2   = common.BytesToHash([]byte("topic2"))
+	)
+	genesis := core.GenesisBlockForTesting(db, addr1, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 20, func(i int, gen *core.BlockGen) {
+		var log *types.Log
+		switch i {
+		case 2, 9:
+			log = &types.Log{Address: addr1, Topics: []common.Hash{hash1}}
+		case 5:
+			log = &types.Log{Address: addr1, Topics: []common.Hash{hash2, hash1}}
+		case 12, 18:
+			log = &types.Log{Address: addr2, Topics: []common.Hash{hash1, hash2}}
+		default:
+			return
+		}
+		receipt := types.NewReceipt(nil, false, 0)
+		receipt.Logs = []*types.Log{log}
+		gen.AddUncheckedReceipt(receipt)
+		gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.Address{}, big.NewInt(1), 1, gen.BaseFee(), nil))
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	rawdb.WriteCanonicalHash(db, genesis.Hash(), 0)
+
+	// Index the first two sections, leaving the rest of the chain unindexed
+	for section := uint64(0); section < backend.sections; section++ {
+		var (
+			head    = rawdb.ReadCanonicalHash(db, (section+1)*backend.size-1)
+			entries = make(map[string][]uint64)
+		)
+		for number := section * backend.size; number < (section+1)*backend.size; number++ {
+			hash := rawdb.ReadCanonicalHash(db, number)
+			for _, receipt := range rawdb.ReadRawReceipts(db, hash, number) {
+				for _, log := range receipt.Logs {
+					key := string(append([]byte{rawdb.LogIndexAddress}, log.Address[:]...))
+					entries[key] = append(entries[key], number)
+					for i, topic := range log.Topics {
+						key := string(append([]byte{rawdb.LogIndexTopic + byte(i)}, topic[:]...))
+						entries[key] = append(entries[key], number)
+					}
+				}
+			}
+		}
+		for key, numbers := range entries {
+			rawdb.WriteLogIndex(db, key[0], []byte(key[1:]), section, head, numbers)
+		}
+	}
+	tests := []struct {
+		begin, end int64
+		addresses  []common.Address
+		topics     [][]common.Hash
+		blocks     []uint64
+	}{
+		{0, -1, []common.Address{addr1}, nil, []uint64{3, 6, 10}},
+		{0, -1, nil, [][]common.Hash{{hash1}}, []uint64{3, 10, 13, 19}},
+		{0, -1, nil, [][]common.Hash{nil, {hash1}}, []uint64{6}},
+		{0, -1, []common.Address{addr1, addr2}, [][]common.Hash{{hash1}, {hash2}}, []uint64{13, 19}},
+		{4, 12, nil, [][]common.Hash{{hash1, hash2}}, []uint64{6, 10}},
+		{11, 11, []common.Address{addr1}, nil, nil},
+	}
+	for i, tt := range tests {
+		logs, err := NewRangeFilter(backend, tt.begin, tt.end, tt.addresses, tt.topics).Logs(context.Background())
+		if err != nil {
+			t.Fatalf("test %d: failed to filter logs: %v", i, err)
+		}
+		if len(logs) != len(tt.blocks) {
+			t.Errorf("test %d: log count mismatch: have %d, want %d", i, len(logs), len(tt.blocks))
+			continue
+		}
+		for j, log := range logs {
+			if log.BlockNumber != tt.blocks[j] {
+				t.Errorf("test %d, log %d: block mismatch: have %d, want %d", i, j, log.BlockNumber, tt.blocks[j])
+			}
+		}
+	}
+}
+
+func TestGetLogsLimits(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		api     = NewPublicFilterAPI(backend, false, deadline)
+		addr    = common.BytesToAddress([]byte("address"))
+	)
+	// Create a chain with three logs in every third block
+	genesis := core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 12, func(i int, gen *core.BlockGen) {
+		if i%3 != 0 {
+			return
+		}
+		receipt := types.NewReceipt(nil, false, 0)
+		receipt.Logs = []*types.Log{{Address: addr}, {Address: addr}, {Address: addr}}
+		gen.AddUncheckedReceipt(receipt)
+		gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.Address{}, big.NewInt(1), 1, gen.BaseFee(), nil))
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	// Page through all the logs and ensure none is skipped or duplicated
+	var (
+		crit  = FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(12), Addresses: []common.Address{addr}, Limit: 2}
+		pages [][]*types.Log
+	)
+	for {
+		logs, err := api.GetLogs(context.Background(), crit)
+		if err != nil {
+			t.Fatalf("failed to retrieve page %d: %v", len(pages), err)
+		}
+		if len(logs) == 0 {
+			break
+		}
+		pages = append(pages, logs)
+
+		last := logs[len(logs)-1]
+		crit.FromBlock, crit.FromLogIndex = new(big.Int).SetUint64(last.BlockNumber), last.Index+1
+	}
+	if len(pages) != 6 {
+		t.Fatalf("page count mismatch: have %d, want 6", len(pages))
+	}
+	for i, page := range pages {
+		for j, log := range page {
+			n := 2*i + j
+			if want := uint64(n/3*3 + 1); log.BlockNumber != want || log.Index != uint(n%3) {
+				t.Errorf("page %d, log %d: position mismatch: have %d/%d, want %d/%d", i, j, log.BlockNumber, log.Index, want, n%3)
+			}
+		}
+	}
+	// Ensure the caps reject oversized queries with a narrower range
+	backend.rangeCap, backend.resultCap = 8, 4
+
+	tests := []struct {
+		crit     FilterCriteria
+		from, to uint64
+	}{
+		{FilterCriteria{FromBlock: big.NewInt(2), ToBlock: big.NewInt(12)}, 2, 9},
+		{FilterCriteria{FromBlock: big.NewInt(6)}, 6, 9},
+		{FilterCriteria{FromBlock: big.NewInt(3)}, 3, 10},
+		{FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(7)}, 0, 3},
+		{FilterCriteria{FromBlock: big.NewInt(4), ToBlock: big.NewInt(11), Limit: 5}, 4, 6},
+	}
+	for i, tt := range tests {
+		_, err := api.GetLogs(context.Background(), tt.crit)
+		limitErr, ok := err.(*LimitExceededError)
+		if !ok {
+			t.Errorf("test %d: expected limit error, got %v", i, err)
+			continue
+		}
+		if limitErr.FromBlock != tt.from || limitErr.ToBlock != tt.to {
+			t.Errorf("test %d: suggested range mismatch: have [%d, %d], want [%d, %d]", i, limitErr.FromBlock, limitErr.ToBlock, tt.from, tt.to)
+		}
+	}
+	if logs, err := api.GetLogs(context.Background(), FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(7), Limit: 4}); err != nil || len(logs) != 4 {
+		t.Errorf("limited query within caps failed: %d logs, %v", len(logs), err)
+	}
+}
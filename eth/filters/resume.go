@@ -0,0 +1,313 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// resumeBatchBlocks is the number of blocks searched at once while a resumable
+// log subscription catches up with the chain head.
+const resumeBatchBlocks = 1024
+
+// allDelivered is the log index marking every log of a block as delivered.
+const allDelivered = ^uint(0)
+
+// LogCursor is the position a resumable log subscription starts from. At most
+// one of FromBlock and FromBlockHash may be set, if none is, only logs of blocks
+// imported after subscribing are delivered.
+//
+// The logs of the starting block are delivered from FromLogIndex onwards. If the
+// block identified by FromBlockHash is no longer canonical, the logs delivered
+// from it (the ones below FromLogIndex) and from its non-canonical ancestors are
+// sent again, marked as removed, before continuing on the canonical chain.
+type LogCursor struct {
+	FromBlock     *hexutil.Uint64 `json:"fromBlock,omitempty"`
+	FromBlockHash *common.Hash    `json:"fromBlockHash,omitempty"`
+	FromLogIndex  hexutil.Uint    `json:"fromLogIndex"`
+}
+
+// ResumableLog is a notification of a resumable log subscription. Subscribing
+// again with its cursor continues right after the log, without gaps or duplicates.
+type ResumableLog struct {
+	Log    *types.Log `json:"log"`
+	Cursor LogCursor  `json:"cursor"`
+}
+
+// ResumableLogs creates a subscription that delivers the logs matching the given
+// criteria exactly once, starting from the given cursor. Historical logs are
+// replayed before switching to the ones of newly imported blocks, and logs of
+// blocks removed by a reorg are sent again with the removed flag set.
+func (api *PublicFilterAPI) ResumableLogs(ctx context.Context, crit FilterCriteria, cursor LogCursor) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if crit.BlockHash != nil || crit.FromBlock != nil || crit.ToBlock != nil {
+		return nil, errors.New("block range not supported by resumable subscriptions, use the cursor")
+	}
+	stream := &logStream{
+		backend:  api.backend,
+		crit:     crit,
+		notifier: notifier,
+	}
+	if err := stream.seek(ctx, cursor); err != nil {
+		return nil, err
+	}
+	// Track new heads before catching up, so no block is missed in between
+	var (
+		rpcSub  = notifier.CreateSubscription()
+		headers = make(chan *types.Header)
+		headSub = api.events.SubscribeNewHeads(headers)
+		wakeup  = make(chan struct{}, 1)
+	)
+	stream.id = rpcSub.ID
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer cancel()
+		defer headSub.Unsubscribe()
+
+		for {
+			select {
+			case <-headers:
+				select {
+				case wakeup <- struct{}{}:
+				default:
+				}
+			case <-rpcSub.Err(): // client send an unsubscribe request
+				return
+			case <-notifier.Closed(): // connection dropped
+				return
+			}
+		}
+	}()
+	go func() {
+		for {
+			if err := stream.sync(ctx); err != nil && ctx.Err() == nil {
+				log.Warn("Failed to deliver resumable logs", "id", rpcSub.ID, "err", err)
+			}
+			select {
+			case <-wakeup:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// logStream tracks the delivery progress of a resumable log subscription.
+type logStream struct {
+	backend  Backend
+	crit     FilterCriteria
+	notifier *rpc.Notifier
+	id       rpc.ID
+
+	number uint64      // Number of the block being delivered
+	hash   common.Hash // Hash of the block being delivered, zero if not yet canonical
+	index  uint        // Index of the first log of the block not yet delivered
+}
+
+// seek positions the stream at the given cursor.
+func (s *logStream) seek(ctx context.Context, cursor LogCursor) error {
+	s.index = uint(cursor.FromLogIndex)
+
+	switch {
+	case cursor.FromBlock != nil && cursor.FromBlockHash != nil:
+		return errors.New("cannot specify both fromBlock and fromBlockHash, choose one or the other")
+
+	case cursor.FromBlockHash != nil:
+		header, err := s.backend.HeaderByHash(ctx, *cursor.FromBlockHash)
+		if err != nil {
+			return err
+		}
+		if header == nil {
+			return errors.New("unknown block")
+		}
+		s.number, s.hash = header.Number.Uint64(), header.Hash()
+
+	case cursor.FromBlock != nil:
+		s.number = uint64(*cursor.FromBlock)
+
+	default:
+		header, err := s.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+		if err != nil {
+			return err
+		}
+		if header != nil {
+			s.number = header.Number.Uint64() + 1
+		}
+		s.index = 0
+	}
+	return nil
+}
+
+// sync delivers the logs between the position of the stream and the current head
+// of the chain, reverting the ones of blocks no longer canonical first.
+func (s *logStream) sync(ctx context.Context) error {
+	for {
+		head, err := s.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+		if head == nil || err != nil {
+			return err
+		}
+		// Revert the logs of the blocks reorged out since their delivery
+		if s.hash == (common.Hash{}) {
+			if s.number > head.Number.Uint64() {
+				return nil
+			}
+			header, err := s.backend.HeaderByNumber(ctx, rpc.BlockNumber(s.number))
+			if header == nil || err != nil {
+				return err
+			}
+			s.hash = header.Hash()
+		}
+		for {
+			canonical, err := s.canonical(ctx, s.number, s.hash)
+			if err != nil {
+				return err
+			}
+			if canonical {
+				break
+			}
+			if err := s.rewind(ctx); err != nil {
+				return err
+			}
+		}
+		// Deliver the remaining logs of the current block
+		if s.index != allDelivered {
+			logs, err := NewBlockFilter(s.backend, s.hash, s.crit.Addresses, s.crit.Topics).Logs(ctx)
+			if err != nil {
+				return err
+			}
+			for _, log := range logs {
+				if log.Index >= s.index {
+					s.deliver(log)
+				}
+			}
+			s.index = allDelivered
+		}
+		// Catch up with the head in batches, starting over if a reorg interferes.
+		// Batches are limited like eth_getLogs queries.
+		batch, resultCap := uint64(resumeBatchBlocks), s.backend.RPCLogsResultCap()
+		if rangeCap := s.backend.RPCLogsRangeCap(); rangeCap > 0 && rangeCap < batch {
+			batch = rangeCap
+		}
+		for s.number < head.Number.Uint64() {
+			end := s.number + batch
+			if end > head.Number.Uint64() {
+				end = head.Number.Uint64()
+			}
+			last, err := s.backend.HeaderByNumber(ctx, rpc.BlockNumber(end))
+			if last == nil || err != nil {
+				return err
+			}
+			filter := NewRangeFilter(s.backend, int64(s.number+1), int64(end), s.crit.Addresses, s.crit.Topics)
+			filter.Paginate(int(resultCap), 0)
+			logs, err := filter.Logs(ctx)
+			if err != nil {
+				return err
+			}
+			if ok, err := s.canonical(ctx, end, last.Hash()); !ok || err != nil {
+				if err != nil {
+					return err
+				}
+				break
+			}
+			if ok, err := s.canonical(ctx, s.number, s.hash); !ok || err != nil {
+				if err != nil {
+					return err
+				}
+				break
+			}
+			for _, log := range logs {
+				s.deliver(log)
+			}
+			if resultCap > 0 && uint64(len(logs)) >= resultCap {
+				// The batch may be cut short, continue right after its last log
+				final := logs[len(logs)-1]
+				s.number, s.hash, s.index = final.BlockNumber, final.BlockHash, final.Index+1
+				break
+			}
+			s.number, s.hash = end, last.Hash()
+		}
+		if s.number >= head.Number.Uint64() && s.index == allDelivered {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// rewind reverts the delivered logs of the current, no longer canonical block
+// and moves the stream onto its parent.
+func (s *logStream) rewind(ctx context.Context) error {
+	header, err := s.backend.HeaderByHash(ctx, s.hash)
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		return errors.New("unknown reorged block")
+	}
+	if s.index > 0 {
+		logs, err := NewBlockFilter(s.backend, s.hash, s.crit.Addresses, s.crit.Topics).Logs(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(logs) - 1; i >= 0; i-- {
+			if logs[i].Index >= s.index {
+				continue
+			}
+			removed := *logs[i]
+			removed.Removed = true
+			s.notify(&removed, removed.Index)
+		}
+	}
+	s.number, s.hash, s.index = header.Number.Uint64()-1, header.ParentHash, allDelivered
+	return nil
+}
+
+// deliver sends a log of a canonical block and advances the stream past it.
+func (s *logStream) deliver(log *types.Log) {
+	s.notify(log, log.Index+1)
+}
+
+// notify sends a log along with the cursor pointing right after it.
+func (s *logStream) notify(log *types.Log, next uint) {
+	hash := log.BlockHash
+	s.notifier.Notify(s.id, &ResumableLog{
+		Log:    log,
+		Cursor: LogCursor{FromBlockHash: &hash, FromLogIndex: hexutil.Uint(next)},
+	})
+}
+
+// canonical reports whether the given block is part of the canonical chain.
+func (s *logStream) canonical(ctx context.Context, number uint64, hash common.Hash) (bool, error) {
+	header, err := s.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+	if err != nil {
+		return false, err
+	}
+	return header != nil && header.Hash() == hash, nil
+}
@@ -0,0 +1,240 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// resumeTestChain generates a chain of the given length on top of the parent,
+// with two logs of the given address in every block. The first topic of the logs
+// identifies the chain, the second one the block.
+func resumeTestChain(db ethdb.Database, parent *types.Block, n int, addr common.Address, id byte) []*types.Block {
+	blocks, receipts := core.GenerateChain(params.TestChainConfig, parent, ethash.NewFaker(), db, n, func(i int, gen *core.BlockGen) {
+		number := common.BigToHash(gen.Number())
+
+		receipt := types.NewReceipt(nil, false, 0)
+		receipt.Logs = []*types.Log{
+			{Address: addr, Topics: []common.Hash{{id}, number}},
+			{Address: addr, Topics: []common.Hash{{id}, number}},
+		}
+		gen.AddUncheckedReceipt(receipt)
+		gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.Address{id}, big.NewInt(1), 1, gen.BaseFee(), nil))
+	})
+	for i, block := range blocks {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	return blocks
+}
+
+// setResumeTestHead makes the given blocks canonical.
+func setResumeTestHead(db ethdb.Database, blocks []*types.Block) {
+	for _, block := range blocks {
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+	}
+	rawdb.WriteHeadBlockHash(db, blocks[len(blocks)-1].Hash())
+}
+
+// expectResumableLogs waits for the given sequence of logs, identified by their
+// block and index, and ensures the cursors point right after them.
+func expectResumableLogs(t *testing.T, ch chan *ResumableLog, blocks []*types.Block, removed bool, reverse bool) {
+	t.Helper()
+
+	var want []*types.Log
+	for _, block := range blocks {
+		for index := uint(0); index < 2; index++ {
+			want = append(want, &types.Log{BlockHash: block.Hash(), BlockNumber: block.NumberU64(), Index: index})
+		}
+	}
+	if reverse {
+		for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+			want[i], want[j] = want[j], want[i]
+		}
+	}
+	for i, w := range want {
+		select {
+		case have := <-ch:
+			if have.Log.BlockHash != w.BlockHash || have.Log.Index != w.Index || have.Log.Removed != removed {
+				t.Fatalf("log %d: mismatch: have %d/%x/%d removed %v, want %d/%x/%d removed %v", i,
+					have.Log.BlockNumber, have.Log.BlockHash[:4], have.Log.Index, have.Log.Removed,
+					w.BlockNumber, w.BlockHash[:4], w.Index, removed)
+			}
+			next := w.Index + 1
+			if removed {
+				next = w.Index
+			}
+			if *have.Cursor.FromBlockHash != w.BlockHash || uint(have.Cursor.FromLogIndex) != next {
+				t.Fatalf("log %d: cursor mismatch: have %x/%d, want %x/%d", i, have.Cursor.FromBlockHash[:4], have.Cursor.FromLogIndex, w.BlockHash[:4], next)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("log %d: timeout waiting for %d/%x/%d", i, w.BlockNumber, w.BlockHash[:4], w.Index)
+		}
+	}
+}
+
+func TestResumableLogs(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		api     = NewPublicFilterAPI(backend, false, deadline)
+		addr    = common.BytesToAddress([]byte("address"))
+		genesis = core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	)
+	rawdb.WriteCanonicalHash(db, genesis.Hash(), 0)
+
+	// Create two forks diverging after block 3, the first one canonical
+	chainA := resumeTestChain(db, genesis, 6, addr, 0xa)
+	chainB := resumeTestChain(db, chainA[2], 4, addr, 0xb)
+	setResumeTestHead(db, chainA)
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	// Replay the full history and ensure reorgs are reported in order
+	var (
+		ctx   = context.Background()
+		crit  = map[string]interface{}{"address": addr}
+		logs  = make(chan *ResumableLog)
+		start = map[string]interface{}{"fromBlock": "0x1"}
+	)
+	sub, err := client.EthSubscribe(ctx, logs, "resumableLogs", crit, start)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	expectResumableLogs(t, logs, chainA, false, false)
+
+	setResumeTestHead(db, append(chainA[:3:3], chainB...))
+	backend.chainFeed.Send(core.ChainEvent{Block: chainB[3], Hash: chainB[3].Hash()})
+
+	expectResumableLogs(t, logs, chainA[3:], true, true)
+	expectResumableLogs(t, logs, chainB, false, false)
+	sub.Unsubscribe()
+
+	// Resume from the middle of a block which has been reorged out since
+	sub, err = client.EthSubscribe(ctx, logs, "resumableLogs", crit, map[string]interface{}{
+		"fromBlockHash": chainA[4].Hash(),
+		"fromLogIndex":  "0x1",
+	})
+	if err != nil {
+		t.Fatalf("failed to resubscribe: %v", err)
+	}
+	select {
+	case have := <-logs:
+		if have.Log.BlockHash != chainA[4].Hash() || have.Log.Index != 0 || !have.Log.Removed {
+			t.Fatalf("partially delivered block not reverted: %d/%d removed %v", have.Log.BlockNumber, have.Log.Index, have.Log.Removed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for reverted log")
+	}
+	expectResumableLogs(t, logs, chainA[3:4], true, true)
+	expectResumableLogs(t, logs, chainB, false, false)
+	sub.Unsubscribe()
+
+	// Resume from the middle of a canonical block and ensure nothing is repeated
+	sub, err = client.EthSubscribe(ctx, logs, "resumableLogs", crit, map[string]interface{}{
+		"fromBlockHash": chainB[3].Hash(),
+		"fromLogIndex":  "0x1",
+	})
+	if err != nil {
+		t.Fatalf("failed to resubscribe: %v", err)
+	}
+	select {
+	case have := <-logs:
+		if have.Log.BlockHash != chainB[3].Hash() || have.Log.Index != 1 || have.Log.Removed {
+			t.Fatalf("wrong log resumed: %d/%d removed %v", have.Log.BlockNumber, have.Log.Index, have.Log.Removed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for resumed log")
+	}
+	select {
+	case have := <-logs:
+		t.Fatalf("unexpected log delivered: %d/%d", have.Log.BlockNumber, have.Log.Index)
+	case <-time.After(100 * time.Millisecond):
+	}
+	sub.Unsubscribe()
+
+	// Ensure invalid cursors are rejected
+	if _, err := client.EthSubscribe(ctx, logs, "resumableLogs", crit, map[string]interface{}{
+		"fromBlock":     "0x1",
+		"fromBlockHash": chainB[3].Hash(),
+	}); err == nil {
+		t.Fatalf("conflicting cursor accepted")
+	}
+	if _, err := client.EthSubscribe(ctx, logs, "resumableLogs", crit, map[string]interface{}{
+		"fromBlockHash": common.Hash{0xff},
+	}); err == nil {
+		t.Fatalf("unknown block accepted")
+	}
+}
+
+// This test checks that the history is replayed in batches within the block range
+// and result caps of eth_getLogs, without gaps or duplicates.
+func TestResumableLogsLimits(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db, rangeCap: 2, resultCap: 3}
+		api     = NewPublicFilterAPI(backend, false, deadline)
+		addr    = common.BytesToAddress([]byte("address"))
+		genesis = core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	)
+	rawdb.WriteCanonicalHash(db, genesis.Hash(), 0)
+	chain := resumeTestChain(db, genesis, 7, addr, 0xa)
+	setResumeTestHead(db, chain)
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	var (
+		crit  = map[string]interface{}{"address": addr}
+		logs  = make(chan *ResumableLog)
+		start = map[string]interface{}{"fromBlock": "0x1"}
+	)
+	sub, err := client.EthSubscribe(context.Background(), logs, "resumableLogs", crit, start)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+	expectResumableLogs(t, logs, chain, false, false)
+	select {
+	case have := <-logs:
+		t.Fatalf("unexpected log delivered: %d/%d", have.Log.BlockNumber, have.Log.Index)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -459,10 +459,11 @@ func (h *handler) runSnapExtension(peer *snap.Peer, handler snap.Handler) error
 	return handler(peer)
 }
 
-// removePeer requests disconnection of a peer.
+// removePeer requests disconnection of a misbehaving peer.
 func (h *handler) removePeer(id string) {
 	peer := h.peers.peer(id)
 	if peer != nil {
+		peer.Peer.Report(p2p.InvalidData)
 		peer.Peer.Disconnect(p2p.DiscUselessPeer)
 	}
 }
@@ -564,7 +565,7 @@ func (h *handler) BroadcastBlock(block *types.Block, propagate bool) {
 			return
 		}
 		// Send the block to a subset of our peers
-		transfer := peers[:int(math.Sqrt(float64(len(peers))))]
+		transfer := peers[:directPeerCount(peers)]
 		for _, peer := range transfer {
 			peer.AsyncSendNewBlock(block, td)
 		}
@@ -599,7 +600,7 @@ func (h *handler) BroadcastTransactions(txs types.Transactions) {
 	for _, tx := range txs {
 		peers := h.peers.peersWithoutTransaction(tx.Hash())
 		// Send the tx unconditionally to a subset of our peers
-		numDirect := int(math.Sqrt(float64(len(peers))))
+		numDirect := directPeerCount(peers)
 		for _, peer := range peers[:numDirect] {
 			txset[peer] = append(txset[peer], tx.Hash())
 		}
@@ -623,6 +624,23 @@ func (h *handler) BroadcastTransactions(txs types.Transactions) {
 		"tx packs", directPeers, "broadcast txs", directCount)
 }
 
+// directPeerCount returns the number of peers which a block or transaction is
+// sent to directly. It moves private peers, i.e. validators behind this sentry,
+// to the front of the slice, so they always receive data directly.
+func directPeerCount(peers []*ethPeer) int {
+	private := 0
+	for i, peer := range peers {
+		if peer.Peer.Private() {
+			peers[private], peers[i] = peers[i], peers[private]
+			private++
+		}
+	}
+	if n := int(math.Sqrt(float64(len(peers)))); n > private {
+		return n
+	}
+	return private
+}
+
 // minedBroadcastLoop sends mined blocks to connected peers.
 func (h *handler) minedBroadcastLoop() {
 	defer h.wg.Done()
@@ -26,6 +26,7 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 )
 
@@ -71,16 +72,38 @@ func (h *ethHandler) Handle(peer *eth.Peer, packet eth.Packet) error {
 		return h.txFetcher.Notify(peer.ID(), *packet)
 
 	case *eth.TransactionsPacket:
-		return h.txFetcher.Enqueue(peer.ID(), *packet, false)
+		return h.handleTransactions(peer, *packet, false)
 
 	case *eth.PooledTransactionsPacket:
-		return h.txFetcher.Enqueue(peer.ID(), *packet, true)
+		return h.handleTransactions(peer, *packet, true)
 
 	default:
 		return fmt.Errorf("unexpected eth packet type: %T", packet)
 	}
 }
 
+// handleTransactions is invoked from a peer's message handler when it transmits
+// transactions, either as a broadcast or in reply to a request. The peer is
+// credited if any of them were new and made it into the pool.
+func (h *ethHandler) handleTransactions(peer *eth.Peer, txs []*types.Transaction, direct bool) error {
+	var fresh []common.Hash
+	for _, tx := range txs {
+		if !h.txpool.Has(tx.Hash()) {
+			fresh = append(fresh, tx.Hash())
+		}
+	}
+	if err := h.txFetcher.Enqueue(peer.ID(), txs, direct); err != nil {
+		return err
+	}
+	for _, hash := range fresh {
+		if h.txpool.Has(hash) {
+			peer.Peer.Report(p2p.UsefulTransactions)
+			break
+		}
+	}
+	return nil
+}
+
 // handleBlockAnnounces is invoked from a peer's message handler when it transmits a
 // batch of block announcements for the local node to process.
 func (h *ethHandler) handleBlockAnnounces(peer *eth.Peer, hashes []common.Hash, numbers []uint64) error {
@@ -120,6 +143,10 @@ func (h *ethHandler) handleBlockBroadcast(peer *eth.Peer, block *types.Block, td
 		return nil
 		// return errors.New("unexpected block announces")
 	}
+	// Credit the peer for propagating blocks we didn't have yet
+	if !h.chain.HasBlock(block.Hash(), block.NumberU64()) {
+		peer.Peer.Report(p2p.UsefulBlock)
+	}
 	// Schedule the block for import
 	h.blockFetcher.Enqueue(peer.ID(), block)
 
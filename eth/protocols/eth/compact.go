@@ -0,0 +1,283 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/trie"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// compactBlockTimeout is how long to wait for the missing transactions of a
+	// compact block before falling back to retrieving the full block.
+	compactBlockTimeout = 1 * time.Second
+
+	// maxPendingCompactBlocks is the maximum number of compact blocks waiting for
+	// missing transactions from a single peer.
+	maxPendingCompactBlocks = 4
+
+	// maxServedCompactBlocks is the number of blocks recently propagated as compact
+	// blocks that are kept for serving their transactions, as they may not have
+	// been imported yet.
+	maxServedCompactBlocks = 8
+
+	// maxCompactIndexes is the number of recently announced blocks for which the
+	// short ID index of the transaction pool is kept.
+	maxCompactIndexes = 8
+)
+
+var (
+	compactReconstructMeter = metrics.NewRegisteredMeter("eth/compact/reconstructed", nil)
+	compactFallbackMeter    = metrics.NewRegisteredMeter("eth/compact/fallback", nil)
+)
+
+// compactBlocks tracks the compact blocks announced to the local node by all peers.
+var compactBlocks = newCompactTracker()
+
+// shortTxID computes the short ID of a transaction within a block. The ID is
+// salted with the block hash, so colliding transactions can't be crafted before
+// the block exists.
+func shortTxID(block common.Hash, tx common.Hash) ShortTxID {
+	var id ShortTxID
+	copy(id[:], crypto.Keccak256(block[:], tx[:]))
+	return id
+}
+
+// newCompactBlockPacket creates the compact form of a block.
+func newCompactBlockPacket(block *types.Block, td *big.Int) *NewCompactBlockPacket {
+	hash := block.Hash()
+	ids := make([]ShortTxID, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		ids[i] = shortTxID(hash, tx.Hash())
+	}
+	return &NewCompactBlockPacket{
+		Header: block.Header(),
+		Uncles: block.Uncles(),
+		TxIDs:  ids,
+		TD:     td,
+	}
+}
+
+// reconstructTxs looks up the transactions of a compact block in the pool. It
+// returns the transactions, with nil for those not found, and the indexes of the
+// missing ones.
+func reconstructTxs(pool TxPool, hash common.Hash, ids []ShortTxID) ([]*types.Transaction, []uint64) {
+	var (
+		index   = compactBlocks.index(pool, hash)
+		txs     = make([]*types.Transaction, len(ids))
+		missing []uint64
+	)
+	for i, id := range ids {
+		if txs[i] = index[id]; txs[i] == nil {
+			missing = append(missing, uint64(i))
+		}
+	}
+	return txs, missing
+}
+
+// compactKey identifies a block announced to a node. Blocks are tracked per pool
+// since a process may run several nodes.
+type compactKey struct {
+	pool TxPool
+	hash common.Hash
+}
+
+// compactTracker caches the short ID index of the transaction pool for recently
+// announced blocks, so the pool is only hashed once per block rather than once per
+// announcement. It also tracks the blocks waiting for missing transactions from
+// any peer, so the same block isn't reconstructed from several peers at once.
+type compactTracker struct {
+	indexes  *lru.Cache              // Short ID indexes of recently announced blocks
+	inflight map[compactKey]struct{} // Blocks waiting for missing transactions
+	lock     sync.Mutex
+}
+
+func newCompactTracker() *compactTracker {
+	indexes, _ := lru.New(maxCompactIndexes)
+	return &compactTracker{
+		indexes:  indexes,
+		inflight: make(map[compactKey]struct{}),
+	}
+}
+
+// index returns the pooled transactions by their short IDs within a block.
+func (t *compactTracker) index(pool TxPool, hash common.Hash) map[ShortTxID]*types.Transaction {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	key := compactKey{pool, hash}
+	if index, ok := t.indexes.Get(key); ok {
+		return index.(map[ShortTxID]*types.Transaction)
+	}
+	index := make(map[ShortTxID]*types.Transaction)
+	for _, list := range pool.Pending(false) {
+		for _, tx := range list {
+			index[shortTxID(hash, tx.Hash())] = tx
+		}
+	}
+	t.indexes.Add(key, index)
+	return index
+}
+
+// pending reports whether a block is waiting for missing transactions.
+func (t *compactTracker) pending(pool TxPool, hash common.Hash) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	_, ok := t.inflight[compactKey{pool, hash}]
+	return ok
+}
+
+// track marks a block as waiting for missing transactions. It returns false if
+// the block is already being retrieved.
+func (t *compactTracker) track(pool TxPool, hash common.Hash) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	key := compactKey{pool, hash}
+	if _, ok := t.inflight[key]; ok {
+		return false
+	}
+	t.inflight[key] = struct{}{}
+	return true
+}
+
+// untrack removes a block from the ones waiting for missing transactions.
+func (t *compactTracker) untrack(pool TxPool, hash common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.inflight, compactKey{pool, hash})
+}
+
+// compactBlock is a compact block waiting for its missing transactions.
+type compactBlock struct {
+	packet   *NewCompactBlockPacket
+	txs      []*types.Transaction
+	missing  []uint64
+	received time.Time
+	timer    *time.Timer
+}
+
+// deliverCompactBlock assembles a compact block whose transactions are complete
+// and hands it to the backend like a full block propagation. If the transactions
+// don't match the header due to a short ID collision, the block is announced to
+// the backend instead, so it's retrieved in full.
+func deliverCompactBlock(backend Backend, peer *Peer, packet *NewCompactBlockPacket, txs []*types.Transaction, received time.Time) error {
+	block := types.NewBlockWithHeader(packet.Header).WithBody(txs, packet.Uncles)
+	if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != block.TxHash() {
+		peer.Log().Debug("Compact block reconstruction failed", "number", block.Number(), "hash", block.Hash())
+		return fallbackCompactBlock(backend, peer, packet)
+	}
+	ann := &NewBlockPacket{Block: block, TD: packet.TD}
+	if err := ann.sanityCheck(); err != nil {
+		return err
+	}
+	block.ReceivedAt = received
+	block.ReceivedFrom = peer
+	compactReconstructMeter.Mark(1)
+	return backend.Handle(peer, ann)
+}
+
+// fallbackCompactBlock announces a compact block which couldn't be reconstructed
+// to the backend, which then retrieves the full block.
+func fallbackCompactBlock(backend Backend, peer *Peer, packet *NewCompactBlockPacket) error {
+	compactFallbackMeter.Mark(1)
+	return backend.Handle(peer, &NewBlockHashesPacket{{
+		Hash:   packet.Header.Hash(),
+		Number: packet.Header.Number.Uint64(),
+	}})
+}
+
+// requestBlockTransactions retrieves the missing transactions of a compact block.
+// If they don't arrive in time, the block is retrieved in full.
+func (p *Peer) requestBlockTransactions(backend Backend, pending *compactBlock) error {
+	hash := pending.packet.Header.Hash()
+
+	p.compactLock.Lock()
+	if len(p.compactBlocks) >= maxPendingCompactBlocks {
+		p.compactLock.Unlock()
+		return fallbackCompactBlock(backend, p, pending.packet)
+	}
+	if !compactBlocks.track(p.txpool, hash) {
+		p.compactLock.Unlock()
+		return nil // already being retrieved from another peer
+	}
+	p.compactBlocks[hash] = pending
+	pending.timer = time.AfterFunc(compactBlockTimeout, func() {
+		if p.takeCompactBlock(hash) != nil {
+			p.Log().Debug("Compact block transactions timed out", "hash", hash)
+			if err := fallbackCompactBlock(backend, p, pending.packet); err != nil {
+				log.Debug("Failed to retrieve compact block", "hash", hash, "err", err)
+			}
+		}
+	})
+	p.compactLock.Unlock()
+
+	id := rand.Uint64()
+	requestTracker.Track(p.id, p.version, GetBlockTransactionsMsg, BlockTransactionsMsg, id)
+	return p.sendGetBlockTransactions(id, hash, pending.missing)
+}
+
+// takeCompactBlock removes a pending compact block.
+func (p *Peer) takeCompactBlock(hash common.Hash) *compactBlock {
+	p.compactLock.Lock()
+	defer p.compactLock.Unlock()
+
+	pending := p.compactBlocks[hash]
+	if pending != nil {
+		pending.timer.Stop()
+		delete(p.compactBlocks, hash)
+		compactBlocks.untrack(p.txpool, hash)
+	}
+	return pending
+}
+
+// rememberServedBlock keeps a block propagated in compact form, so its missing
+// transactions can be served before the block is imported.
+func (p *Peer) rememberServedBlock(block *types.Block) {
+	p.compactLock.Lock()
+	defer p.compactLock.Unlock()
+
+	if len(p.servedBlocks) >= maxServedCompactBlocks {
+		p.servedBlocks = p.servedBlocks[1:]
+	}
+	p.servedBlocks = append(p.servedBlocks, block)
+}
+
+// servedBlock returns a recently propagated compact block.
+func (p *Peer) servedBlock(hash common.Hash) *types.Block {
+	p.compactLock.Lock()
+	defer p.compactLock.Unlock()
+
+	for _, block := range p.servedBlocks {
+		if block.Hash() == hash {
+			return block
+		}
+	}
+	return nil
+}
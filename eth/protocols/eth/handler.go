@@ -17,6 +17,7 @@
 package eth
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 	"time"
@@ -91,6 +92,10 @@ type Backend interface {
 type TxPool interface {
 	// Get retrieves the transaction from the local txpool with the given hash.
 	Get(hash common.Hash) *types.Transaction
+
+	// Pending retrieves the executable transactions of the local txpool, which
+	// are used to reconstruct compact blocks.
+	Pending(enforceTips bool) map[common.Address]types.Transactions
 }
 
 // MakeProtocols constructs the P2P protocol definitions for `eth`.
@@ -119,11 +124,25 @@ func MakeProtocols(backend Backend, network uint64, dnsdisc enode.Iterator) []p2
 			},
 			Attributes:     []enr.Entry{currentENREntry(backend.Chain())},
 			DialCandidates: dnsdisc,
+			TrafficClass:   trafficClass,
 		}
 	}
 	return protocols
 }
 
+// trafficClass prioritizes block propagation over other messages when outbound
+// bandwidth is limited. State data for fast sync is served at the lowest priority.
+func trafficClass(code uint64) p2p.TrafficClass {
+	switch code {
+	case NewBlockHashesMsg, NewBlockMsg, NewCompactBlockMsg, GetBlockTransactionsMsg, BlockTransactionsMsg:
+		return p2p.TrafficHigh
+	case NodeDataMsg:
+		return p2p.TrafficBulk
+	default:
+		return p2p.TrafficNormal
+	}
+}
+
 // NodeInfo represents a short summary of the `eth` sub-protocol metadata
 // known about the host peer.
 type NodeInfo struct {
@@ -153,6 +172,9 @@ func Handle(backend Backend, peer *Peer) error {
 	for {
 		if err := handleMessage(backend, peer); err != nil {
 			peer.Log().Debug("Message handling failed in `eth`", "err", err)
+			if errors.Is(err, errDecode) || errors.Is(err, errMsgTooLarge) || errors.Is(err, errInvalidMsgCode) {
+				peer.Peer.Report(p2p.InvalidData)
+			}
 			return err
 		}
 	}
@@ -181,6 +203,39 @@ var eth66 = map[uint64]msgHandler{
 	PooledTransactionsMsg:         handlePooledTransactions66,
 }
 
+var eth67 = map[uint64]msgHandler{
+	NewBlockHashesMsg:             handleNewBlockhashes,
+	NewBlockMsg:                   handleNewBlock,
+	TransactionsMsg:               handleTransactions,
+	NewPooledTransactionHashesMsg: handleNewPooledTransactionHashes,
+	GetBlockHeadersMsg:            handleGetBlockHeaders66,
+	BlockHeadersMsg:               handleBlockHeaders66,
+	GetBlockBodiesMsg:             handleGetBlockBodies66,
+	BlockBodiesMsg:                handleBlockBodies66,
+	GetReceiptsMsg:                handleGetReceipts66,
+	ReceiptsMsg:                   handleReceipts66,
+	GetPooledTransactionsMsg:      handleGetPooledTransactions66,
+	PooledTransactionsMsg:         handlePooledTransactions66,
+}
+
+var ethCompact = map[uint64]msgHandler{
+	NewBlockHashesMsg:             handleNewBlockhashes,
+	NewBlockMsg:                   handleNewBlock,
+	TransactionsMsg:               handleTransactions,
+	NewPooledTransactionHashesMsg: handleNewPooledTransactionHashes,
+	GetBlockHeadersMsg:            handleGetBlockHeaders66,
+	BlockHeadersMsg:               handleBlockHeaders66,
+	GetBlockBodiesMsg:             handleGetBlockBodies66,
+	BlockBodiesMsg:                handleBlockBodies66,
+	GetReceiptsMsg:                handleGetReceipts66,
+	ReceiptsMsg:                   handleReceipts66,
+	GetPooledTransactionsMsg:      handleGetPooledTransactions66,
+	PooledTransactionsMsg:         handlePooledTransactions66,
+	NewCompactBlockMsg:            handleNewCompactBlock,
+	GetBlockTransactionsMsg:       handleGetBlockTransactions67,
+	BlockTransactionsMsg:          handleBlockTransactions67,
+}
+
 // handleMessage is invoked whenever an inbound message is received from a remote
 // peer. The remote connection is torn down upon returning any error.
 func handleMessage(backend Backend, peer *Peer) error {
@@ -195,9 +250,12 @@ func handleMessage(backend Backend, peer *Peer) error {
 	defer msg.Discard()
 
 	var handlers = eth66
-	//if peer.Version() >= ETH67 { // Left in as a sample when new protocol is added
-	//	handlers = eth67
-	//}
+	if peer.Version() >= ETH67 {
+		handlers = eth67
+	}
+	if peer.Version() >= ETHCompact {
+		handlers = ethCompact
+	}
 
 	// Track the amount of time it takes to serve the request and run the handler
 	if metrics.Enabled {
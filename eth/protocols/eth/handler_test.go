@@ -17,10 +17,13 @@
 package eth
 
 import (
+	"errors"
 	"math"
 	"math/big"
 	"math/rand"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
@@ -557,3 +560,177 @@ func testGetBlockReceipts(t *testing.T, protocol uint) {
 		t.Errorf("receipts mismatch: %v", err)
 	}
 }
+
+// compactBackend is a testBackend which records the packets passed to Handle.
+type compactBackend struct {
+	*testBackend
+	handled chan Packet
+}
+
+func (b *compactBackend) Handle(peer *Peer, packet Packet) error {
+	b.handled <- packet
+	return nil
+}
+
+// Tests that compact blocks are reconstructed from the local pool, with the missing
+// transactions retrieved from the remote peer.
+func TestCompactBlock(t *testing.T) {
+	t.Parallel()
+
+	// Create a block with two transactions, and a backend which has only seen one
+	signer := types.LatestSigner(params.TestChainConfig)
+	var txs []*types.Transaction
+	source := newTestBackendWithGenerator(1, func(i int, block *core.BlockGen) {
+		for j := 0; j < 2; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testAddr), common.Address{}, big.NewInt(1), params.TxGas, block.BaseFee(), nil), signer, testKey)
+			block.AddTx(tx)
+			txs = append(txs, tx)
+		}
+	})
+	defer source.close()
+	block := source.chain.CurrentBlock()
+
+	backend := &compactBackend{testBackend: newTestBackend(0), handled: make(chan Packet, 1)}
+	defer backend.close()
+	if errs := backend.txpool.AddRemotesSync(txs[:1]); errs[0] != nil {
+		t.Fatalf("failed to add transaction: %v", errs[0])
+	}
+	peer, _ := newTestPeer("peer", ETHCompact, backend)
+	defer peer.close()
+
+	// Relay the compact block and expect the missing transaction to be requested
+	p2p.Send(peer.app, NewCompactBlockMsg, newCompactBlockPacket(block, big.NewInt(1)))
+	msg, err := peer.app.ReadMsg()
+	if err != nil {
+		t.Fatalf("failed to read request: %v", err)
+	}
+	if msg.Code != GetBlockTransactionsMsg {
+		t.Fatalf("wrong message code: have %d, want %d", msg.Code, GetBlockTransactionsMsg)
+	}
+	var req GetBlockTransactionsPacket67
+	if err := msg.Decode(&req); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+	if req.BlockHash != block.Hash() || !reflect.DeepEqual(req.Indexes, []uint64{1}) {
+		t.Fatalf("wrong request: hash %x, indexes %v", req.BlockHash, req.Indexes)
+	}
+	// Relay the same block from another peer and expect it to be skipped while the
+	// transactions are retrieved from the first one
+	other, _ := newTestPeer("other", ETHCompact, backend)
+	defer other.close()
+
+	p2p.Send(other.app, NewCompactBlockMsg, newCompactBlockPacket(block, big.NewInt(1)))
+	p2p.Send(other.app, GetBlockTransactionsMsg, &GetBlockTransactionsPacket67{RequestId: 1})
+	if err := p2p.ExpectMsg(other.app, BlockTransactionsMsg, &BlockTransactionsPacket67{RequestId: 1}); err != nil {
+		t.Fatalf("in-flight block requested again: %v", err)
+	}
+	// Serve the transaction from the chain and expect the block to be delivered
+	server, _ := newTestPeer("server", ETHCompact, source)
+	defer server.close()
+
+	p2p.Send(server.app, GetBlockTransactionsMsg, &req)
+	if err := p2p.ExpectMsg(server.app, BlockTransactionsMsg, &BlockTransactionsPacket67{
+		RequestId: req.RequestId,
+		BlockTransactionsPacket: BlockTransactionsPacket{
+			BlockHash:    block.Hash(),
+			Transactions: txs[1:],
+		},
+	}); err != nil {
+		t.Fatalf("transactions mismatch: %v", err)
+	}
+	p2p.Send(peer.app, BlockTransactionsMsg, &BlockTransactionsPacket67{
+		RequestId: req.RequestId,
+		BlockTransactionsPacket: BlockTransactionsPacket{
+			BlockHash:    block.Hash(),
+			Transactions: txs[1:],
+		},
+	})
+	select {
+	case packet := <-backend.handled:
+		ann, ok := packet.(*NewBlockPacket)
+		if !ok {
+			t.Fatalf("wrong packet delivered: %T", packet)
+		}
+		if ann.Block.Hash() != block.Hash() || ann.Block.Transactions().Len() != 2 {
+			t.Fatalf("wrong block delivered: %x", ann.Block.Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("block not delivered")
+	}
+	// A compact block with unknown transactions falls back to an announcement
+	p2p.Send(peer.app, NewCompactBlockMsg, &NewCompactBlockPacket{
+		Header: block.Header(),
+		TxIDs:  []ShortTxID{{1}},
+		TD:     big.NewInt(1),
+	})
+	if _, err := peer.app.ReadMsg(); err != nil {
+		t.Fatalf("failed to read request: %v", err)
+	}
+	select {
+	case packet := <-backend.handled:
+		if _, ok := packet.(*NewBlockHashesPacket); !ok {
+			t.Fatalf("wrong packet delivered: %T", packet)
+		}
+	case <-time.After(2 * compactBlockTimeout):
+		t.Fatal("block not announced")
+	}
+}
+
+// Tests that the transactions of compact blocks are served, and that malformed
+// queries disconnect the peer.
+func TestGetBlockTransactions(t *testing.T) {
+	t.Parallel()
+
+	signer := types.LatestSigner(params.TestChainConfig)
+	var txs []*types.Transaction
+	backend := newTestBackendWithGenerator(1, func(i int, block *core.BlockGen) {
+		for j := 0; j < 2; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testAddr), common.Address{}, big.NewInt(1), params.TxGas, block.BaseFee(), nil), signer, testKey)
+			block.AddTx(tx)
+			txs = append(txs, tx)
+		}
+	})
+	defer backend.close()
+	hash := backend.chain.CurrentBlock().Hash()
+
+	// Duplicate indexes are only served once
+	peer, _ := newTestPeer("peer", ETHCompact, backend)
+	defer peer.close()
+
+	p2p.Send(peer.app, GetBlockTransactionsMsg, &GetBlockTransactionsPacket67{
+		RequestId: 1,
+		GetBlockTransactionsPacket: GetBlockTransactionsPacket{
+			BlockHash: hash,
+			Indexes:   []uint64{1, 1},
+		},
+	})
+	if err := p2p.ExpectMsg(peer.app, BlockTransactionsMsg, &BlockTransactionsPacket67{
+		RequestId: 1,
+		BlockTransactionsPacket: BlockTransactionsPacket{
+			BlockHash:    hash,
+			Transactions: []*types.Transaction{txs[1]},
+		},
+	}); err != nil {
+		t.Fatalf("transactions mismatch: %v", err)
+	}
+	// Out of range indexes and oversized queries are rejected
+	for _, indexes := range [][]uint64{{2}, {0, 1, 1}} {
+		peer, errc := newTestPeer("peer", ETHCompact, backend)
+		p2p.Send(peer.app, GetBlockTransactionsMsg, &GetBlockTransactionsPacket67{
+			RequestId: 1,
+			GetBlockTransactionsPacket: GetBlockTransactionsPacket{
+				BlockHash: hash,
+				Indexes:   indexes,
+			},
+		})
+		select {
+		case err := <-errc:
+			if !errors.Is(err, errDecode) {
+				t.Errorf("indexes %v: wrong error: %v", indexes, err)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("indexes %v: peer not dropped", indexes)
+		}
+		peer.close()
+	}
+}
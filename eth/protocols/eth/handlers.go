@@ -526,3 +526,97 @@ func handlePooledTransactions66(backend Backend, msg Decoder, peer *Peer) error
 
 	return backend.Handle(peer, &txs.PooledTransactionsPacket)
 }
+
+func handleNewCompactBlock(backend Backend, msg Decoder, peer *Peer) error {
+	// Retrieve and decode the propagated compact block
+	ann := new(NewCompactBlockPacket)
+	if err := msg.Decode(ann); err != nil {
+		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+	}
+	if err := ann.sanityCheck(); err != nil {
+		return err
+	}
+	if hash := types.CalcUncleHash(ann.Uncles); hash != ann.Header.UncleHash {
+		log.Warn("Propagated compact block has invalid uncles", "have", hash, "exp", ann.Header.UncleHash)
+		return nil
+	}
+	// Mark the peer as owning the block
+	hash := ann.Header.Hash()
+	peer.markBlock(hash)
+
+	// Skip blocks which are already imported or retrieved from another peer
+	if backend.Chain().HasBlock(hash, ann.Header.Number.Uint64()) || compactBlocks.pending(backend.TxPool(), hash) {
+		return nil
+	}
+	// Reconstruct the block from the local pool, fetching any missing transactions
+	txs, missing := reconstructTxs(backend.TxPool(), hash, ann.TxIDs)
+	if len(missing) == 0 {
+		return deliverCompactBlock(backend, peer, ann, txs, msg.Time())
+	}
+	return peer.requestBlockTransactions(backend, &compactBlock{
+		packet:   ann,
+		txs:      txs,
+		missing:  missing,
+		received: msg.Time(),
+	})
+}
+
+func handleGetBlockTransactions67(backend Backend, msg Decoder, peer *Peer) error {
+	// Decode the compact block transactions retrieval message
+	var query GetBlockTransactionsPacket67
+	if err := msg.Decode(&query); err != nil {
+		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+	}
+	// Blocks recently relayed to the peer may not have been imported yet
+	block := peer.servedBlock(query.BlockHash)
+	if block == nil {
+		block = backend.Chain().GetBlockByHash(query.BlockHash)
+	}
+	var (
+		bytes int
+		txs   []*types.Transaction
+	)
+	if block != nil {
+		all := block.Transactions()
+		if len(query.Indexes) > len(all) {
+			return fmt.Errorf("%w: %d transactions requested from block with %d", errDecode, len(query.Indexes), len(all))
+		}
+		served := make([]bool, len(all))
+		for _, index := range query.Indexes {
+			if index >= uint64(len(all)) {
+				return fmt.Errorf("%w: transaction index %d out of range", errDecode, index)
+			}
+			if served[index] || bytes >= softResponseLimit {
+				continue
+			}
+			served[index] = true
+			txs = append(txs, all[index])
+			bytes += int(all[index].Size())
+		}
+	}
+	return peer.ReplyBlockTransactions(query.RequestId, query.BlockHash, txs)
+}
+
+func handleBlockTransactions67(backend Backend, msg Decoder, peer *Peer) error {
+	// Transactions arrived for a compact block, make sure they are the requested ones
+	var res BlockTransactionsPacket67
+	if err := msg.Decode(&res); err != nil {
+		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+	}
+	requestTracker.Fulfil(peer.id, peer.version, BlockTransactionsMsg, res.RequestId)
+
+	pending := peer.takeCompactBlock(res.BlockHash)
+	if pending == nil {
+		return nil // timed out, the full block is retrieved instead
+	}
+	if len(res.Transactions) != len(pending.missing) {
+		return fallbackCompactBlock(backend, peer, pending.packet)
+	}
+	for i, tx := range res.Transactions {
+		if tx == nil {
+			return fmt.Errorf("%w: transaction %d is nil", errDecode, i)
+		}
+		pending.txs[pending.missing[i]] = tx
+	}
+	return deliverCompactBlock(backend, peer, pending.packet, pending.txs, pending.received)
+}
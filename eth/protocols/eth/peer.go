@@ -84,6 +84,10 @@ type Peer struct {
 	txBroadcast chan []common.Hash // Channel used to queue transaction propagation requests
 	txAnnounce  chan []common.Hash // Channel used to queue transaction announcement requests
 
+	compactBlocks map[common.Hash]*compactBlock // Compact blocks waiting for missing transactions
+	servedBlocks  []*types.Block                // Blocks recently propagated in compact form
+	compactLock   sync.Mutex                    // Mutex protecting the compact block fields
+
 	reqDispatch chan *request  // Dispatch channel to send requests and track then until fulfilment
 	reqCancel   chan *cancel   // Dispatch channel to cancel pending requests and untrack them
 	resDispatch chan *response // Dispatch channel to fulfil pending requests and untrack them
@@ -110,6 +114,7 @@ func NewPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWriter, txpool TxPool) *Pe
 		reqCancel:       make(chan *cancel),
 		resDispatch:     make(chan *response),
 		txpool:          txpool,
+		compactBlocks:   make(map[common.Hash]*compactBlock),
 		term:            make(chan struct{}),
 	}
 	// Start up all the broadcasters
@@ -126,6 +131,13 @@ func NewPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWriter, txpool TxPool) *Pe
 // clean it up!
 func (p *Peer) Close() {
 	close(p.term)
+
+	p.compactLock.Lock()
+	for hash, pending := range p.compactBlocks {
+		pending.timer.Stop()
+		compactBlocks.untrack(p.txpool, hash)
+	}
+	p.compactLock.Unlock()
 }
 
 // ID retrieves the peer's unique identifier.
@@ -284,6 +296,39 @@ func (p *Peer) SendNewBlock(block *types.Block, td *big.Int) error {
 	})
 }
 
+// SendNewCompactBlock propagates a block to a remote peer, identifying its
+// transactions by short IDs.
+func (p *Peer) SendNewCompactBlock(block *types.Block, td *big.Int) error {
+	// Mark all the block hash as known, but ensure we don't overflow our limits
+	p.knownBlocks.Add(block.Hash())
+	p.rememberServedBlock(block)
+	return p2p.Send(p.rw, NewCompactBlockMsg, newCompactBlockPacket(block, td))
+}
+
+// sendGetBlockTransactions requests the transactions at the given indexes of a
+// compact block.
+func (p *Peer) sendGetBlockTransactions(id uint64, hash common.Hash, indexes []uint64) error {
+	p.Log().Debug("Fetching compact block transactions", "hash", hash, "count", len(indexes))
+	return p2p.Send(p.rw, GetBlockTransactionsMsg, &GetBlockTransactionsPacket67{
+		RequestId: id,
+		GetBlockTransactionsPacket: GetBlockTransactionsPacket{
+			BlockHash: hash,
+			Indexes:   indexes,
+		},
+	})
+}
+
+// ReplyBlockTransactions is the response to GetBlockTransactions.
+func (p *Peer) ReplyBlockTransactions(id uint64, hash common.Hash, txs []*types.Transaction) error {
+	return p2p.Send(p.rw, BlockTransactionsMsg, &BlockTransactionsPacket67{
+		RequestId: id,
+		BlockTransactionsPacket: BlockTransactionsPacket{
+			BlockHash:    hash,
+			Transactions: txs,
+		},
+	})
+}
+
 // AsyncSendNewBlock queues an entire block for propagation to a remote peer. If
 // the peer's broadcast queue is full, the event is silently dropped.
 func (p *Peer) AsyncSendNewBlock(block *types.Block, td *big.Int) {
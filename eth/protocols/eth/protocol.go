@@ -31,6 +31,12 @@ import (
 // Constants to match up protocol versions and messages
 const (
 	ETH66 = 66
+	ETH67 = 67
+
+	// ETHCompact is a private extension of eth/67 with compact block relay. The
+	// version is far above the ones used on the public network, so it's only
+	// negotiated between nodes which both implement the extension.
+	ETHCompact = 1067
 )
 
 // ProtocolName is the official short name of the `eth` protocol used during
@@ -39,11 +45,11 @@ const ProtocolName = "eth"
 
 // ProtocolVersions are the supported versions of the `eth` protocol (first
 // is primary).
-var ProtocolVersions = []uint{ETH66}
+var ProtocolVersions = []uint{ETHCompact, ETH67, ETH66}
 
 // protocolLengths are the number of implemented message corresponding to
 // different protocol versions.
-var protocolLengths = map[uint]uint64{ETH66: 17}
+var protocolLengths = map[uint]uint64{ETHCompact: 20, ETH67: 17, ETH66: 17}
 
 // maxMessageSize is the maximum cap on the size of a protocol message.
 const maxMessageSize = 10 * 1024 * 1024
@@ -64,6 +70,11 @@ const (
 	NewPooledTransactionHashesMsg = 0x08
 	GetPooledTransactionsMsg      = 0x09
 	PooledTransactionsMsg         = 0x0a
+
+	// Protocol messages of the compact block extension
+	NewCompactBlockMsg      = 0x11
+	GetBlockTransactionsMsg = 0x12
+	BlockTransactionsMsg    = 0x13
 )
 
 var (
@@ -204,6 +215,60 @@ func (request *NewBlockPacket) sanityCheck() error {
 	return nil
 }
 
+// ShortTxID identifies a transaction within a compact block.
+type ShortTxID [6]byte
+
+// NewCompactBlockPacket is the network packet for compact block propagation. It
+// contains the block without its transactions, which are identified by short IDs
+// instead. Receivers reconstruct the block from their transaction pools.
+type NewCompactBlockPacket struct {
+	Header *types.Header
+	Uncles []*types.Header
+	TxIDs  []ShortTxID
+	TD     *big.Int
+}
+
+// sanityCheck verifies that the values are reasonable, as a DoS protection
+func (request *NewCompactBlockPacket) sanityCheck() error {
+	if request.Header == nil || request.TD == nil {
+		return errors.New("missing header or TD")
+	}
+	if err := request.Header.SanityCheck(); err != nil {
+		return err
+	}
+	if tdlen := request.TD.BitLen(); tdlen > 100 {
+		return fmt.Errorf("too large block TD: bitlen %d", tdlen)
+	}
+	return nil
+}
+
+// GetBlockTransactionsPacket requests the transactions of a compact block which
+// are missing from the transaction pool.
+type GetBlockTransactionsPacket struct {
+	BlockHash common.Hash
+	Indexes   []uint64 // Positions of the requested transactions in the block
+}
+
+// GetBlockTransactionsPacket67 represents a block transactions query over the
+// compact block extension of eth/67.
+type GetBlockTransactionsPacket67 struct {
+	RequestId uint64
+	GetBlockTransactionsPacket
+}
+
+// BlockTransactionsPacket is the response to GetBlockTransactionsPacket.
+type BlockTransactionsPacket struct {
+	BlockHash    common.Hash
+	Transactions []*types.Transaction
+}
+
+// BlockTransactionsPacket67 is the network packet for block transactions over the
+// compact block extension of eth/67.
+type BlockTransactionsPacket67 struct {
+	RequestId uint64
+	BlockTransactionsPacket
+}
+
 // GetBlockBodiesPacket represents a block body query.
 type GetBlockBodiesPacket []common.Hash
 
@@ -371,3 +436,12 @@ func (*GetPooledTransactionsPacket) Kind() byte   { return GetPooledTransactions
 
 func (*PooledTransactionsPacket) Name() string { return "PooledTransactions" }
 func (*PooledTransactionsPacket) Kind() byte   { return PooledTransactionsMsg }
+
+func (*NewCompactBlockPacket) Name() string { return "NewCompactBlock" }
+func (*NewCompactBlockPacket) Kind() byte   { return NewCompactBlockMsg }
+
+func (*GetBlockTransactionsPacket) Name() string { return "GetBlockTransactions" }
+func (*GetBlockTransactionsPacket) Kind() byte   { return GetBlockTransactionsMsg }
+
+func (*BlockTransactionsPacket) Name() string { return "BlockTransactions" }
+func (*BlockTransactionsPacket) Kind() byte   { return BlockTransactionsMsg }
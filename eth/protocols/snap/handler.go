@@ -111,6 +111,8 @@ func MakeProtocols(backend Backend, dnsdisc enode.Iterator) []p2p.Protocol {
 			},
 			Attributes:     []enr.Entry{&enrEntry{}},
 			DialCandidates: dnsdisc,
+			// Serving snap sync must not delay block propagation on eth.
+			TrafficClass: func(code uint64) p2p.TrafficClass { return p2p.TrafficBulk },
 		}
 	}
 	return protocols
@@ -398,6 +398,12 @@ func toFilterArg(q ethereum.FilterQuery) (interface{}, error) {
 		}
 		arg["toBlock"] = toBlockNumArg(q.ToBlock)
 	}
+	if q.Limit != 0 {
+		arg["limit"] = hexutil.Uint64(q.Limit)
+	}
+	if q.FromLogIndex != 0 {
+		arg["fromLogIndex"] = hexutil.Uint(q.FromLogIndex)
+	}
 	return arg, nil
 }
 
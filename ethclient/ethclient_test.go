@@ -28,6 +28,7 @@ import (
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
@@ -164,6 +165,26 @@ func TestToFilterArg(t *testing.T) {
 			nil,
 			blockHashErr,
 		},
+		{
+			"with limit and cursor",
+			ethereum.FilterQuery{
+				Addresses:    addresses,
+				FromBlock:    big.NewInt(1),
+				ToBlock:      big.NewInt(2),
+				Topics:       [][]common.Hash{},
+				Limit:        100,
+				FromLogIndex: 3,
+			},
+			map[string]interface{}{
+				"address":      addresses,
+				"fromBlock":    "0x1",
+				"toBlock":      "0x2",
+				"topics":       [][]common.Hash{},
+				"limit":        hexutil.Uint64(100),
+				"fromLogIndex": hexutil.Uint(3),
+			},
+			nil,
+		},
 	} {
 		t.Run(testCase.name, func(t *testing.T) {
 			output, err := toFilterArg(testCase.input)
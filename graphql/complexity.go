@@ -0,0 +1,539 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The complexity of a query is an estimate of the work needed to resolve it.
+// Every field costs one, or its weight if it executes transactions. The fields
+// selected below a list are counted once per expected list element, which is
+// the page size requested by the query or otherwise a typical length.
+
+// fieldWeights are the costs of fields doing more work than a database lookup.
+var fieldWeights = map[string]int64{
+	"call":        10,
+	"estimateGas": 10,
+	"trace":       100,
+}
+
+// listSizes are the expected lengths of list fields whose size isn't limited by
+// the query.
+var listSizes = map[string]int64{
+	"blocks":       1000,
+	"transactions": 200,
+	"logs":         100,
+	"ommers":       2,
+	"accessList":   10,
+}
+
+var errFragmentCycle = errors.New("fragment cycle")
+
+// queryComplexity computes the complexity of the operation of a query which is
+// executed. The computation stops once the complexity exceeds limit.
+func queryComplexity(query, operationName string, variables map[string]interface{}, limit int64) (int64, error) {
+	doc, err := parseDocument(query)
+	if err != nil {
+		return 0, err
+	}
+	c := &complexity{
+		doc:       doc,
+		variables: variables,
+		limit:     limit,
+		visiting:  make(map[string]bool),
+	}
+	var cost int64
+	for _, op := range doc.operations {
+		if operationName != "" && op.name != operationName {
+			continue
+		}
+		// Anonymous requests carry a single operation. Should there be more,
+		// execution fails, so the estimate doesn't matter.
+		opcost, err := c.selections(op.selections)
+		if err != nil {
+			return 0, err
+		}
+		if opcost > cost {
+			cost = opcost
+		}
+	}
+	return cost, nil
+}
+
+type complexity struct {
+	doc       *document
+	variables map[string]interface{}
+	limit     int64
+	visiting  map[string]bool // fragments being expanded
+}
+
+func (c *complexity) selections(sels []*selection) (int64, error) {
+	var total int64
+	for _, sel := range sels {
+		var (
+			cost int64
+			err  error
+		)
+		switch {
+		case sel.fragment != "":
+			frag, ok := c.doc.fragments[sel.fragment]
+			if !ok {
+				continue // rejected by validation
+			}
+			if c.visiting[sel.fragment] {
+				return 0, errFragmentCycle
+			}
+			c.visiting[sel.fragment] = true
+			cost, err = c.selections(frag)
+			delete(c.visiting, sel.fragment)
+
+		case sel.field == "":
+			cost, err = c.selections(sel.children)
+
+		default:
+			weight, ok := fieldWeights[sel.field]
+			if !ok {
+				weight = 1
+			}
+			var children int64
+			if children, err = c.selections(sel.children); err == nil {
+				cost = saturatingAdd(weight, saturatingMul(c.listSize(sel), children))
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+		if total = saturatingAdd(total, cost); total > c.limit {
+			return total, nil
+		}
+	}
+	return total, nil
+}
+
+// listSize returns the expected number of elements returned by a field.
+func (c *complexity) listSize(sel *selection) int64 {
+	size, ok := listSizes[sel.field]
+	if !ok {
+		return 1
+	}
+	if sel.field == "blocks" {
+		from, fromOK := c.intArg(sel, "from")
+		to, toOK := c.intArg(sel, "to")
+		if fromOK && toOK && to >= from && to-from < size {
+			size = to - from + 1
+		}
+	}
+	if first, ok := c.intArg(sel, "first"); ok && first >= 0 && first < size {
+		size = first
+	}
+	return size
+}
+
+// intArg returns the value of an integer argument given as literal or variable.
+func (c *complexity) intArg(sel *selection, name string) (int64, bool) {
+	arg, ok := sel.args[name]
+	if !ok {
+		return 0, false
+	}
+	if v, ok := arg.(variable); ok {
+		switch val := c.variables[string(v)].(type) {
+		case float64: // as decoded from JSON
+			return int64(val), true
+		case string:
+			n, err := strconv.ParseInt(val, 0, 64)
+			return n, err == nil
+		}
+		return 0, false
+	}
+	n, ok := arg.(int64)
+	return n, ok
+}
+
+func saturatingAdd(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+func saturatingMul(a, b int64) int64 {
+	if a != 0 && b > math.MaxInt64/a {
+		return math.MaxInt64
+	}
+	return a * b
+}
+
+// The parser below handles the subset of the GraphQL query language needed for
+// the complexity analysis. Type conditions, directives and argument values other
+// than integers and variables are skipped. Queries are validated by the schema
+// before their complexity is computed.
+
+// document is a parsed GraphQL query.
+type document struct {
+	operations []*operation
+	fragments  map[string][]*selection
+}
+
+type operation struct {
+	name       string
+	selections []*selection
+}
+
+// selection is a field, a fragment spread or an inline fragment.
+type selection struct {
+	field    string                 // field name, empty for fragments
+	args     map[string]interface{} // int64 and variable values of arguments
+	fragment string                 // name of spread fragment
+	children []*selection
+}
+
+// variable is an argument value referring to a query variable.
+type variable string
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+type parser struct {
+	lex  lexer
+	tok  token
+	err  error
+	deep int
+}
+
+// maxNesting bounds the recursion of the parser on nested selections and values.
+const maxNesting = 256
+
+func parseDocument(query string) (doc *document, err error) {
+	p := &parser{lex: lexer{src: query}}
+	p.next()
+	doc = &document{fragments: make(map[string][]*selection)}
+	for p.err == nil && p.tok.kind != tokEOF {
+		switch {
+		case p.peek(tokPunct, "{"):
+			doc.operations = append(doc.operations, &operation{selections: p.selectionSet()})
+		case p.peek(tokName, "fragment"):
+			p.next()
+			name := p.name()
+			p.expect(tokName, "on")
+			p.name()
+			p.directives()
+			doc.fragments[name] = p.selectionSet()
+		case p.peek(tokName, "query"), p.peek(tokName, "mutation"), p.peek(tokName, "subscription"):
+			p.next()
+			op := new(operation)
+			if p.tok.kind == tokName {
+				op.name = p.name()
+			}
+			if p.peek(tokPunct, "(") {
+				p.variableDefinitions()
+			}
+			p.directives()
+			op.selections = p.selectionSet()
+			doc.operations = append(doc.operations, op)
+		default:
+			p.fail()
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return doc, nil
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+	p.tok, p.err = p.lex.next()
+}
+
+func (p *parser) peek(kind tokenKind, text string) bool {
+	return p.err == nil && p.tok.kind == kind && p.tok.text == text
+}
+
+func (p *parser) fail() {
+	if p.err == nil {
+		p.err = fmt.Errorf("syntax error at %q", p.tok.text)
+	}
+}
+
+func (p *parser) expect(kind tokenKind, text string) {
+	if !p.peek(kind, text) {
+		p.fail()
+	}
+	p.next()
+}
+
+func (p *parser) name() string {
+	if p.err != nil || p.tok.kind != tokName {
+		p.fail()
+		return ""
+	}
+	name := p.tok.text
+	p.next()
+	return name
+}
+
+func (p *parser) enter() bool {
+	if p.deep++; p.deep > maxNesting {
+		if p.err == nil {
+			p.err = errors.New("query nested too deeply")
+		}
+		return false
+	}
+	return true
+}
+
+func (p *parser) leave() { p.deep-- }
+
+func (p *parser) selectionSet() []*selection {
+	defer p.leave()
+	if !p.enter() {
+		return nil
+	}
+	var sels []*selection
+	p.expect(tokPunct, "{")
+	for p.err == nil && !p.peek(tokPunct, "}") {
+		sel := new(selection)
+		if p.peek(tokPunct, "...") {
+			p.next()
+			switch {
+			case p.peek(tokName, "on"):
+				p.next()
+				p.name()
+				p.directives()
+				sel.children = p.selectionSet()
+			case p.tok.kind == tokName:
+				sel.fragment = p.name()
+				p.directives()
+			default:
+				p.directives()
+				sel.children = p.selectionSet()
+			}
+		} else {
+			sel.field = p.name()
+			if p.peek(tokPunct, ":") {
+				p.next()
+				sel.field = p.name() // aliased field
+			}
+			if p.peek(tokPunct, "(") {
+				sel.args = p.arguments()
+			}
+			p.directives()
+			if p.peek(tokPunct, "{") {
+				sel.children = p.selectionSet()
+			}
+		}
+		sels = append(sels, sel)
+	}
+	p.expect(tokPunct, "}")
+	return sels
+}
+
+func (p *parser) arguments() map[string]interface{} {
+	args := make(map[string]interface{})
+	p.expect(tokPunct, "(")
+	for p.err == nil && !p.peek(tokPunct, ")") {
+		name := p.name()
+		p.expect(tokPunct, ":")
+		args[name] = p.value()
+	}
+	p.expect(tokPunct, ")")
+	return args
+}
+
+func (p *parser) directives() {
+	for p.peek(tokPunct, "@") {
+		p.next()
+		p.name()
+		if p.peek(tokPunct, "(") {
+			p.arguments()
+		}
+	}
+}
+
+func (p *parser) variableDefinitions() {
+	p.expect(tokPunct, "(")
+	for p.err == nil && !p.peek(tokPunct, ")") {
+		p.expect(tokPunct, "$")
+		p.name()
+		p.expect(tokPunct, ":")
+		p.typeRef()
+		if p.peek(tokPunct, "=") {
+			p.next()
+			p.value()
+		}
+		p.directives()
+	}
+	p.expect(tokPunct, ")")
+}
+
+func (p *parser) typeRef() {
+	defer p.leave()
+	if !p.enter() {
+		return
+	}
+	if p.peek(tokPunct, "[") {
+		p.next()
+		p.typeRef()
+		p.expect(tokPunct, "]")
+	} else {
+		p.name()
+	}
+	if p.peek(tokPunct, "!") {
+		p.next()
+	}
+}
+
+// value parses an argument value, returning integers and variables.
+func (p *parser) value() interface{} {
+	defer p.leave()
+	if !p.enter() {
+		return nil
+	}
+	switch {
+	case p.peek(tokPunct, "$"):
+		p.next()
+		return variable(p.name())
+	case p.peek(tokPunct, "["):
+		p.next()
+		for p.err == nil && !p.peek(tokPunct, "]") {
+			p.value()
+		}
+		p.expect(tokPunct, "]")
+	case p.peek(tokPunct, "{"):
+		p.next()
+		for p.err == nil && !p.peek(tokPunct, "}") {
+			p.name()
+			p.expect(tokPunct, ":")
+			p.value()
+		}
+		p.expect(tokPunct, "}")
+	case p.tok.kind == tokInt:
+		n, err := strconv.ParseInt(p.tok.text, 10, 64)
+		p.next()
+		if err == nil {
+			return n
+		}
+	case p.tok.kind == tokFloat, p.tok.kind == tokString, p.tok.kind == tokName:
+		p.next()
+	default:
+		p.fail()
+	}
+	return nil
+}
+
+// lexer splits a GraphQL query into tokens.
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	if l.pos == len(l.src) {
+		return token{kind: tokEOF}, nil
+	}
+	start := l.pos
+	switch c := l.src[l.pos]; {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return token{tokPunct, "..."}, nil
+	case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+		l.pos++
+		return token{tokPunct, l.src[start:l.pos]}, nil
+	case c == '_' || isLetter(c):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{tokName, l.src[start:l.pos]}, nil
+	case c == '-' || isDigit(c):
+		kind := tokInt
+		for l.pos++; l.pos < len(l.src); l.pos++ {
+			c := l.src[l.pos]
+			if c == '.' || c == 'e' || c == 'E' {
+				kind = tokFloat
+			} else if !isDigit(c) && !(kind == tokFloat && (c == '+' || c == '-')) {
+				break
+			}
+		}
+		return token{kind, l.src[start:l.pos]}, nil
+	case strings.HasPrefix(l.src[l.pos:], `"""`):
+		for l.pos += 3; l.pos < len(l.src); l.pos++ {
+			if strings.HasPrefix(l.src[l.pos:], `\"""`) {
+				l.pos += 3
+			} else if strings.HasPrefix(l.src[l.pos:], `"""`) {
+				l.pos += 3
+				return token{tokString, l.src[start:l.pos]}, nil
+			}
+		}
+		return token{}, errors.New("unterminated block string")
+	case c == '"':
+		for l.pos++; l.pos < len(l.src); l.pos++ {
+			switch l.src[l.pos] {
+			case '\\':
+				l.pos++
+			case '"':
+				l.pos++
+				return token{tokString, l.src[start:l.pos]}, nil
+			case '\n', '\r':
+				return token{}, errors.New("unterminated string")
+			}
+		}
+		return token{}, errors.New("unterminated string")
+	}
+	return token{}, fmt.Errorf("unexpected character %q", l.src[l.pos])
+}
+
+// skipIgnored advances over whitespace, commas, comments and byte order marks.
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' && l.src[l.pos] != '\r' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\ufeff"):
+			l.pos += len("\ufeff")
+		default:
+			return
+		}
+	}
+}
+
+func isLetter(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
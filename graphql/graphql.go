@@ -19,10 +19,13 @@ package graphql
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
@@ -31,12 +34,20 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/eth/tracers"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
 var (
-	errBlockInvariant = errors.New("block objects must be instantiated with at least one of num or hash")
+	errBlockInvariant        = errors.New("block objects must be instantiated with at least one of num or hash")
+	errInvalidCursor         = errors.New("invalid cursor")
+	errInvalidPageSize       = errors.New("page size must be positive")
+	errTracingUnsupported    = errors.New("transaction tracing is not supported by this node")
+	errTracingDisabled       = errors.New("transaction tracing is disabled")
+	errSubscriptionsDisabled = errors.New("subscriptions are not available")
+	errInvalidOperation      = errors.New("invalid operation")
+	errTooManySubscriptions  = errors.New("too many active subscriptions")
 )
 
 type Long int64
@@ -123,6 +134,55 @@ func (a *Account) Storage(ctx context.Context, args struct{ Slot common.Hash })
 	return state.GetState(a.address, args.Slot), nil
 }
 
+// JSON is an arbitrary JSON value, used for the output of transaction tracers.
+type JSON json.RawMessage
+
+// ImplementsGraphQLType returns true if JSON implements the provided GraphQL type.
+func (j JSON) ImplementsGraphQLType(name string) bool { return name == "JSON" }
+
+// UnmarshalGraphQL unmarshals the provided GraphQL query data.
+func (j *JSON) UnmarshalGraphQL(input interface{}) error {
+	blob, err := json.Marshal(input)
+	*j = blob
+	return err
+}
+
+// MarshalJSON returns the value itself, it is JSON already.
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+// encodeCursor creates an opaque pagination cursor pointing at an item.
+func encodeCursor(kind string, position ...uint64) string {
+	parts := []string{kind}
+	for _, p := range position {
+		parts = append(parts, strconv.FormatUint(p, 10))
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(parts, ":")))
+}
+
+// decodeCursor parses a pagination cursor created by encodeCursor.
+func decodeCursor(cursor string, kind string, size int) ([]uint64, error) {
+	blob, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	parts := strings.Split(string(blob), ":")
+	if len(parts) != size+1 || parts[0] != kind {
+		return nil, errInvalidCursor
+	}
+	position := make([]uint64, size)
+	for i := range position {
+		if position[i], err = strconv.ParseUint(parts[i+1], 10, 64); err != nil {
+			return nil, errInvalidCursor
+		}
+	}
+	return position, nil
+}
+
 // Log represents an individual log message. All arguments are mandatory.
 type Log struct {
 	backend     ethapi.Backend
@@ -154,6 +214,14 @@ func (l *Log) Data(ctx context.Context) hexutil.Bytes {
 	return l.log.Data
 }
 
+func (l *Log) Removed(ctx context.Context) bool {
+	return l.log.Removed
+}
+
+func (l *Log) Cursor(ctx context.Context) string {
+	return encodeCursor("log", l.log.BlockNumber, uint64(l.log.Index))
+}
+
 // AccessTuple represents EIP-2930
 type AccessTuple struct {
 	address     common.Address
@@ -425,6 +493,40 @@ func (t *Transaction) Logs(ctx context.Context) (*[]*Log, error) {
 	return &ret, nil
 }
 
+// Trace replays the transaction with the given tracer. It returns nil if the
+// transaction has not yet been mined.
+func (t *Transaction) Trace(ctx context.Context, args struct{ Tracer *string }) (*JSON, error) {
+	if _, ok := t.backend.(noTracingBackend); ok {
+		return nil, errTracingDisabled
+	}
+	backend, ok := t.backend.(tracers.Backend)
+	if !ok {
+		return nil, errTracingUnsupported
+	}
+	if _, err := t.resolve(ctx); err != nil {
+		return nil, err
+	}
+	if t.block == nil {
+		return nil, nil
+	}
+	result, err := tracers.NewAPI(backend).TraceTransaction(ctx, t.hash, &tracers.TraceConfig{Tracer: args.Tracer})
+	if err != nil {
+		return nil, err
+	}
+	blob, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	trace := JSON(blob)
+	return &trace, nil
+}
+
+// noTracingBackend hides the tracing methods of a backend, so the trace field is
+// only served if tracing was enabled.
+type noTracingBackend struct {
+	ethapi.Backend
+}
+
 func (t *Transaction) Type(ctx context.Context) (*int32, error) {
 	tx, err := t.resolve(ctx)
 	if err != nil {
@@ -560,6 +662,14 @@ func (b *Block) Number(ctx context.Context) (Long, error) {
 	return Long(header.Number.Uint64()), nil
 }
 
+func (b *Block) Cursor(ctx context.Context) (string, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return "", err
+	}
+	return encodeCursor("block", header.Number.Uint64()), nil
+}
+
 func (b *Block) Hash(ctx context.Context) (common.Hash, error) {
 	if b.hash == (common.Hash{}) {
 		header, err := b.resolveHeader(ctx)
@@ -1050,6 +1160,7 @@ func (p *Pending) EstimateGas(ctx context.Context, args struct {
 // Resolver is the top-level object in the GraphQL hierarchy.
 type Resolver struct {
 	backend ethapi.Backend
+	events  *filters.EventSystem // source of subscription events, nil if unavailable
 }
 
 func (r *Resolver) Block(ctx context.Context, args struct {
@@ -1092,18 +1203,41 @@ func (r *Resolver) Block(ctx context.Context, args struct {
 	return block, nil
 }
 
+// Blocks returns the blocks of a range. The page size limits the number of blocks
+// returned, the cursor of the last block of a page continues with the next one.
 func (r *Resolver) Blocks(ctx context.Context, args struct {
-	From *Long
-	To   *Long
+	From  *Long
+	To    *Long
+	First *int32
+	After *string
 }) ([]*Block, error) {
-	from := rpc.BlockNumber(*args.From)
-
+	var from rpc.BlockNumber
+	if args.From != nil {
+		from = rpc.BlockNumber(*args.From)
+	}
+	if args.After != nil {
+		position, err := decodeCursor(*args.After, "block", 1)
+		if err != nil {
+			return nil, err
+		}
+		if next := rpc.BlockNumber(position[0] + 1); next > from {
+			from = next
+		}
+	}
 	var to rpc.BlockNumber
 	if args.To != nil {
 		to = rpc.BlockNumber(*args.To)
 	} else {
 		to = rpc.BlockNumber(r.backend.CurrentBlock().Number().Int64())
 	}
+	if args.First != nil {
+		if *args.First <= 0 {
+			return nil, errInvalidPageSize
+		}
+		if last := from + rpc.BlockNumber(*args.First) - 1; last < to {
+			to = last
+		}
+	}
 	if to < from {
 		return []*Block{}, nil
 	}
@@ -1177,7 +1311,13 @@ type FilterCriteria struct {
 	Topics *[][]common.Hash
 }
 
-func (r *Resolver) Logs(ctx context.Context, args struct{ Filter FilterCriteria }) ([]*Log, error) {
+// Logs returns the logs matching a filter. The page size limits the number of
+// logs returned, the cursor of the last log of a page continues with the next one.
+func (r *Resolver) Logs(ctx context.Context, args struct {
+	Filter FilterCriteria
+	First  *int32
+	After  *string
+}) ([]*Log, error) {
 	// Convert the RPC block numbers into internal representations
 	begin := rpc.LatestBlockNumber.Int64()
 	if args.Filter.FromBlock != nil {
@@ -1187,6 +1327,25 @@ func (r *Resolver) Logs(ctx context.Context, args struct{ Filter FilterCriteria
 	if args.Filter.ToBlock != nil {
 		end = int64(*args.Filter.ToBlock)
 	}
+	// Continue after the cursor, which must be within the queried range
+	var fromLogIndex uint
+	if args.After != nil {
+		position, err := decodeCursor(*args.After, "log", 2)
+		if err != nil {
+			return nil, err
+		}
+		if (begin >= 0 && position[0] < uint64(begin)) || (end >= 0 && position[0] > uint64(end)) {
+			return nil, errInvalidCursor
+		}
+		begin, fromLogIndex = int64(position[0]), uint(position[1]+1)
+	}
+	var limit int
+	if args.First != nil {
+		if *args.First <= 0 {
+			return nil, errInvalidPageSize
+		}
+		limit = int(*args.First)
+	}
 	var addresses []common.Address
 	if args.Filter.Addresses != nil {
 		addresses = *args.Filter.Addresses
@@ -1197,9 +1356,132 @@ func (r *Resolver) Logs(ctx context.Context, args struct{ Filter FilterCriteria
 	}
 	// Construct the range filter
 	filter := filters.NewRangeFilter(filters.Backend(r.backend), begin, end, addresses, topics)
+	filter.Paginate(limit, fromLogIndex)
 	return runFilter(ctx, r.backend, filter)
 }
 
+// subscriptionBuffer is the number of events buffered for a subscriber. Slow
+// subscribers falling further behind are dropped.
+const subscriptionBuffer = 128
+
+// LogFilterCriteria encapsulates the arguments to the `newLogs` subscription.
+type LogFilterCriteria struct {
+	Addresses *[]common.Address // restricts matches to events created by specific contracts
+	Topics    *[][]common.Hash  // restricts matches to particular event topics, see FilterCriteria
+}
+
+// NewBlocks streams the blocks imported into the chain.
+func (r *Resolver) NewBlocks(ctx context.Context) (<-chan *Block, error) {
+	if r.events == nil {
+		return nil, errSubscriptionsDisabled
+	}
+	var (
+		blocks = make(chan *types.Block)
+		out    = make(chan *Block, subscriptionBuffer)
+		sub    = r.events.SubscribeNewBlocks(blocks)
+	)
+	go func() {
+		defer close(out)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case block := <-blocks:
+				// Light clients deliver blocks without bodies, so only the header
+				// is kept and the rest fetched on demand.
+				numberOrHash := rpc.BlockNumberOrHashWithHash(block.Hash(), false)
+				select {
+				case out <- &Block{backend: r.backend, numberOrHash: &numberOrHash, hash: block.Hash(), header: block.Header()}:
+				default:
+					return
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// NewPendingTransactions streams the transactions entering the transaction pool.
+func (r *Resolver) NewPendingTransactions(ctx context.Context) (<-chan *Transaction, error) {
+	if r.events == nil {
+		return nil, errSubscriptionsDisabled
+	}
+	var (
+		txs = make(chan []*types.Transaction)
+		out = make(chan *Transaction, subscriptionBuffer)
+		sub = r.events.SubscribePendingTxs(txs)
+	)
+	go func() {
+		defer close(out)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case batch := <-txs:
+				for _, tx := range batch {
+					select {
+					case out <- &Transaction{backend: r.backend, hash: tx.Hash(), tx: tx}:
+					default:
+						return
+					}
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// NewLogs streams the logs matching a filter as blocks are imported. Logs of
+// blocks dropped by a reorg are delivered again, marked as removed.
+func (r *Resolver) NewLogs(ctx context.Context, args struct{ Filter *LogFilterCriteria }) (<-chan *Log, error) {
+	if r.events == nil {
+		return nil, errSubscriptionsDisabled
+	}
+	var crit ethereum.FilterQuery
+	if args.Filter != nil && args.Filter.Addresses != nil {
+		crit.Addresses = *args.Filter.Addresses
+	}
+	if args.Filter != nil && args.Filter.Topics != nil {
+		crit.Topics = *args.Filter.Topics
+	}
+	logs := make(chan []*types.Log)
+	sub, err := r.events.SubscribeLogs(crit, logs)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan *Log, subscriptionBuffer)
+	go func() {
+		defer close(out)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case batch := <-logs:
+				for _, log := range batch {
+					select {
+					case out <- &Log{backend: r.backend, transaction: &Transaction{backend: r.backend, hash: log.TxHash}, log: log}:
+					default:
+						return
+					}
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
 func (r *Resolver) GasPrice(ctx context.Context) (hexutil.Big, error) {
 	tipcap, err := r.backend.SuggestGasTipCap(ctx)
 	if err != nil {
@@ -17,10 +17,13 @@
 package graphql
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"net/http"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -35,6 +38,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/gorilla/websocket"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -53,7 +57,7 @@ func TestBuildSchema(t *testing.T) {
 		t.Fatalf("could not create new node: %v", err)
 	}
 	// Make sure the schema can be parsed and matched up to the object model.
-	if err := newHandler(stack, nil, []string{}, []string{}); err != nil {
+	if err := newHandler(stack, nil, []string{}, []string{}, Config{}); err != nil {
 		t.Errorf("Could not construct GraphQL handler: %v", err)
 	}
 }
@@ -214,6 +218,389 @@ func TestGraphQLHTTPOnSamePort_GQLRequest_Unsuccessful(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
 }
 
+func TestGraphQLPagination(t *testing.T) {
+	stack := createNode(t, true, false)
+	defer stack.Close()
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	for i, tt := range []struct {
+		body string
+		want string
+		code int
+	}{
+		{
+			body: `{"query": "{blocks(from:2, first:3){number}}"}`,
+			want: `{"data":{"blocks":[{"number":2},{"number":3},{"number":4}]}}`,
+			code: 200,
+		},
+		{
+			body: `{"query": "{blocks(first:2){number cursor}}"}`,
+			want: fmt.Sprintf(`{"data":{"blocks":[{"number":0,"cursor":"%s"},{"number":1,"cursor":"%s"}]}}`, encodeCursor("block", 0), encodeCursor("block", 1)),
+			code: 200,
+		},
+		{
+			body: fmt.Sprintf(`{"query": "{blocks(after:\"%s\", first:2){number}}"}`, encodeCursor("block", 1)),
+			want: `{"data":{"blocks":[{"number":2},{"number":3}]}}`,
+			code: 200,
+		},
+		{
+			body: fmt.Sprintf(`{"query": "{blocks(after:\"%s\"){number}}"}`, encodeCursor("block", 8)),
+			want: `{"data":{"blocks":[{"number":9},{"number":10}]}}`,
+			code: 200,
+		},
+		{
+			body: fmt.Sprintf(`{"query": "{blocks(after:\"%s\"){number}}"}`, encodeCursor("log", 1, 0)),
+			want: `{"errors":[{"message":"invalid cursor","path":["blocks"]}],"data":null}`,
+			code: 400,
+		},
+		{
+			body: `{"query": "{blocks(first:0){number}}"}`,
+			want: `{"errors":[{"message":"page size must be positive","path":["blocks"]}],"data":null}`,
+			code: 400,
+		},
+		{
+			body: `{"query": "{logs(filter:{fromBlock:0}, first:10){index}}"}`,
+			want: `{"data":{"logs":[]}}`,
+			code: 200,
+		},
+	} {
+		resp, err := http.Post(fmt.Sprintf("%s/graphql", stack.HTTPEndpoint()), "application/json", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatalf("could not post: %v", err)
+		}
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("could not read from response body: %v", err)
+		}
+		if have := string(bodyBytes); have != tt.want {
+			t.Errorf("testcase %d %s,\nhave:\n%v\nwant:\n%v", i, tt.body, have, tt.want)
+		}
+		if tt.code != resp.StatusCode {
+			t.Errorf("testcase %d %s,\nwrong statuscode, have: %v, want: %v", i, tt.body, resp.StatusCode, tt.code)
+		}
+	}
+}
+
+func TestGraphQLTransactionTrace(t *testing.T) {
+	stack := createNode(t, false, false)
+	defer stack.Close()
+	createGQLServiceWithTransactions(t, stack, Config{Tracing: true})
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	body := `{"query": "{transaction(hash:\"0xd864c9d7d37fade6b70164740540c06dd58bb9c3f6b46101908d6339db6a6a7b\"){trace}}"}`
+	resp, err := http.Post(fmt.Sprintf("%s/graphql", stack.HTTPEndpoint()), "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("could not post: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data struct {
+			Transaction struct {
+				Trace struct {
+					Gas        uint64
+					Failed     bool
+					StructLogs []struct{ Op string }
+				}
+			}
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	trace := result.Data.Transaction.Trace
+	if trace.Failed || trace.Gas == 0 {
+		t.Errorf("wrong trace result %+v", trace)
+	}
+	// The 0xdad contract executes PC PC SLOAD SLOAD
+	var ops []string
+	for _, log := range trace.StructLogs {
+		ops = append(ops, log.Op)
+	}
+	if have := strings.Join(ops, " "); have != "PC PC SLOAD SLOAD STOP" {
+		t.Errorf("wrong traced opcodes %q", have)
+	}
+}
+
+// Tests that tracing is only served if it was enabled.
+func TestGraphQLTransactionTraceDisabled(t *testing.T) {
+	stack := createNode(t, true, true)
+	defer stack.Close()
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	body := `{"query": "{transaction(hash:\"0xd864c9d7d37fade6b70164740540c06dd58bb9c3f6b46101908d6339db6a6a7b\"){trace}}"}`
+	resp, err := http.Post(fmt.Sprintf("%s/graphql", stack.HTTPEndpoint()), "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("could not post: %v", err)
+	}
+	defer resp.Body.Close()
+
+	blob, _ := ioutil.ReadAll(resp.Body)
+	if !strings.Contains(string(blob), errTracingDisabled.Error()) {
+		t.Errorf("trace served without tracing enabled: %s", blob)
+	}
+}
+
+// Tests that WebSocket connections from origins outside of the CORS list are
+// rejected.
+func TestGraphQLWebsocketOrigin(t *testing.T) {
+	stack, err := node.New(&node.Config{HTTPHost: "127.0.0.1", HTTPPort: 0})
+	if err != nil {
+		t.Fatalf("could not create node: %v", err)
+	}
+	defer stack.Close()
+	if err := newHandler(stack, nil, []string{"http://allowed.example"}, []string{"*"}, Config{}); err != nil {
+		t.Fatalf("could not create graphql service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	var (
+		url    = strings.Replace(stack.HTTPEndpoint(), "http://", "ws://", 1) + "/graphql"
+		dialer = websocket.Dialer{Subprotocols: []string{protocolTransportWS}}
+	)
+	for _, tt := range []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{"http://allowed.example", true},
+		{"http://evil.example", false},
+	} {
+		header := make(http.Header)
+		if tt.origin != "" {
+			header.Set("Origin", tt.origin)
+		}
+		conn, _, err := dialer.Dial(url, header)
+		if conn != nil {
+			conn.Close()
+		}
+		if (err == nil) != tt.ok {
+			t.Errorf("origin %q: have err %v, want ok %v", tt.origin, err, tt.ok)
+		}
+	}
+}
+
+func TestGraphQLSubscription(t *testing.T) {
+	stack, err := node.New(&node.Config{HTTPHost: "127.0.0.1", HTTPPort: 0})
+	if err != nil {
+		t.Fatalf("could not create node: %v", err)
+	}
+	defer stack.Close()
+	backend := createGQLService(t, stack)
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	dialer := websocket.Dialer{Subprotocols: []string{protocolTransportWS}}
+	conn, _, err := dialer.Dial(strings.Replace(stack.HTTPEndpoint(), "http://", "ws://", 1)+"/graphql", nil)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn.Close()
+
+	var ack wsMessage
+	if err := conn.WriteJSON(&wsMessage{Type: msgConnectionInit}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.ReadJSON(&ack); err != nil || ack.Type != msgConnectionAck {
+		t.Fatalf("connection not acknowledged: %v %+v", err, ack)
+	}
+	sub := &wsMessage{ID: "1", Type: msgSubscribe, Payload: json.RawMessage(`{"query":"subscription{newBlocks{number}}"}`)}
+	if err := conn.WriteJSON(sub); err != nil {
+		t.Fatal(err)
+	}
+	// Duplicate operation IDs terminate the connection, which is tested at the
+	// end. Until then, keep importing blocks until the subscription delivers.
+	msgs := make(chan wsMessage)
+	go func() {
+		defer close(msgs)
+		for {
+			var msg wsMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			msgs <- msg
+		}
+	}()
+	chain := backend.BlockChain()
+	timeout := time.After(5 * time.Second)
+	for number := 11; ; number++ {
+		blocks, _ := core.GenerateChain(params.AllEthashProtocolChanges, chain.CurrentBlock(), ethash.NewFaker(), backend.ChainDb(), 1, func(i int, gen *core.BlockGen) {})
+		if _, err := chain.InsertChain(blocks); err != nil {
+			t.Fatalf("could not import block: %v", err)
+		}
+		select {
+		case msg := <-msgs:
+			want := fmt.Sprintf(`{"data":{"newBlocks":{"number":%d}}}`, number)
+			if msg.ID != "1" || msg.Type != msgNext || string(msg.Payload) != want {
+				t.Fatalf("wrong message %s %s %s, want payload %s", msg.ID, msg.Type, msg.Payload, want)
+			}
+		case <-time.After(100 * time.Millisecond):
+			continue
+		case <-timeout:
+			t.Fatal("no block delivered")
+		}
+		break
+	}
+	if err := conn.WriteJSON(sub); err != nil {
+		t.Fatal(err)
+	}
+	for range msgs {
+	}
+}
+
+func TestQueryComplexity(t *testing.T) {
+	tests := []struct {
+		query string
+		vars  map[string]interface{}
+		cost  int64
+	}{
+		{query: `{block{number}}`, cost: 2},
+		{query: `query Q { block(number: 1) { n: number hash } }`, cost: 3},
+		{query: `{block{transactions{hash}}}`, cost: 1 + 1 + 200},
+		{query: `{blocks(first: 10){number call(data: {}) {data}}}`, cost: 1 + 10*(1+10+1)},
+		{query: `{blocks(from: 5, to: 9){number}}`, cost: 1 + 5},
+		{query: `query($n: Int) {blocks(first: $n){number}}`, vars: map[string]interface{}{"n": float64(3)}, cost: 1 + 3},
+		{query: `{logs(filter: {topics: [["0x00"]]}, first: 2000) {index}}`, cost: 1 + 100},
+		{query: `{block{...F} ... on Query {gasPrice}} fragment F on Block {number hash}`, cost: 1 + 2 + 1},
+		{query: `{block{ transaction: transactionAt(index: 0) { trace(tracer: """{ "result": 1 }""") } }}`, cost: 1 + 1 + 100},
+	}
+	for i, tt := range tests {
+		cost, err := queryComplexity(tt.query, "", tt.vars, math.MaxInt64)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		} else if cost != tt.cost {
+			t.Errorf("test %d: wrong cost %d, want %d", i, cost, tt.cost)
+		}
+	}
+	// Nested lists multiply without overflowing
+	nested := "{blocks{" + strings.Repeat("transactions{block{", 8) + "number" + strings.Repeat("}}", 8) + "}}"
+	cost, err := queryComplexity(nested, "", nil, math.MaxInt64)
+	if err != nil || cost != math.MaxInt64 {
+		t.Errorf("wrong cost of nested lists %d: %v", cost, err)
+	}
+	if _, err := queryComplexity(`{...A} fragment A on Query {...A}`, "", nil, math.MaxInt64); err != errFragmentCycle {
+		t.Errorf("fragment cycle not detected: %v", err)
+	}
+}
+
+func TestPersistedQueries(t *testing.T) {
+	file, err := ioutil.TempFile("", "persisted-queries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	fmt.Fprint(file, `{"typename": "{__typename}"}`)
+	file.Close()
+
+	known := queryHash("{__typename}")
+	unknown := queryHash("{block{number}}")
+	apq := func(query, hash string) *request {
+		req := &request{Query: query}
+		req.Extensions.PersistedQuery = &struct {
+			Version    int    `json:"version"`
+			Sha256Hash string `json:"sha256Hash"`
+		}{1, hash}
+		return req
+	}
+	tests := []struct {
+		only  bool
+		req   *request
+		query string
+		err   error
+	}{
+		{false, &request{ID: "typename"}, "{__typename}", nil},
+		{false, &request{ID: "other"}, "", errPersistedQueryNotFound},
+		{false, apq("", known), "{__typename}", nil},
+		{false, apq("", unknown), "", errPersistedQueryNotFound},
+		{false, apq("{block{number}}", known), "", errPersistedQueryMismatch},
+		{false, &request{Query: "{block{number}}"}, "{block{number}}", nil},
+		{true, &request{ID: "typename"}, "{__typename}", nil},
+		{true, &request{Query: "{__typename}"}, "{__typename}", nil},
+		{true, &request{Query: "{block{number}}"}, "", errQueryNotAllowed},
+		{true, apq("{block{number}}", unknown), "", errQueryNotAllowed},
+	}
+	for i, tt := range tests {
+		p, err := newPersistedQueries(file.Name(), tt.only)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query, err := p.resolve(tt.req)
+		if query != tt.query || err != tt.err {
+			t.Errorf("test %d: have %q, %v, want %q, %v", i, query, err, tt.query, tt.err)
+		}
+	}
+	// Queries sent along with their hash are remembered
+	p, _ := newPersistedQueries("", false)
+	if _, err := p.resolve(apq("{block{number}}", unknown)); err != nil {
+		t.Fatal(err)
+	}
+	if query, err := p.resolve(apq("", unknown)); query != "{block{number}}" || err != nil {
+		t.Errorf("registered query not found: %q, %v", query, err)
+	}
+	if _, err := newPersistedQueries("", true); err == nil {
+		t.Error("restricting queries without a file accepted")
+	}
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	stack, err := node.New(&node.Config{HTTPHost: "127.0.0.1", HTTPPort: 0})
+	if err != nil {
+		t.Fatalf("could not create node: %v", err)
+	}
+	defer stack.Close()
+	if err := newHandler(stack, nil, []string{}, []string{}, Config{MaxDepth: 3, MaxComplexity: 100}); err != nil {
+		t.Fatalf("could not create graphql service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	for i, tt := range []struct {
+		body string
+		want string
+		code int
+	}{
+		{
+			body: `{"query": "{__typename}"}`,
+			want: `{"data":{"__typename":"Query"}}`,
+			code: 200,
+		},
+		{
+			body: `{"query": "{block{transactions{from{address}}}}"}`,
+			want: `{"errors":[{"message":"Field \"address\" has depth 4 that exceeds max depth 3","locations":[{"line":1,"column":26}]}]}`,
+			code: 400,
+		},
+		{
+			body: `{"query": "{blocks{number}}"}`,
+			want: `{"errors":[{"message":"query complexity exceeds limit 100"}]}`,
+			code: 400,
+		},
+		{
+			body: `{"query": "{blocks{number}}", "extensions": {"persistedQuery": {"version": 1, "sha256Hash": "00"}}}`,
+			want: `{"errors":[{"message":"provided sha does not match query"}]}`,
+			code: 400,
+		},
+	} {
+		resp, err := http.Post(fmt.Sprintf("%s/graphql", stack.HTTPEndpoint()), "application/json", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatalf("could not post: %v", err)
+		}
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("could not read from response body: %v", err)
+		}
+		if have := string(bodyBytes); have != tt.want {
+			t.Errorf("testcase %d %s,\nhave:\n%v\nwant:\n%v", i, tt.body, have, tt.want)
+		}
+		if tt.code != resp.StatusCode {
+			t.Errorf("testcase %d %s,\nwrong statuscode, have: %v, want: %v", i, tt.body, resp.StatusCode, tt.code)
+		}
+	}
+}
+
 func createNode(t *testing.T, gqlEnabled bool, txEnabled bool) *node.Node {
 	stack, err := node.New(&node.Config{
 		HTTPHost: "127.0.0.1",
@@ -230,12 +617,12 @@ func createNode(t *testing.T, gqlEnabled bool, txEnabled bool) *node.Node {
 	if !txEnabled {
 		createGQLService(t, stack)
 	} else {
-		createGQLServiceWithTransactions(t, stack)
+		createGQLServiceWithTransactions(t, stack, Config{})
 	}
 	return stack
 }
 
-func createGQLService(t *testing.T, stack *node.Node) {
+func createGQLService(t *testing.T, stack *node.Node) *eth.Ethereum {
 	// create backend
 	ethConf := &ethconfig.Config{
 		Genesis: &core.Genesis{
@@ -266,13 +653,14 @@ func createGQLService(t *testing.T, stack *node.Node) {
 		t.Fatalf("could not create import blocks: %v", err)
 	}
 	// create gql service
-	err = New(stack, ethBackend.APIBackend, []string{}, []string{})
+	err = New(stack, ethBackend.APIBackend, []string{}, []string{}, Config{})
 	if err != nil {
 		t.Fatalf("could not create graphql service: %v", err)
 	}
+	return ethBackend
 }
 
-func createGQLServiceWithTransactions(t *testing.T, stack *node.Node) {
+func createGQLServiceWithTransactions(t *testing.T, stack *node.Node, config Config) {
 	// create backend
 	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 	address := crypto.PubkeyToAddress(key.PublicKey)
@@ -351,7 +739,7 @@ func createGQLServiceWithTransactions(t *testing.T, stack *node.Node) {
 		t.Fatalf("could not create import blocks: %v", err)
 	}
 	// create gql service
-	err = New(stack, ethBackend.APIBackend, []string{}, []string{})
+	err = New(stack, ethBackend.APIBackend, []string{}, []string{}, config)
 	if err != nil {
 		t.Fatalf("could not create graphql service: %v", err)
 	}
@@ -0,0 +1,144 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// persistedQueryCacheSize is the number of queries registered by clients which
+// are remembered.
+const persistedQueryCacheSize = 1024
+
+var (
+	// These messages are matched by Apollo clients, which send the query text
+	// once told the hash is unknown.
+	errPersistedQueryNotFound     = errors.New("PersistedQueryNotFound")
+	errPersistedQueryNotSupported = errors.New("PersistedQueryNotSupported")
+
+	errPersistedQueryMismatch = errors.New("provided sha does not match query")
+	errQueryNotAllowed        = errors.New("query is not in the persisted query list")
+)
+
+// request is the body of a GraphQL request. Besides the query text, it can
+// refer to a persisted query by its ID (as sent by Relay) or by the SHA-256
+// hash of the text (automatic persisted queries as sent by Apollo).
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	ID            string                 `json:"id"`
+	Extensions    struct {
+		PersistedQuery *struct {
+			Version    int    `json:"version"`
+			Sha256Hash string `json:"sha256Hash"`
+		} `json:"persistedQuery"`
+	} `json:"extensions"`
+}
+
+// persistedQueries resolves the queries referenced by requests.
+type persistedQueries struct {
+	known map[string]string // queries loaded from file, by ID and by hash
+	cache *lru.Cache        // queries registered by clients, nil if only known queries are allowed
+}
+
+// newPersistedQueries loads the persisted queries of a file, which holds a JSON
+// object mapping query IDs to query texts. If only is set, requests are limited
+// to the queries of the file.
+func newPersistedQueries(file string, only bool) (*persistedQueries, error) {
+	p := &persistedQueries{known: make(map[string]string)}
+	if file != "" {
+		blob, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var queries map[string]string
+		if err := json.Unmarshal(blob, &queries); err != nil {
+			return nil, fmt.Errorf("invalid persisted queries file %s: %v", file, err)
+		}
+		for id, query := range queries {
+			p.known[id] = query
+			p.known[queryHash(query)] = query
+		}
+	} else if only {
+		return nil, errors.New("persisted queries file required to restrict queries")
+	}
+	if !only {
+		p.cache, _ = lru.New(persistedQueryCacheSize)
+	}
+	return p, nil
+}
+
+// resolve returns the query text to execute for a request.
+func (p *persistedQueries) resolve(req *request) (string, error) {
+	var hash string
+	if pq := req.Extensions.PersistedQuery; pq != nil {
+		if pq.Version != 1 {
+			return "", errPersistedQueryNotSupported
+		}
+		hash = pq.Sha256Hash
+	}
+	switch {
+	case req.Query != "":
+		sum := queryHash(req.Query)
+		if hash != "" && hash != sum {
+			return "", errPersistedQueryMismatch
+		}
+		if p.cache == nil {
+			if _, ok := p.known[sum]; !ok {
+				return "", errQueryNotAllowed
+			}
+		} else if hash != "" {
+			p.cache.Add(sum, req.Query)
+		}
+		return req.Query, nil
+
+	case req.ID != "":
+		if query, ok := p.known[req.ID]; ok {
+			return query, nil
+		}
+		return "", errPersistedQueryNotFound
+
+	case hash != "":
+		if query, ok := p.known[hash]; ok {
+			return query, nil
+		}
+		if p.cache != nil {
+			if query, ok := p.cache.Get(hash); ok {
+				return query.(string), nil
+			}
+		}
+		return "", errPersistedQueryNotFound
+	}
+	if p.cache == nil {
+		return "", errQueryNotAllowed
+	}
+	return "", nil
+}
+
+// queryHash returns the hex encoded SHA-256 hash of a query.
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
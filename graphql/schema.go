@@ -30,10 +30,13 @@ const schema string = `
     scalar BigInt
     # Long is a 64 bit unsigned integer.
     scalar Long
+    # JSON is an arbitrary JSON value, e.g. the output of a tracer.
+    scalar JSON
 
     schema {
         query: Query
         mutation: Mutation
+        subscription: Subscription
     }
 
     # Account is an Ethereum account at a particular block.
@@ -67,6 +70,12 @@ const schema string = `
         data: Bytes!
         # Transaction is the transaction that generated this log entry.
         transaction: Transaction!
+        # Removed is true if the log was reverted by a chain reorganisation. It
+        # is only ever set on logs delivered by the newLogs subscription.
+        removed: Boolean!
+        # Cursor is an opaque position of this log, which can be passed as the
+        # after argument of the logs query to continue after it.
+        cursor: String!
     }
 
     #EIP-2718 
@@ -138,6 +147,12 @@ const schema string = `
         #Envelope transaction support
         type: Int
         accessList: [AccessTuple!]
+        # Trace re-executes the transaction and returns the output of a tracer,
+        # which is either the name of a built-in tracer or the code of a
+        # JavaScript tracer. The default is the struct logger. This field will
+        # be null if the transaction has not yet been mined. Tracing has to be
+        # enabled on the node, otherwise an error is returned.
+        trace(tracer: String): JSON
     }
 
     # BlockFilterCriteria encapsulates log filter criteria for a filter applied
@@ -231,6 +246,9 @@ const schema string = `
         # EstimateGas estimates the amount of gas that will be required for
         # successful execution of a transaction at the current block's state.
         estimateGas(data: CallData!): Long!
+        # Cursor is an opaque position of this block, which can be passed as the
+        # after argument of the blocks query to continue after it.
+        cursor: String!
     }
 
     # CallData represents the data associated with a local contract call.
@@ -320,13 +338,17 @@ const schema string = `
         block(number: Long, hash: Bytes32): Block
         # Blocks returns all the blocks between two numbers, inclusive. If
         # to is not supplied, it defaults to the most recent known block.
-        blocks(from: Long, to: Long): [Block!]!
+        # Results are paginated by passing the maximum number of blocks as
+        # first and the cursor of the last block received as after.
+        blocks(from: Long, to: Long, first: Int, after: String): [Block!]!
         # Pending returns the current pending state.
         pending: Pending!
         # Transaction returns a transaction specified by its hash.
         transaction(hash: Bytes32!): Transaction
-        # Logs returns log entries matching the provided filter.
-        logs(filter: FilterCriteria!): [Log!]!
+        # Logs returns log entries matching the provided filter. Results are
+        # paginated by passing the maximum number of logs as first and the
+        # cursor of the last log received as after.
+        logs(filter: FilterCriteria!, first: Int, after: String): [Log!]!
         # GasPrice returns the node's estimate of a gas price sufficient to
         # ensure a transaction is mined in a timely fashion.
         gasPrice: BigInt!
@@ -343,4 +365,27 @@ const schema string = `
         # SendRawTransaction sends an RLP-encoded transaction to the network.
         sendRawTransaction(data: Bytes!): Bytes32!
     }
+
+    # LogFilterCriteria encapsulates log filter criteria for a subscription.
+    input LogFilterCriteria {
+        # Addresses is list of addresses that are of interest. If this list is
+        # empty, results will not be filtered by address.
+        addresses: [Address!]
+        # Topics list restricts matches to particular event topics, see
+        # FilterCriteria.
+        topics: [[Bytes32!]!]
+    }
+
+    # Subscription streams events over a WebSocket connection to /graphql.
+    type Subscription {
+        # NewBlocks delivers every block imported into the chain.
+        newBlocks: Block!
+        # NewPendingTransactions delivers every transaction entering the
+        # transaction pool.
+        newPendingTransactions: Transaction!
+        # NewLogs delivers the logs matching the filter as blocks are imported.
+        # Logs of blocks dropped by a reorg are delivered again, with removed
+        # set to true.
+        newLogs(filter: LogFilterCriteria): Log!
+    }
 `
@@ -17,30 +17,62 @@
 package graphql
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/ethereum/go-ethereum/eth/filters"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
 )
 
+// subscribeResolverTimeout is the time allowed to resolve a subscription event.
+const subscribeResolverTimeout = 5 * time.Second
+
+// Config contains the limits applied to GraphQL requests.
+type Config struct {
+	MaxDepth         int    // Maximum nesting of fields in a query, zero if unlimited
+	MaxComplexity    int    // Maximum estimated cost of a query, zero if unlimited
+	PersistedQueries string // File holding the persisted queries, see newPersistedQueries
+	PersistedOnly    bool   // Whether to reject queries which aren't persisted
+	Tracing          bool   // Whether to serve the trace field of transactions
+}
+
 type handler struct {
-	Schema *graphql.Schema
+	Schema        *graphql.Schema
+	maxComplexity int64
+	timeout       time.Duration // time allowed to execute a query, zero if unlimited
+	persisted     *persistedQueries
+	checkOrigin   func(*http.Request) bool // origin check of WebSocket upgrades
 }
 
 func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	var params struct {
-		Query         string                 `json:"query"`
-		OperationName string                 `json:"operationName"`
-		Variables     map[string]interface{} `json:"variables"`
+	if isWebsocket(r) {
+		serveWebsocket(&h, w, r)
+		return
 	}
+	var params request
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	response := h.Schema.Exec(r.Context(), params.Query, params.OperationName, params.Variables)
+	ctx := r.Context()
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	query, response := h.prepare(&params)
+	if response == nil {
+		response = h.Schema.Exec(ctx, query, params.OperationName, params.Variables)
+	}
 	responseJSON, err := json.Marshal(response)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -55,25 +87,80 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// prepare resolves the query of a request and checks it against the limits of
+// the handler. If the query is rejected, the error response is returned.
+func (h *handler) prepare(req *request) (string, *graphql.Response) {
+	query, err := h.persisted.resolve(req)
+	if err != nil {
+		return "", errorResponse(err)
+	}
+	if h.maxComplexity == 0 {
+		return query, nil
+	}
+	if errs := h.Schema.ValidateWithVariables(query, req.Variables); len(errs) > 0 {
+		return "", &graphql.Response{Errors: errs}
+	}
+	cost, err := queryComplexity(query, req.OperationName, req.Variables, h.maxComplexity)
+	if err != nil {
+		return "", errorResponse(err)
+	}
+	if cost > h.maxComplexity {
+		return "", errorResponse(fmt.Errorf("query complexity exceeds limit %d", h.maxComplexity))
+	}
+	return query, nil
+}
+
+func errorResponse(err error) *graphql.Response {
+	return &graphql.Response{Errors: []*gqlerrors.QueryError{gqlerrors.Errorf("%v", err)}}
+}
+
+// isWebsocket reports whether the request asks for a WebSocket upgrade.
+func isWebsocket(r *http.Request) bool {
+	return strings.ToLower(r.Header.Get("Upgrade")) == "websocket" &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
 // New constructs a new GraphQL service instance.
-func New(stack *node.Node, backend ethapi.Backend, cors, vhosts []string) error {
+func New(stack *node.Node, backend ethapi.Backend, cors, vhosts []string, config Config) error {
 	if backend == nil {
 		panic("missing backend")
 	}
 	// check if http server with given endpoint exists and enable graphQL on it
-	return newHandler(stack, backend, cors, vhosts)
+	return newHandler(stack, backend, cors, vhosts, config)
 }
 
 // newHandler returns a new `http.Handler` that will answer GraphQL queries.
 // It additionally exports an interactive query browser on the / endpoint.
-func newHandler(stack *node.Node, backend ethapi.Backend, cors, vhosts []string) error {
-	q := Resolver{backend}
-
-	s, err := graphql.ParseSchema(schema, &q)
+func newHandler(stack *node.Node, backend ethapi.Backend, cors, vhosts []string, config Config) error {
+	// Tracing runs arbitrary JavaScript tracers, it has to be enabled explicitly.
+	if backend != nil && !config.Tracing {
+		backend = noTracingBackend{backend}
+	}
+	q := Resolver{backend: backend}
+	if backend != nil {
+		q.events = filters.NewEventSystem(backend, false)
+	}
+	s, err := graphql.ParseSchema(schema, &q,
+		graphql.SubscribeResolverTimeout(subscribeResolverTimeout),
+		graphql.MaxDepth(config.MaxDepth),
+	)
+	if err != nil {
+		return err
+	}
+	persisted, err := newPersistedQueries(config.PersistedQueries, config.PersistedOnly)
 	if err != nil {
 		return err
 	}
-	h := handler{Schema: s}
+	h := handler{
+		Schema:        s,
+		maxComplexity: int64(config.MaxComplexity),
+		persisted:     persisted,
+		checkOrigin:   rpc.WebsocketOriginValidator(cors),
+	}
+	// Queries may execute calls, so they get the time allowed for those.
+	if backend != nil {
+		h.timeout = backend.RPCEVMTimeout()
+	}
 	handler := node.NewHTTPHandlerStack(h, cors, vhosts)
 
 	stack.RegisterHandler("GraphQL UI", "/graphql/ui", GraphiQL{})
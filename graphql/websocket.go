@@ -0,0 +1,335 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/gorilla/websocket"
+)
+
+// Subscriptions are served over WebSocket using either the graphql-transport-ws
+// protocol or its predecessor graphql-ws of the subscriptions-transport-ws
+// library, which many clients still default to.
+const (
+	protocolTransportWS = "graphql-transport-ws"
+	protocolLegacyWS    = "graphql-ws"
+)
+
+const (
+	wsInitTimeout       = 10 * time.Second
+	wsWriteTimeout      = 10 * time.Second
+	wsKeepAliveInterval = 30 * time.Second
+	wsMessageSizeLimit  = 1024 * 1024
+	wsMaxSubscriptions  = 100 // active operations per connection
+)
+
+// Message types of both protocols. The legacy protocol uses start, stop, data
+// and ka in place of subscribe, complete, next and ping.
+const (
+	msgConnectionInit      = "connection_init"
+	msgConnectionAck       = "connection_ack"
+	msgConnectionError     = "connection_error"
+	msgConnectionTerminate = "connection_terminate"
+	msgPing                = "ping"
+	msgPong                = "pong"
+	msgSubscribe           = "subscribe"
+	msgNext                = "next"
+	msgError               = "error"
+	msgComplete            = "complete"
+	msgStart               = "start"
+	msgStop                = "stop"
+	msgData                = "data"
+	msgKeepAlive           = "ka"
+)
+
+// Close codes defined by graphql-transport-ws.
+const (
+	closeBadRequest          = 4400
+	closeInitTimeout         = 4408
+	closeDuplicateID         = 4409
+	closeTooManyInitRequests = 4429
+	closeUnauthorized        = 4401
+)
+
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	Subprotocols:    []string{protocolTransportWS, protocolLegacyWS},
+}
+
+// wsConn is a GraphQL WebSocket connection executing the operations requested
+// by the client.
+type wsConn struct {
+	handler *handler
+	conn    *websocket.Conn
+	legacy  bool // whether the graphql-ws protocol is spoken
+
+	writeMu sync.Mutex
+
+	mu   sync.Mutex
+	ops  map[string]context.CancelFunc
+	wg   sync.WaitGroup
+	init bool
+}
+
+// serveWebsocket upgrades the request and serves GraphQL operations on the
+// connection until it is closed.
+func serveWebsocket(h *handler, w http.ResponseWriter, r *http.Request) {
+	// The CORS handler doesn't stop upgrade requests, the origin is checked here.
+	upgrader := wsUpgrader
+	upgrader.CheckOrigin = h.checkOrigin
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Debug("GraphQL WebSocket upgrade failed", "err", err)
+		return
+	}
+	c := &wsConn{
+		handler: h,
+		conn:    conn,
+		legacy:  conn.Subprotocol() == protocolLegacyWS,
+		ops:     make(map[string]context.CancelFunc),
+	}
+	if conn.Subprotocol() == "" {
+		c.close(websocket.CloseProtocolError, "unsupported subprotocol")
+		return
+	}
+	c.serve(r.Context())
+}
+
+func (c *wsConn) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		c.wg.Wait()
+		c.conn.Close()
+	}()
+	c.conn.SetReadLimit(wsMessageSizeLimit)
+
+	// The client must initialise the connection before anything else.
+	initTimer := time.AfterFunc(wsInitTimeout, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if !c.init {
+			c.close(closeInitTimeout, "connection initialisation timeout")
+		}
+	})
+	defer initTimer.Stop()
+
+	for {
+		var msg wsMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			if _, ok := err.(*websocket.CloseError); !ok {
+				log.Debug("GraphQL WebSocket read failed", "err", err)
+			}
+			return
+		}
+		if !c.handle(ctx, &msg) {
+			return
+		}
+	}
+}
+
+// handle processes a client message, returning false if the connection should
+// be closed.
+func (c *wsConn) handle(ctx context.Context, msg *wsMessage) bool {
+	switch msg.Type {
+	case msgConnectionInit:
+		c.mu.Lock()
+		dup := c.init
+		c.init = true
+		c.mu.Unlock()
+		if dup {
+			c.close(closeTooManyInitRequests, "too many initialisation requests")
+			return false
+		}
+		if err := c.write(&wsMessage{Type: msgConnectionAck}); err != nil {
+			return false
+		}
+		if c.legacy {
+			go c.keepAlive(ctx)
+		}
+		return true
+
+	case msgPing:
+		return c.write(&wsMessage{Type: msgPong, Payload: msg.Payload}) == nil
+
+	case msgPong:
+		return true
+
+	case msgConnectionTerminate:
+		return false
+
+	case msgSubscribe, msgStart:
+		c.mu.Lock()
+		init := c.init
+		c.mu.Unlock()
+		if !init {
+			c.close(closeUnauthorized, "unauthorized")
+			return false
+		}
+		return c.start(ctx, msg)
+
+	case msgComplete, msgStop:
+		c.mu.Lock()
+		if cancel, ok := c.ops[msg.ID]; ok {
+			cancel()
+		}
+		c.mu.Unlock()
+		return true
+
+	default:
+		if c.legacy {
+			c.write(&wsMessage{Type: msgConnectionError, Payload: errorPayload(fmt.Errorf("invalid message type %q", msg.Type))})
+			return true
+		}
+		c.close(closeBadRequest, fmt.Sprintf("invalid message type %q", msg.Type))
+		return false
+	}
+}
+
+// start runs a requested operation, streaming its results to the client.
+func (c *wsConn) start(ctx context.Context, msg *wsMessage) bool {
+	var req request
+	if msg.ID == "" || json.Unmarshal(msg.Payload, &req) != nil {
+		if c.legacy {
+			c.write(&wsMessage{ID: msg.ID, Type: msgError, Payload: errorPayload(errInvalidOperation)})
+			return true
+		}
+		c.close(closeBadRequest, errInvalidOperation.Error())
+		return false
+	}
+	query, rejected := c.handler.prepare(&req)
+	if rejected != nil {
+		payload, _ := json.Marshal(rejected.Errors)
+		c.write(&wsMessage{ID: msg.ID, Type: msgError, Payload: payload})
+		return true
+	}
+	c.mu.Lock()
+	if _, ok := c.ops[msg.ID]; ok {
+		c.mu.Unlock()
+		if c.legacy {
+			c.write(&wsMessage{ID: msg.ID, Type: msgError, Payload: errorPayload(fmt.Errorf("subscriber for %s already exists", msg.ID))})
+			return true
+		}
+		c.close(closeDuplicateID, fmt.Sprintf("subscriber for %s already exists", msg.ID))
+		return false
+	}
+	if len(c.ops) >= wsMaxSubscriptions {
+		c.mu.Unlock()
+		c.write(&wsMessage{ID: msg.ID, Type: msgError, Payload: errorPayload(errTooManySubscriptions)})
+		return true
+	}
+	opctx, cancel := context.WithCancel(ctx)
+	c.ops[msg.ID] = cancel
+	c.wg.Add(1)
+	c.mu.Unlock()
+
+	results, err := c.handler.Schema.Subscribe(opctx, query, req.OperationName, req.Variables)
+	if err != nil {
+		c.finish(msg.ID, cancel)
+		c.write(&wsMessage{ID: msg.ID, Type: msgError, Payload: errorPayload(err)})
+		return true
+	}
+	go func() {
+		defer c.finish(msg.ID, cancel)
+
+		next := msgNext
+		if c.legacy {
+			next = msgData
+		}
+		for result := range results {
+			payload, err := json.Marshal(result)
+			if err == nil {
+				err = c.write(&wsMessage{ID: msg.ID, Type: next, Payload: payload})
+			}
+			if err != nil {
+				// The connection is gone, drain the results until the
+				// operation shuts down.
+				cancel()
+				for range results {
+				}
+				return
+			}
+		}
+		// Operations stopped by the client are not completed again.
+		if opctx.Err() == nil {
+			c.write(&wsMessage{ID: msg.ID, Type: msgComplete})
+		}
+	}()
+	return true
+}
+
+// finish releases an operation once it has delivered its last result.
+func (c *wsConn) finish(id string, cancel context.CancelFunc) {
+	cancel()
+	c.mu.Lock()
+	delete(c.ops, id)
+	c.mu.Unlock()
+	c.wg.Done()
+}
+
+// keepAlive periodically sends keep-alive messages as expected by graphql-ws
+// clients.
+func (c *wsConn) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(wsKeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if c.write(&wsMessage{Type: msgKeepAlive}) != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *wsConn) write(msg *wsMessage) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return c.conn.WriteJSON(msg)
+}
+
+// close terminates the connection with the given close code.
+func (c *wsConn) close(code int, reason string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteTimeout))
+	c.conn.Close()
+}
+
+// errorPayload encodes an error as list of GraphQL errors.
+func errorPayload(err error) json.RawMessage {
+	payload, _ := json.Marshal([]map[string]string{{"message": err.Error()}})
+	return payload
+}
@@ -171,6 +171,15 @@ type FilterQuery struct {
 	// {{A}, {B}}         matches topic A in first position AND B in second position
 	// {{A, B}, {C, D}}   matches topic (A OR B) in first position AND (C OR D) in second position
 	Topics [][]common.Hash
+
+	// Limit caps the number of logs returned by eth_getLogs, 0 means no limit. To
+	// page through a larger result, query again with FromBlock set to the block of
+	// the last log received and FromLogIndex to its index plus one.
+	Limit uint64
+
+	// FromLogIndex skips the logs of the first block of the range (or of the block
+	// with BlockHash) whose index is below it, continuing a limited query.
+	FromLogIndex uint
 }
 
 // LogFilterer provides access to contract log events using a one-off query or continuous
@@ -51,6 +51,8 @@ type Backend interface {
 	RPCGasCap() uint64            // global gas cap for eth_call over rpc: DoS protection
 	RPCEVMTimeout() time.Duration // global timeout for eth_call over rpc: DoS protection
 	RPCTxFeeCap() float64         // global tx fee cap for all transaction related APIs
+	RPCLogsRangeCap() uint64      // global block range cap for eth_getLogs: DoS protection
+	RPCLogsResultCap() uint64     // global result cap for eth_getLogs: DoS protection
 	UnprotectedAllowed() bool     // allows only for EIP155 transactions.
 
 	// Blockchain API
@@ -0,0 +1,321 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	exportQueueSize = 4096            // spans waiting for export before new ones are dropped
+	exportBatchSize = 512             // spans sent in a single request
+	exportInterval  = 5 * time.Second // maximum time a span waits for export
+	exportTimeout   = 10 * time.Second
+	otlpTracesPath  = "/v1/traces"
+)
+
+var (
+	exportedSpanMeter = metrics.NewRegisteredMeter("tracing/exported", nil)
+	droppedSpanMeter  = metrics.NewRegisteredMeter("tracing/dropped", nil)
+)
+
+// exporter sends finished spans to an OTLP/HTTP collector in batches, using the
+// JSON encoding of the protocol.
+type exporter struct {
+	endpoint string
+	client   *http.Client
+	resource otlpResource
+
+	queue   chan *Span
+	closeCh chan chan struct{}
+	failing bool // whether the last export failed, to avoid repeated warnings
+}
+
+func newExporter(config Config) (*exporter, error) {
+	u, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported OTLP endpoint scheme %q", u.Scheme)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = otlpTracesPath
+	}
+	e := &exporter{
+		endpoint: u.String(),
+		client:   &http.Client{Timeout: exportTimeout},
+		resource: otlpResource{Attributes: otlpAttributes([]interface{}{
+			"service.name", config.ServiceName,
+			"service.version", params.VersionWithMeta,
+		})},
+		queue:   make(chan *Span, exportQueueSize),
+		closeCh: make(chan chan struct{}),
+	}
+	go e.loop()
+	return e, nil
+}
+
+// enqueue schedules a finished span for export. Spans are dropped if the
+// collector can't keep up.
+func (e *exporter) enqueue(s *Span) {
+	select {
+	case e.queue <- s:
+	default:
+		droppedSpanMeter.Mark(1)
+	}
+}
+
+// close exports all queued spans and stops the exporter.
+func (e *exporter) close() {
+	done := make(chan struct{})
+	e.closeCh <- done
+	<-done
+}
+
+func (e *exporter) loop() {
+	var (
+		ticker = time.NewTicker(exportInterval)
+		batch  = make([]*Span, 0, exportBatchSize)
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case s := <-e.queue:
+			if batch = append(batch, s); len(batch) == exportBatchSize {
+				e.export(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				e.export(batch)
+				batch = batch[:0]
+			}
+		case done := <-e.closeCh:
+			for len(e.queue) > 0 {
+				batch = append(batch, <-e.queue)
+			}
+			for len(batch) > 0 {
+				n := len(batch)
+				if n > exportBatchSize {
+					n = exportBatchSize
+				}
+				e.export(batch[:n])
+				batch = batch[n:]
+			}
+			close(done)
+			return
+		}
+	}
+}
+
+// export sends a batch of spans to the collector.
+func (e *exporter) export(batch []*Span) {
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		spans[i] = s.encode()
+	}
+	blob, err := json.Marshal(&otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: e.resource,
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/ethereum/go-ethereum", Version: params.VersionWithMeta},
+				Spans: spans,
+			}},
+		}},
+	})
+	if err == nil {
+		err = e.post(blob)
+	}
+	switch {
+	case err != nil && !e.failing:
+		log.Warn("Failed to export traces", "endpoint", e.endpoint, "spans", len(batch), "err", err)
+		e.failing = true
+	case err != nil:
+		log.Debug("Failed to export traces", "endpoint", e.endpoint, "spans", len(batch), "err", err)
+	case e.failing:
+		log.Info("Resumed trace export", "endpoint", e.endpoint)
+		e.failing = false
+	}
+	if err != nil {
+		droppedSpanMeter.Mark(int64(len(batch)))
+	} else {
+		exportedSpanMeter.Mark(int64(len(batch)))
+	}
+}
+
+func (e *exporter) post(blob []byte) error {
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(blob))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("collector responded %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// encode converts a finished span into its OTLP representation.
+func (s *Span) encode() otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	span := otlpSpan{
+		TraceID:           s.context.TraceID.String(),
+		SpanID:            s.context.SpanID.String(),
+		Name:              s.name,
+		Kind:              int(s.kind),
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Attributes:        otlpAttributes(s.attrs),
+	}
+	if s.parent != (SpanID{}) {
+		span.ParentSpanID = s.parent.String()
+	}
+	if s.err != "" {
+		span.Status = otlpStatus{Code: otlpStatusError, Message: s.err}
+	}
+	return span
+}
+
+// The types below are the subset of the OTLP trace request used by the exporter.
+// See https://github.com/open-telemetry/opentelemetry-proto for the protocol and
+// its JSON mapping.
+
+const otlpStatusError = 2
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // 64 bit integers are encoded as strings
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// otlpAttributes converts alternating keys and values into OTLP attributes.
+func otlpAttributes(kv []interface{}) []otlpKeyValue {
+	attrs := make([]otlpKeyValue, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		attrs = append(attrs, otlpKeyValue{Key: fmt.Sprint(kv[i]), Value: otlpAttributeValue(kv[i+1])})
+	}
+	return attrs
+}
+
+func otlpAttributeValue(v interface{}) otlpValue {
+	var str string
+	switch v := v.(type) {
+	case string:
+		str = v
+	case bool:
+		return otlpValue{BoolValue: &v}
+	case int:
+		return otlpInt(int64(v))
+	case int32:
+		return otlpInt(int64(v))
+	case int64:
+		return otlpInt(v)
+	case uint:
+		return otlpInt(int64(v))
+	case uint32:
+		return otlpInt(int64(v))
+	case uint64:
+		if v > math.MaxInt64 {
+			str = strconv.FormatUint(v, 10)
+		} else {
+			return otlpInt(int64(v))
+		}
+	case float64:
+		return otlpValue{DoubleValue: &v}
+	case float32:
+		f := float64(v)
+		return otlpValue{DoubleValue: &f}
+	case time.Duration:
+		str = v.String()
+	case error:
+		str = v.Error()
+	case fmt.Stringer:
+		str = v.String()
+	default:
+		str = fmt.Sprint(v)
+	}
+	return otlpValue{StringValue: &str}
+}
+
+func otlpInt(v int64) otlpValue {
+	s := strconv.FormatInt(v, 10)
+	return otlpValue{IntValue: &s}
+}
@@ -0,0 +1,394 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tracing records OpenTelemetry-compatible trace spans and exports them
+// to an OTLP collector.
+//
+// Spans are only recorded after Setup has been called. Until then, Start returns
+// a nil span, whose methods do nothing, so instrumented code paths cost next to
+// nothing when tracing is disabled. Trace context is propagated between processes
+// using the W3C traceparent header.
+package tracing
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceparentHeader is the HTTP header carrying the trace context of a request.
+const TraceparentHeader = "traceparent"
+
+var errInvalidTraceparent = errors.New("invalid traceparent")
+
+// TraceID identifies a trace across all processes taking part in it.
+type TraceID [16]byte
+
+// String returns the hex encoding of the ID.
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// String returns the hex encoding of the ID.
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanContext is the part of a span that is propagated to its children, both
+// within the process and to remote services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool // whether the spans of the trace are recorded
+	Remote  bool // whether the span belongs to another process
+}
+
+// IsValid reports whether the context has non-zero IDs.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != (TraceID{}) && sc.SpanID != (SpanID{})
+}
+
+// Traceparent encodes the context as W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := 0
+	if sc.Sampled {
+		flags = 1
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent decodes a W3C traceparent header value.
+func ParseTraceparent(value string) (SpanContext, error) {
+	// Future versions may append fields, but must keep the layout of version 00.
+	if len(value) < 55 || (len(value) > 55 && value[55] != '-') {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	if value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	version, err := hex.DecodeString(value[:2])
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(value) != 55) {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	var (
+		sc    = SpanContext{Remote: true}
+		flags []byte
+	)
+	if _, err := hex.Decode(sc.TraceID[:], []byte(value[3:35])); err != nil {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(value[36:52])); err != nil {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	if flags, err = hex.DecodeString(value[53:55]); err != nil {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a copy of ctx carrying the span context, which
+// becomes the parent of spans started from the returned context.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by ctx, if any.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// Extract returns a copy of ctx carrying the trace context of an incoming HTTP
+// request. It returns ctx itself if tracing is disabled or the request has no
+// valid trace context.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	if !Enabled() {
+		return ctx
+	}
+	value := header.Get(TraceparentHeader)
+	if value == "" {
+		return ctx
+	}
+	sc, err := ParseTraceparent(value)
+	if err != nil {
+		return ctx
+	}
+	return ContextWithSpanContext(ctx, sc)
+}
+
+// Inject adds the trace context carried by ctx to the header of an outgoing
+// HTTP request.
+func Inject(ctx context.Context, header http.Header) {
+	if sc, ok := SpanContextFromContext(ctx); ok && sc.IsValid() {
+		header.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
+
+// SpanKind describes the relationship of a span to remote processes.
+type SpanKind int
+
+// Span kinds as defined by OpenTelemetry.
+const (
+	SpanKindInternal SpanKind = 1 // operation within the process
+	SpanKindServer   SpanKind = 2 // handling of a remote request
+	SpanKindClient   SpanKind = 3 // request to a remote service
+)
+
+// Span is a timed operation within a trace. A nil span is valid and ignores all
+// calls, which is what Start returns for spans that are not recorded.
+type Span struct {
+	tracer  *tracer
+	name    string
+	kind    SpanKind
+	context SpanContext
+	parent  SpanID
+	start   time.Time
+
+	mu    sync.Mutex
+	end   time.Time
+	attrs []interface{} // key/value pairs, see SetAttributes
+	err   string
+	ended bool
+}
+
+// Start creates a span as child of the span context carried by ctx, or as root
+// of a new trace if there is none. The returned context carries the new span.
+//
+// The span is nil if tracing is disabled or the trace is not sampled. Additional
+// arguments are recorded as attributes, see SetAttributes.
+func Start(ctx context.Context, name string, attrs ...interface{}) (context.Context, *Span) {
+	return start(ctx, name, SpanKindInternal, attrs)
+}
+
+// StartServer creates a span for the handling of a remote request, see Start.
+func StartServer(ctx context.Context, name string, attrs ...interface{}) (context.Context, *Span) {
+	return start(ctx, name, SpanKindServer, attrs)
+}
+
+func start(ctx context.Context, name string, kind SpanKind, attrs []interface{}) (context.Context, *Span) {
+	t := active()
+	if t == nil {
+		return ctx, nil
+	}
+	parent, ok := SpanContextFromContext(ctx)
+	if ok && parent.IsValid() {
+		if !parent.Sampled {
+			return ctx, nil
+		}
+	} else {
+		// New traces are sampled at the configured ratio. The decision is carried
+		// along as context, so the trace is skipped as a whole.
+		parent = SpanContext{TraceID: t.newTraceID(), Sampled: t.sample()}
+		if !parent.Sampled {
+			parent.SpanID = t.newSpanID()
+			return ContextWithSpanContext(ctx, parent), nil
+		}
+	}
+	s := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		context: SpanContext{
+			TraceID: parent.TraceID,
+			SpanID:  t.newSpanID(),
+			Sampled: true,
+		},
+		parent: parent.SpanID,
+		start:  time.Now(),
+	}
+	s.SetAttributes(attrs...)
+	return ContextWithSpanContext(ctx, s.context), s
+}
+
+// Recording reports whether the span is recorded. Expensive attributes should
+// only be gathered for recorded spans.
+func (s *Span) Recording() bool {
+	return s != nil
+}
+
+// Context returns the span context, which is zero for nil spans.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetAttributes records attributes given as alternating keys and values, like the
+// context of log messages. Strings, booleans, integers and floats are exported
+// with their type, other values are formatted as strings.
+func (s *Span) SetAttributes(attrs ...interface{}) {
+	if s == nil || len(attrs) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(attrs)%2 != 0 {
+		attrs = append(attrs, nil)
+	}
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// SetError marks the span as failed if err is non-nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export. Calls after the first one are
+// ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	s.tracer.exporter.enqueue(s)
+}
+
+// Config contains the settings of trace recording and export.
+type Config struct {
+	Endpoint    string  // URL of the OTLP/HTTP collector, e.g. http://localhost:4318
+	ServiceName string  // Service name reported to the collector
+	SampleRatio float64 // Fraction of new traces which are recorded
+}
+
+// DefaultConfig is the default tracing configuration.
+var DefaultConfig = Config{
+	Endpoint:    "http://localhost:4318",
+	ServiceName: "geth",
+	SampleRatio: 1,
+}
+
+// tracer holds the state of an active tracing setup.
+type tracer struct {
+	config   Config
+	exporter *exporter
+
+	randMu sync.Mutex
+	rand   *rand.Rand
+}
+
+var (
+	current atomic.Value // *tracer
+	setupMu sync.Mutex
+)
+
+func init() {
+	current.Store((*tracer)(nil))
+}
+
+func active() *tracer {
+	return current.Load().(*tracer)
+}
+
+// Enabled reports whether spans are being recorded.
+func Enabled() bool {
+	return active() != nil
+}
+
+// Setup starts recording spans and exporting them to the configured collector.
+// It replaces any previous setup, which is stopped.
+func Setup(config Config) error {
+	if config.SampleRatio < 0 || config.SampleRatio > 1 {
+		return fmt.Errorf("invalid sample ratio %v", config.SampleRatio)
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = DefaultConfig.ServiceName
+	}
+	exp, err := newExporter(config)
+	if err != nil {
+		return err
+	}
+	var seed [8]byte
+	if _, err := crand.Read(seed[:]); err != nil {
+		return err
+	}
+	t := &tracer{
+		config:   config,
+		exporter: exp,
+		rand:     rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(seed[:])))),
+	}
+	setupMu.Lock()
+	defer setupMu.Unlock()
+	if old := active(); old != nil {
+		old.exporter.close()
+	}
+	current.Store(t)
+	return nil
+}
+
+// Stop stops recording spans and exports the ones already finished. It does
+// nothing if tracing is disabled.
+func Stop() {
+	setupMu.Lock()
+	defer setupMu.Unlock()
+	if t := active(); t != nil {
+		current.Store((*tracer)(nil))
+		t.exporter.close()
+	}
+}
+
+func (t *tracer) sample() bool {
+	if t.config.SampleRatio >= 1 {
+		return true
+	}
+	t.randMu.Lock()
+	defer t.randMu.Unlock()
+	return t.rand.Float64() < t.config.SampleRatio
+}
+
+func (t *tracer) newTraceID() (id TraceID) {
+	t.randMu.Lock()
+	defer t.randMu.Unlock()
+	for id == (TraceID{}) {
+		t.rand.Read(id[:])
+	}
+	return id
+}
+
+func (t *tracer) newSpanID() (id SpanID) {
+	t.randMu.Lock()
+	defer t.randMu.Unlock()
+	for id == (SpanID{}) {
+		t.rand.Read(id[:])
+	}
+	return id
+}
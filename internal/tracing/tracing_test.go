@@ -0,0 +1,140 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestTraceparent(t *testing.T) {
+	valid := []string{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+	}
+	for _, value := range valid {
+		sc, err := ParseTraceparent(value)
+		if err != nil {
+			t.Fatalf("%q: %v", value, err)
+		}
+		if enc := sc.Traceparent(); enc != value {
+			t.Errorf("%q: re-encoded as %q", value, enc)
+		}
+	}
+	if sc, _ := ParseTraceparent(valid[0]); !sc.Sampled || !sc.Remote {
+		t.Errorf("wrong flags %+v", sc)
+	}
+	// Future versions may append fields
+	if _, err := ParseTraceparent(valid[0] + "-extra"); err == nil {
+		t.Errorf("version 00 with extra fields accepted")
+	}
+	if _, err := ParseTraceparent("01" + valid[0][2:] + "-extra"); err != nil {
+		t.Errorf("version 01 with extra fields rejected: %v", err)
+	}
+	invalid := []string{
+		"",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}
+	for _, value := range invalid {
+		if _, err := ParseTraceparent(value); err == nil {
+			t.Errorf("%q: invalid traceparent accepted", value)
+		}
+	}
+}
+
+// testCollector is an OTLP/HTTP endpoint recording the exported spans.
+type testCollector struct {
+	mu    sync.Mutex
+	spans map[string]otlpSpan // by name
+}
+
+func (c *testCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req otlpRequest
+	if r.URL.Path != otlpTracesPath || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, span := range ss.Spans {
+				c.spans[span.Name] = span
+			}
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	if _, span := Start(context.Background(), "disabled"); span != nil {
+		t.Fatal("span recorded before setup")
+	}
+	collector := &testCollector{spans: make(map[string]otlpSpan)}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+	if err := Setup(Config{Endpoint: srv.URL, SampleRatio: 1}); err != nil {
+		t.Fatal(err)
+	}
+	// Spans are children of remote callers and of each other
+	header := make(http.Header)
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, root := StartServer(Extract(context.Background(), header), "root", "method", "eth_call", "gas", uint64(21000))
+	_, child := Start(ctx, "child")
+	child.SetError(errors.New("failure"))
+	child.End()
+	root.End()
+
+	// Unsampled remote traces are not recorded
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	if _, span := Start(Extract(context.Background(), header), "unsampled"); span != nil {
+		t.Error("span of unsampled trace recorded")
+	}
+	out := make(http.Header)
+	Inject(ctx, out)
+	if out.Get(TraceparentHeader) != root.Context().Traceparent() {
+		t.Errorf("wrong injected traceparent %q", out.Get(TraceparentHeader))
+	}
+	Stop()
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if len(collector.spans) != 2 {
+		t.Fatalf("wrong number of exported spans %d", len(collector.spans))
+	}
+	r, c := collector.spans["root"], collector.spans["child"]
+	if r.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || r.ParentSpanID != "00f067aa0ba902b7" || r.Kind != int(SpanKindServer) {
+		t.Errorf("wrong root span %+v", r)
+	}
+	if c.TraceID != r.TraceID || c.ParentSpanID != r.SpanID || c.Kind != int(SpanKindInternal) {
+		t.Errorf("wrong child span %+v", c)
+	}
+	if c.Status.Code != otlpStatusError || c.Status.Message != "failure" {
+		t.Errorf("wrong child status %+v", c.Status)
+	}
+	if len(r.Attributes) != 2 || *r.Attributes[0].Value.StringValue != "eth_call" || *r.Attributes[1].Value.IntValue != "21000" {
+		t.Errorf("wrong root attributes %+v", r.Attributes)
+	}
+}
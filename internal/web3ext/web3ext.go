@@ -141,6 +141,21 @@ web3._extend({
 			call: 'admin_removeTrustedPeer',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'addPeerGroup',
+			call: 'admin_addPeerGroup',
+			params: 3
+		}),
+		new web3._extend.Method({
+			name: 'removePeerGroup',
+			call: 'admin_removePeerGroup',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setSubnetQuota',
+			call: 'admin_setSubnetQuota',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
@@ -203,6 +218,18 @@ web3._extend({
 			name: 'datadir',
 			getter: 'admin_datadir'
 		}),
+		new web3._extend.Property({
+			name: 'peerGroups',
+			getter: 'admin_peerGroups'
+		}),
+		new web3._extend.Property({
+			name: 'subnetQuota',
+			getter: 'admin_subnetQuota'
+		}),
+		new web3._extend.Property({
+			name: 'sentryStatus',
+			getter: 'admin_sentryStatus'
+		}),
 	]
 });
 `
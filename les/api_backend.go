@@ -302,6 +302,14 @@ func (b *LesApiBackend) RPCTxFeeCap() float64 {
 	return b.eth.config.RPCTxFeeCap
 }
 
+func (b *LesApiBackend) RPCLogsRangeCap() uint64 {
+	return b.eth.config.RPCLogsRangeCap
+}
+
+func (b *LesApiBackend) RPCLogsResultCap() uint64 {
+	return b.eth.config.RPCLogsResultCap
+}
+
 func (b *LesApiBackend) BloomStatus() (uint64, uint64) {
 	if b.eth.bloomIndexer == nil {
 		return 0, 0
@@ -125,6 +125,53 @@ func (api *privateAdminAPI) RemoveTrustedPeer(url string) (bool, error) {
 	return true, nil
 }
 
+// AddPeerGroup adds a named group of peers with reserved slots, replacing any
+// existing group of the same name. The members are kept connected like static
+// peers.
+func (api *privateAdminAPI) AddPeerGroup(name string, slots int, urls []string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	group := p2p.PeerGroup{Name: name, Slots: slots}
+	for _, url := range urls {
+		node, err := enode.Parse(enode.ValidSchemes, url)
+		if err != nil {
+			return false, fmt.Errorf("invalid enode %q: %v", url, err)
+		}
+		group.Nodes = append(group.Nodes, node)
+	}
+	if err := server.AddPeerGroup(group); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemovePeerGroup removes a peer group, but it does not disconnect its members.
+func (api *privateAdminAPI) RemovePeerGroup(name string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	if err := server.RemovePeerGroup(name); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetSubnetQuota changes the limits of inbound peers from the same subnet.
+func (api *privateAdminAPI) SetSubnetQuota(quota p2p.SubnetQuota) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	server.SetSubnetQuota(quota)
+	return true, nil
+}
+
 // PeerEvents creates an RPC subscription which receives peer events from the
 // node's p2p.Server
 func (api *privateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
@@ -185,6 +232,8 @@ func (api *privateAdminAPI) StartHTTP(host *string, port *int, cors *string, api
 		CorsAllowedOrigins: api.node.config.HTTPCors,
 		Vhosts:             api.node.config.HTTPVirtualHosts,
 		Modules:            api.node.config.HTTPModules,
+		GRPC:               api.node.config.HTTPGRPCEnabled,
+		rpcEndpointConfig:  api.node.rpcConfig,
 	}
 	if cors != nil {
 		config.CorsAllowedOrigins = nil
@@ -259,6 +308,7 @@ func (api *privateAdminAPI) StartWS(host *string, port *int, allowedOrigins *str
 		Modules: api.node.config.WSModules,
 		Origins: api.node.config.WSOrigins,
 		// ExposeAll: api.node.config.WSExposeAll,
+		rpcEndpointConfig: api.node.rpcConfig,
 	}
 	if apis != nil {
 		config.Modules = nil
@@ -311,6 +361,37 @@ func (api *publicAdminAPI) Peers() ([]*p2p.PeerInfo, error) {
 	return server.PeersInfo(), nil
 }
 
+// PeerGroups retrieves the peer groups and the number of their members
+// occupying reserved slots.
+func (api *publicAdminAPI) PeerGroups() ([]*p2p.PeerGroupInfo, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.PeerGroupInfos(), nil
+}
+
+// SubnetQuota retrieves the limits of inbound peers from the same subnet.
+func (api *publicAdminAPI) SubnetQuota() (*p2p.SubnetQuota, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	quota := server.SubnetQuota()
+	return &quota, nil
+}
+
+// SentryStatus retrieves the health of the connections between a sentry and its
+// private peers, or between a validator and its sentries. It returns null if the
+// node runs in neither mode.
+func (api *publicAdminAPI) SentryStatus() (*p2p.SentryInfo, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.SentryStatus(), nil
+}
+
 // NodeInfo retrieves all the information we know about the host node at the
 // protocol granularity.
 func (api *publicAdminAPI) NodeInfo() (*p2p.NodeInfo, error) {
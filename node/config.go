@@ -84,6 +84,13 @@ type Config struct {
 	// scrypt KDF at the expense of security.
 	UseLightweightKDF bool `toml:",omitempty"`
 
+	// KeyStoreKDF selects the key derivation function of new key files. It is
+	// either "scrypt" (version 3 key files, the default) or "argon2id" (version 4).
+	KeyStoreKDF string `toml:",omitempty"`
+
+	// KeyStoreCipher selects the cipher of new version 4 key files.
+	KeyStoreCipher string `toml:",omitempty"`
+
 	// InsecureUnlockAllowed allows user to unlock accounts in unsafe http environment.
 	InsecureUnlockAllowed bool `toml:",omitempty"`
 
@@ -97,6 +104,16 @@ type Config struct {
 	// SmartCardDaemonPath is the path to the smartcard daemon's socket
 	SmartCardDaemonPath string `toml:",omitempty"`
 
+	// PKCS11Module is the path to the PKCS#11 module of a hardware security
+	// module holding secp256k1 signing keys.
+	PKCS11Module string `toml:",omitempty"`
+
+	// PKCS11Token is the label of the PKCS#11 token holding the signing keys.
+	PKCS11Token string `toml:",omitempty"`
+
+	// PKCS11PIN is the user PIN of the PKCS#11 token. It is never persisted.
+	PKCS11PIN string `toml:"-"`
+
 	// IPCPath is the requested location to place the IPC endpoint. If the path is
 	// a simple file name, it is placed inside the data directory (or on the root
 	// pipe path on Windows), whereas if it's a resolvable path name (absolute or
@@ -138,6 +155,10 @@ type Config struct {
 	// HTTPPathPrefix specifies a path prefix on which http-rpc is to be served.
 	HTTPPathPrefix string `toml:",omitempty"`
 
+	// HTTPGRPCEnabled additionally serves the HTTP modules over gRPC-compatible
+	// HTTP/2 streams on the HTTP endpoint, which support subscriptions.
+	HTTPGRPCEnabled bool `toml:",omitempty"`
+
 	// WSHost is the host interface on which to start the websocket RPC server. If
 	// this field is empty, no websocket API endpoint will be started.
 	WSHost string
@@ -181,6 +202,26 @@ type Config struct {
 	// Requests using ip address directly are not affected
 	GraphQLVirtualHosts []string `toml:",omitempty"`
 
+	// GraphQLMaxDepth is the maximum nesting of fields in a GraphQL query. Zero
+	// means unlimited.
+	GraphQLMaxDepth int `toml:",omitempty"`
+
+	// GraphQLMaxComplexity is the maximum estimated cost of a GraphQL query, where
+	// every field costs one and the fields below a list are counted once per
+	// expected element. Zero means unlimited.
+	GraphQLMaxComplexity int `toml:",omitempty"`
+
+	// GraphQLPersistedQueries is the path of a JSON file mapping query IDs to the
+	// text of persisted GraphQL queries, which clients can execute by ID or hash.
+	GraphQLPersistedQueries string `toml:",omitempty"`
+
+	// GraphQLPersistedOnly restricts GraphQL requests to the persisted queries.
+	GraphQLPersistedOnly bool `toml:",omitempty"`
+
+	// GraphQLTracing enables the trace field of transactions, which re-executes
+	// transactions with arbitrary JavaScript tracers.
+	GraphQLTracing bool `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 
@@ -190,6 +231,44 @@ type Config struct {
 
 	// AllowUnprotectedTxs allows non EIP-155 protected transactions to be send over RPC.
 	AllowUnprotectedTxs bool `toml:",omitempty"`
+
+	// BatchRequestLimit is the maximum number of calls in a batch request served
+	// over HTTP or WebSocket. Zero means no limit.
+	BatchRequestLimit int `toml:",omitempty"`
+
+	// BatchResponseMaxSize is the maximum number of bytes returned for a request
+	// served over HTTP or WebSocket, summed across all calls of a batch. Zero means
+	// no limit.
+	BatchResponseMaxSize int `toml:",omitempty"`
+
+	// RPCRateLimit is the number of request tokens credited to each HTTP and
+	// WebSocket client per second. Zero disables rate limiting.
+	RPCRateLimit float64 `toml:",omitempty"`
+
+	// RPCRateBurst is the maximum number of request tokens a client can accumulate.
+	// If zero, it defaults to one second worth of tokens.
+	RPCRateBurst float64 `toml:",omitempty"`
+
+	// RPCRateCosts is the number of request tokens charged per call by API
+	// namespace. Calls in namespaces not listed cost one token.
+	RPCRateCosts map[string]float64 `toml:",omitempty"`
+
+	// RPCJWTSecret is the path of a file containing the hex encoded secret to
+	// verify HS256 bearer tokens with. Clients presenting a valid token are
+	// identified by its subject instead of their IP address.
+	RPCJWTSecret string `toml:",omitempty"`
+
+	// RPCCredentials are the API keys and token subjects of known HTTP, WebSocket
+	// and gRPC clients, along with the methods they may call and their rate limits.
+	RPCCredentials []rpc.Credential `toml:",omitempty"`
+
+	// RPCRequireCredentials rejects the calls of remote clients which present
+	// neither an API key nor a valid token.
+	RPCRequireCredentials bool `toml:",omitempty"`
+
+	// RPCAccessLog logs every call served over HTTP, WebSocket and gRPC at info
+	// level, including its duration, response size and error code.
+	RPCAccessLog bool `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -51,12 +51,13 @@ type Node struct {
 	state         int               // Tracks state of node lifecycle
 
 	lock          sync.Mutex
-	lifecycles    []Lifecycle // All registered backends, services, and auxiliary services that have a lifecycle
-	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
-	http          *httpServer //
-	ws            *httpServer //
-	ipc           *ipcServer  // Stores information about the ipc http server
-	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
+	lifecycles    []Lifecycle       // All registered backends, services, and auxiliary services that have a lifecycle
+	rpcAPIs       []rpc.API         // List of APIs currently provided by the node
+	http          *httpServer       //
+	ws            *httpServer       //
+	ipc           *ipcServer        // Stores information about the ipc http server
+	inprocHandler *rpc.Server       // In-process RPC request handler to process the API requests
+	rpcConfig     rpcEndpointConfig // Request limits of the HTTP and WebSocket endpoints
 
 	databases map[*closeTrackingDB]struct{} // All open databases
 }
@@ -145,6 +146,11 @@ func New(conf *Config) (*Node, error) {
 		return nil, err
 	}
 
+	// Configure RPC request limits.
+	if node.rpcConfig, err = newRPCEndpointConfig(conf); err != nil {
+		return nil, err
+	}
+
 	// Configure RPC servers.
 	node.http = newHTTPServer(node.log, conf.HTTPTimeouts)
 	node.ws = newHTTPServer(node.log, rpc.DefaultHTTPTimeouts)
@@ -356,7 +362,9 @@ func (n *Node) startRPC() error {
 			CorsAllowedOrigins: n.config.HTTPCors,
 			Vhosts:             n.config.HTTPVirtualHosts,
 			Modules:            n.config.HTTPModules,
+			GRPC:               n.config.HTTPGRPCEnabled,
 			prefix:             n.config.HTTPPathPrefix,
+			rpcEndpointConfig:  n.rpcConfig,
 		}
 		if err := n.http.setListenAddr(n.config.HTTPHost, n.config.HTTPPort); err != nil {
 			return err
@@ -370,9 +378,10 @@ func (n *Node) startRPC() error {
 	if n.config.WSHost != "" {
 		server := n.wsServerForPort(n.config.WSPort)
 		config := wsConfig{
-			Modules: n.config.WSModules,
-			Origins: n.config.WSOrigins,
-			prefix:  n.config.WSPathPrefix,
+			Modules:           n.config.WSModules,
+			Origins:           n.config.WSOrigins,
+			prefix:            n.config.WSPathPrefix,
+			rpcEndpointConfig: n.rpcConfig,
 		}
 		if err := server.setListenAddr(n.config.WSHost, n.config.WSPort); err != nil {
 			return err
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"net"
 	"net/http"
 	"sort"
@@ -29,9 +30,12 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/rs/cors"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // httpConfig is the JSON-RPC/HTTP configuration.
@@ -39,7 +43,9 @@ type httpConfig struct {
 	Modules            []string
 	CorsAllowedOrigins []string
 	Vhosts             []string
+	GRPC               bool   // serve gRPC-compatible HTTP/2 streams too
 	prefix             string // path prefix on which to mount http handler
+	rpcEndpointConfig
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
@@ -47,11 +53,31 @@ type wsConfig struct {
 	Origins []string
 	Modules []string
 	prefix  string // path prefix on which to mount ws handler
+	rpcEndpointConfig
+}
+
+// rpcEndpointConfig contains the request limits, access control and logging
+// settings of remote JSON-RPC endpoints.
+type rpcEndpointConfig struct {
+	batchItemLimit         int
+	batchResponseSizeLimit int
+	rateLimiter            *rpc.RateLimiter   // shared by all endpoints, nil if disabled
+	access                 *rpc.AccessControl // nil if clients are not authenticated
+	accessLog              bool
+}
+
+// apply configures the request limits, access control and logging of an RPC server.
+func (c *rpcEndpointConfig) apply(srv *rpc.Server) {
+	srv.SetBatchLimits(c.batchItemLimit, c.batchResponseSizeLimit)
+	srv.SetRateLimiter(c.rateLimiter)
+	srv.SetAccessControl(c.access)
+	srv.SetAccessLog(c.accessLog)
 }
 
 type rpcHandler struct {
 	http.Handler
 	server *rpc.Server
+	grpc   http.Handler // nil if gRPC streams are disabled
 }
 
 type httpServer struct {
@@ -131,6 +157,10 @@ func (h *httpServer) start() error {
 		h.server.WriteTimeout = h.timeouts.WriteTimeout
 		h.server.IdleTimeout = h.timeouts.IdleTimeout
 	}
+	// gRPC streams need HTTP/2, accept it without TLS as well.
+	if h.grpcAllowed() {
+		h.server.Handler = h2c.NewHandler(h, &http2.Server{IdleTimeout: h.timeouts.IdleTimeout})
+	}
 
 	// Start the server.
 	listener, err := net.Listen("tcp", h.endpoint)
@@ -155,6 +185,9 @@ func (h *httpServer) start() error {
 	if !h.rpcAllowed() {
 		return nil
 	}
+	if h.grpcAllowed() {
+		h.log.Info("gRPC streams enabled", "url", fmt.Sprintf("http://%v", listener.Addr()))
+	}
 	// Log http endpoint.
 	h.log.Info("HTTP server started",
 		"endpoint", listener.Addr(),
@@ -183,10 +216,13 @@ func (h *httpServer) start() error {
 func (h *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// check if ws request and serve if ws enabled
 	ws := h.wsHandler.Load().(*rpcHandler)
-	if ws != nil && isWebsocket(r) {
-		if checkPath(r, h.wsConfig.prefix) {
-			ws.ServeHTTP(w, r)
-		}
+	if ws != nil && isWebsocket(r) && checkPath(r, h.wsConfig.prefix) {
+		ws.ServeHTTP(w, r)
+		return
+	}
+	// check if gRPC stream request and serve if gRPC enabled
+	if handler := h.httpHandler.Load().(*rpcHandler); handler != nil && handler.grpc != nil && rpc.IsGRPCRequest(r) {
+		handler.grpc.ServeHTTP(w, r)
 		return
 	}
 	// if http-rpc is enabled, try to serve request
@@ -280,14 +316,19 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	config.apply(srv)
 	if err := RegisterApis(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
-	h.httpConfig = config
-	h.httpHandler.Store(&rpcHandler{
+	handler := &rpcHandler{
 		Handler: NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts),
 		server:  srv,
-	})
+	}
+	if config.GRPC {
+		handler.grpc = newVHostHandler(config.Vhosts, srv.GRPCHandler())
+	}
+	h.httpConfig = config
+	h.httpHandler.Store(handler)
 	return nil
 }
 
@@ -312,6 +353,7 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	config.apply(srv)
 	if err := RegisterApis(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
@@ -350,6 +392,12 @@ func (h *httpServer) rpcAllowed() bool {
 	return h.httpHandler.Load().(*rpcHandler) != nil
 }
 
+// grpcAllowed returns true when JSON-RPC over gRPC-compatible streams is enabled.
+func (h *httpServer) grpcAllowed() bool {
+	handler := h.httpHandler.Load().(*rpcHandler)
+	return handler != nil && handler.grpc != nil
+}
+
 // wsAllowed returns true when JSON-RPC over WebSocket is enabled.
 func (h *httpServer) wsAllowed() bool {
 	return h.wsHandler.Load().(*rpcHandler) != nil
@@ -453,7 +501,9 @@ func (w *gzipResponseWriter) Write(b []byte) (int, error) {
 
 func newGzipHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		// WebSocket connections hijack the underlying connection, which the
+		// compressing writer doesn't support.
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") || isWebsocket(r) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -536,3 +586,57 @@ func RegisterApis(apis []rpc.API, modules []string, srv *rpc.Server, exposeAll b
 	}
 	return nil
 }
+
+// newRPCEndpointConfig creates the request limits and access control of the remote
+// JSON-RPC endpoints from the node configuration.
+func newRPCEndpointConfig(conf *Config) (rpcEndpointConfig, error) {
+	config := rpcEndpointConfig{
+		batchItemLimit:         conf.BatchRequestLimit,
+		batchResponseSizeLimit: conf.BatchResponseMaxSize,
+		accessLog:              conf.RPCAccessLog,
+	}
+	if conf.RPCJWTSecret != "" || len(conf.RPCCredentials) > 0 || conf.RPCRequireCredentials {
+		access := rpc.AccessConfig{
+			Credentials:        conf.RPCCredentials,
+			RequireCredentials: conf.RPCRequireCredentials,
+		}
+		if conf.RPCJWTSecret != "" {
+			blob, err := ioutil.ReadFile(conf.RPCJWTSecret)
+			if err != nil {
+				return config, fmt.Errorf("failed to read RPC JWT secret: %v", err)
+			}
+			secret, err := hexutil.Decode("0x" + strings.TrimPrefix(strings.TrimSpace(string(blob)), "0x"))
+			if err != nil || len(secret) == 0 {
+				return config, fmt.Errorf("invalid RPC JWT secret in %s", conf.RPCJWTSecret)
+			}
+			access.JWTSecret = secret
+		}
+		ac, err := rpc.NewAccessControl(access)
+		if err != nil {
+			return config, fmt.Errorf("invalid RPC credentials: %v", err)
+		}
+		config.access = ac
+	}
+	// The limiter is also needed if only some credentials are rate limited.
+	limited := conf.RPCRateLimit > 0
+	for _, cred := range conf.RPCCredentials {
+		limited = limited || cred.Rate > 0
+	}
+	if !limited {
+		return config, nil
+	}
+	limits := rpc.RateLimitConfig{
+		Rate:  conf.RPCRateLimit,
+		Burst: conf.RPCRateBurst,
+		Costs: conf.RPCRateCosts,
+	}
+	if limits.Rate > 0 && limits.Burst == 0 {
+		limits.Burst = math.Max(limits.Rate, 1)
+	}
+	limiter, err := rpc.NewRateLimiter(limits)
+	if err != nil {
+		return config, err
+	}
+	config.rateLimiter = limiter
+	return config, nil
+}
@@ -18,12 +18,14 @@ package node
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/internal/testlog"
 	"github.com/ethereum/go-ethereum/log"
@@ -174,6 +176,34 @@ func TestIsWebsocket(t *testing.T) {
 	assert.True(t, isWebsocket(r))
 }
 
+// TestGRPCStreams makes sure gRPC streams are served next to plain HTTP requests
+// when enabled.
+func TestGRPCStreams(t *testing.T) {
+	srv := createAndStartServer(t, &httpConfig{GRPC: true}, false, &wsConfig{})
+	defer srv.stop()
+	url := "http://" + srv.listenAddr()
+
+	client, err := rpc.DialGRPC(context.Background(), url)
+	assert.NoError(t, err)
+	defer client.Close()
+
+	var modules map[string]string
+	assert.NoError(t, client.Call(&modules, "rpc_modules"))
+	assert.Contains(t, modules, "rpc")
+
+	resp := rpcRequest(t, url)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Without gRPC enabled, stream requests are not accepted.
+	plain := createAndStartServer(t, &httpConfig{}, false, &wsConfig{})
+	defer plain.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = rpc.DialGRPC(ctx, "http://"+plain.listenAddr())
+	assert.Error(t, err)
+}
+
 func Test_checkPath(t *testing.T) {
 	tests := []struct {
 		req      *http.Request
@@ -79,6 +79,7 @@ var (
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNetRestrict      = errors.New("not contained in netrestrict list")
 	errNoPort           = errors.New("node does not provide TCP port")
+	errLowReputation    = errors.New("reputation too low")
 )
 
 // dialer creates outbound connections and submits them into Server.
@@ -117,6 +118,9 @@ type dialScheduler struct {
 	static     map[enode.ID]*dialTask
 	staticPool []*dialTask
 
+	// Nodes with a good reputation are dialed before any nodes from the iterator.
+	reputableQueue []*enode.Node
+
 	// The dial history keeps recently dialed nodes. Members of history are not dialed.
 	history          expHeap
 	historyTimer     mclock.Timer
@@ -139,6 +143,8 @@ type dialConfig struct {
 	log            log.Logger
 	clock          mclock.Clock
 	rand           *mrand.Rand
+	reputation     func(enode.ID) float64 // reputation lookup, disabled if nil
+	reputable      []*enode.Node          // nodes with good reputation, best first
 }
 
 func (cfg dialConfig) withDefaults() dialConfig {
@@ -174,6 +180,7 @@ func newDialScheduler(config dialConfig, it enode.Iterator, setupFunc dialSetupF
 		addPeerCh:   make(chan *conn),
 		remPeerCh:   make(chan *conn),
 	}
+	d.reputableQueue = append(d.reputableQueue, d.reputable...)
 	d.lastStatsLog = d.clock.Now()
 	d.ctx, d.cancel = context.WithCancel(context.Background())
 	d.wg.Add(2)
@@ -232,6 +239,7 @@ loop:
 		// Launch new dials if slots are available.
 		slots := d.freeDialSlots()
 		slots -= d.startStaticDials(slots)
+		slots -= d.startReputableDials(slots)
 		if slots > 0 {
 			nodesCh = d.nodesIn
 		} else {
@@ -255,7 +263,7 @@ loop:
 			d.doneSinceLastLog++
 
 		case c := <-d.addPeerCh:
-			if c.is(dynDialedConn) || c.is(staticDialedConn) {
+			if (c.is(dynDialedConn) || c.is(staticDialedConn)) && c.group == "" {
 				d.dialPeers++
 			}
 			id := c.node.ID()
@@ -268,7 +276,7 @@ loop:
 			// TODO: cancel dials to connected peers
 
 		case c := <-d.remPeerCh:
-			if c.is(dynDialedConn) || c.is(staticDialedConn) {
+			if (c.is(dynDialedConn) || c.is(staticDialedConn)) && c.group == "" {
 				d.dialPeers--
 			}
 			delete(d.peers, c.node.ID())
@@ -389,7 +397,7 @@ func (d *dialScheduler) checkDial(n *enode.Node) error {
 	if n.ID() == d.self {
 		return errSelf
 	}
-	if n.IP() != nil && n.TCP() == 0 {
+	if n.IP() != nil && n.TCP() == 0 && nodeQUIC(n) == 0 && len(nodeRelays(n)) == 0 {
 		// This check can trigger if a non-TCP node is found
 		// by discovery. If there is no IP, the node is a static
 		// node and the actual endpoint will be resolved later in dialTask.
@@ -407,6 +415,9 @@ func (d *dialScheduler) checkDial(n *enode.Node) error {
 	if d.history.contains(string(n.ID().Bytes())) {
 		return errRecentlyDialed
 	}
+	if _, static := d.static[n.ID()]; !static && d.reputation != nil && d.reputation(n.ID()) < reputationThreshold {
+		return errLowReputation
+	}
 	return nil
 }
 
@@ -421,6 +432,21 @@ func (d *dialScheduler) startStaticDials(n int) (started int) {
 	return started
 }
 
+// startReputableDials starts up to n dial tasks to nodes with a good reputation.
+func (d *dialScheduler) startReputableDials(n int) (started int) {
+	for started < n && len(d.reputableQueue) > 0 {
+		node := d.reputableQueue[0]
+		d.reputableQueue = d.reputableQueue[1:]
+		if err := d.checkDial(node); err != nil {
+			d.log.Trace("Discarding reputable dial candidate", "id", node.ID(), "ip", node.IP(), "reason", err)
+			continue
+		}
+		d.startDial(newDialTask(node, dynDialedConn))
+		started++
+	}
+	return started
+}
+
 // updateStaticPool attempts to move the given static dial back into staticPool.
 func (d *dialScheduler) updateStaticPool(id enode.ID) {
 	task, ok := d.static[id]
@@ -152,6 +152,40 @@ func TestDialSchedNetRestrict(t *testing.T) {
 	})
 }
 
+// This test checks that nodes with a good reputation are dialed first and
+// that nodes with a bad reputation are not dialed.
+func TestDialSchedReputation(t *testing.T) {
+	t.Parallel()
+
+	nodes := []*enode.Node{
+		newNode(uintID(0x01), "127.0.0.1:30303"),
+		newNode(uintID(0x02), "127.0.0.2:30303"),
+		newNode(uintID(0x03), "127.0.0.3:30303"),
+		newNode(uintID(0x04), "127.0.0.4:30303"),
+	}
+	config := dialConfig{
+		maxActiveDials: 2,
+		maxDialPeers:   10,
+		reputable:      nodes[2:4],
+		reputation: func(id enode.ID) float64 {
+			if id == nodes[1].ID() {
+				return reputationThreshold - 1
+			}
+			return 0
+		},
+	}
+	runDialTest(t, config, []dialTestRound{
+		{
+			discovered:   nodes[:2],
+			wantNewDials: nodes[2:4],
+		},
+		{
+			succeeded:    []enode.ID{nodes[2].ID(), nodes[3].ID()},
+			wantNewDials: nodes[:1],
+		},
+	})
+}
+
 // This test checks that static dials work and obey the limits.
 func TestDialSchedStaticDial(t *testing.T) {
 	t.Parallel()
@@ -43,10 +43,20 @@ type Config struct {
 	// These settings are optional:
 	NetRestrict  *netutil.Netlist   // list of allowed IP networks
 	Bootnodes    []*enode.Node      // list of bootstrap nodes
+	PrivateNodes []*enode.Node      // nodes which are never added to the table
 	Unhandled    chan<- ReadPacket  // unhandled packets are sent on this channel
 	Log          log.Logger         // if set, log messages go here
 	ValidSchemes enr.IdentityScheme // allowed identity schemes
 	Clock        mclock.Clock
+
+	// Relays are the rendezvous nodes which may ask the local node to open its NAT
+	// for the initiator of a hole punch. Requests from other nodes are ignored.
+	Relays []*enode.Node
+
+	// HolePunchNotify is called when a rendezvous node asks the local node to open
+	// its NAT for the initiator of a hole punch, after the discovery socket has
+	// sent its packet to addr. It must not block.
+	HolePunchNotify func(initiator enode.ID, addr *net.UDPAddr)
 }
 
 func (cfg Config) withDefaults() Config {
@@ -70,6 +70,7 @@ type Table struct {
 	nursery []*node           // bootstrap nodes
 	rand    *mrand.Rand       // source of randomness, periodically reseeded
 	ips     netutil.DistinctNetSet
+	private map[enode.ID]struct{} // nodes which must not be revealed to others
 
 	log        log.Logger
 	db         *enode.DB // database of known nodes
@@ -99,7 +100,7 @@ type bucket struct {
 	ips          netutil.DistinctNetSet
 }
 
-func newTable(t transport, db *enode.DB, bootnodes []*enode.Node, log log.Logger) (*Table, error) {
+func newTable(t transport, db *enode.DB, bootnodes, private []*enode.Node, log log.Logger) (*Table, error) {
 	tab := &Table{
 		net:        t,
 		db:         db,
@@ -109,8 +110,12 @@ func newTable(t transport, db *enode.DB, bootnodes []*enode.Node, log log.Logger
 		closed:     make(chan struct{}),
 		rand:       mrand.New(mrand.NewSource(0)),
 		ips:        netutil.DistinctNetSet{Subnet: tableSubnet, Limit: tableIPLimit},
+		private:    make(map[enode.ID]struct{}, len(private)),
 		log:        log,
 	}
+	for _, n := range private {
+		tab.private[n.ID()] = struct{}{}
+	}
 	if err := tab.setFallbackNodes(bootnodes); err != nil {
 		return nil, err
 	}
@@ -125,6 +130,13 @@ func newTable(t transport, db *enode.DB, bootnodes []*enode.Node, log log.Logger
 	return tab, nil
 }
 
+// isPrivate reports whether a node must be kept out of the table, so that it
+// is never revealed to other nodes.
+func (tab *Table) isPrivate(id enode.ID) bool {
+	_, ok := tab.private[id]
+	return ok
+}
+
 func (tab *Table) self() *enode.Node {
 	return tab.net.Self()
 }
@@ -461,7 +473,7 @@ func (tab *Table) bucketAtDistance(d int) *bucket {
 //
 // The caller must not hold tab.mutex.
 func (tab *Table) addSeenNode(n *node) {
-	if n.ID() == tab.self().ID() {
+	if n.ID() == tab.self().ID() || tab.isPrivate(n.ID()) {
 		return
 	}
 
@@ -503,7 +515,7 @@ func (tab *Table) addVerifiedNode(n *node) {
 	if !tab.isInitDone() {
 		return
 	}
-	if n.ID() == tab.self().ID() {
+	if n.ID() == tab.self().ID() || tab.isPrivate(n.ID()) {
 		return
 	}
 
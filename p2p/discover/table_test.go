@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
@@ -396,6 +397,27 @@ func TestTable_revalidateSyncRecord(t *testing.T) {
 	}
 }
 
+// This test checks that private nodes are never added to the table.
+func TestTable_privateNodes(t *testing.T) {
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+	self := enode.ID{}
+	n1 := nodeAtDistance(self, 256, net.IP{88, 77, 66, 1})
+	n2 := nodeAtDistance(self, 256, net.IP{88, 77, 66, 2})
+	tab, _ := newTable(newPingRecorder(), db, nil, []*enode.Node{unwrapNode(n2)}, log.Root())
+	go tab.loop()
+	<-tab.initDone
+	defer tab.close()
+
+	tab.addSeenNode(n1)
+	tab.addSeenNode(n2)
+	tab.addVerifiedNode(n2)
+
+	if want := []*node{n1}; !reflect.DeepEqual(tab.bucket(n1.ID()).entries, want) {
+		t.Fatalf("wrong bucket content: %v", tab.bucket(n1.ID()).entries)
+	}
+}
+
 // gen wraps quick.Value so it's easier to use.
 // it generates a random value of the given value's type.
 func gen(typ interface{}, rand *rand.Rand) interface{} {
@@ -0,0 +1,175 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	crand "crypto/rand"
+	"errors"
+	"net"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Hole punching lets a node contact a node behind NAT with the help of a rendezvous
+// node which both of them can reach. The initiator asks the rendezvous node for the
+// endpoint of the target, which the rendezvous node knows from recent packets of the
+// target. The rendezvous node also notifies the target of the initiator's endpoint.
+// The target then sends a packet to the initiator, creating a NAT mapping for it, and
+// the initiator can reach the target. Messages are exchanged using TALKREQ.
+//
+// This only works if the target is behind a cone NAT, which maps a socket to the
+// same external endpoint regardless of the destination. The rendezvous node then
+// sees the endpoint the initiator needs. Symmetric NATs allocate a new external
+// port for every destination, so the endpoint seen by the rendezvous node is of no
+// use to the initiator, and hole punching fails. Nodes behind symmetric NATs can
+// only be reached through relays.
+
+const (
+	holePunchProtocol   = "holepunch"
+	holePunchAttempts   = 5  // pings sent to the target after the rendezvous
+	holePunchPacketSize = 63 // size of the packet opening the target's NAT
+	maxEndpoints        = 1000
+)
+
+var (
+	errHolePunchRefused  = errors.New("rendezvous node doesn't know target")
+	errHolePunchResponse = errors.New("invalid hole punch response")
+)
+
+// holePunchMsg is the content of hole punching TALKREQ/TALKRESP messages.
+//
+// In a request to the rendezvous node, Node is the target. The response contains
+// the target's endpoint. In a notification to the target, Node is the initiator and
+// IP/Port is the initiator's endpoint.
+type holePunchMsg struct {
+	Notify bool
+	Node   enode.ID
+	IP     net.IP
+	Port   uint16
+}
+
+// HolePunch contacts the node n, which may be behind NAT, with the help of the given
+// rendezvous node. On success, it returns n with the endpoint through which it was
+// reached.
+func (t *UDPv5) HolePunch(n, rendezvous *enode.Node) (*enode.Node, error) {
+	req, _ := rlp.EncodeToBytes(&holePunchMsg{Node: n.ID()})
+	enc, err := t.TalkRequest(rendezvous, holePunchProtocol, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(enc) == 0 {
+		return nil, errHolePunchRefused
+	}
+	var resp holePunchMsg
+	if err := rlp.DecodeBytes(enc, &resp); err != nil || resp.Node != n.ID() || resp.IP == nil || resp.Port == 0 {
+		return nil, errHolePunchResponse
+	}
+	dest := withEndpoint(n, &net.UDPAddr{IP: resp.IP, Port: int(resp.Port)})
+
+	// The first pings may arrive before the target has opened its NAT.
+	for i := 0; i < holePunchAttempts; i++ {
+		if err = t.Ping(dest); err == nil {
+			return dest, nil
+		}
+	}
+	return nil, err
+}
+
+// handleHolePunch handles hole punching requests and notifications. It runs on the
+// dispatch goroutine.
+func (t *UDPv5) handleHolePunch(fromID enode.ID, fromAddr *net.UDPAddr, enc []byte) []byte {
+	var msg holePunchMsg
+	if err := rlp.DecodeBytes(enc, &msg); err != nil {
+		t.log.Debug("Invalid hole punch message", "id", fromID, "addr", fromAddr, "err", err)
+		return nil
+	}
+	if msg.Notify {
+		t.handleHolePunchNotify(fromID, &msg)
+		return nil
+	}
+
+	// Act as rendezvous node. The target must have contacted us recently.
+	addr, target := t.endpoints[msg.Node], t.getNode(msg.Node)
+	if addr == nil || target == nil || msg.Node == fromID {
+		return nil
+	}
+	notify, _ := rlp.EncodeToBytes(&holePunchMsg{Notify: true, Node: fromID, IP: fromAddr.IP, Port: uint16(fromAddr.Port)})
+	target = withEndpoint(target, addr)
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		if _, err := t.TalkRequest(target, holePunchProtocol, notify); err != nil {
+			t.log.Debug("Hole punch notification failed", "id", target.ID(), "addr", addr, "err", err)
+		}
+	}()
+	resp, _ := rlp.EncodeToBytes(&holePunchMsg{Node: msg.Node, IP: addr.IP, Port: uint16(addr.Port)})
+	return resp
+}
+
+// handleHolePunchNotify sends a packet to the initiator of a hole punch, so the
+// initiator's packets can pass through our NAT. Only configured relays may send
+// notifications, anyone else could use us to send packets to arbitrary endpoints.
+func (t *UDPv5) handleHolePunchNotify(fromID enode.ID, msg *holePunchMsg) {
+	if _, ok := t.relays[fromID]; !ok {
+		t.log.Debug("Ignoring hole punch notification from unknown node", "id", fromID)
+		return
+	}
+	addr := &net.UDPAddr{IP: msg.IP, Port: int(msg.Port)}
+	if msg.IP == nil || msg.Port == 0 {
+		return
+	}
+	if t.netrestrict != nil && !t.netrestrict.Contains(addr.IP) {
+		return
+	}
+	t.log.Trace("Opening NAT for hole punch", "id", msg.Node, "addr", addr, "rendezvous", fromID)
+	packet := make([]byte, holePunchPacketSize)
+	crand.Read(packet)
+	t.conn.WriteToUDP(packet, addr)
+	if t.holePunchNotify != nil {
+		t.holePunchNotify(msg.Node, addr)
+	}
+}
+
+// rememberEndpoint records the endpoint of an authenticated packet. It runs on the
+// dispatch goroutine.
+func (t *UDPv5) rememberEndpoint(id enode.ID, addr *net.UDPAddr) {
+	if _, ok := t.endpoints[id]; !ok && len(t.endpoints) >= maxEndpoints {
+		for k := range t.endpoints {
+			delete(t.endpoints, k)
+			break
+		}
+	}
+	t.endpoints[id] = addr
+}
+
+// withEndpoint returns n with the given UDP endpoint. If the endpoint differs from the
+// one in the record, the returned node is unsigned and must not be relayed.
+func withEndpoint(n *enode.Node, addr *net.UDPAddr) *enode.Node {
+	if n.IP().Equal(addr.IP) && n.UDP() == addr.Port {
+		return n
+	}
+	r := n.Record()
+	ip := addr.IP
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	r.Set(enr.IP(ip))
+	r.Set(enr.UDP(addr.Port))
+	return enode.SignNull(r, n.ID())
+}
@@ -0,0 +1,171 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/discover/v5wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// This test checks that the rendezvous node returns the endpoint from which the
+// target was last seen and notifies the target.
+func TestUDPv5_holePunchRendezvous(t *testing.T) {
+	t.Parallel()
+	test := newUDPV5Test(t)
+	defer test.close()
+
+	// The target's record contains its internal endpoint, but its packets arrive
+	// from the NAT.
+	var (
+		targetKey = newkey()
+		natAddr   = &net.UDPAddr{IP: net.IP{10, 0, 1, 50}, Port: 40404}
+		target    = test.getNode(targetKey, &net.UDPAddr{IP: net.IP{10, 0, 1, 50}, Port: 30303}).Node()
+	)
+	fillTable(test.table, []*node{wrapNode(target)})
+	test.packetInFrom(targetKey, natAddr, &v5wire.Ping{ReqID: []byte("0")})
+	test.waitPacketOut(func(p *v5wire.Pong, addr *net.UDPAddr, _ v5wire.Nonce) {})
+
+	req, _ := rlp.EncodeToBytes(&holePunchMsg{Node: target.ID()})
+	test.packetIn(&v5wire.TalkRequest{ReqID: []byte("1"), Protocol: holePunchProtocol, Message: req})
+	test.waitPacketOut(func(p *v5wire.TalkResponse, addr *net.UDPAddr, _ v5wire.Nonce) {
+		var resp holePunchMsg
+		if err := rlp.DecodeBytes(p.Message, &resp); err != nil {
+			t.Fatal("can't decode response:", err)
+		}
+		if resp.Node != target.ID() || !resp.IP.Equal(natAddr.IP) || int(resp.Port) != natAddr.Port {
+			t.Errorf("wrong endpoint in response: %v %v:%d", resp.Node, resp.IP, resp.Port)
+		}
+	})
+	test.waitPacketOut(func(p *v5wire.TalkRequest, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if addr.Port != natAddr.Port {
+			t.Errorf("notification sent to wrong endpoint %v", addr)
+		}
+		var notify holePunchMsg
+		if err := rlp.DecodeBytes(p.Message, &notify); err != nil {
+			t.Fatal("can't decode notification:", err)
+		}
+		remoteID := encodePubkey(&test.remotekey.PublicKey).id()
+		if !notify.Notify || notify.Node != remoteID || !notify.IP.Equal(test.remoteaddr.IP) || int(notify.Port) != test.remoteaddr.Port {
+			t.Errorf("wrong notification: %+v", notify)
+		}
+	})
+
+	// Unknown targets are refused.
+	req, _ = rlp.EncodeToBytes(&holePunchMsg{Node: enode.ID{1}})
+	test.packetIn(&v5wire.TalkRequest{ReqID: []byte("2"), Protocol: holePunchProtocol, Message: req})
+	test.waitPacketOut(func(p *v5wire.TalkResponse, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if !bytes.Equal(p.ReqID, []byte("2")) || len(p.Message) != 0 {
+			t.Errorf("wrong response for unknown target: %x", p.Message)
+		}
+	})
+}
+
+// This test checks that hole punch notifications from nodes which aren't relays of
+// the local node are ignored.
+func TestUDPv5_holePunchNotifyUnknown(t *testing.T) {
+	t.Parallel()
+	test := newUDPV5Test(t)
+	defer test.close()
+
+	notify, _ := rlp.EncodeToBytes(&holePunchMsg{Notify: true, Node: enode.ID{1}, IP: net.IP{10, 0, 9, 9}, Port: 1234})
+	test.packetIn(&v5wire.TalkRequest{ReqID: []byte("1"), Protocol: holePunchProtocol, Message: notify})
+	test.waitPacketOut(func(p *v5wire.TalkResponse, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if !addr.IP.Equal(test.remoteaddr.IP) {
+			t.Errorf("packet sent to %v", addr)
+		}
+	})
+	// test.close fails if anything else was sent.
+}
+
+// Real sockets, real crypto: this test checks that a node behind a simulated NAT can
+// be reached after hole punching.
+func TestUDPv5_holePunchE2E(t *testing.T) {
+	t.Parallel()
+
+	rendezvous := startLocalhostV5(t, Config{})
+	defer rendezvous.Close()
+	notified := make(chan enode.ID, 1)
+	targetCfg := Config{
+		Bootnodes:       []*enode.Node{rendezvous.Self()},
+		Relays:          []*enode.Node{rendezvous.Self()},
+		HolePunchNotify: func(id enode.ID, addr *net.UDPAddr) { notified <- id },
+	}
+	target := startLocalhostV5Conn(t, targetCfg, func(c *net.UDPConn) UDPConn {
+		return &natConn{UDPConn: c, open: make(map[string]bool)}
+	})
+	defer target.Close()
+	initiator := startLocalhostV5(t, Config{})
+	defer initiator.Close()
+
+	// The target contacts the rendezvous node while bootstrapping.
+	if err := target.Ping(rendezvous.Self()); err != nil {
+		t.Fatal("target can't ping rendezvous node:", err)
+	}
+	if err := initiator.Ping(target.Self()); err == nil {
+		t.Fatal("ping passed through NAT")
+	}
+	n, err := initiator.HolePunch(target.Self(), rendezvous.Self())
+	if err != nil {
+		t.Fatal("hole punching failed:", err)
+	}
+	if err := initiator.Ping(n); err != nil {
+		t.Fatal("ping after hole punching failed:", err)
+	}
+	select {
+	case id := <-notified:
+		if id != initiator.Self().ID() {
+			t.Errorf("wrong initiator in notification: %v", id)
+		}
+	default:
+		t.Error("hole punch notification not delivered")
+	}
+}
+
+// natConn simulates a NAT. It drops packets from endpoints it hasn't sent to.
+type natConn struct {
+	*net.UDPConn
+	mu   sync.Mutex
+	open map[string]bool
+}
+
+func (c *natConn) ReadFromUDP(b []byte) (int, *net.UDPAddr, error) {
+	for {
+		n, addr, err := c.UDPConn.ReadFromUDP(b)
+		if err != nil {
+			return n, addr, err
+		}
+		c.mu.Lock()
+		open := c.open[addr.String()]
+		c.mu.Unlock()
+		if open {
+			return n, addr, nil
+		}
+	}
+}
+
+func (c *natConn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	c.mu.Lock()
+	c.open[addr.String()] = true
+	c.mu.Unlock()
+	return c.UDPConn.WriteToUDP(b, addr)
+}
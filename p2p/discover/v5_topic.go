@@ -0,0 +1,486 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"context"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/discover/v5wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Topic advertisement works as follows: a node which wants to be found under a
+// topic requests a ticket from registrar nodes close to the topic hash. The ticket
+// tells it how long to wait before it can register. After waiting, the node
+// presents the ticket in REGTOPIC and is added to the registrar's topic queue.
+// Nodes searching for a topic send TOPICQUERY to the same registrars.
+
+const (
+	topicRegLifetime    = 10 * time.Minute // how long registrations are kept
+	topicQueueLength    = 50               // max registrations per topic
+	topicTableLimit     = 2000             // max registrations in total
+	topicTicketValidity = 10 * time.Second // time after the wait time in which a ticket is accepted
+	maxTopicTicketWait  = topicRegLifetime // advertisers don't wait longer than this
+
+	topicRegistrars       = 8                    // number of nodes an advertisement is placed on
+	topicRegInterval      = topicRegLifetime / 2 // how often advertisements are renewed
+	topicRegRetryInterval = 10 * time.Second     // delay before retrying when no registration succeeded
+	topicQueryResultLimit = 16                   // applies in TOPICQUERY handler
+	topicSearchInterval   = 30 * time.Second     // delay between rounds of topic search
+)
+
+var (
+	errInvalidTicket  = errors.New("invalid ticket")
+	errTicketTooEarly = errors.New("ticket used before wait time")
+	errTicketExpired  = errors.New("ticket expired")
+	errTopicFull      = errors.New("topic queue full")
+	errTopicRefused   = errors.New("registration refused")
+	errTopicWait      = errors.New("ticket wait time too long")
+)
+
+// Topic identifies a topic. It is the hash of the topic name.
+type Topic [32]byte
+
+// NewTopic returns the topic with the given name.
+func NewTopic(name string) Topic {
+	return Topic(sha256.Sum256([]byte(name)))
+}
+
+// String returns the topic hash in hex.
+func (t Topic) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// topicTicket is the content of a ticket. Tickets are authenticated by the
+// registrar, but the fields can be read by the ticket holder.
+type topicTicket struct {
+	Topic  Topic
+	Node   enode.ID
+	Issued uint64 // registrar clock
+	Wait   uint64 // nanoseconds
+	MAC    []byte
+}
+
+// topicTable stores the topic registrations of a registrar. It is accessed by the
+// dispatch goroutine only.
+type topicTable struct {
+	clock  mclock.Clock
+	key    []byte // for ticket MACs
+	queues map[Topic][]*topicReg
+	count  int
+}
+
+// topicReg is a registration in a topic queue. Queues are ordered by expiry.
+type topicReg struct {
+	node    *enode.Node
+	expires mclock.AbsTime
+}
+
+func newTopicTable(clock mclock.Clock) *topicTable {
+	key := make([]byte, 32)
+	crand.Read(key)
+	return &topicTable{clock: clock, key: key, queues: make(map[Topic][]*topicReg)}
+}
+
+// expire removes expired registrations.
+func (tt *topicTable) expire(now mclock.AbsTime) {
+	for topic, queue := range tt.queues {
+		i := 0
+		for i < len(queue) && queue[i].expires <= now {
+			i++
+		}
+		tt.count -= i
+		if i == len(queue) {
+			delete(tt.queues, topic)
+		} else {
+			tt.queues[topic] = queue[i:]
+		}
+	}
+}
+
+// indexOf returns the position of a node in a topic queue, or -1.
+func (tt *topicTable) indexOf(topic Topic, id enode.ID) int {
+	for i, reg := range tt.queues[topic] {
+		if reg.node.ID() == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// waitTime computes how long a node has to wait before it can register. Nodes
+// which are already registered can renew their registration immediately.
+func (tt *topicTable) waitTime(topic Topic, id enode.ID, now mclock.AbsTime) time.Duration {
+	queue := tt.queues[topic]
+	switch {
+	case tt.indexOf(topic, id) >= 0:
+		return 0
+	case len(queue) >= topicQueueLength:
+		return time.Duration(queue[0].expires - now)
+	case tt.count >= topicTableLimit:
+		var next mclock.AbsTime
+		for _, q := range tt.queues {
+			if next == 0 || q[0].expires < next {
+				next = q[0].expires
+			}
+		}
+		return time.Duration(next - now)
+	default:
+		return 0
+	}
+}
+
+// issueTicket creates a ticket for registering the given node.
+func (tt *topicTable) issueTicket(topic Topic, id enode.ID) ([]byte, time.Duration) {
+	now := tt.clock.Now()
+	tt.expire(now)
+	// The wait time is sent in milliseconds. Round it up, otherwise the node
+	// would come back before the ticket becomes usable.
+	wait := (tt.waitTime(topic, id, now) + time.Millisecond - 1).Truncate(time.Millisecond)
+	ticket := &topicTicket{
+		Topic:  topic,
+		Node:   id,
+		Issued: uint64(now),
+		Wait:   uint64(wait),
+	}
+	ticket.MAC = tt.ticketMAC(ticket)
+	enc, _ := rlp.EncodeToBytes(ticket)
+	return enc, time.Duration(ticket.Wait)
+}
+
+func (tt *topicTable) ticketMAC(t *topicTicket) []byte {
+	enc, _ := rlp.EncodeToBytes([]interface{}{t.Topic, t.Node, t.Issued, t.Wait})
+	mac := hmac.New(sha256.New, tt.key)
+	mac.Write(enc)
+	return mac.Sum(nil)
+}
+
+// register adds a node to a topic queue using a ticket.
+func (tt *topicTable) register(enc []byte, n *enode.Node) error {
+	var ticket topicTicket
+	if err := rlp.DecodeBytes(enc, &ticket); err != nil {
+		return errInvalidTicket
+	}
+	if ticket.Node != n.ID() || !hmac.Equal(ticket.MAC, tt.ticketMAC(&ticket)) {
+		return errInvalidTicket
+	}
+	now := tt.clock.Now()
+	usable := mclock.AbsTime(ticket.Issued).Add(time.Duration(ticket.Wait))
+	switch {
+	case now < usable:
+		return errTicketTooEarly
+	case now >= usable.Add(topicTicketValidity):
+		return errTicketExpired
+	}
+	tt.expire(now)
+
+	queue := tt.queues[ticket.Topic]
+	if i := tt.indexOf(ticket.Topic, n.ID()); i >= 0 {
+		// Renewal, move the node to the back of the queue.
+		queue = append(queue[:i], queue[i+1:]...)
+		tt.count--
+	} else if len(queue) >= topicQueueLength || tt.count >= topicTableLimit {
+		return errTopicFull
+	}
+	tt.queues[ticket.Topic] = append(queue, &topicReg{node: n, expires: now.Add(topicRegLifetime)})
+	tt.count++
+	return nil
+}
+
+// nodes returns registered nodes of a topic, most recent registrations first.
+func (tt *topicTable) nodes(topic Topic, limit int) []*enode.Node {
+	tt.expire(tt.clock.Now())
+	queue := tt.queues[topic]
+	nodes := make([]*enode.Node, 0, limit)
+	for i := len(queue) - 1; i >= 0 && len(nodes) < limit; i-- {
+		nodes = append(nodes, queue[i].node)
+	}
+	return nodes
+}
+
+// RegisterTopic starts advertising the local node under the given topic. The
+// advertisement is placed on nodes close to the topic hash and renewed until
+// UnregisterTopic is called.
+func (t *UDPv5) RegisterTopic(topic Topic) {
+	t.topicLock.Lock()
+	defer t.topicLock.Unlock()
+
+	if _, ok := t.topicRegs[topic]; ok || t.closeCtx.Err() != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(t.closeCtx)
+	t.topicRegs[topic] = cancel
+	t.wg.Add(1)
+	go t.topicRegLoop(ctx, topic)
+}
+
+// UnregisterTopic stops advertising the local node under the given topic. Existing
+// registrations expire on their own.
+func (t *UDPv5) UnregisterTopic(topic Topic) {
+	t.topicLock.Lock()
+	defer t.topicLock.Unlock()
+
+	if cancel, ok := t.topicRegs[topic]; ok {
+		cancel()
+		delete(t.topicRegs, topic)
+	}
+}
+
+// topicRegLoop maintains the advertisement of a topic.
+func (t *UDPv5) topicRegLoop(ctx context.Context, topic Topic) {
+	defer t.wg.Done()
+
+	for {
+		registrars := t.newLookup(ctx, enode.ID(topic)).run()
+		if len(registrars) > topicRegistrars {
+			registrars = registrars[:topicRegistrars]
+		}
+		var (
+			wg         sync.WaitGroup
+			mu         sync.Mutex
+			registered int
+		)
+		for _, n := range registrars {
+			wg.Add(1)
+			go func(n *enode.Node) {
+				defer wg.Done()
+				if err := t.registerTopicAt(ctx, n, topic); err != nil {
+					t.log.Debug("Topic registration failed", "id", n.ID(), "addr", n.IP(), "err", err)
+					return
+				}
+				mu.Lock()
+				registered++
+				mu.Unlock()
+			}(n)
+		}
+		wg.Wait()
+
+		interval := topicRegInterval
+		if registered == 0 {
+			interval = topicRegRetryInterval
+		}
+		t.log.Trace("Topic advertisement round done", "topic", topic, "registrars", registered)
+		select {
+		case <-t.clock.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// registerTopicAt obtains a ticket from a registrar and uses it to register.
+func (t *UDPv5) registerTopicAt(ctx context.Context, n *enode.Node, topic Topic) error {
+	ticket, wait, err := t.requestTicket(n, topic)
+	if err != nil {
+		return err
+	}
+	if wait > maxTopicTicketWait {
+		return errTopicWait
+	}
+	select {
+	case <-t.clock.After(wait):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.regtopic(n, ticket)
+}
+
+// requestTicket calls REQUESTTICKET on a node and waits for a TICKET response.
+func (t *UDPv5) requestTicket(n *enode.Node, topic Topic) ([]byte, time.Duration, error) {
+	resp := t.call(n, v5wire.TicketMsg, &v5wire.RequestTicket{Topic: topic[:]})
+	defer t.callDone(resp)
+
+	select {
+	case respMsg := <-resp.ch:
+		ticket := respMsg.(*v5wire.Ticket)
+		return ticket.Ticket, time.Duration(ticket.WaitTime) * time.Millisecond, nil
+	case err := <-resp.err:
+		return nil, 0, err
+	}
+}
+
+// regtopic calls REGTOPIC on a node and waits for a REGCONFIRMATION response.
+func (t *UDPv5) regtopic(n *enode.Node, ticket []byte) error {
+	req := &v5wire.Regtopic{Ticket: ticket, ENR: t.Self().Record()}
+	resp := t.call(n, v5wire.RegconfirmationMsg, req)
+	defer t.callDone(resp)
+
+	select {
+	case respMsg := <-resp.ch:
+		if !respMsg.(*v5wire.Regconfirmation).Registered {
+			return errTopicRefused
+		}
+		return nil
+	case err := <-resp.err:
+		return err
+	}
+}
+
+// topicQuery calls TOPICQUERY on a node and waits for responses.
+func (t *UDPv5) topicQuery(n *enode.Node, topic Topic) ([]*enode.Node, error) {
+	resp := t.call(n, v5wire.NodesMsg, &v5wire.TopicQuery{Topic: topic[:]})
+	return t.waitForNodes(resp, nil)
+}
+
+// handleRequestTicket issues a ticket for a topic.
+func (t *UDPv5) handleRequestTicket(p *v5wire.RequestTicket, fromID enode.ID, fromAddr *net.UDPAddr) {
+	if len(p.Topic) != len(Topic{}) {
+		t.log.Debug("Invalid topic in "+p.Name(), "id", fromID, "addr", fromAddr)
+		return
+	}
+	var topic Topic
+	copy(topic[:], p.Topic)
+	ticket, wait := t.topics.issueTicket(topic, fromID)
+	t.sendResponse(fromID, fromAddr, &v5wire.Ticket{
+		ReqID:    p.ReqID,
+		Ticket:   ticket,
+		WaitTime: uint64(wait / time.Millisecond),
+	})
+}
+
+// handleRegtopic registers the sender in a topic queue.
+func (t *UDPv5) handleRegtopic(p *v5wire.Regtopic, fromID enode.ID, fromAddr *net.UDPAddr) {
+	err := errInvalidTicket
+	if p.ENR != nil {
+		var n *enode.Node
+		if n, err = enode.New(t.validSchemes, p.ENR); err == nil {
+			switch {
+			case n.ID() != fromID:
+				err = errors.New("record of different node")
+			case !n.IP().Equal(fromAddr.IP):
+				err = errors.New("record IP does not match sender")
+			case t.netrestrict != nil && !t.netrestrict.Contains(n.IP()):
+				err = errors.New("not contained in netrestrict list")
+			default:
+				err = t.topics.register(p.Ticket, n)
+			}
+		}
+	}
+	if err != nil {
+		t.log.Debug("Rejected topic registration", "id", fromID, "addr", fromAddr, "err", err)
+	}
+	t.sendResponse(fromID, fromAddr, &v5wire.Regconfirmation{ReqID: p.ReqID, Registered: err == nil})
+}
+
+// handleTopicQuery returns the nodes registered for a topic.
+func (t *UDPv5) handleTopicQuery(p *v5wire.TopicQuery, fromID enode.ID, fromAddr *net.UDPAddr) {
+	var nodes []*enode.Node
+	if len(p.Topic) == len(Topic{}) {
+		var topic Topic
+		copy(topic[:], p.Topic)
+		for _, n := range t.topics.nodes(topic, topicQueryResultLimit) {
+			if netutil.CheckRelayIP(fromAddr.IP, n.IP()) == nil {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	for _, resp := range packNodes(p.ReqID, nodes) {
+		t.sendResponse(fromID, fromAddr, resp)
+	}
+}
+
+// TopicNodes returns an iterator that finds nodes advertising the given topic.
+// Searches are repeated periodically, so the iterator may return the same node
+// more than once.
+func (t *UDPv5) TopicNodes(topic Topic) enode.Iterator {
+	ctx, cancel := context.WithCancel(t.closeCtx)
+	return &topicIterator{t: t, topic: topic, ctx: ctx, cancel: cancel}
+}
+
+// topicIterator runs topic searches.
+type topicIterator struct {
+	t        *UDPv5
+	topic    Topic
+	ctx      context.Context
+	cancel   func()
+	searched bool
+	buffer   []*enode.Node
+	cur      *enode.Node
+}
+
+func (it *topicIterator) Next() bool {
+	it.cur = nil
+	for len(it.buffer) == 0 {
+		if it.ctx.Err() != nil {
+			return false
+		}
+		if it.searched {
+			select {
+			case <-it.t.clock.After(topicSearchInterval):
+			case <-it.ctx.Done():
+				return false
+			}
+		}
+		it.searched = true
+		it.buffer = it.search()
+	}
+	it.cur, it.buffer = it.buffer[0], it.buffer[1:]
+	return true
+}
+
+func (it *topicIterator) Node() *enode.Node {
+	return it.cur
+}
+
+func (it *topicIterator) Close() {
+	it.cancel()
+}
+
+// search sends TOPICQUERY to the nodes closest to the topic hash.
+func (it *topicIterator) search() []*enode.Node {
+	registrars := it.t.newLookup(it.ctx, enode.ID(it.topic)).run()
+	if len(registrars) > topicRegistrars {
+		registrars = registrars[:topicRegistrars]
+	}
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		seen   = make(map[enode.ID]struct{})
+		result []*enode.Node
+	)
+	for _, n := range registrars {
+		wg.Add(1)
+		go func(n *enode.Node) {
+			defer wg.Done()
+			nodes, err := it.t.topicQuery(n, it.topic)
+			if err != nil {
+				it.t.log.Debug("Topic query failed", "id", n.ID(), "addr", n.IP(), "err", err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, rn := range nodes {
+				if _, ok := seen[rn.ID()]; !ok && rn.ID() != it.t.Self().ID() {
+					seen[rn.ID()] = struct{}{}
+					result = append(result, rn)
+				}
+			}
+		}(n)
+	}
+	wg.Wait()
+	return result
+}
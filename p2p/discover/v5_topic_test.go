@@ -0,0 +1,187 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/discover/v5wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// This test checks that topic registrations are accepted with a valid ticket and
+// returned by TOPICQUERY.
+func TestUDPv5_topicHandling(t *testing.T) {
+	t.Parallel()
+	test := newUDPV5Test(t)
+	defer test.close()
+
+	topic := NewTopic("test")
+	remote := test.getNode(test.remotekey, test.remoteaddr).Node()
+
+	// Request a ticket.
+	var ticket []byte
+	test.packetIn(&v5wire.RequestTicket{ReqID: []byte("1"), Topic: topic[:]})
+	test.waitPacketOut(func(p *v5wire.Ticket, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if !bytes.Equal(p.ReqID, []byte("1")) {
+			t.Error("wrong request ID in response:", p.ReqID)
+		}
+		if p.WaitTime != 0 {
+			t.Errorf("wrong wait time %d for empty topic queue", p.WaitTime)
+		}
+		ticket = p.Ticket
+	})
+
+	// A tampered ticket is rejected.
+	invalid := append([]byte{}, ticket...)
+	invalid[len(invalid)-1] ^= 1
+	test.packetIn(&v5wire.Regtopic{ReqID: []byte("2"), Ticket: invalid, ENR: remote.Record()})
+	test.waitPacketOut(func(p *v5wire.Regconfirmation, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if p.Registered {
+			t.Error("registration with invalid ticket accepted")
+		}
+	})
+
+	// A record with an IP other than the sender's is rejected.
+	var r enr.Record
+	r.Set(enr.IP(net.IP{10, 0, 0, 1}))
+	enode.SignV4(&r, test.remotekey)
+	test.packetIn(&v5wire.Regtopic{ReqID: []byte("2"), Ticket: ticket, ENR: &r})
+	test.waitPacketOut(func(p *v5wire.Regconfirmation, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if p.Registered {
+			t.Error("registration with wrong IP accepted")
+		}
+	})
+
+	// Register using the ticket.
+	test.packetIn(&v5wire.Regtopic{ReqID: []byte("3"), Ticket: ticket, ENR: remote.Record()})
+	test.waitPacketOut(func(p *v5wire.Regconfirmation, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if !bytes.Equal(p.ReqID, []byte("3")) {
+			t.Error("wrong request ID in response:", p.ReqID)
+		}
+		if !p.Registered {
+			t.Error("registration refused")
+		}
+	})
+
+	// Check that the node is returned for the topic.
+	test.packetIn(&v5wire.TopicQuery{ReqID: []byte("4"), Topic: topic[:]})
+	test.expectNodes([]byte("4"), 1, []*enode.Node{remote})
+
+	other := NewTopic("other")
+	test.packetIn(&v5wire.TopicQuery{ReqID: []byte("5"), Topic: other[:]})
+	test.expectNodes([]byte("5"), 1, nil)
+}
+
+// This test checks ticket wait times and expiry of registrations.
+func TestTopicTable(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		tt    = newTopicTable(clock)
+		topic = NewTopic("test")
+	)
+	newNode := func(i int) *enode.Node {
+		var id enode.ID
+		id[0], id[1] = byte(i), byte(i>>8)
+		return enode.SignNull(new(enr.Record), id)
+	}
+	register := func(n *enode.Node) error {
+		ticket, wait := tt.issueTicket(topic, n.ID())
+		clock.Run(wait)
+		return tt.register(ticket, n)
+	}
+
+	// Fill the topic queue.
+	for i := 0; i < topicQueueLength; i++ {
+		if err := register(newNode(i)); err != nil {
+			t.Fatalf("registration %d failed: %v", i, err)
+		}
+		clock.Run(time.Second)
+	}
+	// The next node has to wait until the first registration expires. The wait
+	// time is rounded up to whole milliseconds.
+	clock.Run(time.Millisecond / 2)
+	late := newNode(topicQueueLength)
+	ticket, wait := tt.issueTicket(topic, late.ID())
+	if want := topicRegLifetime - topicQueueLength*time.Second; wait != want {
+		t.Fatalf("wrong wait time %v, want %v", wait, want)
+	}
+	if err := tt.register(ticket, late); err != errTicketTooEarly {
+		t.Fatalf("wrong error for early registration: %v", err)
+	}
+	clock.Run(wait)
+	if err := tt.register(ticket, late); err != nil {
+		t.Fatalf("registration after wait time failed: %v", err)
+	}
+	if nodes := tt.nodes(topic, 1); len(nodes) != 1 || nodes[0].ID() != late.ID() {
+		t.Fatalf("wrong nodes returned: %v", nodes)
+	}
+
+	// Unused tickets expire.
+	ticket, _ = tt.issueTicket(topic, late.ID())
+	clock.Run(topicTicketValidity)
+	if err := tt.register(ticket, late); err != errTicketExpired {
+		t.Fatalf("wrong error for expired ticket: %v", err)
+	}
+
+	// All registrations expire eventually.
+	clock.Run(topicRegLifetime)
+	if nodes := tt.nodes(topic, topicQueueLength); len(nodes) != 0 {
+		t.Fatalf("%d registrations not expired", len(nodes))
+	}
+	if tt.count != 0 {
+		t.Fatalf("wrong registration count %d after expiry", tt.count)
+	}
+}
+
+// Real sockets, real crypto: this test checks that advertised topics can be found.
+func TestUDPv5_topicE2E(t *testing.T) {
+	t.Parallel()
+
+	const N = 4
+	var nodes []*UDPv5
+	for i := 0; i < N; i++ {
+		var cfg Config
+		if len(nodes) > 0 {
+			cfg.Bootnodes = []*enode.Node{nodes[0].Self()}
+		}
+		node := startLocalhostV5(t, cfg)
+		nodes = append(nodes, node)
+		defer node.Close()
+	}
+	topic := NewTopic("test")
+	advertiser, searcher := nodes[1], nodes[N-1]
+	advertiser.RegisterTopic(topic)
+
+	it := searcher.TopicNodes(topic).(*topicIterator)
+	defer it.Close()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, n := range it.search() {
+			if n.ID() == advertiser.Self().ID() {
+				return
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("advertised node not found")
+}
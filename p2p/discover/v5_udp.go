@@ -72,10 +72,18 @@ type UDPv5 struct {
 	clock        mclock.Clock
 	validSchemes enr.IdentityScheme
 
+	// hole punching
+	relays          map[enode.ID]struct{} // nodes allowed to send notifications
+	holePunchNotify func(enode.ID, *net.UDPAddr)
+
 	// talkreq handler registry
 	trlock     sync.Mutex
 	trhandlers map[string]TalkRequestHandler
 
+	// topic advertisement
+	topicLock sync.Mutex
+	topicRegs map[Topic]context.CancelFunc
+
 	// channels into dispatch
 	packetInCh    chan ReadPacket
 	readNextCh    chan struct{}
@@ -88,6 +96,8 @@ type UDPv5 struct {
 	activeCallByNode map[enode.ID]*callV5
 	activeCallByAuth map[v5wire.Nonce]*callV5
 	callQueue        map[enode.ID][]*callV5
+	topics           *topicTable
+	endpoints        map[enode.ID]*net.UDPAddr // last seen endpoints, for hole punching
 
 	// shutdown stuff
 	closeOnce      sync.Once
@@ -149,6 +159,10 @@ func newUDPv5(conn UDPConn, ln *enode.LocalNode, cfg Config) (*UDPv5, error) {
 		validSchemes: cfg.ValidSchemes,
 		clock:        cfg.Clock,
 		trhandlers:   make(map[string]TalkRequestHandler),
+		topicRegs:    make(map[Topic]context.CancelFunc),
+		// hole punching
+		relays:          make(map[enode.ID]struct{}, len(cfg.Relays)),
+		holePunchNotify: cfg.HolePunchNotify,
 		// channels into dispatch
 		packetInCh:    make(chan ReadPacket, 1),
 		readNextCh:    make(chan struct{}, 1),
@@ -160,15 +174,21 @@ func newUDPv5(conn UDPConn, ln *enode.LocalNode, cfg Config) (*UDPv5, error) {
 		activeCallByNode: make(map[enode.ID]*callV5),
 		activeCallByAuth: make(map[v5wire.Nonce]*callV5),
 		callQueue:        make(map[enode.ID][]*callV5),
+		topics:           newTopicTable(cfg.Clock),
+		endpoints:        make(map[enode.ID]*net.UDPAddr),
 		// shutdown
 		closeCtx:       closeCtx,
 		cancelCloseCtx: cancelCloseCtx,
 	}
-	tab, err := newTable(t, t.db, cfg.Bootnodes, cfg.Log)
+	tab, err := newTable(t, t.db, cfg.Bootnodes, cfg.PrivateNodes, cfg.Log)
 	if err != nil {
 		return nil, err
 	}
 	t.tab = tab
+	for _, n := range cfg.Relays {
+		t.relays[n.ID()] = struct{}{}
+	}
+	t.trhandlers[holePunchProtocol] = t.handleHolePunch
 	return t, nil
 }
 
@@ -658,6 +678,10 @@ func (t *UDPv5) handlePacket(rawpacket []byte, fromAddr *net.UDPAddr) error {
 		// WHOAREYOU logged separately to report errors.
 		t.log.Trace("<< "+packet.Name(), "id", fromID, "addr", addr)
 	}
+	if packet.Kind() != v5wire.WhoareyouPacket && packet.Kind() != v5wire.UnknownPacket {
+		// The packet is authenticated, remember where the node can be reached.
+		t.rememberEndpoint(fromID, fromAddr)
+	}
 	t.handle(packet, fromID, fromAddr)
 	return nil
 }
@@ -714,6 +738,16 @@ func (t *UDPv5) handle(p v5wire.Packet, fromID enode.ID, fromAddr *net.UDPAddr)
 		t.handleTalkRequest(p, fromID, fromAddr)
 	case *v5wire.TalkResponse:
 		t.handleCallResponse(fromID, fromAddr, p)
+	case *v5wire.RequestTicket:
+		t.handleRequestTicket(p, fromID, fromAddr)
+	case *v5wire.Ticket:
+		t.handleCallResponse(fromID, fromAddr, p)
+	case *v5wire.Regtopic:
+		t.handleRegtopic(p, fromID, fromAddr)
+	case *v5wire.Regconfirmation:
+		t.handleCallResponse(fromID, fromAddr, p)
+	case *v5wire.TopicQuery:
+		t.handleTopicQuery(p, fromID, fromAddr)
 	}
 }
 
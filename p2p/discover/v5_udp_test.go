@@ -72,6 +72,11 @@ func TestUDPv5_lookupE2E(t *testing.T) {
 }
 
 func startLocalhostV5(t *testing.T, cfg Config) *UDPv5 {
+	return startLocalhostV5Conn(t, cfg, func(c *net.UDPConn) UDPConn { return c })
+}
+
+// startLocalhostV5Conn is like startLocalhostV5, but wraps the socket.
+func startLocalhostV5Conn(t *testing.T, cfg Config, wrap func(*net.UDPConn) UDPConn) *UDPv5 {
 	cfg.PrivateKey = newkey()
 	db, _ := enode.OpenDB("")
 	ln := enode.NewLocalNode(db, cfg.PrivateKey)
@@ -93,7 +98,7 @@ func startLocalhostV5(t *testing.T, cfg Config) *UDPv5 {
 	realaddr := socket.LocalAddr().(*net.UDPAddr)
 	ln.SetStaticIP(realaddr.IP)
 	ln.Set(enr.UDP(realaddr.Port))
-	udp, err := ListenV5(socket, ln, cfg)
+	udp, err := ListenV5(wrap(socket), ln, cfg)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -21,8 +21,10 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"net"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -50,6 +52,10 @@ const (
 	dbNodePong      = "lastpong"
 	dbNodeSeq       = "seq"
 
+	// These fields are stored per ID only, with the zero IP in the key.
+	dbNodeScore     = "score"
+	dbNodeScoreTime = "scoretime"
+
 	// Local information is keyed by ID only, the full key is "local:<ID>:seq".
 	// Use localItemKey to create those keys.
 	dbLocalSeq = "seq"
@@ -427,6 +433,57 @@ func (db *DB) UpdateFindFailsV5(id ID, ip net.IP, fails int) error {
 	return db.storeInt64(v5Key(id, ip, dbNodeFindFails), int64(fails))
 }
 
+// Score retrieves the reputation score of a node and the time it was stored.
+func (db *DB) Score(id ID) (float64, time.Time) {
+	score := math.Float64frombits(db.fetchUint64(nodeItemKey(id, zeroIP, dbNodeScore)))
+	return score, time.Unix(db.fetchInt64(nodeItemKey(id, zeroIP, dbNodeScoreTime)), 0)
+}
+
+// UpdateScore stores the reputation score of a node.
+func (db *DB) UpdateScore(id ID, score float64, instance time.Time) error {
+	if err := db.storeUint64(nodeItemKey(id, zeroIP, dbNodeScore), math.Float64bits(score)); err != nil {
+		return err
+	}
+	return db.storeInt64(nodeItemKey(id, zeroIP, dbNodeScoreTime), instance.Unix())
+}
+
+// QueryScored retrieves up to n nodes whose stored reputation score is at least
+// minScore, best scored first. Nodes without a record in the database are skipped.
+func (db *DB) QueryScored(n int, minScore float64) []*Node {
+	type scored struct {
+		id    ID
+		score float64
+	}
+	var (
+		candidates []scored
+		it         = db.lvl.NewIterator(util.BytesPrefix([]byte(dbNodePrefix)), nil)
+	)
+	defer it.Release()
+	for it.Next() {
+		id, _ := splitNodeKey(it.Key())
+		if !bytes.Equal(it.Key(), nodeItemKey(id, zeroIP, dbNodeScore)) {
+			continue
+		}
+		val, _ := binary.Uvarint(it.Value())
+		if score := math.Float64frombits(val); score >= minScore {
+			candidates = append(candidates, scored{id, score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	nodes := make([]*Node, 0, n)
+	for _, c := range candidates {
+		if len(nodes) == n {
+			break
+		}
+		if node := db.Node(c.id); node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
 // localSeq retrieves the local record sequence counter, defaulting to the current
 // timestamp if no previous exists. This ensures that wiping all data associated
 // with a node (apart from its key) will not generate already used sequence nums.
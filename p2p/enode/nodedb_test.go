@@ -26,6 +26,8 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 var keytestID = HexID("51232b8d7821617d2b29b54b81cdefb9b3e9c37d7fd5f63270bcc9e1a6f6a439")
@@ -111,6 +113,38 @@ func TestDBInt64(t *testing.T) {
 	}
 }
 
+func TestDBScore(t *testing.T) {
+	db, _ := OpenDB("")
+	defer db.Close()
+
+	var nodes []*Node
+	for i, score := range []float64{-20, 5, 40, 10} {
+		key, _ := crypto.GenerateKey()
+		n := NewV4(&key.PublicKey, net.IP{127, 0, 0, byte(i)}, 30303, 30303)
+		if err := db.UpdateNode(n); err != nil {
+			t.Fatalf("failed to store node: %v", err)
+		}
+		if err := db.UpdateScore(n.ID(), score, time.Now()); err != nil {
+			t.Fatalf("failed to store score: %v", err)
+		}
+		nodes = append(nodes, n)
+	}
+	if score, _ := db.Score(nodes[0].ID()); score != -20 {
+		t.Errorf("wrong score %f, want -20", score)
+	}
+	if score, _ := db.Score(ID{}); score != 0 {
+		t.Errorf("wrong score %f for unknown node", score)
+	}
+	want := []*Node{nodes[2], nodes[3]}
+	if got := db.QueryScored(2, 0); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong scored nodes: got %v, want %v", got, want)
+	}
+	want = []*Node{nodes[2], nodes[3], nodes[1]}
+	if got := db.QueryScored(10, 0); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong scored nodes: got %v, want %v", got, want)
+	}
+}
+
 func TestDBFetchStore(t *testing.T) {
 	node := NewV4(
 		hexPubkey("1dd9d65c4552b5eb43d5ad55a2ee3f56c6cbc1c64a5c8d659f51fcd51bace24351232b8d7821617d2b29b54b81cdefb9b3e9c37d7fd5f63270bcc9e1a6f6a439"),
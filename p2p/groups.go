@@ -0,0 +1,290 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+)
+
+var (
+	errGroupName     = errors.New("peer group name must not be empty")
+	errGroupSlots    = errors.New("peer group slots must not be negative")
+	errTooManySlots  = errors.New("reserved peer slots exceed MaxPeers")
+	errGroupNotFound = errors.New("peer group not found")
+)
+
+// PeerGroup is a named set of nodes with reserved peer slots. Members are kept
+// connected like static nodes, and up to Slots of them are admitted even if all
+// other peer slots are taken. The reserved slots count against MaxPeers.
+type PeerGroup struct {
+	Name  string
+	Slots int
+	Nodes []*enode.Node
+}
+
+func (g *PeerGroup) contains(id enode.ID) bool {
+	for _, n := range g.Nodes {
+		if n.ID() == id {
+			return true
+		}
+	}
+	return false
+}
+
+// PeerGroupInfo is the status of a peer group.
+type PeerGroupInfo struct {
+	Name      string   `json:"name"`
+	Slots     int      `json:"slots"`
+	Connected int      `json:"connected"` // members occupying reserved slots
+	Nodes     []string `json:"nodes"`
+}
+
+// SubnetQuota limits the number of inbound peers sharing a network prefix, so a
+// single hosting provider can't take up all inbound slots. Zero values disable
+// the respective limit. Trusted peers, members of peer groups and peers on the
+// local network are not subject to the quota.
+type SubnetQuota struct {
+	IPv4Subnet24 int `json:"ipv4Subnet24"` // inbound peers per IPv4 /24 subnet
+	IPv4Subnet16 int `json:"ipv4Subnet16"` // inbound peers per IPv4 /16 subnet
+	IPv6Prefix48 int `json:"ipv6Prefix48"` // inbound peers per IPv6 /48 prefix
+}
+
+// allows reports whether another inbound peer with the given IP is allowed.
+func (q SubnetQuota) allows(ip net.IP, inbound []net.IP) bool {
+	if ip == nil || netutil.IsLAN(ip) {
+		return true
+	}
+	check := func(bits, limit int) bool {
+		if limit <= 0 {
+			return true
+		}
+		set := netutil.DistinctNetSet{Subnet: uint(bits), Limit: uint(limit)}
+		for _, peerIP := range inbound {
+			if (peerIP.To4() == nil) == (ip.To4() == nil) {
+				set.Add(peerIP)
+			}
+		}
+		return set.Add(ip)
+	}
+	if ip.To4() != nil {
+		return check(24, q.IPv4Subnet24) && check(16, q.IPv4Subnet16)
+	}
+	return check(48, q.IPv6Prefix48)
+}
+
+// peerGroups holds the peer groups and subnet quota, which can be changed while
+// the server is running.
+type peerGroups struct {
+	groups map[string]*PeerGroup
+	quota  SubnetQuota
+}
+
+// reserved returns the total number of reserved slots.
+func (pg *peerGroups) reserved() int {
+	n := 0
+	for _, g := range pg.groups {
+		n += g.Slots
+	}
+	return n
+}
+
+// isMember reports whether a node belongs to any group.
+func (pg *peerGroups) isMember(id enode.ID) bool {
+	for _, g := range pg.groups {
+		if g.contains(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddPeerGroup adds a peer group, replacing any existing group of the same name.
+// The members of the group are dialed like static nodes.
+func (srv *Server) AddPeerGroup(group PeerGroup) error {
+	if group.Name == "" {
+		return errGroupName
+	}
+	if group.Slots < 0 {
+		return errGroupSlots
+	}
+	srv.groupsLock.Lock()
+	reserved := srv.groups.reserved() + group.Slots
+	prev := srv.groups.groups[group.Name]
+	if prev != nil {
+		reserved -= prev.Slots
+	}
+	if reserved > srv.MaxPeers {
+		srv.groupsLock.Unlock()
+		return fmt.Errorf("%w: %d > %d", errTooManySlots, reserved, srv.MaxPeers)
+	}
+	g := &PeerGroup{Name: group.Name, Slots: group.Slots, Nodes: append([]*enode.Node{}, group.Nodes...)}
+	srv.groups.groups[group.Name] = g
+	srv.groupsLock.Unlock()
+
+	srv.log.Debug("Adding peer group", "name", g.Name, "slots", g.Slots, "nodes", len(g.Nodes))
+	if prev != nil {
+		srv.removeGroupDials(prev)
+	}
+	for _, n := range g.Nodes {
+		srv.dialsched.addStatic(n)
+	}
+	return nil
+}
+
+// RemovePeerGroup removes a peer group. Its members are no longer dialed unless
+// they are static nodes or members of another group, but they stay connected.
+func (srv *Server) RemovePeerGroup(name string) error {
+	srv.groupsLock.Lock()
+	g := srv.groups.groups[name]
+	delete(srv.groups.groups, name)
+	srv.groupsLock.Unlock()
+
+	if g == nil {
+		return errGroupNotFound
+	}
+	srv.log.Debug("Removing peer group", "name", name)
+	srv.removeGroupDials(g)
+	return nil
+}
+
+// removeGroupDials stops dialing the members of a removed group.
+func (srv *Server) removeGroupDials(g *PeerGroup) {
+	srv.groupsLock.RLock()
+	defer srv.groupsLock.RUnlock()
+
+	for _, n := range g.Nodes {
+		if !srv.groups.isMember(n.ID()) && !containsNode(srv.StaticNodes, n.ID()) {
+			srv.dialsched.removeStatic(n)
+		}
+	}
+}
+
+// PeerGroupInfos returns the status of all peer groups.
+func (srv *Server) PeerGroupInfos() []*PeerGroupInfo {
+	connected := make(map[string]int)
+	srv.doPeerOp(func(peers map[enode.ID]*Peer) {
+		for _, p := range peers {
+			if p.rw.group != "" {
+				connected[p.rw.group]++
+			}
+		}
+	})
+	srv.groupsLock.RLock()
+	defer srv.groupsLock.RUnlock()
+
+	infos := make([]*PeerGroupInfo, 0, len(srv.groups.groups))
+	for _, g := range srv.groups.groups {
+		info := &PeerGroupInfo{Name: g.Name, Slots: g.Slots, Connected: connected[g.Name], Nodes: []string{}}
+		for _, n := range g.Nodes {
+			info.Nodes = append(info.Nodes, n.URLv4())
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// SubnetQuota returns the current inbound subnet quota.
+func (srv *Server) SubnetQuota() SubnetQuota {
+	srv.groupsLock.RLock()
+	defer srv.groupsLock.RUnlock()
+
+	return srv.groups.quota
+}
+
+// SetSubnetQuota changes the inbound subnet quota. Connected peers exceeding
+// the new quota are not disconnected.
+func (srv *Server) SetSubnetQuota(quota SubnetQuota) {
+	srv.groupsLock.Lock()
+	defer srv.groupsLock.Unlock()
+
+	srv.groups.quota = quota
+}
+
+// reserveSlot admits c into a reserved slot if it is a member of a peer group
+// with free slots. It runs on the main loop.
+func (srv *Server) reserveSlot(peers map[enode.ID]*Peer, c *conn) bool {
+	c.group = ""
+
+	srv.groupsLock.RLock()
+	defer srv.groupsLock.RUnlock()
+
+	for name, g := range srv.groups.groups {
+		if !g.contains(c.node.ID()) {
+			continue
+		}
+		used := 0
+		for _, p := range peers {
+			if p.rw.group == name {
+				used++
+			}
+		}
+		if used < g.Slots {
+			c.group = name
+			return true
+		}
+	}
+	return false
+}
+
+// regularPeers returns the number of peers not occupying a reserved slot, and
+// the limit of such peers.
+func (srv *Server) regularPeers(peers map[enode.ID]*Peer) (count, limit int) {
+	srv.groupsLock.RLock()
+	defer srv.groupsLock.RUnlock()
+
+	for _, p := range peers {
+		if p.rw.group == "" || srv.groups.groups[p.rw.group] == nil {
+			count++
+		}
+	}
+	return count, srv.MaxPeers - srv.groups.reserved()
+}
+
+// checkSubnetQuota reports whether the inbound connection c is within the
+// subnet quota.
+func (srv *Server) checkSubnetQuota(peers map[enode.ID]*Peer, c *conn) bool {
+	var inbound []net.IP
+	for _, p := range peers {
+		if p.Inbound() {
+			if ip := remoteIP(p.rw); ip != nil {
+				inbound = append(inbound, ip)
+			}
+		}
+	}
+	return srv.SubnetQuota().allows(remoteIP(c), inbound)
+}
+
+// remoteIP returns the IP of the remote end of a connection.
+func remoteIP(c *conn) net.IP {
+	return netutil.AddrIP(c.fd.RemoteAddr())
+}
+
+func containsNode(nodes []*enode.Node, id enode.ID) bool {
+	for _, n := range nodes {
+		if n.ID() == id {
+			return true
+		}
+	}
+	return false
+}
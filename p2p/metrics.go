@@ -92,3 +92,22 @@ func (c *meteredConn) Close() error {
 	}
 	return err
 }
+
+// meteredStream meters the traffic of an additional stream of a connection, e.g.
+// a QUIC protocol stream. Unlike meteredConn, it doesn't count as a peer.
+type meteredStream struct {
+	meteredConn
+}
+
+// newMeteredStream wraps a stream if the metrics system is enabled.
+func newMeteredStream(conn net.Conn) net.Conn {
+	if !metrics.Enabled {
+		return conn
+	}
+	return &meteredStream{meteredConn{Conn: conn}}
+}
+
+// Close closes the underlying stream without touching the peer gauge.
+func (s *meteredStream) Close() error {
+	return s.Conn.Close()
+}
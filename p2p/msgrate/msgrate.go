@@ -182,6 +182,15 @@ func roundCapacity(cap float64) int {
 	return int(math.Min(maxInt32, math.Max(1, math.Ceil(cap))))
 }
 
+// RoundTrip returns the estimated latency at which the peer responds to data
+// requests.
+func (t *Tracker) RoundTrip() time.Duration {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.roundtrip
+}
+
 // Update modifies the peer's capacity values for a specific data type with a new
 // measurement. If the delivery is zero, the peer is assumed to have either timed
 // out or to not have the requested data, resulting in a slash to 0 capacity. This
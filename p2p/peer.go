@@ -114,6 +114,13 @@ type Peer struct {
 	closed   chan struct{}
 	disc     chan DiscReason
 
+	reputation *reputation
+
+	// traffic counts the bytes exchanged per protocol and message code.
+	traffic *trafficStats
+	// egress limits the outbound bandwidth of protocol messages if set.
+	egress []*egressLimiter
+
 	// events receives message send / receive events if set
 	events   *event.Feed
 	testPipe *MsgPipeRW // for testing
@@ -224,16 +231,26 @@ func (p *Peer) Inbound() bool {
 	return p.rw.is(inboundConn)
 }
 
+// Private returns true if the peer is a private peer of a sentry node.
+func (p *Peer) Private() bool {
+	return p.rw.is(privateConn)
+}
+
 func newPeer(log log.Logger, conn *conn, protocols []Protocol) *Peer {
 	protomap := matchProtocols(protocols, conn.caps, conn)
+	if qt, ok := conn.transport.(*quicTransport); ok {
+		qt.setProtocols(protomap)
+	}
 	p := &Peer{
-		rw:       conn,
-		running:  protomap,
-		created:  mclock.Now(),
-		disc:     make(chan DiscReason),
-		protoErr: make(chan error, len(protomap)+1), // protocols + pingLoop
-		closed:   make(chan struct{}),
-		log:      log.New("id", conn.node.ID(), "conn", conn.flags),
+		rw:         conn,
+		running:    protomap,
+		created:    mclock.Now(),
+		disc:       make(chan DiscReason),
+		protoErr:   make(chan error, len(protomap)+1), // protocols + pingLoop
+		closed:     make(chan struct{}),
+		log:        log.New("id", conn.node.ID(), "conn", conn.flags),
+		reputation: new(reputation),
+		traffic:    newTrafficStats(),
 	}
 	return p
 }
@@ -351,6 +368,7 @@ func (p *Peer) handle(msg Msg) error {
 			metrics.GetOrRegisterMeter(m, nil).Mark(int64(msg.meterSize))
 			metrics.GetOrRegisterMeter(m+"/packets", nil).Mark(1)
 		}
+		p.traffic.addIngress(proto.cap(), msg.Code-proto.offset, msg.Size)
 		select {
 		case proto.in <- msg:
 			return nil
@@ -405,6 +423,8 @@ func (p *Peer) startProtocols(writeStart <-chan struct{}, writeErr chan<- error)
 		proto.closed = p.closed
 		proto.wstart = writeStart
 		proto.werr = writeErr
+		proto.traffic = p.traffic
+		proto.limiters = p.egress
 		var rw MsgReadWriter = proto
 		if p.events != nil {
 			rw = newMsgEventer(rw, p.events, p.ID(), proto.Name, p.Info().Network.RemoteAddress, p.Info().Network.LocalAddress)
@@ -443,6 +463,9 @@ type protoRW struct {
 	werr   chan<- error    // for write results
 	offset uint64
 	w      MsgWriter
+
+	traffic  *trafficStats
+	limiters []*egressLimiter // waited on in order before each write
 }
 
 func (rw *protoRW) WriteMsg(msg Msg) (err error) {
@@ -454,9 +477,24 @@ func (rw *protoRW) WriteMsg(msg Msg) (err error) {
 
 	msg.Code += rw.offset
 
+	if len(rw.limiters) > 0 {
+		class := TrafficNormal
+		if rw.TrafficClass != nil {
+			class = rw.TrafficClass(msg.meterCode)
+		}
+		for _, l := range rw.limiters {
+			if err := l.wait(class, msg.Size, rw.closed); err != nil {
+				return err
+			}
+		}
+	}
 	select {
 	case <-rw.wstart:
+		size := msg.Size
 		err = rw.w.WriteMsg(msg)
+		if err == nil {
+			rw.traffic.addEgress(rw.cap(), msg.meterCode, size)
+		}
 		// Report write status back to Peer.run. It will initiate
 		// shutdown if the error is non-nil and unblock the next write
 		// otherwise. The calling protocol code should exit for errors
@@ -493,8 +531,12 @@ type PeerInfo struct {
 		Inbound       bool   `json:"inbound"`
 		Trusted       bool   `json:"trusted"`
 		Static        bool   `json:"static"`
+		Group         string `json:"group,omitempty"`
+		Private       bool   `json:"private,omitempty"`
 	} `json:"network"`
-	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
+	Reputation float64                     `json:"reputation"` // Score of the peer's past behaviour
+	Traffic    map[string]*ProtocolTraffic `json:"traffic"`    // Bytes exchanged per protocol and message
+	Protocols  map[string]interface{}      `json:"protocols"`  // Sub-protocol specific metadata fields
 }
 
 // Info gathers and returns a collection of metadata known about a peer.
@@ -520,6 +562,10 @@ func (p *Peer) Info() *PeerInfo {
 	info.Network.Inbound = p.rw.is(inboundConn)
 	info.Network.Trusted = p.rw.is(trustedConn)
 	info.Network.Static = p.rw.is(staticDialedConn)
+	info.Network.Group = p.rw.group
+	info.Network.Private = p.rw.is(privateConn)
+	info.Reputation = p.Reputation()
+	info.Traffic = p.traffic.info()
 
 	// Gather all the running protocol infos
 	for _, proto := range p.running {
@@ -61,6 +61,10 @@ type Protocol struct {
 
 	// Attributes contains protocol specific information for the node record.
 	Attributes []enr.Entry
+
+	// TrafficClass is an optional function that returns the priority of outbound
+	// messages when bandwidth is limited. Messages default to TrafficNormal.
+	TrafficClass func(code uint64) TrafficClass
 }
 
 func (p Protocol) cap() Cap {
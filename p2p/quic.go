@@ -0,0 +1,490 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// QUIC support is experimental. The QUIC implementation is provided through the
+// QUICTransport interface, see package p2p/quic. Connections established via
+// QUIC run the RLPx handshakes on the first stream of the session, which also
+// carries the base protocol messages. Every subprotocol gets its own stream in
+// each direction, so large responses of one protocol don't hold up the messages of
+// another. Protocol streams are encrypted with keys derived from the RLPx session.
+
+const (
+	// maxQUICStreams limits the number of protocol streams a peer can open.
+	maxQUICStreams = 32
+
+	// maxQUICProtocolName is the maximum length of a protocol name in the header
+	// of a protocol stream.
+	maxQUICProtocolName = 32
+
+	// maxQUICPendingSessions limits the accepted sessions waiting for their
+	// first stream.
+	maxQUICPendingSessions = 50
+)
+
+var (
+	errQUICClosed         = errors.New("QUIC transport closed")
+	errQUICTooManyStreams = errors.New("too many QUIC streams")
+	errQUICDuplicate      = errors.New("duplicate QUIC protocol stream")
+)
+
+// QUICTransport is a QUIC implementation which the server can use to connect to
+// peers. Nodes accepting QUIC connections advertise the "quic" ENR entry.
+type QUICTransport interface {
+	// Listen starts accepting QUIC sessions on the given UDP address.
+	Listen(addr string) (QUICListener, error)
+	// Dial establishes a session with the given UDP address.
+	Dial(ctx context.Context, addr *net.UDPAddr) (QUICSession, error)
+}
+
+// QUICHolePuncher is implemented by QUIC transports which can send a packet from
+// the listening socket. Sending a packet to a node opens the local NAT for the
+// QUIC sessions it dials to us, see hole punching in relay.go.
+type QUICHolePuncher interface {
+	PunchHole(addr *net.UDPAddr) error
+}
+
+// QUICListener accepts QUIC sessions.
+type QUICListener interface {
+	Accept() (QUICSession, error)
+	Addr() net.Addr
+	Close() error
+}
+
+// QUICSession is a QUIC connection, which carries multiple streams.
+type QUICSession interface {
+	// OpenStream opens a new bidirectional stream.
+	OpenStream() (net.Conn, error)
+	// AcceptStream waits for the next stream opened by the remote end. It returns
+	// an error when the session is closed.
+	AcceptStream() (net.Conn, error)
+
+	LocalAddr() net.Addr
+	RemoteAddr() net.Addr
+	Close() error
+}
+
+// quicConn is the first stream of a QUIC session. Closing it closes the session.
+type quicConn struct {
+	net.Conn
+	session QUICSession
+}
+
+func (c *quicConn) LocalAddr() net.Addr  { return c.session.LocalAddr() }
+func (c *quicConn) RemoteAddr() net.Addr { return c.session.RemoteAddr() }
+
+func (c *quicConn) Close() error {
+	c.Conn.Close()
+	return c.session.Close()
+}
+
+// quicSessionOf returns the QUIC session of a connection established via QUIC.
+func quicSessionOf(conn net.Conn) QUICSession {
+	if mc, ok := conn.(*meteredConn); ok {
+		conn = mc.Conn
+	}
+	if qc, ok := conn.(*quicConn); ok {
+		return qc.session
+	}
+	return nil
+}
+
+// newTransport creates the transport of a connection, which is RLPx unless the
+// connection was established via QUIC.
+func newTransport(conn net.Conn, dialDest *ecdsa.PublicKey) transport {
+	if session := quicSessionOf(conn); session != nil {
+		return newQUIC(conn, session, dialDest)
+	}
+	return newRLPX(conn, dialDest)
+}
+
+// nodeQUIC returns the QUIC port of a node, or zero if it doesn't accept QUIC
+// connections.
+func nodeQUIC(n *enode.Node) int {
+	var port enr.QUIC
+	n.Load(&port)
+	return int(port)
+}
+
+// quicDialer dials nodes via QUIC if they support it, and falls back to another
+// dialer otherwise.
+type quicDialer struct {
+	quic     QUICTransport
+	fallback NodeDialer
+	log      log.Logger
+}
+
+func (d quicDialer) Dial(ctx context.Context, dest *enode.Node) (net.Conn, error) {
+	port := nodeQUIC(dest)
+	if port == 0 || dest.IP() == nil {
+		return d.fallback.Dial(ctx, dest)
+	}
+	conn, err := dialQUIC(ctx, d.quic, &net.UDPAddr{IP: dest.IP(), Port: port})
+	if err == nil {
+		return conn, nil
+	}
+	if dest.TCP() == 0 {
+		return nil, err
+	}
+	d.log.Trace("QUIC dial failed, falling back to TCP", "id", dest.ID(), "err", err)
+	return d.fallback.Dial(ctx, dest)
+}
+
+// dialQUIC establishes a QUIC session and opens its first stream.
+func dialQUIC(ctx context.Context, quic QUICTransport, addr *net.UDPAddr) (net.Conn, error) {
+	session, err := quic.Dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := session.OpenStream()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	return &quicConn{Conn: stream, session: session}, nil
+}
+
+// quicListener adapts a QUICListener to net.Listener. Accept returns the first
+// stream of each session. Sessions are accepted in the background and wait for
+// their first stream concurrently, so a session which never opens a stream
+// doesn't hold up the others.
+type quicListener struct {
+	QUICListener
+
+	conns     chan net.Conn
+	slots     chan struct{} // limits the sessions waiting for their first stream
+	quit      chan struct{}
+	closeOnce sync.Once
+
+	err error // error of the underlying Accept, set when closing quit
+}
+
+func newQUICListener(l QUICListener) *quicListener {
+	ql := &quicListener{
+		QUICListener: l,
+		conns:        make(chan net.Conn),
+		slots:        make(chan struct{}, maxQUICPendingSessions),
+		quit:         make(chan struct{}),
+	}
+	go ql.acceptLoop()
+	return ql
+}
+
+func (l *quicListener) acceptLoop() {
+	for {
+		select {
+		case l.slots <- struct{}{}:
+		case <-l.quit:
+			return
+		}
+		session, err := l.QUICListener.Accept()
+		if err != nil {
+			l.closeOnce.Do(func() {
+				l.err = err
+				close(l.quit)
+			})
+			return
+		}
+		go l.awaitStream(session)
+	}
+}
+
+// awaitStream waits for the first stream of a session. Sessions which don't
+// open a stream in time are dropped.
+func (l *quicListener) awaitStream(session QUICSession) {
+	timer := time.AfterFunc(handshakeTimeout, func() { session.Close() })
+	stream, err := session.AcceptStream()
+	timer.Stop()
+	<-l.slots
+	if err != nil {
+		session.Close()
+		return
+	}
+	conn := &quicConn{Conn: stream, session: session}
+	select {
+	case l.conns <- conn:
+	case <-l.quit:
+		conn.Close()
+	}
+}
+
+func (l *quicListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.quit:
+		if l.err != nil {
+			return nil, l.err
+		}
+		return nil, errQUICClosed
+	}
+}
+
+func (l *quicListener) Close() error {
+	l.closeOnce.Do(func() { close(l.quit) })
+	return l.QUICListener.Close()
+}
+
+// setupQUICListening starts accepting QUIC connections and advertises the QUIC
+// port in the local node record.
+func (srv *Server) setupQUICListening() error {
+	listener, err := srv.QUIC.Listen(srv.QUICListenAddr)
+	if err != nil {
+		return err
+	}
+	srv.quicListener = newQUICListener(listener)
+	srv.QUICListenAddr = listener.Addr().String()
+	if udp, ok := listener.Addr().(*net.UDPAddr); ok {
+		srv.localnode.Set(enr.QUIC(udp.Port))
+	}
+	srv.log.Debug("QUIC listener up", "addr", listener.Addr())
+	srv.loopWG.Add(1)
+	go srv.listenLoop(srv.quicListener)
+	return nil
+}
+
+// quicTransport is the transport of connections established via QUIC.
+type quicTransport struct {
+	*rlpxTransport // the first stream, for the handshakes and base protocol messages
+	session        QUICSession
+	initiator      bool
+
+	mu        sync.Mutex
+	protocols map[string]*protoRW       // matched protocols, for routing messages
+	outgoing  map[string]*rlpxTransport // streams for sending protocol messages
+	incoming  map[string]bool           // names of streams opened by the remote end
+
+	msgs      chan quicMsg
+	quit      chan struct{}
+	closeOnce sync.Once
+}
+
+type quicMsg struct {
+	msg    Msg
+	err    error
+	stream string // protocol of the stream, empty for the first stream
+}
+
+func newQUIC(conn net.Conn, session QUICSession, dialDest *ecdsa.PublicKey) transport {
+	return &quicTransport{
+		rlpxTransport: newRLPX(conn, dialDest).(*rlpxTransport),
+		session:       session,
+		initiator:     dialDest != nil,
+		outgoing:      make(map[string]*rlpxTransport),
+		incoming:      make(map[string]bool),
+		msgs:          make(chan quicMsg),
+		quit:          make(chan struct{}),
+	}
+}
+
+// setProtocols is called with the matched protocols when the peer is created.
+// Messages of these protocols are sent on separate streams.
+func (t *quicTransport) setProtocols(protocols map[string]*protoRW) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.protocols = protocols
+}
+
+func (t *quicTransport) doProtoHandshake(our *protoHandshake) (*protoHandshake, error) {
+	their, err := t.rlpxTransport.doProtoHandshake(our)
+	if err != nil {
+		return nil, err
+	}
+	go t.readLoop("", t.rlpxTransport.ReadMsg)
+	go t.acceptLoop()
+	return their, nil
+}
+
+func (t *quicTransport) ReadMsg() (Msg, error) {
+	select {
+	case m := <-t.msgs:
+		if m.err == nil && m.stream != "" && !t.inProtocol(m.stream, m.msg.Code) {
+			return Msg{}, fmt.Errorf("msg code %d out of range for QUIC stream %q", m.msg.Code, m.stream)
+		}
+		return m.msg, m.err
+	case <-t.quit:
+		return Msg{}, errQUICClosed
+	}
+}
+
+func (t *quicTransport) WriteMsg(msg Msg) error {
+	stream, err := t.stream(msg.Code)
+	if err != nil {
+		return err
+	}
+	return stream.WriteMsg(msg)
+}
+
+func (t *quicTransport) close(err error) {
+	t.closeOnce.Do(func() { close(t.quit) })
+	t.rlpxTransport.close(err)
+}
+
+// inProtocol reports whether a message code belongs to the given protocol. The
+// protocols are set before the peer reads any messages.
+func (t *quicTransport) inProtocol(name string, code uint64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	proto := t.protocols[name]
+	return proto != nil && code >= proto.offset && code < proto.offset+proto.Length
+}
+
+// stream returns the stream on which a message is sent, opening it if needed.
+func (t *quicTransport) stream(code uint64) (*rlpxTransport, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var name string
+	for n, proto := range t.protocols {
+		if code >= proto.offset && code < proto.offset+proto.Length {
+			name = n
+			break
+		}
+	}
+	if name == "" {
+		return t.rlpxTransport, nil
+	}
+	if stream := t.outgoing[name]; stream != nil {
+		return stream, nil
+	}
+	conn, err := t.session.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	conn = newMeteredStream(conn)
+	conn.SetWriteDeadline(time.Now().Add(frameWriteTimeout))
+	if _, err := conn.Write(append([]byte{byte(len(name))}, name...)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	label := t.streamLabel(name, t.initiator)
+	stream := &rlpxTransport{conn: t.rlpxTransport.conn.Fork(conn, label)}
+	t.outgoing[name] = stream
+	return stream, nil
+}
+
+// streamLabel returns the key derivation label of the stream of a protocol. As
+// each end opens its own stream, the label includes the role of the opener.
+func (t *quicTransport) streamLabel(name string, initiator bool) []byte {
+	if initiator {
+		return []byte(name + "/initiator")
+	}
+	return []byte(name + "/recipient")
+}
+
+// acceptLoop handles the protocol streams opened by the remote end.
+func (t *quicTransport) acceptLoop() {
+	for {
+		conn, err := t.session.AcceptStream()
+		if err != nil {
+			return
+		}
+		conn = newMeteredStream(conn)
+		go func() {
+			if err := t.handleStream(conn); err != nil {
+				conn.Close()
+				t.deliver(quicMsg{err: err})
+			}
+		}()
+	}
+}
+
+// handleStream reads the header of a protocol stream, then its messages.
+func (t *quicTransport) handleStream(conn net.Conn) error {
+	conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	var size [1]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return err
+	}
+	if size[0] == 0 || size[0] > maxQUICProtocolName {
+		return fmt.Errorf("invalid QUIC stream header")
+	}
+	name := make([]byte, size[0])
+	if _, err := io.ReadFull(conn, name); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	t.mu.Lock()
+	switch {
+	case t.incoming[string(name)]:
+		t.mu.Unlock()
+		return errQUICDuplicate
+	case len(t.incoming) >= maxQUICStreams:
+		t.mu.Unlock()
+		return errQUICTooManyStreams
+	}
+	t.incoming[string(name)] = true
+	t.mu.Unlock()
+
+	// The stream was opened by the remote end, so it has the opposite role.
+	stream := t.rlpxTransport.conn.Fork(conn, t.streamLabel(string(name), !t.initiator))
+	t.readLoop(string(name), func() (Msg, error) {
+		// Protocol streams may be idle for a long time, there is no read deadline.
+		code, data, wireSize, err := stream.Read()
+		if err != nil {
+			return Msg{}, err
+		}
+		data = common.CopyBytes(data)
+		return Msg{
+			ReceivedAt: time.Now(),
+			Code:       code,
+			Size:       uint32(len(data)),
+			meterSize:  uint32(wireSize),
+			Payload:    bytes.NewReader(data),
+		}, nil
+	})
+	return nil
+}
+
+// readLoop delivers the messages of a stream until reading fails.
+func (t *quicTransport) readLoop(stream string, read func() (Msg, error)) {
+	for {
+		msg, err := read()
+		if !t.deliver(quicMsg{msg, err, stream}) || err != nil {
+			return
+		}
+	}
+}
+
+func (t *quicTransport) deliver(m quicMsg) bool {
+	select {
+	case t.msgs <- m:
+		return true
+	case <-t.quit:
+		return false
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package quic implements the experimental QUIC transport of the p2p server on
+// top of quic-go.
+//
+// QUIC always runs TLS 1.3, but the TLS certificates are not used to authenticate
+// peers. Each transport uses a throwaway self-signed certificate and certificates
+// aren't verified when dialing. Peers are authenticated by the RLPx handshake on
+// the first stream of the session, and protocol streams are encrypted with keys
+// derived from the RLPx session, exactly like connections over TCP.
+package quic
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	quicgo "github.com/quic-go/quic-go"
+)
+
+const (
+	// alpn is the application protocol negotiated in the TLS handshake.
+	alpn = "devp2p"
+
+	// Sessions are kept alive while idle, peers ping each other only every 15s.
+	keepAlivePeriod = 10 * time.Second
+	maxIdleTimeout  = 30 * time.Second
+
+	// maxIncomingStreams limits the streams a peer can have open at any time. The
+	// p2p server limits the number of protocol streams further.
+	maxIncomingStreams = 64
+
+	// holePunchPacketSize is the size of packets sent for hole punching.
+	holePunchPacketSize = 32
+)
+
+var errNotListening = errors.New("QUIC transport not listening")
+
+// Transport is a QUIC transport for the p2p server.
+type Transport struct {
+	server *tls.Config
+	client *tls.Config
+	config *quicgo.Config
+
+	mu  sync.Mutex
+	udp *quicgo.Transport // transport of the listener, also used for dialing
+}
+
+var (
+	_ p2p.QUICTransport   = (*Transport)(nil)
+	_ p2p.QUICHolePuncher = (*Transport)(nil)
+)
+
+// NewTransport creates a QUIC transport with a fresh self-signed certificate.
+func NewTransport() (*Transport, error) {
+	cert, err := selfSignedCertificate()
+	if err != nil {
+		return nil, err
+	}
+	return &Transport{
+		server: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{alpn},
+			MinVersion:   tls.VersionTLS13,
+		},
+		client: &tls.Config{
+			// Peers are authenticated by the RLPx handshake, see package docs.
+			InsecureSkipVerify: true,
+			NextProtos:         []string{alpn},
+			MinVersion:         tls.VersionTLS13,
+		},
+		config: &quicgo.Config{
+			KeepAlivePeriod:    keepAlivePeriod,
+			MaxIdleTimeout:     maxIdleTimeout,
+			MaxIncomingStreams: maxIncomingStreams,
+		},
+	}, nil
+}
+
+// Listen implements p2p.QUICTransport. Sessions dialed after Listen was called
+// originate from the listening socket, so their source port is the QUIC port.
+func (t *Transport) Listen(addr string) (p2p.QUICListener, error) {
+	laddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+	udp := &quicgo.Transport{Conn: conn}
+	ln, err := udp.Listen(t.server, t.config)
+	if err != nil {
+		udp.Close()
+		return nil, err
+	}
+	t.mu.Lock()
+	t.udp = udp
+	t.mu.Unlock()
+	return &listener{t: t, udp: udp, ln: ln}, nil
+}
+
+// Dial implements p2p.QUICTransport.
+func (t *Transport) Dial(ctx context.Context, addr *net.UDPAddr) (p2p.QUICSession, error) {
+	t.mu.Lock()
+	udp := t.udp
+	t.mu.Unlock()
+
+	var (
+		conn quicgo.Connection
+		err  error
+	)
+	if udp != nil {
+		conn, err = udp.Dial(ctx, addr, t.client, t.config)
+	} else {
+		// Without a listener, every session gets its own socket.
+		conn, err = quicgo.DialAddr(ctx, addr.String(), t.client, t.config)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session{conn}, nil
+}
+
+// PunchHole implements p2p.QUICHolePuncher. It sends a random packet from the
+// listening socket, which opens the local NAT for sessions dialed from the
+// address. Receivers drop the packet as it isn't a valid QUIC packet.
+func (t *Transport) PunchHole(addr *net.UDPAddr) error {
+	t.mu.Lock()
+	udp := t.udp
+	t.mu.Unlock()
+
+	if udp == nil {
+		return errNotListening
+	}
+	packet := make([]byte, holePunchPacketSize)
+	rand.Read(packet)
+	_, err := udp.WriteTo(packet, addr)
+	return err
+}
+
+// listener implements p2p.QUICListener.
+type listener struct {
+	t   *Transport
+	udp *quicgo.Transport
+	ln  *quicgo.Listener
+}
+
+func (l *listener) Accept() (p2p.QUICSession, error) {
+	conn, err := l.ln.Accept(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &session{conn}, nil
+}
+
+func (l *listener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+// Close stops accepting sessions and closes the socket, along with all sessions
+// using it.
+func (l *listener) Close() error {
+	l.t.mu.Lock()
+	if l.t.udp == l.udp {
+		l.t.udp = nil
+	}
+	l.t.mu.Unlock()
+
+	l.ln.Close()
+	return l.udp.Close()
+}
+
+// session implements p2p.QUICSession.
+type session struct {
+	conn quicgo.Connection
+}
+
+func (s *session) OpenStream() (net.Conn, error) {
+	st, err := s.conn.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	return &stream{st, s.conn}, nil
+}
+
+func (s *session) AcceptStream() (net.Conn, error) {
+	st, err := s.conn.AcceptStream(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &stream{st, s.conn}, nil
+}
+
+func (s *session) LocalAddr() net.Addr  { return s.conn.LocalAddr() }
+func (s *session) RemoteAddr() net.Addr { return s.conn.RemoteAddr() }
+
+func (s *session) Close() error {
+	return s.conn.CloseWithError(0, "")
+}
+
+// stream is a QUIC stream wrapped as a net.Conn.
+type stream struct {
+	quicgo.Stream
+	conn quicgo.Connection
+}
+
+func (s *stream) LocalAddr() net.Addr  { return s.conn.LocalAddr() }
+func (s *stream) RemoteAddr() net.Addr { return s.conn.RemoteAddr() }
+
+// Close closes both directions of the stream. Closing a quic-go stream only
+// finishes the sending side.
+func (s *stream) Close() error {
+	s.CancelRead(0)
+	return s.Stream.Close()
+}
+
+// selfSignedCertificate creates a throwaway TLS certificate.
+func selfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(10 * 365 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package quic
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/testlog"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+// This test connects two servers via QUIC and exchanges protocol messages.
+func TestServerQUIC(t *testing.T) {
+	results := make(chan string, 2)
+	proto := p2p.Protocol{
+		Name:    "test",
+		Version: 1,
+		Length:  2,
+		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			if _, ok := p.RemoteAddr().(*net.UDPAddr); !ok {
+				results <- "not connected via QUIC"
+				return nil
+			}
+			if err := p2p.SendItems(rw, 1, "hello"); err != nil {
+				return err
+			}
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				return err
+			}
+			var greeting []string
+			if err := msg.Decode(&greeting); err != nil || msg.Code != 1 {
+				results <- "invalid message"
+				return nil
+			}
+			results <- greeting[0]
+			// Wait for disconnect.
+			_, err = rw.ReadMsg()
+			return err
+		},
+	}
+	start := func(quicAddr string) *p2p.Server {
+		transport, err := NewTransport()
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, _ := crypto.GenerateKey()
+		srv := &p2p.Server{Config: p2p.Config{
+			PrivateKey:     key,
+			MaxPeers:       10,
+			NoDiscovery:    true,
+			ListenAddr:     "",
+			Protocols:      []p2p.Protocol{proto},
+			QUIC:           transport,
+			QUICListenAddr: quicAddr,
+			Logger:         testlog.Logger(t, log.LvlTrace),
+		}}
+		if err := srv.Start(); err != nil {
+			t.Fatalf("could not start: %v", err)
+		}
+		return srv
+	}
+	srv1 := start("127.0.0.1:0")
+	defer srv1.Stop()
+	srv2 := start("")
+	defer srv2.Stop()
+
+	// The first server has no TCP listener, so the connection can only be
+	// established via QUIC.
+	srv2.AddPeer(srv1.Self())
+	for i := 0; i < 2; i++ {
+		select {
+		case res := <-results:
+			if res != "hello" {
+				t.Fatal(res)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
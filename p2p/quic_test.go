@@ -0,0 +1,324 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/internal/testlog"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+func TestServerQUIC(t *testing.T) {
+	var (
+		network = newLoopbackQUIC()
+		results = make(chan string, 2)
+	)
+	proto := Protocol{
+		Name:    "test",
+		Version: 1,
+		Length:  2,
+		Run: func(p *Peer, rw MsgReadWriter) error {
+			if _, ok := p.RemoteAddr().(*net.UDPAddr); !ok {
+				results <- "not connected via QUIC"
+				return nil
+			}
+			if err := SendItems(rw, 1, "hello"); err != nil {
+				return err
+			}
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				return err
+			}
+			var greeting []string
+			if err := msg.Decode(&greeting); err != nil || msg.Code != 1 {
+				results <- "invalid message"
+				return nil
+			}
+			results <- greeting[0]
+			// Wait for disconnect.
+			_, err = rw.ReadMsg()
+			return err
+		},
+	}
+	start := func(quicAddr string) *Server {
+		srv := &Server{Config: Config{
+			PrivateKey:     newkey(),
+			MaxPeers:       10,
+			NoDiscovery:    true,
+			ListenAddr:     "127.0.0.1:0",
+			Protocols:      []Protocol{proto},
+			QUIC:           network,
+			QUICListenAddr: quicAddr,
+			Logger:         testlog.Logger(t, log.LvlTrace),
+		}}
+		if err := srv.Start(); err != nil {
+			t.Fatalf("could not start: %v", err)
+		}
+		return srv
+	}
+	srv1 := start("127.0.0.1:0")
+	defer srv1.Stop()
+	srv2 := start("")
+	defer srv2.Stop()
+
+	var port enr.QUIC
+	if err := srv1.Self().Load(&port); err != nil {
+		t.Fatal("QUIC port not advertised:", err)
+	}
+	srv2.AddPeer(srv1.Self())
+	for i := 0; i < 2; i++ {
+		select {
+		case res := <-results:
+			if res != "hello" {
+				t.Fatal(res)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+	// Both ends have opened a stream for the test protocol.
+	if n := atomic.LoadInt32(&network.streams); n != 3 {
+		t.Fatalf("wrong number of streams: %d", n)
+	}
+}
+
+// This test checks that sessions which don't open their first stream don't hold
+// up the sessions accepted after them.
+func TestQUICListenerPendingSession(t *testing.T) {
+	network := newLoopbackQUIC()
+	l, _ := network.Listen("127.0.0.1:0")
+	listener := newQUICListener(l)
+	defer listener.Close()
+
+	addr := l.Addr().(*net.UDPAddr)
+	idle, err := network.Dial(context.Background(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+	active, err := network.Dial(context.Background(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer active.Close()
+	if _, err := active.OpenStream(); err != nil {
+		t.Fatal(err)
+	}
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- conn
+	}()
+	select {
+	case conn := <-accepted:
+		if conn.RemoteAddr().String() != active.LocalAddr().String() {
+			t.Fatalf("accepted wrong session from %v", conn.RemoteAddr())
+		}
+		conn.Close()
+	case <-time.After(handshakeTimeout / 2):
+		t.Fatal("session blocked by idle session")
+	}
+
+	// Accept fails after closing.
+	listener.Close()
+	if _, err := listener.Accept(); err == nil {
+		t.Fatal("accept succeeded on closed listener")
+	}
+}
+
+// This test checks that messages received on a protocol stream must belong to the
+// protocol of the stream.
+func TestQUICStreamMsgCode(t *testing.T) {
+	qt := &quicTransport{
+		protocols: map[string]*protoRW{
+			"test": {Protocol: Protocol{Name: "test", Length: 2}, offset: baseProtocolLength},
+		},
+		msgs: make(chan quicMsg),
+		quit: make(chan struct{}),
+	}
+	defer close(qt.quit)
+
+	tests := []struct {
+		stream string
+		code   uint64
+		ok     bool
+	}{
+		{stream: "", code: pingMsg, ok: true},
+		{stream: "test", code: baseProtocolLength, ok: true},
+		{stream: "test", code: baseProtocolLength + 1, ok: true},
+		{stream: "test", code: baseProtocolLength + 2},
+		{stream: "test", code: discMsg},
+		{stream: "other", code: baseProtocolLength},
+	}
+	for _, test := range tests {
+		go qt.deliver(quicMsg{msg: Msg{Code: test.code}, stream: test.stream})
+		_, err := qt.ReadMsg()
+		if (err == nil) != test.ok {
+			t.Errorf("stream %q, code %d: wrong error %v", test.stream, test.code, err)
+		}
+	}
+}
+
+// loopbackQUIC is an in-memory QUICTransport.
+type loopbackQUIC struct {
+	mu        sync.Mutex
+	listeners map[int]*loopbackListener
+	nextPort  int
+	streams   int32
+}
+
+func newLoopbackQUIC() *loopbackQUIC {
+	return &loopbackQUIC{listeners: make(map[int]*loopbackListener), nextPort: 40000}
+}
+
+func (q *loopbackQUIC) Listen(addr string) (QUICListener, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextPort++
+	l := &loopbackListener{
+		network:  q,
+		addr:     &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: q.nextPort},
+		sessions: make(chan *loopbackSession),
+		closed:   make(chan struct{}),
+	}
+	q.listeners[q.nextPort] = l
+	return l, nil
+}
+
+func (q *loopbackQUIC) Dial(ctx context.Context, addr *net.UDPAddr) (QUICSession, error) {
+	q.mu.Lock()
+	l := q.listeners[addr.Port]
+	q.nextPort++
+	local := &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: q.nextPort}
+	q.mu.Unlock()
+	if l == nil {
+		return nil, errors.New("connection refused")
+	}
+	s1, s2 := newLoopbackSessions(q, local, addr)
+	select {
+	case l.sessions <- s2:
+		return s1, nil
+	case <-l.closed:
+		return nil, errors.New("connection refused")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type loopbackListener struct {
+	network   *loopbackQUIC
+	addr      *net.UDPAddr
+	sessions  chan *loopbackSession
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *loopbackListener) Accept() (QUICSession, error) {
+	select {
+	case s := <-l.sessions:
+		return s, nil
+	case <-l.closed:
+		return nil, errors.New("listener closed")
+	}
+}
+
+func (l *loopbackListener) Addr() net.Addr { return l.addr }
+
+func (l *loopbackListener) Close() error {
+	l.closeOnce.Do(func() {
+		l.network.mu.Lock()
+		delete(l.network.listeners, l.addr.Port)
+		l.network.mu.Unlock()
+		close(l.closed)
+	})
+	return nil
+}
+
+// loopbackSession is one end of an in-memory session. Streams are net.Pipes.
+type loopbackSession struct {
+	network       *loopbackQUIC
+	local, remote net.Addr
+	peer          *loopbackSession
+	accept        chan net.Conn
+	state         *loopbackState // shared by both ends
+}
+
+type loopbackState struct {
+	mu      sync.Mutex
+	streams []net.Conn
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func newLoopbackSessions(network *loopbackQUIC, a, b net.Addr) (*loopbackSession, *loopbackSession) {
+	state := &loopbackState{closed: make(chan struct{})}
+	s1 := &loopbackSession{network: network, local: a, remote: b, accept: make(chan net.Conn, maxQUICStreams), state: state}
+	s2 := &loopbackSession{network: network, local: b, remote: a, accept: make(chan net.Conn, maxQUICStreams), state: state}
+	s1.peer, s2.peer = s2, s1
+	return s1, s2
+}
+
+func (s *loopbackSession) OpenStream() (net.Conn, error) {
+	c1, c2 := net.Pipe()
+	s.state.mu.Lock()
+	s.state.streams = append(s.state.streams, c1, c2)
+	s.state.mu.Unlock()
+	select {
+	case s.peer.accept <- c2:
+		atomic.AddInt32(&s.network.streams, 1)
+		return c1, nil
+	case <-s.state.closed:
+		return nil, errors.New("session closed")
+	}
+}
+
+func (s *loopbackSession) AcceptStream() (net.Conn, error) {
+	select {
+	case c := <-s.accept:
+		return c, nil
+	case <-s.state.closed:
+		return nil, errors.New("session closed")
+	}
+}
+
+func (s *loopbackSession) LocalAddr() net.Addr  { return s.local }
+func (s *loopbackSession) RemoteAddr() net.Addr { return s.remote }
+
+func (s *loopbackSession) Close() error {
+	s.state.once.Do(func() {
+		close(s.state.closed)
+		s.state.mu.Lock()
+		for _, c := range s.state.streams {
+			c.Close()
+		}
+		s.state.mu.Unlock()
+	})
+	return nil
+}
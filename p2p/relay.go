@@ -0,0 +1,800 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// Relays make nodes behind NAT reachable. A node behind NAT stays connected to its
+// relays and advertises them in the "relay" entry of its node record. Nodes which
+// fail to dial it directly ask one of these relays, if they are connected to it, to
+// open a stream to the node. The relay forwards the stream over its connection to
+// the node behind NAT. The usual RLPx handshake runs end-to-end over the stream, so
+// the relay can't read or modify the relayed connection.
+//
+// Streams are carried by the "relay" protocol, which runs on all nodes that have
+// relaying enabled. Streams are flow controlled: the receiving end grants the sender
+// a window of relayStreamWindow bytes, and extends it as the data is consumed, so
+// the relay protocol read loop never waits for a slow stream. Relays queue forwarded
+// data per stream, which the window bounds as well, so a slow target peer doesn't
+// hold up the other streams of the source peer.
+//
+// Before falling back to a relay, the dialer tries to reach the node directly via
+// hole punching, using the relays as discv5 rendezvous nodes. Nodes behind NAT ping
+// their relays via discv5, so the relays know their current endpoint. When asked
+// by the relay, the node opens its NAT for the dialer from both the discovery and
+// the QUIC socket, and the dialer establishes a QUIC session to the node. This needs
+// discv5 and QUIC on both nodes, and only supports full cone and address-restricted
+// cone NATs which keep the QUIC port, i.e. NATs which map the QUIC socket of the node
+// to its listening port for all destinations and accept packets from any port of an
+// address it has sent to. Nodes behind symmetric or port-restricted NATs are reached
+// through the relay.
+
+const (
+	relayProtocolName    = "relay"
+	relayProtocolVersion = 1
+	relayProtocolLength  = 5
+
+	relayConnectMsg  = 0x00 // request to a relay to open a stream to a node
+	relayIncomingMsg = 0x01 // stream opened by a relay on behalf of a node
+	relayDataMsg     = 0x02
+	relayCloseMsg    = 0x03
+	relayWindowMsg   = 0x04 // extends the send window of a stream
+
+	// maxRelayStreams limits the number of streams on a relay connection.
+	maxRelayStreams = 16
+
+	// maxRelayChunk is the maximum amount of data carried by a message.
+	maxRelayChunk = 16 * 1024
+
+	// relayStreamWindow is the amount of data which may be sent on a stream before
+	// the receiver has consumed it. Up to this much data is buffered per stream.
+	relayStreamWindow = 16 * maxRelayChunk
+
+	// relayPingInterval is how often a node behind NAT pings its relays via discv5.
+	// This keeps the NAT mapping of the discovery socket alive and lets the relays
+	// act as rendezvous nodes for hole punching.
+	relayPingInterval = 20 * time.Second
+
+	// maxRelayEndpoints is the number of relays advertised in the node record.
+	maxRelayEndpoints = 2
+)
+
+var (
+	errRelayNotConnected = errors.New("not connected to relay")
+	errRelayStreamLimit  = errors.New("too many relay streams")
+	errRelayInvalidID    = errors.New("invalid relay stream ID")
+	errNoHolePunch       = errors.New("hole punching unavailable")
+)
+
+type relayConnect struct {
+	Stream uint64
+	Node   enode.ID // target in relayConnectMsg, source in relayIncomingMsg
+}
+
+type relayData struct {
+	Stream uint64
+	Data   []byte
+}
+
+type relayClose struct {
+	Stream uint64
+}
+
+type relayWindow struct {
+	Stream uint64
+	Bytes  uint64
+}
+
+// relayEntry is the "relay" ENR entry. It lists the relays through which the node
+// accepts connections.
+type relayEntry []relayEndpoint
+
+type relayEndpoint struct {
+	Key enode.Secp256k1
+	IP  net.IP
+	TCP uint16
+	UDP uint16
+}
+
+func (relayEntry) ENRKey() string { return "relay" }
+
+// NewRelayEntry creates the ENR entry advertising the given relays.
+func NewRelayEntry(relays []*enode.Node) enr.Entry {
+	entry := make(relayEntry, 0, maxRelayEndpoints)
+	for _, n := range relays {
+		if len(entry) == maxRelayEndpoints {
+			break
+		}
+		if n.Pubkey() == nil {
+			continue
+		}
+		entry = append(entry, relayEndpoint{Key: enode.Secp256k1(*n.Pubkey()), IP: n.IP(), TCP: uint16(n.TCP()), UDP: uint16(n.UDP())})
+	}
+	return entry
+}
+
+// nodeRelays returns the relays advertised by n.
+func nodeRelays(n *enode.Node) []*enode.Node {
+	var entry relayEntry
+	if n.Load(&entry) != nil {
+		return nil
+	}
+	relays := make([]*enode.Node, 0, len(entry))
+	for _, e := range entry {
+		key := e.Key
+		relays = append(relays, enode.NewV4((*ecdsa.PublicKey)(&key), e.IP, int(e.TCP), int(e.UDP)))
+	}
+	return relays
+}
+
+// isRelayEnabled reports whether the server runs the relay protocol.
+func (srv *Server) isRelayEnabled() bool {
+	return srv.Relay || srv.RelayService || len(srv.Relays) > 0
+}
+
+// setupRelay adds the relay protocol and advertises the relays of the server.
+func (srv *Server) setupRelay() {
+	srv.relay = &relayHub{srv: srv, peers: make(map[enode.ID]*relayPeer)}
+	for _, p := range srv.Protocols {
+		if p.Name == relayProtocolName {
+			return
+		}
+	}
+	srv.Protocols = append(srv.Protocols, Protocol{
+		Name:    relayProtocolName,
+		Version: relayProtocolVersion,
+		Length:  relayProtocolLength,
+		Run: func(p *Peer, rw MsgReadWriter) error {
+			return srv.relay.runPeer(p, rw)
+		},
+	})
+}
+
+// relayHub tracks the peers running the relay protocol.
+type relayHub struct {
+	srv   *Server
+	mu    sync.Mutex
+	peers map[enode.ID]*relayPeer
+}
+
+func (h *relayHub) peer(id enode.ID) *relayPeer {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.peers[id]
+}
+
+// isRelay reports whether id is one of the relays of the server.
+func (h *relayHub) isRelay(id enode.ID) bool {
+	return containsNode(h.srv.Relays, id)
+}
+
+func (h *relayHub) runPeer(p *Peer, rw MsgReadWriter) error {
+	rp := &relayPeer{id: p.ID(), rw: rw, streams: make(map[uint64]relayStream)}
+	// Stream IDs are allocated with different parity by the two ends.
+	if self := h.srv.localnode.ID(); bytes.Compare(self[:], rp.id[:]) > 0 {
+		rp.parity = 1
+	}
+	rp.nextID = rp.parity + 2
+	h.mu.Lock()
+	h.peers[rp.id] = rp
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.peers, rp.id)
+		h.mu.Unlock()
+		rp.closeAll()
+	}()
+
+	for {
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+		err = h.handle(rp, msg)
+		msg.Discard()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (h *relayHub) handle(rp *relayPeer, msg Msg) error {
+	switch msg.Code {
+	case relayConnectMsg:
+		var req relayConnect
+		if err := msg.Decode(&req); err != nil {
+			return err
+		}
+		if rp.isLocalID(req.Stream) || rp.stream(req.Stream) != nil {
+			return errRelayInvalidID
+		}
+		h.handleConnect(rp, &req)
+	case relayIncomingMsg:
+		var req relayConnect
+		if err := msg.Decode(&req); err != nil {
+			return err
+		}
+		if rp.isLocalID(req.Stream) || rp.stream(req.Stream) != nil {
+			return errRelayInvalidID
+		}
+		h.handleIncoming(rp, &req)
+	case relayDataMsg:
+		var data relayData
+		if err := msg.Decode(&data); err != nil {
+			return err
+		}
+		if len(data.Data) > maxRelayChunk {
+			return fmt.Errorf("relay chunk too large (%d bytes)", len(data.Data))
+		}
+		// Data for unknown streams is ignored, the stream may have been closed.
+		if s := rp.stream(data.Stream); s != nil && !s.deliver(data.Data) {
+			rp.closeStream(data.Stream, true)
+		}
+	case relayCloseMsg:
+		var req relayClose
+		if err := msg.Decode(&req); err != nil {
+			return err
+		}
+		rp.closeStream(req.Stream, false)
+	case relayWindowMsg:
+		var req relayWindow
+		if err := msg.Decode(&req); err != nil {
+			return err
+		}
+		if s := rp.stream(req.Stream); s != nil && !s.window(req.Bytes) {
+			rp.closeStream(req.Stream, true)
+		}
+	default:
+		return fmt.Errorf("invalid relay message code %d", msg.Code)
+	}
+	return nil
+}
+
+// handleConnect forwards a stream to the requested node.
+func (h *relayHub) handleConnect(rp *relayPeer, req *relayConnect) {
+	var target *relayPeer
+	if h.srv.RelayService && req.Node != rp.id {
+		target = h.peer(req.Node)
+	}
+	if target == nil {
+		Send(rp.rw, relayCloseMsg, &relayClose{req.Stream})
+		return
+	}
+	targetID := target.allocate()
+	fwd, back := newRelayForward(target, targetID), newRelayForward(rp, req.Stream)
+	if err := rp.addStream(req.Stream, fwd); err != nil {
+		fwd.shutdown()
+		back.shutdown()
+		Send(rp.rw, relayCloseMsg, &relayClose{req.Stream})
+		return
+	}
+	if err := target.addStream(targetID, back); err != nil {
+		back.shutdown()
+		rp.closeStream(req.Stream, true)
+		return
+	}
+	h.srv.log.Trace("Relaying connection", "from", rp.id, "to", target.id)
+	if err := Send(target.rw, relayIncomingMsg, &relayConnect{targetID, rp.id}); err != nil {
+		target.closeStream(targetID, false)
+	}
+}
+
+// handleIncoming accepts a stream opened by one of our relays.
+func (h *relayHub) handleIncoming(rp *relayPeer, req *relayConnect) {
+	if !h.isRelay(rp.id) {
+		Send(rp.rw, relayCloseMsg, &relayClose{req.Stream})
+		return
+	}
+	conn := newRelayConn(rp, req.Stream, req.Node)
+	if err := rp.addStream(req.Stream, conn); err != nil {
+		conn.shutdown()
+		Send(rp.rw, relayCloseMsg, &relayClose{req.Stream})
+		return
+	}
+	go h.srv.SetupConn(conn, inboundConn, nil)
+}
+
+// dial opens a stream to the given node through a relay.
+func (h *relayHub) dial(relay enode.ID, dest *enode.Node) (net.Conn, error) {
+	rp := h.peer(relay)
+	if rp == nil {
+		return nil, errRelayNotConnected
+	}
+	id := rp.allocate()
+	conn := newRelayConn(rp, id, dest.ID())
+	if err := rp.addStream(id, conn); err != nil {
+		conn.shutdown()
+		return nil, err
+	}
+	if err := Send(rp.rw, relayConnectMsg, &relayConnect{id, dest.ID()}); err != nil {
+		rp.closeStream(id, false)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// relayPeer is a peer running the relay protocol.
+type relayPeer struct {
+	id     enode.ID
+	rw     MsgReadWriter
+	parity uint64 // of locally allocated stream IDs
+
+	mu      sync.Mutex
+	nextID  uint64
+	streams map[uint64]relayStream
+}
+
+// relayStream is the local end of a stream, or a stream forwarded to another peer.
+type relayStream interface {
+	// deliver handles data received on the stream. It returns false if the stream
+	// should be closed. It must not block.
+	deliver(data []byte) bool
+	// window extends the send window of the stream by n bytes. It returns false if
+	// the stream should be closed.
+	window(n uint64) bool
+	close()
+}
+
+func (rp *relayPeer) allocate() uint64 {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	id := rp.nextID
+	rp.nextID += 2
+	return id
+}
+
+// isLocalID reports whether the stream ID is allocated by us.
+func (rp *relayPeer) isLocalID(id uint64) bool {
+	return id%2 == rp.parity
+}
+
+func (rp *relayPeer) stream(id uint64) relayStream {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return rp.streams[id]
+}
+
+func (rp *relayPeer) addStream(id uint64, s relayStream) error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if len(rp.streams) >= maxRelayStreams {
+		return errRelayStreamLimit
+	}
+	rp.streams[id] = s
+	return nil
+}
+
+// closeStream removes a stream. If notify is true, the remote end is told to close
+// the stream as well.
+func (rp *relayPeer) closeStream(id uint64, notify bool) {
+	rp.mu.Lock()
+	s := rp.streams[id]
+	delete(rp.streams, id)
+	rp.mu.Unlock()
+	if s == nil {
+		return
+	}
+	if notify {
+		Send(rp.rw, relayCloseMsg, &relayClose{id})
+	}
+	s.close()
+}
+
+func (rp *relayPeer) closeAll() {
+	rp.mu.Lock()
+	streams := rp.streams
+	rp.streams = make(map[uint64]relayStream)
+	rp.mu.Unlock()
+	for _, s := range streams {
+		s.close()
+	}
+}
+
+// relayForward is a stream which a relay forwards to another peer. Received data
+// and window updates are queued, and sent to the peer by a separate goroutine.
+type relayForward struct {
+	peer   *relayPeer // peer the stream is forwarded to
+	stream uint64     // stream ID on that peer
+
+	mu      sync.Mutex
+	queue   [][]byte // data not yet sent to the peer
+	queued  int      // bytes in queue
+	grant   uint64   // window extension not yet sent to the peer
+	closing bool     // set when the stream is closed, after the queue is sent
+
+	wake      chan struct{}
+	quit      chan struct{}
+	closeOnce sync.Once
+}
+
+func newRelayForward(peer *relayPeer, stream uint64) *relayForward {
+	f := &relayForward{
+		peer:   peer,
+		stream: stream,
+		wake:   make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+	}
+	go f.loop()
+	return f
+}
+
+// deliver queues data for the peer. The sender may only have a window of data in
+// flight, so anything beyond it is a protocol violation.
+func (f *relayForward) deliver(data []byte) bool {
+	f.mu.Lock()
+	if f.queued+len(data) > relayStreamWindow {
+		f.mu.Unlock()
+		return false
+	}
+	f.queued += len(data)
+	f.queue = append(f.queue, data)
+	f.mu.Unlock()
+	f.signal()
+	return true
+}
+
+// window queues a window extension for the peer. Pending extensions are merged,
+// they can't exceed the window either.
+func (f *relayForward) window(n uint64) bool {
+	f.mu.Lock()
+	if n > relayStreamWindow-f.grant {
+		f.mu.Unlock()
+		return false
+	}
+	f.grant += n
+	f.mu.Unlock()
+	f.signal()
+	return true
+}
+
+func (f *relayForward) signal() {
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// close closes the stream on the peer once the queued data has been sent.
+func (f *relayForward) close() {
+	f.mu.Lock()
+	f.closing = true
+	f.mu.Unlock()
+	f.signal()
+}
+
+func (f *relayForward) shutdown() {
+	f.closeOnce.Do(func() { close(f.quit) })
+}
+
+// loop sends queued data and window extensions to the peer. If sending fails,
+// the stream is closed on both ends of the relay.
+func (f *relayForward) loop() {
+	for {
+		select {
+		case <-f.wake:
+		case <-f.quit:
+			return
+		}
+		f.mu.Lock()
+		queue, grant, closing := f.queue, f.grant, f.closing
+		f.queue, f.grant = nil, 0
+		f.mu.Unlock()
+
+		var err error
+		if grant > 0 {
+			err = Send(f.peer.rw, relayWindowMsg, &relayWindow{f.stream, grant})
+		}
+		for _, data := range queue {
+			if err != nil {
+				break
+			}
+			err = Send(f.peer.rw, relayDataMsg, &relayData{f.stream, data})
+			f.mu.Lock()
+			f.queued -= len(data)
+			f.mu.Unlock()
+		}
+		if closing || err != nil {
+			f.shutdown()
+			f.peer.closeStream(f.stream, true)
+			return
+		}
+	}
+}
+
+// relayAddr is the remote address of a relayed connection.
+type relayAddr struct {
+	relay, node enode.ID
+}
+
+func (a relayAddr) Network() string { return "relay" }
+func (a relayAddr) String() string {
+	return fmt.Sprintf("%x@relay:%x", a.node[:8], a.relay[:8])
+}
+
+// relayConn is a connection carried by a relay stream. The connection is the end of
+// an in-memory pipe. The other end is served by two goroutines, which move data
+// between the pipe and the relay protocol.
+type relayConn struct {
+	net.Conn
+	inner  net.Conn
+	peer   *relayPeer
+	stream uint64
+	addr   relayAddr
+
+	mu       sync.Mutex
+	queue    [][]byte // received data not yet written to the pipe
+	received int      // bytes received since the window was last extended
+	credit   int      // bytes which may be sent
+
+	queued    chan struct{} // signals that data was queued
+	granted   chan struct{} // signals that credit was granted
+	quit      chan struct{}
+	closeOnce sync.Once
+}
+
+func newRelayConn(rp *relayPeer, stream uint64, remote enode.ID) *relayConn {
+	local, inner := net.Pipe()
+	c := &relayConn{
+		Conn:    local,
+		inner:   inner,
+		peer:    rp,
+		stream:  stream,
+		addr:    relayAddr{relay: rp.id, node: remote},
+		credit:  relayStreamWindow,
+		queued:  make(chan struct{}, 1),
+		granted: make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+	}
+	go c.readLoop()
+	go c.writeLoop()
+	return c
+}
+
+func (c *relayConn) LocalAddr() net.Addr  { return c.addr }
+func (c *relayConn) RemoteAddr() net.Addr { return c.addr }
+
+// deliver queues received data. The remote end may only send as much data as the
+// window allows, so the queue is bounded. Exceeding the window closes the stream.
+func (c *relayConn) deliver(data []byte) bool {
+	c.mu.Lock()
+	if c.received+len(data) > relayStreamWindow {
+		c.mu.Unlock()
+		return false
+	}
+	c.received += len(data)
+	c.queue = append(c.queue, data)
+	c.mu.Unlock()
+
+	select {
+	case c.queued <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// window extends the send window. The remote end can't grant more than the data
+// sent to it, so the window never exceeds relayStreamWindow.
+func (c *relayConn) window(n uint64) bool {
+	c.mu.Lock()
+	if n > uint64(relayStreamWindow-c.credit) {
+		c.mu.Unlock()
+		return false
+	}
+	c.credit += int(n)
+	c.mu.Unlock()
+
+	select {
+	case c.granted <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (c *relayConn) close() {
+	c.shutdown()
+}
+
+func (c *relayConn) shutdown() {
+	c.closeOnce.Do(func() {
+		close(c.quit)
+		c.inner.Close()
+	})
+}
+
+// writeLoop writes received data into the pipe. The window is extended when half
+// of it has been consumed.
+func (c *relayConn) writeLoop() {
+	var consumed int
+	for {
+		select {
+		case <-c.queued:
+		case <-c.quit:
+			return
+		}
+		for {
+			c.mu.Lock()
+			if len(c.queue) == 0 {
+				c.mu.Unlock()
+				break
+			}
+			data := c.queue[0]
+			c.queue = c.queue[1:]
+			c.mu.Unlock()
+
+			if _, err := c.inner.Write(data); err != nil {
+				c.peer.closeStream(c.stream, true)
+				return
+			}
+			if consumed += len(data); consumed >= relayStreamWindow/2 {
+				c.mu.Lock()
+				c.received -= consumed
+				c.mu.Unlock()
+				if err := Send(c.peer.rw, relayWindowMsg, &relayWindow{c.stream, uint64(consumed)}); err != nil {
+					c.peer.closeStream(c.stream, true)
+					return
+				}
+				consumed = 0
+			}
+		}
+	}
+}
+
+// readLoop sends data written to the connection, as far as the window allows.
+func (c *relayConn) readLoop() {
+	buf := make([]byte, maxRelayChunk)
+	for {
+		credit := c.waitCredit()
+		if credit == 0 {
+			break
+		}
+		if credit > len(buf) {
+			credit = len(buf)
+		}
+		n, err := c.inner.Read(buf[:credit])
+		if err != nil {
+			break
+		}
+		c.mu.Lock()
+		c.credit -= n
+		c.mu.Unlock()
+		if err := Send(c.peer.rw, relayDataMsg, &relayData{c.stream, buf[:n]}); err != nil {
+			break
+		}
+	}
+	c.peer.closeStream(c.stream, true)
+	c.shutdown()
+}
+
+// waitCredit waits until data may be sent. It returns the available credit, or
+// zero if the connection was closed.
+func (c *relayConn) waitCredit() int {
+	for {
+		c.mu.Lock()
+		credit := c.credit
+		c.mu.Unlock()
+		if credit > 0 {
+			return credit
+		}
+		select {
+		case <-c.granted:
+		case <-c.quit:
+			return 0
+		}
+	}
+}
+
+// relayDialer dials nodes through their relays if dialing them directly fails.
+type relayDialer struct {
+	hub      *relayHub
+	fallback NodeDialer
+}
+
+func (d relayDialer) Dial(ctx context.Context, dest *enode.Node) (net.Conn, error) {
+	relays := nodeRelays(dest)
+	if len(relays) == 0 {
+		return d.fallback.Dial(ctx, dest)
+	}
+	var err error
+	if dest.TCP() != 0 || nodeQUIC(dest) != 0 {
+		var conn net.Conn
+		if conn, err = d.fallback.Dial(ctx, dest); err == nil {
+			return conn, nil
+		}
+	}
+	conn, herr := d.holePunch(ctx, dest, relays)
+	if herr == nil {
+		return conn, nil
+	}
+	if herr != errNoHolePunch {
+		d.hub.srv.log.Trace("Hole punching failed", "id", dest.ID(), "err", herr)
+	}
+	for _, r := range relays {
+		if r.ID() == d.hub.srv.localnode.ID() {
+			continue
+		}
+		conn, rerr := d.hub.dial(r.ID(), dest)
+		if rerr == nil {
+			d.hub.srv.log.Trace("Dialing through relay", "id", dest.ID(), "relay", r.ID())
+			return conn, nil
+		}
+		if err == nil {
+			err = rerr
+		}
+	}
+	return nil, err
+}
+
+// holePunch tries to reach a node behind NAT via QUIC, with its relays acting as
+// rendezvous nodes.
+func (d relayDialer) holePunch(ctx context.Context, dest *enode.Node, relays []*enode.Node) (net.Conn, error) {
+	srv := d.hub.srv
+	port := nodeQUIC(dest)
+	if srv.DiscV5 == nil || srv.QUIC == nil || port == 0 {
+		return nil, errNoHolePunch
+	}
+	err := errNoHolePunch
+	for _, r := range relays {
+		if r.ID() == srv.localnode.ID() || r.UDP() == 0 {
+			continue
+		}
+		var n *enode.Node
+		if n, err = srv.DiscV5.HolePunch(dest, r); err != nil {
+			continue
+		}
+		var conn net.Conn
+		if conn, err = dialQUIC(ctx, srv.QUIC, &net.UDPAddr{IP: n.IP(), Port: port}); err == nil {
+			srv.log.Trace("Dialing after hole punching", "id", dest.ID(), "addr", n.IP(), "rendezvous", r.ID())
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// relayPingLoop pings the relays of the server via discv5.
+func (srv *Server) relayPingLoop() {
+	defer srv.loopWG.Done()
+
+	ticker := time.NewTicker(relayPingInterval)
+	defer ticker.Stop()
+	for {
+		for _, n := range srv.Relays {
+			if n.UDP() == 0 {
+				continue
+			}
+			if err := srv.DiscV5.Ping(n); err != nil {
+				srv.log.Trace("Relay ping failed", "id", n.ID(), "err", err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-srv.quit:
+			return
+		}
+	}
+}
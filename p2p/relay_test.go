@@ -0,0 +1,346 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/internal/testlog"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/p2p/nat"
+)
+
+func TestServerRelay(t *testing.T) {
+	results := make(chan string, 2)
+	proto := Protocol{
+		Name:    "test",
+		Version: 1,
+		Length:  2,
+		Run: func(p *Peer, rw MsgReadWriter) error {
+			if _, ok := p.RemoteAddr().(relayAddr); !ok {
+				// Only the relayed connection is of interest.
+				_, err := rw.ReadMsg()
+				return err
+			}
+			if err := SendItems(rw, 1, "hello"); err != nil {
+				return err
+			}
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				return err
+			}
+			var greeting []string
+			if err := msg.Decode(&greeting); err != nil || msg.Code != 1 {
+				results <- "invalid message"
+				return nil
+			}
+			results <- greeting[0]
+			_, err = rw.ReadMsg()
+			return err
+		},
+	}
+	start := func(cfg Config) *Server {
+		cfg.PrivateKey = newkey()
+		cfg.MaxPeers = 10
+		cfg.NoDiscovery = true
+		cfg.Protocols = []Protocol{proto}
+		cfg.Logger = testlog.Logger(t, log.LvlTrace)
+		srv := &Server{Config: cfg}
+		if err := srv.Start(); err != nil {
+			t.Fatalf("could not start: %v", err)
+		}
+		return srv
+	}
+	relay := start(Config{ListenAddr: "127.0.0.1:0", RelayService: true})
+	defer relay.Stop()
+	// The node behind NAT doesn't listen.
+	natted := start(Config{Relays: []*enode.Node{relay.Self()}})
+	defer natted.Stop()
+	dialer := start(Config{ListenAddr: "127.0.0.1:0", Relay: true})
+	defer dialer.Stop()
+
+	if relays := nodeRelays(natted.Self()); len(relays) != 1 || relays[0].ID() != relay.Self().ID() {
+		t.Fatalf("wrong relays in record: %v", relays)
+	}
+	dialer.AddPeer(relay.Self())
+	waitForPeers(t, relay, 2)
+
+	dialer.AddPeer(natted.Self())
+	for i := 0; i < 2; i++ {
+		select {
+		case res := <-results:
+			if res != "hello" {
+				t.Fatal(res)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
+
+// This test checks that a node behind NAT is reached via hole punching, with its
+// relay acting as the rendezvous node.
+func TestServerHolePunch(t *testing.T) {
+	var (
+		network = newLoopbackQUIC()
+		results = make(chan string, 2)
+	)
+	proto := Protocol{
+		Name:    "test",
+		Version: 1,
+		Length:  2,
+		Run: func(p *Peer, rw MsgReadWriter) error {
+			switch p.RemoteAddr().(type) {
+			case *net.UDPAddr:
+				results <- "ok"
+			case relayAddr:
+				results <- "relayed"
+			}
+			_, err := rw.ReadMsg()
+			return err
+		},
+	}
+	start := func(cfg Config) *Server {
+		cfg.PrivateKey = newkey()
+		cfg.MaxPeers = 10
+		cfg.NoDiscovery = true
+		cfg.DiscoveryV5 = true
+		cfg.Protocols = []Protocol{proto}
+		cfg.Logger = testlog.Logger(t, log.LvlTrace)
+		srv := &Server{Config: cfg}
+		if err := srv.Start(); err != nil {
+			t.Fatalf("could not start: %v", err)
+		}
+		return srv
+	}
+	relay := start(Config{ListenAddr: "127.0.0.1:0", RelayService: true})
+	defer relay.Stop()
+
+	// The node behind NAT advertises an address which can't be dialed.
+	nattedQUIC := &natQUIC{loopbackQUIC: network}
+	natted := start(Config{
+		NAT:            nat.ExtIP(net.IP{10, 0, 0, 1}),
+		Relays:         []*enode.Node{relay.Self()},
+		QUIC:           nattedQUIC,
+		QUICListenAddr: "127.0.0.1:0",
+	})
+	defer natted.Stop()
+	if err := natted.DiscV5.Ping(relay.Self()); err != nil {
+		t.Fatal("can't ping relay:", err)
+	}
+
+	// The dialer isn't connected to the relay, so it can't relay the connection.
+	dialer := start(Config{
+		ListenAddr:     "127.0.0.1:0",
+		Relay:          true,
+		QUIC:           &natQUIC{loopbackQUIC: network},
+		QUICListenAddr: "127.0.0.1:0",
+	})
+	defer dialer.Stop()
+
+	dialer.AddPeer(natted.Self())
+	for i := 0; i < 2; i++ {
+		select {
+		case res := <-results:
+			if res != "ok" {
+				t.Fatal(res)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+	if nattedQUIC.punched() == 0 {
+		t.Error("NAT of QUIC socket not opened")
+	}
+}
+
+// natQUIC is a loopbackQUIC which refuses sessions to anything but the loopback
+// address, and records hole punches.
+type natQUIC struct {
+	*loopbackQUIC
+	mu      sync.Mutex
+	punches int
+}
+
+func (q *natQUIC) Dial(ctx context.Context, addr *net.UDPAddr) (QUICSession, error) {
+	if !addr.IP.IsLoopback() {
+		return nil, errors.New("connection refused")
+	}
+	return q.loopbackQUIC.Dial(ctx, addr)
+}
+
+func (q *natQUIC) PunchHole(addr *net.UDPAddr) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.punches++
+	return nil
+}
+
+func (q *natQUIC) punched() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.punches
+}
+
+// This test checks that relayed streams are flow controlled, and that a stream
+// exceeding its window is closed instead of blocking the relay.
+func TestRelayConnFlowControl(t *testing.T) {
+	rw, remote := MsgPipe()
+	defer rw.Close()
+	rp := &relayPeer{rw: rw, streams: make(map[uint64]relayStream)}
+	c := newRelayConn(rp, 1, enode.ID{})
+	rp.addStream(1, c)
+	defer c.shutdown()
+
+	// The receive window is buffered without a reader, anything beyond it is refused.
+	chunk := make([]byte, maxRelayChunk)
+	for i := 0; i < relayStreamWindow/maxRelayChunk; i++ {
+		if !c.deliver(chunk) {
+			t.Fatalf("chunk %d refused", i)
+		}
+	}
+	if c.deliver([]byte{1}) {
+		t.Fatal("data beyond window accepted")
+	}
+	// Reading half of the window extends it.
+	if _, err := io.ReadFull(c, make([]byte, relayStreamWindow/2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExpectMsg(remote, relayWindowMsg, &relayWindow{1, relayStreamWindow / 2}); err != nil {
+		t.Fatal(err)
+	}
+	if !c.deliver([]byte{1}) {
+		t.Fatal("data refused after window was extended")
+	}
+
+	// Sending stops when the window is used up.
+	go c.Write(make([]byte, relayStreamWindow+100))
+	for sent := 0; sent < relayStreamWindow; {
+		msg, err := remote.ReadMsg()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data relayData
+		if msg.Code != relayDataMsg || msg.Decode(&data) != nil {
+			t.Fatalf("unexpected message %d", msg.Code)
+		}
+		sent += len(data.Data)
+	}
+	if c.window(relayStreamWindow + 1) {
+		t.Fatal("window extended beyond maximum")
+	}
+	if !c.window(100) {
+		t.Fatal("window not extended")
+	}
+	if err := ExpectMsg(remote, relayDataMsg, &relayData{1, make([]byte, 100)}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// This test checks that a relay queues forwarded data instead of waiting for the
+// target peer, and that the queue is bounded by the stream window.
+func TestRelayForwardQueue(t *testing.T) {
+	rw, remote := MsgPipe()
+	defer rw.Close()
+	target := &relayPeer{rw: rw, streams: make(map[uint64]relayStream)}
+	f := newRelayForward(target, 2)
+	defer f.shutdown()
+
+	// The target doesn't read, a window of data is queued anyway.
+	chunk := make([]byte, maxRelayChunk)
+	for i := 0; i < relayStreamWindow/maxRelayChunk; i++ {
+		if !f.deliver(chunk) {
+			t.Fatalf("chunk %d refused", i)
+		}
+	}
+	if f.deliver([]byte{1}) {
+		t.Fatal("data beyond window accepted")
+	}
+	if f.window(relayStreamWindow + 1) {
+		t.Fatal("window extended beyond maximum")
+	}
+	for i := 0; i < relayStreamWindow/maxRelayChunk; i++ {
+		if err := ExpectMsg(remote, relayDataMsg, &relayData{2, chunk}); err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+	}
+
+	// Window updates are forwarded as well.
+	if !f.window(100) {
+		t.Fatal("window not extended")
+	}
+	if err := ExpectMsg(remote, relayWindowMsg, &relayWindow{2, 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing the stream sends the queued data first.
+	back := newRelayForward(&relayPeer{rw: rw, streams: make(map[uint64]relayStream)}, 3)
+	target.addStream(2, back)
+	f.deliver([]byte{1})
+	f.close()
+	if err := ExpectMsg(remote, relayDataMsg, &relayData{2, []byte{1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExpectMsg(remote, relayCloseMsg, &relayClose{2}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRelayEntry(t *testing.T) {
+	relays := []*enode.Node{
+		enode.NewV4(&newkey().PublicKey, []byte{1, 2, 3, 4}, 30303, 30301),
+		enode.NewV4(&newkey().PublicKey, []byte{5, 6, 7, 8}, 30304, 30304),
+		enode.NewV4(&newkey().PublicKey, []byte{9, 9, 9, 9}, 30305, 30305),
+	}
+	var r enr.Record
+	r.Set(NewRelayEntry(relays))
+	if err := enode.SignV4(&r, newkey()); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := nodeRelays(n)
+	if len(got) != maxRelayEndpoints {
+		t.Fatalf("wrong number of relays %d", len(got))
+	}
+	for i, n := range got {
+		if n.ID() != relays[i].ID() || !n.IP().Equal(relays[i].IP()) || n.TCP() != relays[i].TCP() || n.UDP() != relays[i].UDP() {
+			t.Errorf("relay %d mismatch: got %v, want %v", i, n, relays[i])
+		}
+	}
+}
+
+func waitForPeers(t *testing.T, srv *Server, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if srv.PeerCount() >= n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for %d peers", n)
+}
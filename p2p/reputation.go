@@ -0,0 +1,172 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	maxReputation = 100
+	minReputation = -100
+
+	// reputationHalfLife is the time after which a reputation has decayed halfway
+	// back to neutral, so peers are not judged by their distant past.
+	reputationHalfLife = 6 * time.Hour
+
+	// Peers falling below reputationThreshold are disconnected. Nodes below it
+	// are neither dialed nor accepted until their reputation has decayed.
+	reputationThreshold = -50
+
+	// reputableThreshold is the stored reputation above which nodes are dialed
+	// before any discovered ones.
+	reputableThreshold = 5
+
+	// reputationEvictionMargin is how much better the reputation of a new peer
+	// must be for the worst connected peer to be evicted in its favour when all
+	// slots are taken.
+	reputationEvictionMargin = 10
+
+	// latencyWeight is the most a single latency measurement can change the
+	// reputation by.
+	latencyWeight = 0.5
+)
+
+// ReputationEvent is an observation about a peer which affects its reputation.
+type ReputationEvent int
+
+const (
+	UsefulBlock        ReputationEvent = iota // peer delivered a block we didn't know yet
+	UsefulTransactions                        // peer delivered transactions accepted into the pool
+	InvalidData                               // peer delivered invalid or malformed data
+	RequestTimeout                            // peer didn't deliver requested data in time
+)
+
+var reputationWeights = [...]float64{
+	UsefulBlock:        1,
+	UsefulTransactions: 0.1,
+	InvalidData:        -25,
+	RequestTimeout:     -5,
+}
+
+// reputation is a score of a peer's past behaviour. The score decays towards
+// zero over time.
+type reputation struct {
+	lock    sync.Mutex
+	score   float64
+	updated time.Time
+	dropped bool // whether the peer was disconnected for its reputation
+}
+
+// loadReputation retrieves the stored reputation of a node.
+func loadReputation(db *enode.DB, id enode.ID) *reputation {
+	score, updated := db.Score(id)
+	return &reputation{score: score, updated: updated}
+}
+
+// store persists the reputation into the node database. Neutral reputations
+// are only stored if they overwrite a previous score.
+func (r *reputation) store(db *enode.DB, id enode.ID, now time.Time) error {
+	score := r.value(now)
+	if score == 0 {
+		if prev, _ := db.Score(id); prev == 0 {
+			return nil
+		}
+	}
+	return db.UpdateScore(id, score, now)
+}
+
+// value returns the reputation at the given time.
+func (r *reputation) value(now time.Time) float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.decay(now)
+}
+
+// add changes the reputation by delta, returning the new value.
+func (r *reputation) add(delta float64, now time.Time) float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.score = math.Max(minReputation, math.Min(maxReputation, r.decay(now)+delta))
+	r.updated = now
+	return r.score
+}
+
+// decay applies the decay since the last update. It assumes the lock is held.
+func (r *reputation) decay(now time.Time) float64 {
+	elapsed := now.Sub(r.updated)
+	if elapsed <= 0 || r.score == 0 {
+		return r.score
+	}
+	return r.score * math.Exp2(-float64(elapsed)/float64(reputationHalfLife))
+}
+
+// latencyScore rates a measured round trip time against a reference, e.g. the
+// median of all peers. Peers twice as fast gain latencyWeight, peers twice as
+// slow lose it.
+func latencyScore(rtt, reference time.Duration) float64 {
+	if rtt <= 0 || reference <= 0 {
+		return 0
+	}
+	ratio := math.Log2(float64(reference) / float64(rtt))
+	return latencyWeight * math.Max(-1, math.Min(1, ratio))
+}
+
+// Report records an event affecting the reputation of the peer. Peers whose
+// reputation falls below the threshold are disconnected, unless they are trusted,
+// static or private.
+func (p *Peer) Report(event ReputationEvent) {
+	p.adjustReputation(reputationWeights[event])
+}
+
+// ReportLatency records the latency at which the peer responds to requests,
+// compared to a reference round trip time.
+func (p *Peer) ReportLatency(rtt, reference time.Duration) {
+	p.adjustReputation(latencyScore(rtt, reference))
+}
+
+// Reputation returns the current reputation score of the peer.
+func (p *Peer) Reputation() float64 {
+	return p.reputation.value(time.Now())
+}
+
+func (p *Peer) adjustReputation(delta float64) {
+	if delta == 0 {
+		return
+	}
+	if p.reputation.add(delta, time.Now()) >= reputationThreshold {
+		return
+	}
+	if p.rw.is(trustedConn | staticDialedConn | privateConn) {
+		return
+	}
+	p.reputation.lock.Lock()
+	dropped := p.reputation.dropped
+	p.reputation.dropped = true
+	p.reputation.lock.Unlock()
+
+	if !dropped {
+		p.log.Debug("Dropping peer with bad reputation", "reputation", p.Reputation())
+		go p.Disconnect(DiscUselessPeer)
+	}
+}
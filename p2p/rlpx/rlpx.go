@@ -51,6 +51,7 @@ type Conn struct {
 	dialDest *ecdsa.PublicKey
 	conn     net.Conn
 	session  *sessionState
+	secret   []byte // session secret from which forked connections derive their keys
 
 	// These are the buffers for snappy compression.
 	// Compression is enabled if they are non-nil.
@@ -340,6 +341,44 @@ func (c *Conn) InitWithSecrets(sec Secrets) {
 		egressMAC:  newHashMAC(macc, sec.EgressMAC),
 		ingressMAC: newHashMAC(macc, sec.IngressMAC),
 	}
+	c.secret = crypto.Keccak256(sec.AES, sec.MAC)
+}
+
+// Fork creates a connection on another stream of the same session, e.g. a stream
+// of a multiplexed transport. No handshake is needed on the new stream: its keys
+// are derived from the secrets of c and the label, which must be unique among the
+// streams of the session. Both ends must fork with the same label.
+//
+// Fork must be called after the handshake. The new connection inherits the
+// compression setting of c.
+func (c *Conn) Fork(conn net.Conn, label []byte) *Conn {
+	if c.session == nil {
+		panic("can't fork before handshake")
+	}
+	derive := func(purpose string) []byte {
+		return crypto.Keccak256(c.secret, []byte(purpose), label)
+	}
+	mac := derive("mac")
+	newMAC := func(initiator bool) hash.Hash {
+		h := sha3.NewLegacyKeccak256()
+		h.Write(mac)
+		if initiator {
+			h.Write([]byte("initiator"))
+		} else {
+			h.Write([]byte("recipient"))
+		}
+		return h
+	}
+	initiator := c.dialDest != nil
+	fork := NewConn(conn, c.dialDest)
+	fork.InitWithSecrets(Secrets{
+		AES:        derive("aes"),
+		MAC:        mac,
+		EgressMAC:  newMAC(initiator),
+		IngressMAC: newMAC(!initiator),
+	})
+	fork.SetSnappy(c.snappyReadBuffer != nil)
+	return fork
 }
 
 // Close closes the underlying network connection.
@@ -64,6 +64,33 @@ func TestReadWriteMsg(t *testing.T) {
 	checkMsgReadWrite(t, peer1, peer2, testCode, testData)
 }
 
+func TestFork(t *testing.T) {
+	peer1, peer2 := createPeers(t)
+	defer peer1.Close()
+	defer peer2.Close()
+	peer1.SetSnappy(true)
+	peer2.SetSnappy(true)
+
+	conn1, conn2 := net.Pipe()
+	fork1 := peer1.Fork(conn1, []byte("eth"))
+	fork2 := peer2.Fork(conn2, []byte("eth"))
+	defer fork1.Close()
+	defer fork2.Close()
+	checkMsgReadWrite(t, fork1, fork2, 23, []byte("test"))
+	checkMsgReadWrite(t, fork2, fork1, 24, []byte("test"))
+
+	// Streams forked with different labels can't talk to each other.
+	conn3, conn4 := net.Pipe()
+	fork3 := peer1.Fork(conn3, []byte("eth"))
+	fork4 := peer2.Fork(conn4, []byte("snap"))
+	defer fork3.Close()
+	defer fork4.Close()
+	go fork3.Write(23, []byte("test"))
+	if _, _, _, err := fork4.Read(); err == nil {
+		t.Fatal("read from stream with different label succeeded")
+	}
+}
+
 func checkMsgReadWrite(t *testing.T, p1, p2 *Conn, msgCode uint64, msgData []byte) {
 	// Set up the reader.
 	ch := make(chan message, 1)
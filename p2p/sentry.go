@@ -0,0 +1,200 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	// sentryCheckInterval is how often a validator checks its sentry connections.
+	sentryCheckInterval = 10 * time.Second
+
+	// sentryFailoverTimeout is how long an active sentry may stay disconnected
+	// before a validator replaces it with a standby sentry.
+	sentryFailoverTimeout = 30 * time.Second
+)
+
+// Sentry modes reported by SentryStatus.
+const (
+	SentryModeSentry    = "sentry"
+	SentryModeValidator = "validator"
+)
+
+// SentryInfo is the health status of a sentry or validator node.
+//
+// A sentry is healthy if all of its private peers are connected and it has at
+// least one public peer. A validator is healthy if at least one of its sentries
+// is connected.
+type SentryInfo struct {
+	Mode        string            `json:"mode"`
+	Healthy     bool              `json:"healthy"`
+	PublicPeers int               `json:"publicPeers"`
+	Peers       []*SentryPeerInfo `json:"peers"` // private peers of a sentry, sentries of a validator
+}
+
+// SentryPeerInfo is the status of a private peer or a sentry.
+type SentryPeerInfo struct {
+	Enode     string `json:"enode"`
+	Connected bool   `json:"connected"`
+	Active    bool   `json:"active"` // whether a validator maintains a connection to the sentry
+}
+
+// sentrySet tracks which of a validator's sentries are active. Active sentries
+// are kept connected, the others are on standby and take over from active
+// sentries which remain unreachable for longer than the failover timeout.
+type sentrySet struct {
+	active  []*enode.Node
+	standby []*enode.Node               // in order of preference
+	down    map[enode.ID]mclock.AbsTime // when active sentries were first seen disconnected
+}
+
+func newSentrySet(sentries []*enode.Node, active int) *sentrySet {
+	if active <= 0 || active > len(sentries) {
+		active = len(sentries)
+	}
+	return &sentrySet{
+		active:  append([]*enode.Node{}, sentries[:active]...),
+		standby: append([]*enode.Node{}, sentries[active:]...),
+		down:    make(map[enode.ID]mclock.AbsTime),
+	}
+}
+
+// isActive reports whether id is an active sentry.
+func (s *sentrySet) isActive(id enode.ID) bool {
+	return containsNode(s.active, id)
+}
+
+// update checks the connection status of active sentries and replaces those
+// which have been disconnected for too long. Replaced sentries are moved to the
+// back of the standby queue. It returns the sentries to stop and start dialing.
+func (s *sentrySet) update(connected map[enode.ID]bool, now mclock.AbsTime) (removed, added []*enode.Node) {
+	for i, n := range s.active {
+		if connected[n.ID()] {
+			delete(s.down, n.ID())
+			continue
+		}
+		since, ok := s.down[n.ID()]
+		if !ok {
+			s.down[n.ID()] = now
+			continue
+		}
+		if now.Sub(since) < sentryFailoverTimeout || len(s.standby) == 0 {
+			continue
+		}
+		next := s.standby[0]
+		s.standby = append(s.standby[1:], n)
+		s.active[i] = next
+		delete(s.down, n.ID())
+		removed = append(removed, n)
+		added = append(added, next)
+	}
+	return removed, added
+}
+
+// isSentryMode reports whether the server relays for private peers.
+func (srv *Server) isSentryMode() bool {
+	return len(srv.PrivatePeers) > 0
+}
+
+// isValidatorMode reports whether the server only connects through sentries.
+func (srv *Server) isValidatorMode() bool {
+	return len(srv.Sentries) > 0
+}
+
+// sentryLoop maintains the connections of a validator to its sentries.
+func (srv *Server) sentryLoop() {
+	defer srv.loopWG.Done()
+
+	timer := srv.Clock.NewTimer(sentryCheckInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C():
+			srv.checkSentries()
+			timer.Reset(sentryCheckInterval)
+		case <-srv.quit:
+			return
+		}
+	}
+}
+
+// checkSentries fails over from disconnected active sentries to standby ones.
+func (srv *Server) checkSentries() {
+	connected := make(map[enode.ID]bool)
+	srv.doPeerOp(func(peers map[enode.ID]*Peer) {
+		for id := range peers {
+			connected[id] = true
+		}
+	})
+	srv.sentryLock.Lock()
+	removed, added := srv.sentries.update(connected, srv.Clock.Now())
+	srv.sentryLock.Unlock()
+
+	for i := range removed {
+		srv.log.Warn("Failing over to standby sentry", "from", removed[i].ID(), "to", added[i].ID())
+		srv.dialsched.removeStatic(removed[i])
+		srv.dialsched.addStatic(added[i])
+	}
+}
+
+// SentryStatus returns the health status of the sentry or validator node, or nil
+// if the server runs in neither mode.
+func (srv *Server) SentryStatus() *SentryInfo {
+	var nodes []*enode.Node
+	info := new(SentryInfo)
+	switch {
+	case srv.isValidatorMode():
+		info.Mode, nodes = SentryModeValidator, srv.Sentries
+	case srv.isSentryMode():
+		info.Mode, nodes = SentryModeSentry, srv.PrivatePeers
+	default:
+		return nil
+	}
+	connected := make(map[enode.ID]bool)
+	srv.doPeerOp(func(peers map[enode.ID]*Peer) {
+		for id, p := range peers {
+			connected[id] = true
+			if info.Mode == SentryModeSentry && !p.rw.is(privateConn) {
+				info.PublicPeers++
+			}
+		}
+	})
+	srv.sentryLock.Lock()
+	defer srv.sentryLock.Unlock()
+
+	info.Healthy = info.Mode == SentryModeSentry
+	for _, n := range nodes {
+		peer := &SentryPeerInfo{Enode: n.URLv4(), Connected: connected[n.ID()]}
+		switch info.Mode {
+		case SentryModeValidator:
+			peer.Active = srv.sentries.isActive(n.ID())
+			info.Healthy = info.Healthy || peer.Connected
+		case SentryModeSentry:
+			info.Healthy = info.Healthy && peer.Connected
+		}
+		info.Peers = append(info.Peers, peer)
+	}
+	if info.Mode == SentryModeSentry && info.PublicPeers == 0 {
+		info.Healthy = false
+	}
+	return info
+}
@@ -85,6 +85,13 @@ type Config struct {
 	// Setting DialRatio to zero defaults it to 3.
 	DialRatio int `toml:",omitempty"`
 
+	// MaxEgress limits the outbound bandwidth of protocol messages to all peers,
+	// in bytes per second. MaxPeerEgress limits it for each peer. When a limit is
+	// reached, messages are sent in the order of their protocol's TrafficClass.
+	// Zero means unlimited.
+	MaxEgress     int `toml:",omitempty"`
+	MaxPeerEgress int `toml:",omitempty"`
+
 	// NoDiscovery can be used to disable the peer discovery mechanism.
 	// Disabling is useful for protocol debugging (manual topology).
 	NoDiscovery bool
@@ -114,6 +121,28 @@ type Config struct {
 	// allowed to connect, even above the peer limit.
 	TrustedNodes []*enode.Node
 
+	// PeerGroups are named sets of nodes which are maintained like static nodes
+	// and have peer slots reserved for them.
+	PeerGroups []PeerGroup `toml:",omitempty"`
+
+	// InboundQuota limits the number of inbound peers from the same subnet.
+	InboundQuota SubnetQuota `toml:",omitempty"`
+
+	// PrivatePeers puts the server into sentry mode. Private peers, typically
+	// validators, are always allowed to connect, are relayed to first and are
+	// never revealed through discovery.
+	PrivatePeers []*enode.Node `toml:",omitempty"`
+
+	// Sentries puts the server into validator mode. The server disables
+	// discovery, only connects to its sentries and rejects all other peers
+	// except trusted ones.
+	Sentries []*enode.Node `toml:",omitempty"`
+
+	// ActiveSentries is the number of sentries a validator stays connected to.
+	// The remaining sentries are on standby and replace unreachable ones. Zero
+	// means all sentries are active.
+	ActiveSentries int `toml:",omitempty"`
+
 	// Connectivity can be restricted to certain IP networks.
 	// If this option is set to a non-nil value, only hosts which match one of the
 	// IP networks contained in the list are considered.
@@ -145,6 +174,31 @@ type Config struct {
 	// is used to dial outbound peer connections.
 	Dialer NodeDialer `toml:"-"`
 
+	// QUIC enables the experimental QUIC transport. Nodes advertising a QUIC
+	// port are dialed via QUIC, falling back to TCP if that fails. Package
+	// p2p/quic provides the implementation.
+	QUIC QUICTransport `toml:"-"`
+
+	// If QUICListenAddr is set and QUIC is enabled, the server accepts QUIC
+	// connections on this UDP address. It must differ from the discovery port.
+	QUICListenAddr string `toml:",omitempty"`
+
+	// Relay enables relayed connections. Nodes which can't be dialed directly are
+	// dialed through the relays advertised in their records. With discovery v5 and
+	// QUIC enabled, hole punching is tried before relaying. Hole punching only
+	// works with cone NATs, see relay.go.
+	Relay bool `toml:",omitempty"`
+
+	// RelayService makes the server relay connections between its peers, so that
+	// peers behind NAT can be reached through it. With discovery v5 enabled, the
+	// server is also the rendezvous node for hole punching to those peers.
+	RelayService bool `toml:",omitempty"`
+
+	// Relays are the relays of a server behind NAT. The server stays connected to
+	// them, advertises them in its record and accepts connections they relay. With
+	// discovery v5 enabled, it also pings them periodically to allow hole punching.
+	Relays []*enode.Node `toml:",omitempty"`
+
 	// If NoDial is true, the server will not dial any peers.
 	NoDial bool `toml:",omitempty"`
 
@@ -155,7 +209,9 @@ type Config struct {
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 
-	clock mclock.Clock
+	// Clock is the clock used for dial scheduling and connection throttling.
+	// It defaults to the system clock. Simulations set a simulated clock.
+	Clock mclock.Clock `toml:"-"`
 }
 
 // Server manages all peer connections.
@@ -173,6 +229,7 @@ type Server struct {
 	running bool
 
 	listener     net.Listener
+	quicListener net.Listener
 	ourHandshake *protoHandshake
 	loopWG       sync.WaitGroup // loop, listenLoop
 	peerFeed     event.Feed
@@ -184,6 +241,8 @@ type Server struct {
 	DiscV5    *discover.UDPv5
 	discmix   *enode.FairMix
 	dialsched *dialScheduler
+	relay     *relayHub
+	egress    *egressLimiter // shared by all peers, nil if unlimited
 
 	// Channels into the run loop.
 	quit                    chan struct{}
@@ -195,8 +254,17 @@ type Server struct {
 	checkpointPostHandshake chan *conn
 	checkpointAddPeer       chan *conn
 
+	// Peer groups and subnet quota, which can be changed at runtime.
+	groupsLock sync.RWMutex
+	groups     peerGroups
+
+	// Sentries of a validator, see sentryLoop.
+	sentryLock sync.Mutex
+	sentries   *sentrySet
+
 	// State of run loop and listenLoop.
 	inboundHistory expHeap
+	evicting       map[enode.ID]bool // peers being evicted for better ones, value is whether inbound
 }
 
 type peerOpFunc func(map[enode.ID]*Peer)
@@ -214,6 +282,7 @@ const (
 	staticDialedConn
 	inboundConn
 	trustedConn
+	privateConn
 )
 
 // conn wraps a network connection with information gathered
@@ -226,6 +295,7 @@ type conn struct {
 	cont  chan error // The run loop uses cont to signal errors to SetupConn.
 	caps  []Cap      // valid after the protocol handshake
 	name  string     // valid after the protocol handshake
+	group string     // peer group whose reserved slot is taken, set by the run loop
 }
 
 type transport interface {
@@ -256,6 +326,9 @@ func (f connFlag) String() string {
 	if f&trustedConn != 0 {
 		s += "-trusted"
 	}
+	if f&privateConn != 0 {
+		s += "-private"
+	}
 	if f&dynDialedConn != 0 {
 		s += "-dyndial"
 	}
@@ -400,6 +473,9 @@ func (srv *Server) Stop() {
 		// this unblocks listener Accept
 		srv.listener.Close()
 	}
+	if srv.quicListener != nil {
+		srv.quicListener.Close()
+	}
 	close(srv.quit)
 	srv.lock.Unlock()
 	srv.loopWG.Wait()
@@ -444,8 +520,8 @@ func (srv *Server) Start() (err error) {
 	if srv.log == nil {
 		srv.log = log.Root()
 	}
-	if srv.clock == nil {
-		srv.clock = mclock.System{}
+	if srv.Clock == nil {
+		srv.Clock = mclock.System{}
 	}
 	if srv.NoDial && srv.ListenAddr == "" {
 		srv.log.Warn("P2P server will be useless, neither dialing nor listening")
@@ -456,7 +532,7 @@ func (srv *Server) Start() (err error) {
 		return errors.New("Server.PrivateKey must be set to a non-nil key")
 	}
 	if srv.newTransport == nil {
-		srv.newTransport = newRLPX
+		srv.newTransport = newTransport
 	}
 	if srv.listenFunc == nil {
 		srv.listenFunc = net.Listen
@@ -469,6 +545,22 @@ func (srv *Server) Start() (err error) {
 	srv.removetrusted = make(chan *enode.Node)
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
+	srv.evicting = make(map[enode.ID]bool)
+	if srv.MaxEgress < 0 || srv.MaxPeerEgress < 0 {
+		return errors.New("negative egress limit")
+	}
+	if srv.MaxEgress > 0 {
+		srv.egress = newEgressLimiter(srv.Clock, srv.MaxEgress)
+	}
+	if err := srv.setupPeerGroups(); err != nil {
+		return err
+	}
+	if srv.isValidatorMode() {
+		srv.sentries = newSentrySet(srv.Sentries, srv.ActiveSentries)
+	}
+	if srv.isRelayEnabled() {
+		srv.setupRelay()
+	}
 
 	if err := srv.setupLocalNode(); err != nil {
 		return err
@@ -478,6 +570,11 @@ func (srv *Server) Start() (err error) {
 			return err
 		}
 	}
+	if srv.QUIC != nil && srv.QUICListenAddr != "" {
+		if err := srv.setupQUICListening(); err != nil {
+			return err
+		}
+	}
 	if err := srv.setupDiscovery(); err != nil {
 		return err
 	}
@@ -485,6 +582,14 @@ func (srv *Server) Start() (err error) {
 
 	srv.loopWG.Add(1)
 	go srv.run()
+	if srv.isValidatorMode() {
+		srv.loopWG.Add(1)
+		go srv.sentryLoop()
+	}
+	if srv.DiscV5 != nil && len(srv.Relays) > 0 {
+		srv.loopWG.Add(1)
+		go srv.relayPingLoop()
+	}
 	return nil
 }
 
@@ -511,6 +616,9 @@ func (srv *Server) setupLocalNode() error {
 			srv.localnode.Set(e)
 		}
 	}
+	if len(srv.Relays) > 0 {
+		srv.localnode.Set(NewRelayEntry(srv.Relays))
+	}
 	switch srv.NAT.(type) {
 	case nil:
 		// No NAT interface, do nothing.
@@ -535,6 +643,11 @@ func (srv *Server) setupLocalNode() error {
 func (srv *Server) setupDiscovery() error {
 	srv.discmix = enode.NewFairMix(discmixTimeout)
 
+	// Validators only connect to their sentries.
+	if srv.isValidatorMode() {
+		return nil
+	}
+
 	// Add protocol-specific discovery sources.
 	added := make(map[string]bool)
 	for _, proto := range srv.Protocols {
@@ -579,11 +692,12 @@ func (srv *Server) setupDiscovery() error {
 			sconn = &sharedUDPConn{conn, unhandled}
 		}
 		cfg := discover.Config{
-			PrivateKey:  srv.PrivateKey,
-			NetRestrict: srv.NetRestrict,
-			Bootnodes:   srv.BootstrapNodes,
-			Unhandled:   unhandled,
-			Log:         srv.log,
+			PrivateKey:   srv.PrivateKey,
+			NetRestrict:  srv.NetRestrict,
+			Bootnodes:    srv.BootstrapNodes,
+			PrivateNodes: srv.PrivatePeers,
+			Unhandled:    unhandled,
+			Log:          srv.log,
 		}
 		ntab, err := discover.ListenV4(conn, srv.localnode, cfg)
 		if err != nil {
@@ -596,10 +710,19 @@ func (srv *Server) setupDiscovery() error {
 	// Discovery V5
 	if srv.DiscoveryV5 {
 		cfg := discover.Config{
-			PrivateKey:  srv.PrivateKey,
-			NetRestrict: srv.NetRestrict,
-			Bootnodes:   srv.BootstrapNodesV5,
-			Log:         srv.log,
+			PrivateKey:   srv.PrivateKey,
+			NetRestrict:  srv.NetRestrict,
+			Bootnodes:    srv.BootstrapNodesV5,
+			PrivateNodes: srv.PrivatePeers,
+			Relays:       srv.Relays,
+			Log:          srv.log,
+		}
+		if puncher, ok := srv.QUIC.(QUICHolePuncher); ok {
+			cfg.HolePunchNotify = func(id enode.ID, addr *net.UDPAddr) {
+				if err := puncher.PunchHole(addr); err != nil {
+					srv.log.Trace("Can't open NAT for QUIC", "id", id, "addr", addr, "err", err)
+				}
+			}
 		}
 		var err error
 		if sconn != nil {
@@ -622,7 +745,9 @@ func (srv *Server) setupDialScheduler() {
 		log:            srv.Logger,
 		netRestrict:    srv.NetRestrict,
 		dialer:         srv.Dialer,
-		clock:          srv.clock,
+		clock:          srv.Clock,
+		reputation:     srv.nodeReputation,
+		reputable:      srv.nodedb.QueryScored(srv.maxDialedConns(), reputableThreshold),
 	}
 	if srv.ntab != nil {
 		config.resolver = srv.ntab
@@ -630,10 +755,52 @@ func (srv *Server) setupDialScheduler() {
 	if config.dialer == nil {
 		config.dialer = tcpDialer{&net.Dialer{Timeout: defaultDialTimeout}}
 	}
+	if srv.QUIC != nil {
+		config.dialer = quicDialer{quic: srv.QUIC, fallback: config.dialer, log: srv.log}
+	}
+	if srv.relay != nil {
+		config.dialer = relayDialer{hub: srv.relay, fallback: config.dialer}
+	}
+	if srv.isValidatorMode() {
+		config.reputable = nil
+	}
 	srv.dialsched = newDialScheduler(config, srv.discmix, srv.SetupConn)
+	if srv.sentries != nil {
+		for _, n := range srv.sentries.active {
+			srv.dialsched.addStatic(n)
+		}
+	}
 	for _, n := range srv.StaticNodes {
 		srv.dialsched.addStatic(n)
 	}
+	for _, n := range srv.Relays {
+		srv.dialsched.addStatic(n)
+	}
+	for _, g := range srv.groups.groups {
+		for _, n := range g.Nodes {
+			srv.dialsched.addStatic(n)
+		}
+	}
+}
+
+// setupPeerGroups validates the configured peer groups.
+func (srv *Server) setupPeerGroups() error {
+	srv.groups = peerGroups{groups: make(map[string]*PeerGroup), quota: srv.InboundQuota}
+	for _, g := range srv.PeerGroups {
+		switch {
+		case g.Name == "":
+			return errGroupName
+		case g.Slots < 0:
+			return errGroupSlots
+		case srv.groups.groups[g.Name] != nil:
+			return fmt.Errorf("duplicate peer group %q", g.Name)
+		}
+		srv.groups.groups[g.Name] = &PeerGroup{Name: g.Name, Slots: g.Slots, Nodes: append([]*enode.Node{}, g.Nodes...)}
+	}
+	if reserved := srv.groups.reserved(); reserved > srv.MaxPeers {
+		return fmt.Errorf("%w: %d > %d", errTooManySlots, reserved, srv.MaxPeers)
+	}
+	return nil
 }
 
 func (srv *Server) maxInboundConns() int {
@@ -676,8 +843,9 @@ func (srv *Server) setupListening() error {
 		}
 	}
 
+	srv.log.Debug("TCP listener up", "addr", listener.Addr())
 	srv.loopWG.Add(1)
-	go srv.listenLoop()
+	go srv.listenLoop(srv.listener)
 	return nil
 }
 
@@ -702,12 +870,16 @@ func (srv *Server) run() {
 		peers        = make(map[enode.ID]*Peer)
 		inboundCount = 0
 		trusted      = make(map[enode.ID]bool, len(srv.TrustedNodes))
+		private      = make(map[enode.ID]bool, len(srv.PrivatePeers))
 	)
 	// Put trusted nodes into a map to speed up checks.
 	// Trusted peers are loaded on startup or added via AddTrustedPeer RPC.
 	for _, n := range srv.TrustedNodes {
 		trusted[n.ID()] = true
 	}
+	for _, n := range srv.PrivatePeers {
+		private[n.ID()] = true
+	}
 
 running:
 	for {
@@ -746,6 +918,9 @@ running:
 				// Ensure that the trusted flag is set before checking against MaxPeers.
 				c.flags |= trustedConn
 			}
+			if private[c.node.ID()] {
+				c.flags |= privateConn
+			}
 			// TODO: track in-progress inbound node IDs (pre-Peer) to avoid dialing them.
 			c.cont <- srv.postHandshakeChecks(peers, inboundCount, c)
 
@@ -769,6 +944,8 @@ running:
 			// A peer disconnected.
 			d := common.PrettyDuration(mclock.Now() - pd.created)
 			delete(peers, pd.ID())
+			delete(srv.evicting, pd.ID())
+			srv.storeReputation(pd.Peer)
 			srv.log.Debug("Removing p2p peer", "peercount", len(peers), "id", pd.ID(), "duration", d, "req", pd.requested, "err", pd.err)
 			srv.dialsched.peerRemoved(pd.rw)
 			if pd.Inbound() {
@@ -797,24 +974,96 @@ running:
 		p := <-srv.delpeer
 		p.log.Trace("<-delpeer (spindown)")
 		delete(peers, p.ID())
+		srv.storeReputation(p.Peer)
 	}
 }
 
 func (srv *Server) postHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
 	switch {
-	case !c.is(trustedConn) && len(peers) >= srv.MaxPeers:
-		return DiscTooManyPeers
-	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns():
-		return DiscTooManyPeers
 	case peers[c.node.ID()] != nil:
 		return DiscAlreadyConnected
 	case c.node.ID() == srv.localnode.ID():
 		return DiscSelf
+	case c.is(trustedConn | privateConn):
+		return nil
+	case srv.isValidatorMode() && !containsNode(srv.Sentries, c.node.ID()):
+		return DiscUselessPeer
+	case srv.isValidatorMode():
+		return nil
+	case !c.is(staticDialedConn) && srv.nodeReputation(c.node.ID()) < reputationThreshold:
+		return DiscUselessPeer
+	case srv.reserveSlot(peers, c):
+		return nil
+	case c.is(inboundConn) && !srv.checkSubnetQuota(peers, c):
+		return DiscTooManyPeers
+	case srv.regularPeersFull(peers) && !srv.evictPeer(peers, c, false):
+		return DiscTooManyPeers
+	case c.is(inboundConn) && inboundCount-srv.evictingInbound() >= srv.maxInboundConns() && !srv.evictPeer(peers, c, true):
+		return DiscTooManyPeers
 	default:
 		return nil
 	}
 }
 
+// regularPeersFull reports whether all peer slots which aren't reserved for
+// peer groups are taken.
+func (srv *Server) regularPeersFull(peers map[enode.ID]*Peer) bool {
+	count, limit := srv.regularPeers(peers)
+	return count-len(srv.evicting) >= limit
+}
+
+// evictPeer disconnects the connected peer with the worst reputation to make
+// room for c, if the reputation of c is sufficiently better. Trusted, static and
+// private peers and peers in reserved slots are never evicted. If inbound is set, only
+// inbound peers are considered.
+func (srv *Server) evictPeer(peers map[enode.ID]*Peer, c *conn, inbound bool) bool {
+	var (
+		worst      *Peer
+		worstScore float64
+	)
+	for id, p := range peers {
+		if _, ok := srv.evicting[id]; ok || p.rw.is(trustedConn|staticDialedConn|privateConn) || p.rw.group != "" {
+			continue
+		}
+		if inbound && !p.Inbound() {
+			continue
+		}
+		if score := p.Reputation(); worst == nil || score < worstScore {
+			worst, worstScore = p, score
+		}
+	}
+	if worst == nil || worstScore+reputationEvictionMargin > srv.nodeReputation(c.node.ID()) {
+		return false
+	}
+	srv.log.Debug("Evicting peer with worse reputation", "id", worst.ID(), "reputation", worstScore, "for", c.node.ID())
+	srv.evicting[worst.ID()] = worst.Inbound()
+	worst.Disconnect(DiscTooManyPeers)
+	return true
+}
+
+// evictingInbound returns the number of inbound peers being evicted.
+func (srv *Server) evictingInbound() int {
+	n := 0
+	for _, inbound := range srv.evicting {
+		if inbound {
+			n++
+		}
+	}
+	return n
+}
+
+// nodeReputation returns the stored reputation of a node.
+func (srv *Server) nodeReputation(id enode.ID) float64 {
+	return loadReputation(srv.nodedb, id).value(time.Now())
+}
+
+// storeReputation persists the reputation of a disconnected peer.
+func (srv *Server) storeReputation(p *Peer) {
+	if err := p.reputation.store(srv.nodedb, p.ID(), time.Now()); err != nil {
+		srv.log.Debug("Failed to store peer reputation", "id", p.ID(), "err", err)
+	}
+}
+
 func (srv *Server) addPeerChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
 	// Drop connections with no matching protocols.
 	if len(srv.Protocols) > 0 && countMatchingProtocols(srv.Protocols, c.caps) == 0 {
@@ -827,9 +1076,7 @@ func (srv *Server) addPeerChecks(peers map[enode.ID]*Peer, inboundCount int, c *
 
 // listenLoop runs in its own goroutine and accepts
 // inbound connections.
-func (srv *Server) listenLoop() {
-	srv.log.Debug("TCP listener up", "addr", srv.listener.Addr())
-
+func (srv *Server) listenLoop(listener net.Listener) {
 	// The slots channel limits accepts of new connections.
 	tokens := defaultMaxPendingPeers
 	if srv.MaxPendingPeers > 0 {
@@ -859,7 +1106,7 @@ func (srv *Server) listenLoop() {
 			lastLog time.Time
 		)
 		for {
-			fd, err = srv.listener.Accept()
+			fd, err = listener.Accept()
 			if netutil.IsTemporaryError(err) {
 				if time.Since(lastLog) > 1*time.Second {
 					srv.log.Debug("Temporary read error", "err", err)
@@ -906,7 +1153,7 @@ func (srv *Server) checkInboundConn(remoteIP net.IP) error {
 		return fmt.Errorf("not in netrestrict list")
 	}
 	// Reject Internet peers that try too often.
-	now := srv.clock.Now()
+	now := srv.Clock.Now()
 	srv.inboundHistory.expire(now, nil)
 	if !netutil.IsLAN(remoteIP) && srv.inboundHistory.contains(remoteIP.String()) {
 		return fmt.Errorf("too many attempts")
@@ -992,10 +1239,9 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 }
 
 func nodeFromConn(pubkey *ecdsa.PublicKey, conn net.Conn) *enode.Node {
-	var ip net.IP
+	ip := netutil.AddrIP(conn.RemoteAddr())
 	var port int
 	if tcp, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
-		ip = tcp.IP
 		port = tcp.Port
 	}
 	return enode.NewV4(pubkey, ip, port, port)
@@ -1014,6 +1260,15 @@ func (srv *Server) checkpoint(c *conn, stage chan<- *conn) error {
 
 func (srv *Server) launchPeer(c *conn) *Peer {
 	p := newPeer(srv.log, c, srv.Protocols)
+	p.reputation = loadReputation(srv.nodedb, c.node.ID())
+	// The peer's own limit is waited on first, so a peer over its limit doesn't
+	// hold up the shared one.
+	if srv.MaxPeerEgress > 0 {
+		p.egress = append(p.egress, newEgressLimiter(srv.Clock, srv.MaxPeerEgress))
+	}
+	if srv.egress != nil {
+		p.egress = append(p.egress, srv.egress)
+	}
 	if srv.EnableMsgEvents {
 		// If message events are enabled, pass the peerFeed
 		// to the peer.
@@ -27,6 +27,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/internal/testlog"
 	"github.com/ethereum/go-ethereum/log"
@@ -300,6 +301,283 @@ func TestServerAtCap(t *testing.T) {
 	}
 }
 
+func TestServerReputation(t *testing.T) {
+	remote := newkey()
+	srv := &Server{
+		Config: Config{
+			PrivateKey:  newkey(),
+			MaxPeers:    3,
+			NoDial:      true,
+			NoDiscovery: true,
+			Logger:      testlog.Logger(t, log.LvlTrace),
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	newconn := func(id enode.ID) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&remote.PublicKey, fd, nil)
+		node := enode.SignNull(new(enr.Record), id)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+	// Nodes with a bad stored reputation are rejected even with free slots.
+	badID := randomID()
+	srv.nodedb.UpdateScore(badID, reputationThreshold-10, time.Now())
+	if err := srv.checkpoint(newconn(badID), srv.checkpointPostHandshake); err != DiscUselessPeer {
+		t.Fatal("wrong error for node with bad reputation:", err)
+	}
+	// Static peers are dialed regardless of their reputation, so they are
+	// accepted too.
+	static := newconn(badID)
+	static.flags = staticDialedConn
+	if err := srv.checkpoint(static, srv.checkpointPostHandshake); err != nil {
+		t.Fatal("static peer with bad reputation rejected:", err)
+	}
+	// Fill up the peer set and give one peer a poor reputation.
+	for i := 0; i < 3; i++ {
+		if err := srv.checkpoint(newconn(randomID()), srv.checkpointAddPeer); err != nil {
+			t.Fatalf("could not add conn %d: %v", i, err)
+		}
+	}
+	worst := srv.Peers()[0]
+	worst.reputation.add(-5, time.Now())
+
+	// Neutral nodes can't evict the peer, but nodes with a good reputation can.
+	if err := srv.checkpoint(newconn(randomID()), srv.checkpointPostHandshake); err != DiscTooManyPeers {
+		t.Fatal("wrong error for neutral node:", err)
+	}
+	goodID := randomID()
+	srv.nodedb.UpdateScore(goodID, 20, time.Now())
+	if err := srv.checkpoint(newconn(goodID), srv.checkpointPostHandshake); err != nil {
+		t.Fatal("unexpected error for node with good reputation:", err)
+	}
+	// The evicted peer's reputation is persisted once it is gone.
+	for start := time.Now(); srv.PeerCount() == 3; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 2*time.Second {
+			t.Fatal("worst peer was not evicted")
+		}
+	}
+	for _, p := range srv.Peers() {
+		if p.ID() == worst.ID() {
+			t.Fatal("wrong peer evicted")
+		}
+	}
+	if score, _ := srv.nodedb.Score(worst.ID()); score > -4.9 || score < -5 {
+		t.Errorf("wrong stored reputation %f, want -5", score)
+	}
+}
+
+func TestServerPeerGroups(t *testing.T) {
+	remote := newkey()
+	sentries := []*enode.Node{newNode(randomID(), ""), newNode(randomID(), ""), newNode(randomID(), "")}
+	srv := &Server{
+		Config: Config{
+			PrivateKey:  newkey(),
+			MaxPeers:    4,
+			NoDial:      true,
+			NoDiscovery: true,
+			PeerGroups:  []PeerGroup{{Name: "sentries", Slots: 2, Nodes: sentries}},
+			Logger:      testlog.Logger(t, log.LvlTrace),
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	newconn := func(id enode.ID) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&remote.PublicKey, fd, nil)
+		node := enode.SignNull(new(enr.Record), id)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+	// Only two slots are left for peers outside of the group.
+	for i := 0; i < 2; i++ {
+		if err := srv.checkpoint(newconn(randomID()), srv.checkpointAddPeer); err != nil {
+			t.Fatalf("could not add conn %d: %v", i, err)
+		}
+	}
+	if err := srv.checkpoint(newconn(randomID()), srv.checkpointPostHandshake); err != DiscTooManyPeers {
+		t.Fatal("wrong error for regular peer:", err)
+	}
+	// Group members take the reserved slots, but no more.
+	for i := 0; i < 2; i++ {
+		if err := srv.checkpoint(newconn(sentries[i].ID()), srv.checkpointAddPeer); err != nil {
+			t.Fatalf("could not add group member %d: %v", i, err)
+		}
+	}
+	if err := srv.checkpoint(newconn(sentries[2].ID()), srv.checkpointPostHandshake); err != DiscTooManyPeers {
+		t.Fatal("wrong error for group member beyond reserved slots:", err)
+	}
+	groups := srv.PeerGroupInfos()
+	if len(groups) != 1 || groups[0].Name != "sentries" || groups[0].Connected != 2 {
+		t.Fatalf("wrong peer groups: %+v", groups)
+	}
+	// Groups can't reserve more slots than there are.
+	if err := srv.AddPeerGroup(PeerGroup{Name: "partners", Slots: 3}); !errors.Is(err, errTooManySlots) {
+		t.Fatal("wrong error for too many reserved slots:", err)
+	}
+	if err := srv.RemovePeerGroup("sentries"); err != nil {
+		t.Fatal("could not remove group:", err)
+	}
+	if err := srv.RemovePeerGroup("sentries"); err != errGroupNotFound {
+		t.Fatal("wrong error for unknown group:", err)
+	}
+}
+
+func TestServerSentryModes(t *testing.T) {
+	remote := newkey()
+	newconn := func(id enode.ID) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&remote.PublicKey, fd, nil)
+		node := enode.SignNull(new(enr.Record), id)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+	start := func(cfg Config) *Server {
+		cfg.PrivateKey = newkey()
+		cfg.MaxPeers = 1
+		cfg.NoDial = true
+		cfg.NoDiscovery = true
+		cfg.Logger = testlog.Logger(t, log.LvlTrace)
+		srv := &Server{Config: cfg}
+		if err := srv.Start(); err != nil {
+			t.Fatalf("could not start: %v", err)
+		}
+		return srv
+	}
+
+	// A sentry admits its private peers even if it's full.
+	private := newNode(randomID(), "")
+	sentry := start(Config{PrivatePeers: []*enode.Node{private}})
+	defer sentry.Stop()
+	if err := sentry.checkpoint(newconn(randomID()), sentry.checkpointAddPeer); err != nil {
+		t.Fatal("could not add public peer:", err)
+	}
+	c := newconn(private.ID())
+	if err := sentry.checkpoint(c, sentry.checkpointPostHandshake); err != nil {
+		t.Fatal("could not accept private peer:", err)
+	}
+	if err := sentry.checkpoint(c, sentry.checkpointAddPeer); err != nil {
+		t.Fatal("could not add private peer:", err)
+	}
+	if !c.is(privateConn) {
+		t.Fatal("private peer not flagged")
+	}
+	status := sentry.SentryStatus()
+	if status.Mode != SentryModeSentry || !status.Healthy || status.PublicPeers != 1 || !status.Peers[0].Connected {
+		t.Fatalf("wrong sentry status: %+v", status)
+	}
+
+	// A validator only admits its sentries.
+	sentries := []*enode.Node{newNode(randomID(), ""), newNode(randomID(), "")}
+	validator := start(Config{Sentries: sentries, ActiveSentries: 1})
+	defer validator.Stop()
+	if status := validator.SentryStatus(); status.Healthy {
+		t.Fatalf("validator without sentries reported healthy: %+v", status)
+	}
+	if err := validator.checkpoint(newconn(randomID()), validator.checkpointPostHandshake); err != DiscUselessPeer {
+		t.Fatal("wrong error for non-sentry peer:", err)
+	}
+	for i, n := range sentries {
+		if err := validator.checkpoint(newconn(n.ID()), validator.checkpointAddPeer); err != nil {
+			t.Fatalf("could not add sentry %d: %v", i, err)
+		}
+	}
+	status = validator.SentryStatus()
+	if status.Mode != SentryModeValidator || !status.Healthy || !status.Peers[0].Active || status.Peers[1].Active {
+		t.Fatalf("wrong validator status: %+v", status)
+	}
+}
+
+func TestSentryFailover(t *testing.T) {
+	var (
+		clock    mclock.Simulated
+		sentries = []*enode.Node{newNode(uintID(1), ""), newNode(uintID(2), ""), newNode(uintID(3), "")}
+		set      = newSentrySet(sentries, 2)
+	)
+	check := func(connected []int, wantRemoved, wantAdded []*enode.Node) {
+		t.Helper()
+		conns := make(map[enode.ID]bool)
+		for _, i := range connected {
+			conns[sentries[i].ID()] = true
+		}
+		removed, added := set.update(conns, clock.Now())
+		if !reflect.DeepEqual(removed, wantRemoved) || !reflect.DeepEqual(added, wantAdded) {
+			t.Fatalf("wrong failover: removed %v, added %v", removed, added)
+		}
+	}
+	check([]int{0, 1}, nil, nil)
+	// The second sentry goes away, it is replaced after the timeout.
+	check([]int{0}, nil, nil)
+	clock.Run(sentryFailoverTimeout - 1)
+	check([]int{0}, nil, nil)
+	clock.Run(1)
+	check([]int{0}, []*enode.Node{sentries[1]}, []*enode.Node{sentries[2]})
+	if !set.isActive(sentries[2].ID()) || set.isActive(sentries[1].ID()) {
+		t.Fatal("wrong active sentries after failover")
+	}
+	// Sentries which reconnect in time are kept.
+	check([]int{0}, nil, nil)
+	clock.Run(sentryFailoverTimeout / 2)
+	check([]int{0, 2}, nil, nil)
+	clock.Run(sentryFailoverTimeout)
+	check([]int{0, 2}, nil, nil)
+}
+
+func TestSubnetQuota(t *testing.T) {
+	quota := SubnetQuota{IPv4Subnet24: 2, IPv4Subnet16: 3, IPv6Prefix48: 1}
+	inbound := []net.IP{
+		net.ParseIP("1.2.3.4"),
+		net.ParseIP("1.2.3.5"),
+		net.ParseIP("1.2.4.1"),
+		net.ParseIP("2001:db8:1::1"),
+	}
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"1.2.3.6", false},         // /24 is full
+		{"1.2.5.1", false},         // /16 is full
+		{"1.3.3.1", true},          // different /16
+		{"192.168.1.1", true},      // LAN is exempt
+		{"2001:db8:1:2::1", false}, // /48 is full
+		{"2001:db8:2::1", true},    // different /48
+	}
+	for _, test := range tests {
+		if got := quota.allows(net.ParseIP(test.ip), inbound); got != test.want {
+			t.Errorf("%s: got %t, want %t", test.ip, got, test.want)
+		}
+	}
+	if !(SubnetQuota{}).allows(net.ParseIP("1.2.3.6"), inbound) {
+		t.Error("zero quota should not limit peers")
+	}
+}
+
+func TestReputationDecay(t *testing.T) {
+	var (
+		now = time.Now()
+		r   = &reputation{updated: now}
+	)
+	if v := r.add(-40, now); v != -40 {
+		t.Fatalf("wrong reputation %f after penalty", v)
+	}
+	if v := r.value(now.Add(reputationHalfLife)); v != -20 {
+		t.Errorf("wrong reputation %f after one half-life, want -20", v)
+	}
+	if v := r.add(2*maxReputation, now); v != maxReputation {
+		t.Errorf("reputation %f not capped at %d", v, maxReputation)
+	}
+	if s := latencyScore(time.Second, 4*time.Second); s != latencyWeight {
+		t.Errorf("wrong score %f for fast peer", s)
+	}
+	if s := latencyScore(2*time.Second, time.Second); s != -latencyWeight {
+		t.Errorf("wrong score %f for slow peer", s)
+	}
+}
+
 func TestServerPeerLimits(t *testing.T) {
 	srvkey := newkey()
 	clientkey := newkey()
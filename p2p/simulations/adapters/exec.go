@@ -35,6 +35,7 @@ import (
 	"time"
 
 	"github.com/docker/docker/pkg/reexec"
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
@@ -487,6 +488,7 @@ func startExecNodeStack() (*node.Node, error) {
 		ctx := &ServiceContext{
 			RPCDialer: &wsRPCDialer{addrs: conf.PeerAddrs},
 			Config:    conf.Node,
+			Clock:     mclock.System{},
 		}
 		if conf.Snapshots != nil {
 			ctx.Snapshot = conf.Snapshots[name]
@@ -23,7 +23,9 @@ import (
 	"math"
 	"net"
 	"sync"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
@@ -38,6 +40,7 @@ import (
 // connects them using net.Pipe
 type SimAdapter struct {
 	pipe       func() (net.Conn, net.Conn, error)
+	links      *Links
 	mtx        sync.RWMutex
 	nodes      map[enode.ID]*SimNode
 	lifecycles LifecycleConstructors
@@ -50,16 +53,33 @@ type SimAdapter struct {
 func NewSimAdapter(services LifecycleConstructors) *SimAdapter {
 	return &SimAdapter{
 		pipe:       pipes.NetPipe,
+		links:      NewLinks(mclock.System{}, time.Now().UnixNano()),
 		nodes:      make(map[enode.ID]*SimNode),
 		lifecycles: services,
 	}
 }
 
+// NewDeterministicSimAdapter creates a SimAdapter which measures link delays on the
+// given simulated clock and draws packet loss from a random source derived from
+// seed. The clock is also used by the nodes' p2p servers and passed to services in
+// ServiceContext. Nothing that is delayed by a link is delivered until the clock is
+// advanced with clock.Run.
+func NewDeterministicSimAdapter(services LifecycleConstructors, clock *mclock.Simulated, seed int64) *SimAdapter {
+	s := NewSimAdapter(services)
+	s.links = NewLinks(clock, seed)
+	return s
+}
+
 // Name returns the name of the adapter for logging purposes
 func (s *SimAdapter) Name() string {
 	return "sim-adapter"
 }
 
+// Links returns the network conditions between the adapter's nodes.
+func (s *SimAdapter) Links() *Links {
+	return s.links
+}
+
 // NewNode returns a new SimNode using the given config
 func (s *SimAdapter) NewNode(config *NodeConfig) (Node, error) {
 	s.mtx.Lock()
@@ -86,6 +106,19 @@ func (s *SimAdapter) NewNode(config *NodeConfig) (Node, error) {
 		}
 	}
 
+	// resolve the relays of the node, which must already exist
+	var relays []*enode.Node
+	for _, relayID := range config.Relays {
+		relay, ok := s.nodes[relayID]
+		if !ok {
+			return nil, fmt.Errorf("unknown relay: %s", relayID)
+		}
+		relays = append(relays, relay.Node())
+	}
+	if len(relays) > 0 {
+		config.Record.Set(p2p.NewRelayEntry(relays))
+	}
+
 	err := config.initDummyEnode()
 	if err != nil {
 		return nil, err
@@ -96,8 +129,12 @@ func (s *SimAdapter) NewNode(config *NodeConfig) (Node, error) {
 			PrivateKey:      config.PrivateKey,
 			MaxPeers:        math.MaxInt32,
 			NoDiscovery:     true,
-			Dialer:          s,
+			Dialer:          &simDialer{s, id},
 			EnableMsgEvents: config.EnableMsgEvents,
+			Relay:           config.Relay,
+			RelayService:    config.RelayService,
+			Relays:          relays,
+			Clock:           s.links.Clock(),
 		},
 		ExternalSigner: config.ExternalSigner,
 		Logger:         log.New("node.id", id.String()),
@@ -117,13 +154,34 @@ func (s *SimAdapter) NewNode(config *NodeConfig) (Node, error) {
 	return simNode, nil
 }
 
+// errNATRefused is returned when dialing a node behind a simulated NAT.
+var errNATRefused = errors.New("connection refused by NAT")
+
 // Dial implements the p2p.NodeDialer interface by connecting to the node using
 // an in-memory net.Pipe
 func (s *SimAdapter) Dial(ctx context.Context, dest *enode.Node) (conn net.Conn, err error) {
+	return s.dial(ctx, enode.ID{}, dest)
+}
+
+// simDialer is the dialer of a node. Connections it creates are subject to the
+// conditions of the link between the node and the destination.
+type simDialer struct {
+	adapter *SimAdapter
+	self    enode.ID
+}
+
+func (d *simDialer) Dial(ctx context.Context, dest *enode.Node) (net.Conn, error) {
+	return d.adapter.dial(ctx, d.self, dest)
+}
+
+func (s *SimAdapter) dial(ctx context.Context, src enode.ID, dest *enode.Node) (conn net.Conn, err error) {
 	node, ok := s.GetNode(dest.ID())
 	if !ok {
 		return nil, fmt.Errorf("unknown node: %s", dest.ID())
 	}
+	if node.config.NAT {
+		return nil, errNATRefused
+	}
 	srv := node.Server()
 	if srv == nil {
 		return nil, fmt.Errorf("node not running: %s", dest.ID())
@@ -133,6 +191,11 @@ func (s *SimAdapter) Dial(ctx context.Context, dest *enode.Node) (conn net.Conn,
 	if err != nil {
 		return nil, err
 	}
+	if src != (enode.ID{}) {
+		if pipe2, pipe1, err = s.links.wrap(src, dest.ID(), pipe2, pipe1); err != nil {
+			return nil, err
+		}
+	}
 	// this is simulated 'listening'
 	// asynchronously call the dialed destination node's p2p server
 	// to set up connection on the 'listening' side
@@ -248,6 +311,7 @@ func (sn *SimNode) Start(snapshots map[string][]byte) error {
 			ctx := &ServiceContext{
 				RPCDialer: sn.adapter,
 				Config:    sn.config,
+				Clock:     sn.adapter.links.Clock(),
 			}
 			if snapshots != nil {
 				ctx.Snapshot = snapshots[name]
@@ -0,0 +1,395 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package adapters
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	linkQueueSize  = 64                     // writes in flight per direction
+	minRetransmit  = 200 * time.Millisecond // minimum delay of a lost write
+	maxRetransmits = 15                     // retransmissions of a lost write, like TCP
+)
+
+var (
+	errPartitioned = errors.New("nodes are partitioned")
+	errLinkClosed  = errors.New("link closed")
+)
+
+// LinkConfig describes the conditions of a simulated network link.
+type LinkConfig struct {
+	// Latency is the one-way delay of the link.
+	Latency time.Duration `json:"latency,omitempty"`
+
+	// Bandwidth is the capacity of the link in bytes per second in each
+	// direction. Zero means unlimited.
+	Bandwidth int64 `json:"bandwidth,omitempty"`
+
+	// Loss is the probability that a write is lost. Since devp2p runs over
+	// reliable connections, lost writes are retransmitted after a timeout,
+	// delaying all data written after them. It must be less than one.
+	Loss float64 `json:"loss,omitempty"`
+}
+
+// Validate checks that the configuration describes a usable link.
+func (cfg LinkConfig) Validate() error {
+	if cfg.Loss < 0 || cfg.Loss >= 1 {
+		return fmt.Errorf("invalid loss %v, must be in [0, 1)", cfg.Loss)
+	}
+	if cfg.Latency < 0 || cfg.Bandwidth < 0 {
+		return errors.New("negative latency or bandwidth")
+	}
+	return nil
+}
+
+// retransmitDelay is the delay caused by losing a write.
+func (cfg LinkConfig) retransmitDelay() time.Duration {
+	if rto := 2 * cfg.Latency; rto > minRetransmit {
+		return rto
+	}
+	return minRetransmit
+}
+
+// Links holds the network conditions between simulation nodes. All links use the
+// default configuration unless configured individually. Nodes can also be
+// partitioned into groups which can't reach each other.
+//
+// Delays are measured on the clock given to NewLinks and packet loss is drawn from
+// a random source derived from the seed, so simulations using mclock.Simulated are
+// reproducible.
+type Links struct {
+	clock mclock.Clock
+	seed  int64
+
+	mu       sync.Mutex
+	defaults LinkConfig
+	links    map[[2]enode.ID]LinkConfig
+	groups   map[enode.ID]int
+	conns    map[*linkConn]struct{}
+	count    map[[2]enode.ID]int64 // connections created per direction
+}
+
+// NewLinks creates a set of ideal links.
+func NewLinks(clock mclock.Clock, seed int64) *Links {
+	return &Links{
+		clock:  clock,
+		seed:   seed,
+		links:  make(map[[2]enode.ID]LinkConfig),
+		groups: make(map[enode.ID]int),
+		conns:  make(map[*linkConn]struct{}),
+		count:  make(map[[2]enode.ID]int64),
+	}
+}
+
+// Clock returns the clock on which link delays are measured.
+func (l *Links) Clock() mclock.Clock {
+	return l.clock
+}
+
+// linkKey returns the key of the link between two nodes, which is the same for
+// both directions.
+func linkKey(one, other enode.ID) [2]enode.ID {
+	if bytes.Compare(one[:], other[:]) > 0 {
+		one, other = other, one
+	}
+	return [2]enode.ID{one, other}
+}
+
+// SetDefault sets the configuration of links which aren't configured individually.
+func (l *Links) SetDefault(cfg LinkConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.defaults = cfg
+	return nil
+}
+
+// Default returns the configuration of links which aren't configured individually.
+func (l *Links) Default() LinkConfig {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.defaults
+}
+
+// Set configures the link between two nodes. The configuration applies to both
+// directions and takes effect for data written after the call.
+func (l *Links) Set(one, other enode.ID, cfg LinkConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.links[linkKey(one, other)] = cfg
+	return nil
+}
+
+// Reset removes the configuration of the link between two nodes, reverting it to
+// the default.
+func (l *Links) Reset(one, other enode.ID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.links, linkKey(one, other))
+}
+
+// Get returns the configuration of the link between two nodes.
+func (l *Links) Get(one, other enode.ID) LinkConfig {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.get(one, other)
+}
+
+func (l *Links) get(one, other enode.ID) LinkConfig {
+	if cfg, ok := l.links[linkKey(one, other)]; ok {
+		return cfg
+	}
+	return l.defaults
+}
+
+// Partition splits the network into the given groups of nodes. Nodes in different
+// groups can't reach each other and existing connections between them are closed.
+// Nodes which aren't in any group are unaffected. Partition replaces any previous
+// partition.
+func (l *Links) Partition(groups [][]enode.ID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.groups = make(map[enode.ID]int)
+	for i, group := range groups {
+		for _, id := range group {
+			l.groups[id] = i + 1
+		}
+	}
+	for c := range l.conns {
+		if l.partitioned(c.from, c.to) {
+			delete(l.conns, c)
+			go c.Close()
+		}
+	}
+}
+
+// Heal removes the partition.
+func (l *Links) Heal() {
+	l.Partition(nil)
+}
+
+// Partitioned reports whether the two nodes are in different partition groups.
+func (l *Links) Partitioned(one, other enode.ID) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.partitioned(one, other)
+}
+
+func (l *Links) partitioned(one, other enode.ID) bool {
+	g1, g2 := l.groups[one], l.groups[other]
+	return g1 != 0 && g2 != 0 && g1 != g2
+}
+
+// wrap applies the conditions of the link between two nodes to a connection. The
+// given connection ends must be connected to each other, as with net.Pipe.
+func (l *Links) wrap(from, to enode.ID, fromConn, toConn net.Conn) (net.Conn, net.Conn, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.partitioned(from, to) {
+		fromConn.Close()
+		toConn.Close()
+		return nil, nil, errPartitioned
+	}
+	c1 := l.newConn(fromConn, from, to)
+	c2 := l.newConn(toConn, to, from)
+	return c1, c2, nil
+}
+
+func (l *Links) newConn(conn net.Conn, from, to enode.ID) *linkConn {
+	dir := [2]enode.ID{from, to}
+	c := &linkConn{
+		Conn:    conn,
+		links:   l,
+		from:    from,
+		to:      to,
+		rand:    rand.New(rand.NewSource(l.connSeed(dir, l.count[dir]))),
+		queue:   make(chan linkWrite, linkQueueSize),
+		closing: make(chan struct{}),
+	}
+	l.count[dir]++
+	l.conns[c] = struct{}{}
+	go c.deliverLoop()
+	return c
+}
+
+// connSeed derives the seed of a connection's random source. It depends only on the
+// direction and the number of earlier connections in that direction, so loss is
+// reproducible regardless of the order in which connections are created.
+func (l *Links) connSeed(dir [2]enode.ID, n int64) int64 {
+	h := fnv.New64a()
+	binary.Write(h, binary.BigEndian, l.seed)
+	h.Write(dir[0][:])
+	h.Write(dir[1][:])
+	binary.Write(h, binary.BigEndian, n)
+	return int64(h.Sum64())
+}
+
+func (l *Links) removeConn(c *linkConn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.conns, c)
+}
+
+// linkWrite is data in flight.
+type linkWrite struct {
+	data []byte
+	at   mclock.AbsTime // delivery time
+}
+
+// linkConn is one end of a conditioned connection. Data written to it is delivered
+// to the underlying connection by deliverLoop when its delivery time is reached.
+type linkConn struct {
+	net.Conn
+	links    *Links
+	from, to enode.ID
+	queue    chan linkWrite
+	closing  chan struct{}
+	once     sync.Once
+	pending  int32 // queued writes which haven't been delivered
+
+	mu            sync.Mutex // protects the fields below and serializes writes
+	rand          *rand.Rand
+	sendTime      mclock.AbsTime // when the link is free to transmit
+	lastDelivery  mclock.AbsTime
+	writeDeadline time.Time
+}
+
+func (c *linkConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.closing:
+		return 0, errLinkClosed
+	default:
+	}
+
+	cfg := c.links.Get(c.from, c.to)
+	now := c.links.clock.Now()
+	if c.sendTime < now {
+		c.sendTime = now
+	}
+	if cfg.Bandwidth > 0 {
+		c.sendTime += mclock.AbsTime(int64(len(b)) * int64(time.Second) / cfg.Bandwidth)
+	}
+	at := c.sendTime.Add(cfg.Latency)
+	for i := 0; i < maxRetransmits && cfg.Loss > 0 && c.rand.Float64() < cfg.Loss; i++ {
+		at = at.Add(cfg.retransmitDelay())
+	}
+	// Delivery is in order, data can't overtake a delayed write.
+	if at < c.lastDelivery {
+		at = c.lastDelivery
+	}
+	c.lastDelivery = at
+
+	// Undelayed writes go directly to the connection when nothing is in flight,
+	// so ideal links behave like the underlying connection.
+	if at <= now && atomic.LoadInt32(&c.pending) == 0 {
+		c.Conn.SetWriteDeadline(c.writeDeadline)
+		return c.Conn.Write(b)
+	}
+
+	w := linkWrite{data: append([]byte(nil), b...), at: at}
+	var timeout <-chan time.Time
+	if !c.writeDeadline.IsZero() {
+		timer := time.NewTimer(time.Until(c.writeDeadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	atomic.AddInt32(&c.pending, 1)
+	select {
+	case c.queue <- w:
+		return len(b), nil
+	case <-c.closing:
+		atomic.AddInt32(&c.pending, -1)
+		return 0, errLinkClosed
+	case <-timeout:
+		atomic.AddInt32(&c.pending, -1)
+		return 0, os.ErrDeadlineExceeded
+	}
+}
+
+func (c *linkConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline for writes. Delivery of delayed writes isn't
+// subject to the deadline because it may happen much later on a simulated clock.
+func (c *linkConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeDeadline = t
+	return nil
+}
+
+func (c *linkConn) Close() error {
+	c.once.Do(func() {
+		close(c.closing)
+		c.links.removeConn(c)
+	})
+	return c.Conn.Close()
+}
+
+func (c *linkConn) deliverLoop() {
+	clock := c.links.clock
+	for {
+		select {
+		case w := <-c.queue:
+			if wait := time.Duration(w.at - clock.Now()); wait > 0 {
+				timer := clock.NewTimer(wait)
+				select {
+				case <-timer.C():
+				case <-c.closing:
+					timer.Stop()
+					return
+				}
+			}
+			c.Conn.SetWriteDeadline(time.Time{})
+			_, err := c.Conn.Write(w.data)
+			atomic.AddInt32(&c.pending, -1)
+			if err != nil {
+				c.Close()
+				return
+			}
+		case <-c.closing:
+			return
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package adapters
+
+import (
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+var (
+	linkTestA = enode.ID{1}
+	linkTestB = enode.ID{2}
+	linkTestC = enode.ID{3}
+)
+
+func TestLinkDelay(t *testing.T) {
+	clock := new(mclock.Simulated)
+	links := NewLinks(clock, 1)
+	links.Set(linkTestA, linkTestB, LinkConfig{Latency: 100 * time.Millisecond, Bandwidth: 1000})
+	p1, p2 := net.Pipe()
+	c1, c2, err := links.wrap(linkTestA, linkTestB, p1, p2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+	defer c2.Close()
+
+	received := make(chan error, 1)
+	go func() {
+		_, err := io.ReadFull(c2, make([]byte, 500))
+		received <- err
+	}()
+	// Transmitting 500 bytes takes 500ms, plus latency.
+	if _, err := c1.Write(make([]byte, 500)); err != nil {
+		t.Fatal(err)
+	}
+	clock.WaitForTimers(1)
+	clock.Run(599 * time.Millisecond)
+	select {
+	case <-received:
+		t.Fatal("data delivered too early")
+	case <-time.After(50 * time.Millisecond):
+	}
+	clock.Run(time.Millisecond)
+	select {
+	case err := <-received:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("data not delivered")
+	}
+}
+
+func TestLinkLossDeterministic(t *testing.T) {
+	cfg := LinkConfig{Latency: 10 * time.Millisecond, Loss: 0.3}
+	schedule := func(seed int64) (times []mclock.AbsTime) {
+		links := NewLinks(new(mclock.Simulated), seed)
+		links.SetDefault(cfg)
+		p1, p2 := net.Pipe()
+		c1, c2, _ := links.wrap(linkTestA, linkTestB, p1, p2)
+		defer c1.Close()
+		defer c2.Close()
+		for i := 0; i < 20; i++ {
+			c1.Write([]byte{byte(i)})
+			times = append(times, c1.(*linkConn).lastDelivery)
+		}
+		return times
+	}
+
+	s1, s2 := schedule(1), schedule(1)
+	if !reflect.DeepEqual(s1, s2) {
+		t.Fatalf("schedules differ with same seed:\n%v\n%v", s1, s2)
+	}
+	if last := s1[len(s1)-1]; time.Duration(last) < cfg.Latency+cfg.retransmitDelay() {
+		t.Fatalf("no write lost: last delivery at %v", time.Duration(last))
+	}
+	if reflect.DeepEqual(s1, schedule(2)) {
+		t.Fatal("schedules equal with different seeds")
+	}
+}
+
+func TestLinkConfigInvalid(t *testing.T) {
+	links := NewLinks(new(mclock.Simulated), 1)
+	valid := LinkConfig{Latency: time.Millisecond, Loss: 0.5}
+	links.SetDefault(valid)
+	links.Set(linkTestA, linkTestB, valid)
+
+	for _, cfg := range []LinkConfig{
+		{Loss: 1},
+		{Loss: -0.1},
+		{Latency: -time.Second},
+		{Bandwidth: -1},
+	} {
+		if err := links.SetDefault(cfg); err == nil {
+			t.Errorf("SetDefault(%+v): no error", cfg)
+		}
+		if err := links.Set(linkTestA, linkTestB, cfg); err == nil {
+			t.Errorf("Set(%+v): no error", cfg)
+		}
+	}
+	if cfg := links.Default(); cfg != valid {
+		t.Fatalf("default config changed to %+v", cfg)
+	}
+	if cfg := links.Get(linkTestA, linkTestB); cfg != valid {
+		t.Fatalf("link config changed to %+v", cfg)
+	}
+}
+
+func TestLinkPartition(t *testing.T) {
+	links := NewLinks(mclock.System{}, 1)
+	p1, p2 := net.Pipe()
+	c1, c2, err := links.wrap(linkTestA, linkTestB, p1, p2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+
+	// Nodes which aren't in a group are unaffected.
+	links.Partition([][]enode.ID{{linkTestA}, {linkTestB}})
+	if links.Partitioned(linkTestA, linkTestC) {
+		t.Fatal("node without group is partitioned")
+	}
+	c2.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c2.Read(make([]byte, 1)); err == nil || os.IsTimeout(err) {
+		t.Fatalf("connection across partition not closed: %v", err)
+	}
+	p1, p2 = net.Pipe()
+	if _, _, err := links.wrap(linkTestB, linkTestA, p1, p2); err != errPartitioned {
+		t.Fatalf("wrong error across partition: %v", err)
+	}
+
+	links.Heal()
+	p1, p2 = net.Pipe()
+	c1, c2, err = links.wrap(linkTestB, linkTestA, p1, p2)
+	if err != nil {
+		t.Fatal("can't connect after heal:", err)
+	}
+	c1.Close()
+	c2.Close()
+}
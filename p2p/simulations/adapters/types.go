@@ -26,6 +26,7 @@ import (
 	"strconv"
 
 	"github.com/docker/docker/pkg/reexec"
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
@@ -121,6 +122,20 @@ type NodeConfig struct {
 
 	Port uint16
 
+	// NAT puts the node behind a simulated NAT. The node can dial other nodes,
+	// but isn't reachable by them. This is only supported by SimAdapter.
+	NAT bool
+
+	// Relay lets the node dial nodes behind NAT through their relays.
+	Relay bool
+
+	// RelayService makes the node relay connections for nodes behind NAT.
+	RelayService bool
+
+	// Relays are the relays of a node behind NAT. They must be created before the
+	// node. This is only supported by SimAdapter.
+	Relays []enode.ID
+
 	// LogFile is the log file name of the p2p node at runtime.
 	//
 	// The default value is empty so that the default log writer
@@ -145,6 +160,10 @@ type nodeConfigJSON struct {
 	Port            uint16   `json:"port"`
 	LogFile         string   `json:"logfile"`
 	LogVerbosity    int      `json:"log_verbosity"`
+	NAT             bool     `json:"nat,omitempty"`
+	Relay           bool     `json:"relay,omitempty"`
+	RelayService    bool     `json:"relay_service,omitempty"`
+	Relays          []string `json:"relays,omitempty"`
 }
 
 // MarshalJSON implements the json.Marshaler interface by encoding the config
@@ -159,6 +178,12 @@ func (n *NodeConfig) MarshalJSON() ([]byte, error) {
 		EnableMsgEvents: n.EnableMsgEvents,
 		LogFile:         n.LogFile,
 		LogVerbosity:    int(n.LogVerbosity),
+		NAT:             n.NAT,
+		Relay:           n.Relay,
+		RelayService:    n.RelayService,
+	}
+	for _, id := range n.Relays {
+		confJSON.Relays = append(confJSON.Relays, id.String())
 	}
 	if n.PrivateKey != nil {
 		confJSON.PrivateKey = hex.EncodeToString(crypto.FromECDSA(n.PrivateKey))
@@ -199,6 +224,17 @@ func (n *NodeConfig) UnmarshalJSON(data []byte) error {
 	n.EnableMsgEvents = confJSON.EnableMsgEvents
 	n.LogFile = confJSON.LogFile
 	n.LogVerbosity = log.Lvl(confJSON.LogVerbosity)
+	n.NAT = confJSON.NAT
+	n.Relay = confJSON.Relay
+	n.RelayService = confJSON.RelayService
+	n.Relays = nil
+	for _, s := range confJSON.Relays {
+		var id enode.ID
+		if err := id.UnmarshalText([]byte(s)); err != nil {
+			return err
+		}
+		n.Relays = append(n.Relays, id)
+	}
 
 	return nil
 }
@@ -256,6 +292,10 @@ type ServiceContext struct {
 
 	Config   *NodeConfig
 	Snapshot []byte
+
+	// Clock is the clock of the simulation. Services which should run in
+	// simulated time use it instead of the system clock.
+	Clock mclock.Clock
 }
 
 // RPCDialer is used when initialising services which need to connect to
@@ -21,6 +21,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -28,7 +29,9 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
@@ -205,6 +208,44 @@ func (c *Client) DisconnectNode(nodeID, peerID string) error {
 	return c.Delete(fmt.Sprintf("/nodes/%s/conn/%s", nodeID, peerID))
 }
 
+// SetLink sets the conditions of a link between two nodes, or of all links which
+// aren't configured individually if the update doesn't specify nodes
+func (c *Client) SetLink(update *LinkUpdate) error {
+	return c.Post("/links", update, nil)
+}
+
+// Partition splits the network into groups of nodes which can't reach each other
+func (c *Client) Partition(groups [][]enode.ID) error {
+	return c.Post("/partition", groups, nil)
+}
+
+// Heal removes the network partition
+func (c *Client) Heal() error {
+	return c.Delete("/partition")
+}
+
+// StartScenario starts a scenario of changing network conditions
+func (c *Client) StartScenario(scenario *Scenario) error {
+	return c.Post("/scenario", scenario, nil)
+}
+
+// StopScenario stops the running scenario
+func (c *Client) StopScenario() error {
+	return c.Delete("/scenario")
+}
+
+// GetClock returns the state of the simulation clock
+func (c *Client) GetClock() (*ClockInfo, error) {
+	info := &ClockInfo{}
+	return info, c.Get("/clock", info)
+}
+
+// AdvanceClock runs the simulated clock of a deterministic network forward
+func (c *Client) AdvanceClock(d time.Duration) (*ClockInfo, error) {
+	info := &ClockInfo{}
+	return info, c.Post("/clock", &ClockAdvance{Duration: d}, info)
+}
+
 // RPCClient returns an RPC client connected to a node
 func (c *Client) RPCClient(ctx context.Context, nodeID string) (*rpc.Client, error) {
 	baseURL := strings.Replace(c.URL, "http", "ws", 1)
@@ -296,6 +337,13 @@ func NewServer(network *Network) *Server {
 	s.POST("/nodes/:nodeid/conn/:peerid", s.ConnectNode)
 	s.DELETE("/nodes/:nodeid/conn/:peerid", s.DisconnectNode)
 	s.GET("/nodes/:nodeid/rpc", s.NodeRPC)
+	s.POST("/links", s.SetLink)
+	s.POST("/partition", s.Partition)
+	s.DELETE("/partition", s.Heal)
+	s.POST("/scenario", s.StartScenario)
+	s.DELETE("/scenario", s.StopScenario)
+	s.GET("/clock", s.GetClock)
+	s.POST("/clock", s.AdvanceClock)
 
 	return s
 }
@@ -642,6 +690,121 @@ func (s *Server) DisconnectNode(w http.ResponseWriter, req *http.Request) {
 	s.JSON(w, http.StatusOK, node.NodeInfo())
 }
 
+// SetLink sets the conditions of a link
+func (s *Server) SetLink(w http.ResponseWriter, req *http.Request) {
+	update := &LinkUpdate{}
+	if err := json.NewDecoder(req.Body).Decode(update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.network.SetLink(update); err != nil {
+		http.Error(w, err.Error(), linkErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Partition splits the network into groups of nodes
+func (s *Server) Partition(w http.ResponseWriter, req *http.Request) {
+	var groups [][]enode.ID
+	if err := json.NewDecoder(req.Body).Decode(&groups); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.network.Partition(groups); err != nil {
+		http.Error(w, err.Error(), linkErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Heal removes the network partition
+func (s *Server) Heal(w http.ResponseWriter, req *http.Request) {
+	if err := s.network.Heal(); err != nil {
+		http.Error(w, err.Error(), linkErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// StartScenario starts a scenario of changing network conditions
+func (s *Server) StartScenario(w http.ResponseWriter, req *http.Request) {
+	scenario := &Scenario{}
+	if err := json.NewDecoder(req.Body).Decode(scenario); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.network.StartScenario(scenario); err != nil {
+		http.Error(w, err.Error(), linkErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// StopScenario stops the running scenario
+func (s *Server) StopScenario(w http.ResponseWriter, req *http.Request) {
+	s.network.StopScenario()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ClockInfo is the state of the simulation clock
+type ClockInfo struct {
+	Now mclock.AbsTime `json:"now"`
+}
+
+// ClockAdvance is a request to run the simulated clock forward
+type ClockAdvance struct {
+	Duration time.Duration `json:"duration"`
+}
+
+// GetClock returns the state of the simulation clock
+func (s *Server) GetClock(w http.ResponseWriter, req *http.Request) {
+	now, err := s.network.Now()
+	if err != nil {
+		http.Error(w, err.Error(), linkErrorStatus(err))
+		return
+	}
+
+	s.JSON(w, http.StatusOK, &ClockInfo{Now: now})
+}
+
+// AdvanceClock runs the simulated clock forward
+func (s *Server) AdvanceClock(w http.ResponseWriter, req *http.Request) {
+	advance := &ClockAdvance{}
+	if err := json.NewDecoder(req.Body).Decode(advance); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if advance.Duration < 0 {
+		http.Error(w, "negative duration", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.network.AdvanceClock(advance.Duration); err != nil {
+		http.Error(w, err.Error(), linkErrorStatus(err))
+		return
+	}
+	s.GetClock(w, req)
+}
+
+// linkErrorStatus returns the HTTP status of an error returned by the network
+// conditions API. Adapters which don't simulate network conditions don't
+// implement the API.
+func linkErrorStatus(err error) int {
+	if errors.Is(err, errNoLinks) {
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
 // Options responds to the OPTIONS HTTP method by returning a 200 OK response
 // with the "Access-Control-Allow-Headers" header set to "Content-Type"
 func (s *Server) Options(w http.ResponseWriter, req *http.Request) {
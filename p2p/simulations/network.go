@@ -26,6 +26,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
@@ -65,6 +66,9 @@ type Network struct {
 	events      event.Feed
 	lock        sync.RWMutex
 	quitc       chan struct{}
+
+	scenarioMu sync.Mutex
+	scenario   []mclock.Timer // pending steps of the running scenario
 }
 
 // NewNetwork returns a Network which uses the given NodeAdapter and NetworkConfig
@@ -689,6 +693,7 @@ func (net *Network) initConn(oneID, otherID enode.ID) (*Conn, error) {
 
 // Shutdown stops all nodes in the network and closes the quit channel
 func (net *Network) Shutdown() {
+	net.StopScenario()
 	for _, node := range net.Nodes {
 		log.Debug("Stopping node", "id", node.ID())
 		if err := node.Stop(); err != nil {
@@ -29,6 +29,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
 )
@@ -873,3 +874,86 @@ func casesNodeUnmarshalJSONConfigField() []nodeUnmarshalTestCase {
 		},
 	}
 }
+
+// TestNetworkNAT checks that a node behind a simulated NAT can't be dialed
+// directly, but is reachable through its relay.
+func TestNetworkNAT(t *testing.T) {
+	adapter := adapters.NewSimAdapter(adapters.LifecycleConstructors{
+		"idle": newIdleTestService,
+	})
+	network := NewNetwork(adapter, &NetworkConfig{
+		DefaultService: "idle",
+	})
+	defer network.Shutdown()
+
+	newNode := func(conf *adapters.NodeConfig) *Node {
+		node, err := network.NewNodeWithConfig(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := network.Start(node.ID()); err != nil {
+			t.Fatal(err)
+		}
+		return node
+	}
+	relayConf := adapters.RandomNodeConfig()
+	relayConf.RelayService = true
+	relay := newNode(relayConf)
+	nattedConf := adapters.RandomNodeConfig()
+	nattedConf.NAT = true
+	nattedConf.Relays = []enode.ID{relay.ID()}
+	natted := newNode(nattedConf)
+	dialerConf := adapters.RandomNodeConfig()
+	dialerConf.Relay = true
+	dialer := newNode(dialerConf)
+
+	if _, err := adapter.Dial(context.Background(), natted.Node.(*adapters.SimNode).Node()); err == nil {
+		t.Fatal("dialing node behind NAT succeeded")
+	}
+	if err := network.Connect(dialer.ID(), relay.ID()); err != nil {
+		t.Fatal(err)
+	}
+	waitForConn(t, network, dialer.ID(), relay.ID())
+	// The node behind NAT connects to its relay by itself.
+	waitForConn(t, network, natted.ID(), relay.ID())
+
+	if err := network.Connect(dialer.ID(), natted.ID()); err != nil {
+		t.Fatal(err)
+	}
+	waitForConn(t, network, dialer.ID(), natted.ID())
+}
+
+// idleTestService runs a protocol which keeps peers connected. Unlike testService,
+// it doesn't get stuck when a connection is dropped early and allows peers to
+// reconnect.
+type idleTestService struct{}
+
+func newIdleTestService(ctx *adapters.ServiceContext, stack *node.Node) (node.Lifecycle, error) {
+	stack.RegisterProtocols([]p2p.Protocol{{
+		Name:    "idle",
+		Version: 1,
+		Length:  1,
+		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			for {
+				if _, err := rw.ReadMsg(); err != nil {
+					return err
+				}
+			}
+		},
+	}})
+	return idleTestService{}, nil
+}
+
+func (idleTestService) Start() error { return nil }
+func (idleTestService) Stop() error  { return nil }
+
+func waitForConn(t *testing.T, network *Network, one, other enode.ID) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if conn := network.GetConn(one, other); conn != nil && conn.Up {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for connection between %v and %v", one.TerminalString(), other.TerminalString())
+}
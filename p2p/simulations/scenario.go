@@ -0,0 +1,241 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+)
+
+var (
+	errNoLinks          = errors.New("network conditions are only simulated by the sim-adapter")
+	errNotDeterministic = errors.New("network doesn't use a simulated clock")
+)
+
+// Scenario is a schedule of changes to the network conditions.
+type Scenario struct {
+	Steps []ScenarioStep `json:"steps"`
+}
+
+// ScenarioStep is a change of the network conditions at a point in time.
+type ScenarioStep struct {
+	// At is the time of the step relative to the start of the scenario.
+	At time.Duration `json:"at"`
+
+	// Links are applied first.
+	Links []LinkUpdate `json:"links,omitempty"`
+
+	// Heal removes the current partition. It is applied before Partition.
+	Heal bool `json:"heal,omitempty"`
+
+	// Partition splits the network into groups of nodes which can't reach each
+	// other. Nodes which aren't in any group are unaffected.
+	Partition [][]enode.ID `json:"partition,omitempty"`
+}
+
+// LinkUpdate sets the conditions of the link between two nodes. If One and Other
+// are nil, it sets the conditions of all links which aren't configured individually.
+type LinkUpdate struct {
+	One   *enode.ID `json:"one,omitempty"`
+	Other *enode.ID `json:"other,omitempty"`
+	adapters.LinkConfig
+}
+
+// Links returns the network conditions of the simulation.
+func (net *Network) Links() (*adapters.Links, error) {
+	la, ok := net.nodeAdapter.(interface{ Links() *adapters.Links })
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", net.nodeAdapter.Name(), errNoLinks)
+	}
+	return la.Links(), nil
+}
+
+// SetLink applies a link update.
+func (net *Network) SetLink(u *LinkUpdate) error {
+	links, err := net.Links()
+	if err != nil {
+		return err
+	}
+	if err := net.checkLinkUpdate(u); err != nil {
+		return err
+	}
+	return net.setLink(links, u)
+}
+
+func (net *Network) setLink(links *adapters.Links, u *LinkUpdate) error {
+	if u.One == nil {
+		log.Debug("Setting default link conditions", "latency", u.Latency, "bandwidth", u.Bandwidth, "loss", u.Loss)
+		return links.SetDefault(u.LinkConfig)
+	}
+	log.Debug("Setting link conditions", "id", *u.One, "other", *u.Other, "latency", u.Latency, "bandwidth", u.Bandwidth, "loss", u.Loss)
+	return links.Set(*u.One, *u.Other, u.LinkConfig)
+}
+
+// Partition splits the network into groups of nodes which can't reach each other.
+// Connections between nodes in different groups are closed.
+func (net *Network) Partition(groups [][]enode.ID) error {
+	links, err := net.Links()
+	if err != nil {
+		return err
+	}
+	if err := net.checkPartition(groups); err != nil {
+		return err
+	}
+	log.Debug("Partitioning network", "groups", len(groups))
+	links.Partition(groups)
+	return nil
+}
+
+// Heal removes the partition of the network. Nodes reconnect by themselves if they
+// dial each other, e.g. because they are static peers.
+func (net *Network) Heal() error {
+	links, err := net.Links()
+	if err != nil {
+		return err
+	}
+	log.Debug("Healing network partition")
+	links.Heal()
+	return nil
+}
+
+// StartScenario schedules the steps of a scenario on the simulation clock, starting
+// now. It replaces the scenario which is currently running.
+func (net *Network) StartScenario(s *Scenario) error {
+	links, err := net.Links()
+	if err != nil {
+		return err
+	}
+	for i, step := range s.Steps {
+		if step.At < 0 {
+			return fmt.Errorf("step %d: negative time %v", i, step.At)
+		}
+		for _, u := range step.Links {
+			if err := net.checkLinkUpdate(&u); err != nil {
+				return fmt.Errorf("step %d: %v", i, err)
+			}
+		}
+		if err := net.checkPartition(step.Partition); err != nil {
+			return fmt.Errorf("step %d: %v", i, err)
+		}
+	}
+
+	net.scenarioMu.Lock()
+	defer net.scenarioMu.Unlock()
+	net.stopScenario()
+	clock := links.Clock()
+	for i := range s.Steps {
+		step := s.Steps[i]
+		net.scenario = append(net.scenario, clock.AfterFunc(step.At, func() {
+			net.applyStep(links, &step)
+		}))
+	}
+	return nil
+}
+
+// StopScenario cancels the steps of the running scenario which haven't been applied
+// yet. It doesn't revert the network conditions.
+func (net *Network) StopScenario() {
+	net.scenarioMu.Lock()
+	defer net.scenarioMu.Unlock()
+	net.stopScenario()
+}
+
+func (net *Network) stopScenario() {
+	for _, timer := range net.scenario {
+		timer.Stop()
+	}
+	net.scenario = nil
+}
+
+func (net *Network) applyStep(links *adapters.Links, step *ScenarioStep) {
+	log.Debug("Applying scenario step", "at", step.At)
+	for i := range step.Links {
+		if err := net.setLink(links, &step.Links[i]); err != nil {
+			log.Warn("Invalid link update in scenario step", "at", step.At, "err", err)
+		}
+	}
+	if step.Heal {
+		links.Heal()
+	}
+	if len(step.Partition) > 0 {
+		links.Partition(step.Partition)
+	}
+}
+
+// AdvanceClock runs the simulated clock of a deterministic network forward by d,
+// delivering delayed data and applying scenario steps which are due.
+func (net *Network) AdvanceClock(d time.Duration) error {
+	links, err := net.Links()
+	if err != nil {
+		return err
+	}
+	clock, ok := links.Clock().(*mclock.Simulated)
+	if !ok {
+		return errNotDeterministic
+	}
+	clock.Run(d)
+	return nil
+}
+
+// Now returns the current time of the simulation clock.
+func (net *Network) Now() (mclock.AbsTime, error) {
+	links, err := net.Links()
+	if err != nil {
+		return 0, err
+	}
+	return links.Clock().Now(), nil
+}
+
+func (net *Network) checkLinkUpdate(u *LinkUpdate) error {
+	if (u.One == nil) != (u.Other == nil) {
+		return errors.New("link update needs both nodes or none")
+	}
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	if u.One != nil {
+		if net.GetNode(*u.One) == nil {
+			return fmt.Errorf("node %v does not exist", *u.One)
+		}
+		if net.GetNode(*u.Other) == nil {
+			return fmt.Errorf("node %v does not exist", *u.Other)
+		}
+	}
+	return nil
+}
+
+func (net *Network) checkPartition(groups [][]enode.ID) error {
+	seen := make(map[enode.ID]bool)
+	for _, group := range groups {
+		for _, id := range group {
+			if net.GetNode(id) == nil {
+				return fmt.Errorf("node %v does not exist", id)
+			}
+			if seen[id] {
+				return fmt.Errorf("node %v is in more than one group", id)
+			}
+			seen[id] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+)
+
+func newDeterministicNetwork(t *testing.T, nodeCount int) (*Network, *mclock.Simulated, []enode.ID) {
+	t.Helper()
+	clock := new(mclock.Simulated)
+	services := adapters.LifecycleConstructors{"idle": newIdleTestService}
+	adapter := adapters.NewDeterministicSimAdapter(services, clock, 1)
+	network := NewNetwork(adapter, &NetworkConfig{DefaultService: "idle"})
+	ids := make([]enode.ID, nodeCount)
+	for i := range ids {
+		node, err := network.NewNodeWithConfig(adapters.RandomNodeConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := network.Start(node.ID()); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = node.ID()
+	}
+	return network, clock, ids
+}
+
+func TestNetworkScenarioPartition(t *testing.T) {
+	network, _, ids := newDeterministicNetwork(t, 3)
+	defer network.Shutdown()
+
+	for _, id := range ids[1:] {
+		if err := network.Connect(ids[0], id); err != nil {
+			t.Fatal(err)
+		}
+		waitForConn(t, network, ids[0], id)
+	}
+	err := network.StartScenario(&Scenario{Steps: []ScenarioStep{
+		{At: time.Second, Partition: [][]enode.ID{{ids[0], ids[1]}, {ids[2]}}},
+		{At: 2 * time.Second, Heal: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing happens until the clock is advanced.
+	time.Sleep(50 * time.Millisecond)
+	if conn := network.GetConn(ids[0], ids[2]); !conn.Up {
+		t.Fatal("connection dropped before partition")
+	}
+	if err := network.AdvanceClock(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	waitForConnDown(t, network, ids[0], ids[2])
+	if conn := network.GetConn(ids[0], ids[1]); !conn.Up {
+		t.Fatal("connection within partition group dropped")
+	}
+
+	// After healing, the static peer is redialed when its dial history entry
+	// expires on the simulated clock.
+	if err := network.AdvanceClock(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := network.AdvanceClock(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	waitForConn(t, network, ids[0], ids[2])
+}
+
+func TestNetworkScenarioInvalid(t *testing.T) {
+	network, _, ids := newDeterministicNetwork(t, 2)
+	defer network.Shutdown()
+
+	unknown := enode.ID{1}
+	tests := []*Scenario{
+		{Steps: []ScenarioStep{{At: -time.Second}}},
+		{Steps: []ScenarioStep{{Partition: [][]enode.ID{{ids[0]}, {unknown}}}}},
+		{Steps: []ScenarioStep{{Partition: [][]enode.ID{{ids[0]}, {ids[0], ids[1]}}}}},
+		{Steps: []ScenarioStep{{Links: []LinkUpdate{{One: &ids[0]}}}}},
+		{Steps: []ScenarioStep{{Links: []LinkUpdate{{One: &ids[0], Other: &unknown}}}}},
+		{Steps: []ScenarioStep{{Links: []LinkUpdate{{LinkConfig: adapters.LinkConfig{Loss: 1}}}}}},
+	}
+	for i, s := range tests {
+		if err := network.StartScenario(s); err == nil {
+			t.Errorf("scenario %d: no error", i)
+		}
+	}
+}
+
+// This test checks that network conditions can be controlled through the HTTP API.
+func TestHTTPNetworkConditions(t *testing.T) {
+	network, clock, ids := newDeterministicNetwork(t, 2)
+	defer network.Shutdown()
+	s := httptest.NewServer(NewServer(network))
+	defer s.Close()
+	client := NewClient(s.URL)
+
+	links, _ := network.Links()
+	update := &LinkUpdate{One: &ids[0], Other: &ids[1], LinkConfig: adapters.LinkConfig{Latency: 50 * time.Millisecond}}
+	if err := client.SetLink(update); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := links.Get(ids[1], ids[0]); cfg != update.LinkConfig {
+		t.Fatalf("wrong link config %+v", cfg)
+	}
+	if err := client.Partition([][]enode.ID{{ids[0]}, {ids[1]}}); err != nil {
+		t.Fatal(err)
+	}
+	if !links.Partitioned(ids[0], ids[1]) {
+		t.Fatal("nodes not partitioned")
+	}
+	if err := client.Heal(); err != nil {
+		t.Fatal(err)
+	}
+	if links.Partitioned(ids[0], ids[1]) {
+		t.Fatal("partition not healed")
+	}
+
+	scenario := &Scenario{Steps: []ScenarioStep{
+		{At: time.Second, Links: []LinkUpdate{{LinkConfig: adapters.LinkConfig{Bandwidth: 1000}}}},
+	}}
+	if err := client.StartScenario(scenario); err != nil {
+		t.Fatal(err)
+	}
+	info, err := client.AdvanceClock(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Now != clock.Now() || time.Duration(info.Now) != time.Second {
+		t.Fatalf("wrong clock time %v", time.Duration(info.Now))
+	}
+	if cfg := links.Default(); cfg.Bandwidth != 1000 {
+		t.Fatalf("scenario step not applied, default link config %+v", cfg)
+	}
+	if err := client.StopScenario(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitForConnDown(t *testing.T, network *Network, one, other enode.ID) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if conn := network.GetConn(one, other); conn != nil && !conn.Up {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for disconnect between %v and %v", one.TerminalString(), other.TerminalString())
+}
+
+// This test checks that the network conditions API fails clearly with adapters
+// which don't simulate network conditions.
+func TestExecAdapterNetworkConditions(t *testing.T) {
+	network := NewNetwork(adapters.NewExecAdapter(t.TempDir()), &NetworkConfig{})
+	defer network.Shutdown()
+
+	if err := network.SetLink(&LinkUpdate{}); !errors.Is(err, errNoLinks) {
+		t.Errorf("SetLink: wrong error %v", err)
+	}
+	if err := network.Partition(nil); !errors.Is(err, errNoLinks) {
+		t.Errorf("Partition: wrong error %v", err)
+	}
+	if err := network.Heal(); !errors.Is(err, errNoLinks) {
+		t.Errorf("Heal: wrong error %v", err)
+	}
+	if err := network.StartScenario(&Scenario{}); !errors.Is(err, errNoLinks) {
+		t.Errorf("StartScenario: wrong error %v", err)
+	}
+	if err := network.AdvanceClock(time.Second); !errors.Is(err, errNoLinks) {
+		t.Errorf("AdvanceClock: wrong error %v", err)
+	}
+	if _, err := network.Now(); !errors.Is(err, errNoLinks) {
+		t.Errorf("Now: wrong error %v", err)
+	}
+
+	s := httptest.NewServer(NewServer(network))
+	defer s.Close()
+	client := NewClient(s.URL)
+	err := client.SetLink(&LinkUpdate{})
+	if err == nil || !strings.Contains(err.Error(), "501") || !strings.Contains(err.Error(), "exec-adapter") {
+		t.Errorf("wrong HTTP error %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+)
+
+// TrafficClass is the priority of an outbound message when bandwidth is limited.
+// Messages of a higher class are sent before waiting messages of lower classes.
+type TrafficClass uint8
+
+const (
+	TrafficNormal TrafficClass = iota // default class
+	TrafficBulk                       // large transfers, e.g. serving state sync
+	TrafficHigh                       // latency sensitive, e.g. block propagation
+
+	numTrafficClasses = 3
+)
+
+// trafficPriority lists the classes from highest to lowest priority.
+var trafficPriority = [numTrafficClasses]TrafficClass{TrafficHigh, TrafficNormal, TrafficBulk}
+
+func (c TrafficClass) String() string {
+	switch c {
+	case TrafficNormal:
+		return "normal"
+	case TrafficBulk:
+		return "bulk"
+	case TrafficHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("class-%d", c)
+	}
+}
+
+// MsgTraffic is the traffic of a single message type.
+type MsgTraffic struct {
+	IngressBytes   uint64 `json:"ingressBytes"`
+	IngressPackets uint64 `json:"ingressPackets"`
+	EgressBytes    uint64 `json:"egressBytes"`
+	EgressPackets  uint64 `json:"egressPackets"`
+}
+
+// ProtocolTraffic is the traffic of a protocol with a peer. Messages are keyed by
+// their hex code within the protocol. Sizes are message payload sizes.
+type ProtocolTraffic struct {
+	IngressBytes uint64                 `json:"ingressBytes"`
+	EgressBytes  uint64                 `json:"egressBytes"`
+	Messages     map[string]*MsgTraffic `json:"messages"`
+}
+
+// trafficStats counts the traffic with a peer by protocol and message code.
+type trafficStats struct {
+	mu    sync.Mutex
+	proto map[Cap]map[uint64]*MsgTraffic
+}
+
+func newTrafficStats() *trafficStats {
+	return &trafficStats{proto: make(map[Cap]map[uint64]*MsgTraffic)}
+}
+
+func (s *trafficStats) counter(cap Cap, code uint64) *MsgTraffic {
+	msgs := s.proto[cap]
+	if msgs == nil {
+		msgs = make(map[uint64]*MsgTraffic)
+		s.proto[cap] = msgs
+	}
+	c := msgs[code]
+	if c == nil {
+		c = new(MsgTraffic)
+		msgs[code] = c
+	}
+	return c
+}
+
+func (s *trafficStats) addIngress(cap Cap, code uint64, size uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.counter(cap, code)
+	c.IngressBytes += uint64(size)
+	c.IngressPackets++
+}
+
+func (s *trafficStats) addEgress(cap Cap, code uint64, size uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.counter(cap, code)
+	c.EgressBytes += uint64(size)
+	c.EgressPackets++
+}
+
+// info returns a copy of the counters, keyed by protocol.
+func (s *trafficStats) info() map[string]*ProtocolTraffic {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info := make(map[string]*ProtocolTraffic, len(s.proto))
+	for cap, msgs := range s.proto {
+		pt := &ProtocolTraffic{Messages: make(map[string]*MsgTraffic, len(msgs))}
+		for code, c := range msgs {
+			cpy := *c
+			pt.Messages[fmt.Sprintf("%#02x", code)] = &cpy
+			pt.IngressBytes += c.IngressBytes
+			pt.EgressBytes += c.EgressBytes
+		}
+		info[cap.String()] = pt
+	}
+	return info
+}
+
+// egressLimiter limits outbound bandwidth using a token bucket. Writers wait in
+// one queue per traffic class and the queues are served in priority order.
+//
+// Messages larger than the available tokens are admitted as soon as the bucket
+// is not empty. The bucket then goes into debt, which delays subsequent writes.
+type egressLimiter struct {
+	clock mclock.Clock
+	rate  float64 // bytes per second
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   mclock.AbsTime
+	queues [numTrafficClasses][]*egressWaiter
+	timer  mclock.Timer
+}
+
+type egressWaiter struct {
+	size    float64
+	granted chan struct{}
+}
+
+// newEgressLimiter creates a limiter allowing rate bytes per second. Up to one
+// second worth of unused bandwidth can be saved for bursts.
+func newEgressLimiter(clock mclock.Clock, rate int) *egressLimiter {
+	return &egressLimiter{
+		clock:  clock,
+		rate:   float64(rate),
+		burst:  float64(rate),
+		tokens: float64(rate),
+		last:   clock.Now(),
+	}
+}
+
+// wait blocks until size bytes of the given class may be sent, or until cancel
+// is closed.
+func (l *egressLimiter) wait(class TrafficClass, size uint32, cancel <-chan struct{}) error {
+	if class >= numTrafficClasses {
+		class = TrafficNormal
+	}
+	w := &egressWaiter{size: float64(size), granted: make(chan struct{})}
+	l.mu.Lock()
+	l.queues[class] = append(l.queues[class], w)
+	l.schedule()
+	l.mu.Unlock()
+
+	select {
+	case <-w.granted:
+		return nil
+	case <-cancel:
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		select {
+		case <-w.granted:
+			// Granted concurrently, return the tokens.
+			l.tokens += w.size
+		default:
+			l.remove(class, w)
+		}
+		l.schedule()
+		return ErrShuttingDown
+	}
+}
+
+// schedule grants waiting writes in priority order while tokens are available
+// and arms the timer for the next grant.
+func (l *egressLimiter) schedule() {
+	now := l.clock.Now()
+	l.tokens += l.rate * time.Duration(now-l.last).Seconds()
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	for l.tokens > 0 {
+		w := l.next()
+		if w == nil {
+			return
+		}
+		l.tokens -= w.size
+		close(w.granted)
+	}
+	if l.timer == nil && l.waiting() {
+		wait := time.Duration(-l.tokens/l.rate*float64(time.Second)) + 1
+		l.timer = l.clock.AfterFunc(wait, func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.timer = nil
+			l.schedule()
+		})
+	}
+}
+
+// next removes and returns the first waiter of the highest priority queue.
+func (l *egressLimiter) next() *egressWaiter {
+	for _, class := range trafficPriority {
+		if q := l.queues[class]; len(q) > 0 {
+			w := q[0]
+			q[0] = nil
+			l.queues[class] = q[1:]
+			return w
+		}
+	}
+	return nil
+}
+
+func (l *egressLimiter) waiting() bool {
+	for _, q := range l.queues {
+		if len(q) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *egressLimiter) remove(class TrafficClass, w *egressWaiter) {
+	q := l.queues[class]
+	for i := range q {
+		if q[i] == w {
+			l.queues[class] = append(q[:i:i], q[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+)
+
+func TestPeerTrafficAccounting(t *testing.T) {
+	done := make(chan struct{})
+	proto := Protocol{
+		Name:    "a",
+		Version: 1,
+		Length:  5,
+		Run: func(peer *Peer, rw MsgReadWriter) error {
+			if err := ExpectMsg(rw, 2, []uint{1}); err != nil {
+				t.Error(err)
+			}
+			if err := SendItems(rw, 3, "foo", "bar"); err != nil {
+				t.Error(err)
+			}
+			<-done
+			return nil
+		},
+	}
+	closer, rw, peer, _ := testPeer([]Protocol{proto})
+	defer closer()
+	defer close(done)
+
+	if err := SendItems(rw, baseProtocolLength+2, uint(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExpectMsg(rw, baseProtocolLength+3, []string{"foo", "bar"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The write is counted when it returns, which can be after the message
+	// has been read.
+	var info *ProtocolTraffic
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		if info = peer.Info().Traffic["a/1"]; info != nil && info.EgressBytes > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if info == nil {
+		t.Fatal("no traffic info for protocol")
+	}
+	in, out := info.Messages["0x02"], info.Messages["0x03"]
+	if in == nil || in.IngressPackets != 1 || in.IngressBytes != 2 || in.EgressPackets != 0 {
+		t.Errorf("wrong ingress counters %+v", in)
+	}
+	if out == nil || out.EgressPackets != 1 || out.EgressBytes != 9 || out.IngressPackets != 0 {
+		t.Errorf("wrong egress counters %+v", out)
+	}
+	if info.IngressBytes != 2 || info.EgressBytes != 9 {
+		t.Errorf("wrong protocol totals %+v", info)
+	}
+}
+
+func TestEgressLimiterPriority(t *testing.T) {
+	clock := new(mclock.Simulated)
+	l := newEgressLimiter(clock, 1000)
+
+	// Go 500 bytes into debt.
+	if err := l.wait(TrafficNormal, 1500, nil); err != nil {
+		t.Fatal(err)
+	}
+	bulk := waitAsync(l, TrafficBulk, 1000)
+	waitQueued(t, l, TrafficBulk, 1)
+	high := waitAsync(l, TrafficHigh, 1000)
+	waitQueued(t, l, TrafficHigh, 1)
+
+	// The debt is paid after 500ms. The high priority write goes first even
+	// though it was queued later.
+	clock.Run(499 * time.Millisecond)
+	expectNoGrant(t, high, bulk)
+	clock.Run(10 * time.Millisecond)
+	expectGrant(t, high)
+	expectNoGrant(t, bulk)
+	clock.Run(980 * time.Millisecond)
+	expectNoGrant(t, bulk)
+	clock.Run(20 * time.Millisecond)
+	expectGrant(t, bulk)
+}
+
+func TestEgressLimiterCancel(t *testing.T) {
+	clock := new(mclock.Simulated)
+	l := newEgressLimiter(clock, 1000)
+	l.wait(TrafficNormal, 2000, nil)
+
+	cancel := make(chan struct{})
+	errc := make(chan error, 1)
+	go func() { errc <- l.wait(TrafficBulk, 100, cancel) }()
+	waitQueued(t, l, TrafficBulk, 1)
+	close(cancel)
+	if err := <-errc; err != ErrShuttingDown {
+		t.Fatalf("wrong error %v", err)
+	}
+	waitQueued(t, l, TrafficBulk, 0)
+}
+
+func waitAsync(l *egressLimiter, class TrafficClass, size uint32) <-chan struct{} {
+	granted := make(chan struct{})
+	go func() {
+		l.wait(class, size, nil)
+		close(granted)
+	}()
+	return granted
+}
+
+func waitQueued(t *testing.T, l *egressLimiter, class TrafficClass, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		l.mu.Lock()
+		queued := len(l.queues[class])
+		l.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d writes of class %v not queued", n, class)
+}
+
+func expectGrant(t *testing.T, granted <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-granted:
+	case <-time.After(time.Second):
+		t.Fatal("write not granted")
+	}
+}
+
+func expectNoGrant(t *testing.T, granted ...<-chan struct{}) {
+	t.Helper()
+	time.Sleep(20 * time.Millisecond)
+	for _, ch := range granted {
+		select {
+		case <-ch:
+			t.Fatal("write granted too early")
+		default:
+		}
+	}
+}
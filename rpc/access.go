@@ -0,0 +1,207 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+var (
+	errAuthRequired       = errors.New("authentication required")
+	errInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Credential grants a remote client access to a set of RPC methods. Clients
+// authenticate by sending either a static API key or a JSON web token signed
+// with the server secret as bearer token in the Authorization header.
+type Credential struct {
+	Name    string   // Identifies the client in rate limiting, must be unique
+	APIKey  string   // Static key authenticating the client, optional
+	Subject string   // Subject of the tokens authenticating the client, optional
+	Allow   []string // Allowed namespaces ("eth"), methods ("debug_traceCall") or everything ("*")
+	Rate    float64  // Request tokens credited per second, zero for the default rate limit
+	Burst   float64  // Maximum number of request tokens, zero for one second worth of tokens
+}
+
+// AccessConfig configures the authorization of remote RPC clients.
+type AccessConfig struct {
+	JWTSecret          []byte       // Secret to verify HS256 bearer tokens with, tokens are rejected if nil
+	Credentials        []Credential // Known clients and their permissions
+	RequireCredentials bool         // Reject the calls of clients which don't authenticate
+}
+
+// AccessControl authorizes the calls of remote clients based on the credentials
+// they present. Tokens with a subject not matching any credential are granted the
+// methods listed in their "allow" claim, or everything if there is none. Clients
+// without credentials may call everything unless credentials are required.
+//
+// Calls over IPC and in-process connections are never restricted. Note that the
+// access control only narrows down the modules exposed on an endpoint.
+type AccessControl struct {
+	config   AccessConfig
+	keys     map[[32]byte]*clientIdentity
+	subjects map[string]*clientIdentity
+}
+
+// clientIdentity is the authenticated identity of a remote client.
+type clientIdentity struct {
+	name  string     // unique name of the client, used as rate limiting key
+	allow *allowList // methods the client may call, nil if unrestricted
+	rate  float64    // request rate of the client, zero for the default
+	burst float64    // request burst of the client
+	err   error      // set if the client failed to authenticate
+}
+
+// NewAccessControl creates an access control with the given configuration.
+func NewAccessControl(config AccessConfig) (*AccessControl, error) {
+	ac := &AccessControl{
+		config:   config,
+		keys:     make(map[[32]byte]*clientIdentity),
+		subjects: make(map[string]*clientIdentity),
+	}
+	names := make(map[string]bool)
+	for _, cred := range config.Credentials {
+		switch {
+		case cred.Name == "":
+			return nil, errors.New("credential without name")
+		case names[cred.Name]:
+			return nil, fmt.Errorf("duplicate credential %q", cred.Name)
+		case cred.APIKey == "" && cred.Subject == "":
+			return nil, fmt.Errorf("credential %q has neither API key nor token subject", cred.Name)
+		case cred.Subject != "" && config.JWTSecret == nil:
+			return nil, fmt.Errorf("credential %q has a token subject, but no JWT secret is configured", cred.Name)
+		case cred.Rate < 0 || cred.Burst < 0:
+			return nil, fmt.Errorf("invalid rate limit for credential %q", cred.Name)
+		}
+		names[cred.Name] = true
+
+		id := &clientIdentity{
+			name:  "cred:" + cred.Name,
+			allow: newAllowList(cred.Allow),
+			rate:  cred.Rate,
+			burst: cred.Burst,
+		}
+		if id.rate > 0 && id.burst == 0 {
+			id.burst = math.Max(id.rate, 1)
+		}
+		if cred.APIKey != "" {
+			hash := sha256.Sum256([]byte(cred.APIKey))
+			if ac.keys[hash] != nil {
+				return nil, fmt.Errorf("duplicate API key of credential %q", cred.Name)
+			}
+			ac.keys[hash] = id
+		}
+		if cred.Subject != "" {
+			if ac.subjects[cred.Subject] != nil {
+				return nil, fmt.Errorf("duplicate token subject of credential %q", cred.Name)
+			}
+			ac.subjects[cred.Subject] = id
+		}
+	}
+	return ac, nil
+}
+
+// identify authenticates the client of a connection. It returns nil for clients
+// which are not restricted.
+func (ac *AccessControl) identify(info PeerInfo) *clientIdentity {
+	switch info.Transport {
+	case "http", "ws", "grpc":
+	default:
+		return nil
+	}
+	token := bearerToken(info.auth)
+	if token == "" {
+		if ac.config.RequireCredentials {
+			return &clientIdentity{err: errAuthRequired}
+		}
+		return nil
+	}
+	// Tokens are told apart from API keys by their format
+	if strings.Count(token, ".") == 2 && ac.config.JWTSecret != nil {
+		claims, err := parseJWT(ac.config.JWTSecret, token, time.Now())
+		if err != nil {
+			return &clientIdentity{err: err}
+		}
+		if id := ac.subjects[claims.Subject]; id != nil {
+			return id
+		}
+		id := &clientIdentity{name: "sub:" + claims.Subject}
+		if claims.Allow != nil {
+			id.allow = newAllowList(claims.Allow)
+		}
+		return id
+	}
+	// API keys are looked up by hash, so the lookup doesn't leak their contents
+	if id := ac.keys[sha256.Sum256([]byte(token))]; id != nil {
+		return id
+	}
+	return &clientIdentity{err: errInvalidCredentials}
+}
+
+// authorize checks whether the client may call the given method.
+func (id *clientIdentity) authorize(method string) error {
+	if id.err != nil {
+		return &unauthorizedError{id.err}
+	}
+	if id.allow != nil && !id.allow.allows(method) {
+		return &accessDeniedError{method}
+	}
+	return nil
+}
+
+// allowList is a set of allowed namespaces and methods.
+type allowList struct {
+	all        bool
+	namespaces map[string]bool
+	methods    map[string]bool
+}
+
+func newAllowList(entries []string) *allowList {
+	l := &allowList{
+		namespaces: make(map[string]bool),
+		methods:    make(map[string]bool),
+	}
+	for _, entry := range entries {
+		switch {
+		case entry == "*":
+			l.all = true
+		case strings.Contains(entry, serviceMethodSeparator):
+			l.methods[entry] = true
+		default:
+			l.namespaces[entry] = true
+		}
+	}
+	return l
+}
+
+// allows reports whether the method is in the list, either by itself or by its
+// namespace. Subscriptions are allowed by the namespace or the subscribe method.
+func (l *allowList) allows(method string) bool {
+	if l.all || l.methods[method] {
+		return true
+	}
+	namespace := method
+	if i := strings.Index(method, serviceMethodSeparator); i >= 0 {
+		namespace = method[:i]
+	}
+	return l.namespaces[namespace]
+}
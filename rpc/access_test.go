@@ -0,0 +1,111 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccessControl(t *testing.T) {
+	secret := []byte("secret")
+	access, err := NewAccessControl(AccessConfig{
+		JWTSecret: secret,
+		Credentials: []Credential{
+			{Name: "partner", APIKey: "partner-key", Allow: []string{"rpc", "test_echo"}},
+			{Name: "internal", Subject: "indexer", Allow: []string{"*"}},
+		},
+		RequireCredentials: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer()
+	server.SetAccessControl(access)
+	defer server.Stop()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	var (
+		partner  = "partner-key"
+		internal = signTestJWT(secret, `{"sub":"indexer"}`)
+		claimed  = signTestJWT(secret, `{"sub":"tool","allow":["test"]}`)
+		unknown  = signTestJWT(secret, `{"sub":"anyone"}`)
+		forged   = signTestJWT([]byte("guess"), `{"sub":"indexer"}`)
+	)
+	tests := []struct {
+		auth   string
+		method string
+		code   int // expected error code, zero for success
+	}{
+		{"", "rpc_modules", errcodeUnauthorized},
+		{"wrong-key", "rpc_modules", errcodeUnauthorized},
+		{forged, "rpc_modules", errcodeUnauthorized},
+		{partner, "rpc_modules", 0},
+		{partner, "test_echo", 0},
+		{partner, "test_noArgsRets", errcodeAccessDenied},
+		{internal, "test_noArgsRets", 0},
+		{claimed, "test_noArgsRets", 0},
+		{claimed, "rpc_modules", errcodeAccessDenied},
+		{unknown, "test_noArgsRets", 0},
+	}
+	for i, test := range tests {
+		client, err := DialHTTP(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.auth != "" {
+			client.SetHeader("Authorization", "Bearer "+test.auth)
+		}
+		var args []interface{}
+		if test.method == "test_echo" {
+			args = []interface{}{"x", 1}
+		}
+		err = client.Call(nil, test.method, args...)
+		client.Close()
+
+		switch {
+		case test.code == 0 && err != nil:
+			t.Errorf("test %d: call failed: %v", i, err)
+		case test.code != 0:
+			if rerr, ok := err.(Error); !ok || rerr.ErrorCode() != test.code {
+				t.Errorf("test %d: wrong error %v, want code %d", i, err, test.code)
+			}
+		}
+	}
+	// Local connections are not restricted
+	client := DialInProc(server)
+	defer client.Close()
+	if err := client.Call(nil, "test_noArgsRets"); err != nil {
+		t.Fatalf("in-process call failed: %v", err)
+	}
+}
+
+func TestAccessControlConfig(t *testing.T) {
+	tests := []AccessConfig{
+		{Credentials: []Credential{{APIKey: "key"}}},
+		{Credentials: []Credential{{Name: "a"}}},
+		{Credentials: []Credential{{Name: "a", Subject: "sub"}}},
+		{Credentials: []Credential{{Name: "a", APIKey: "key"}, {Name: "a", APIKey: "other"}}},
+		{Credentials: []Credential{{Name: "a", APIKey: "key"}, {Name: "b", APIKey: "key"}}},
+	}
+	for i, config := range tests {
+		if _, err := NewAccessControl(config); err == nil {
+			t.Errorf("test %d: invalid config accepted", i)
+		}
+	}
+}
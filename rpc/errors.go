@@ -16,7 +16,10 @@
 
 package rpc
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // HTTPError is returned by client operations when the HTTP status code of the
 // response is not a 2xx status.
@@ -55,9 +58,18 @@ var (
 	_ Error = new(invalidMessageError)
 	_ Error = new(invalidParamsError)
 	_ Error = new(CustomError)
+	_ Error = new(responseTooLargeError)
+	_ Error = new(rateLimitError)
+	_ Error = new(unauthorizedError)
+	_ Error = new(accessDeniedError)
 )
 
-const defaultErrorCode = -32000
+const (
+	defaultErrorCode     = -32000
+	errcodeUnauthorized  = -32001
+	errcodeAccessDenied  = -32004
+	errcodeLimitExceeded = -32005
+)
 
 type methodNotFoundError struct{ method string }
 
@@ -111,3 +123,37 @@ type CustomError struct {
 func (e *CustomError) ErrorCode() int { return e.Code }
 
 func (e *CustomError) Error() string { return e.ValidationError }
+
+// the response exceeds the size limit of the server
+type responseTooLargeError struct{ limit int }
+
+func (e *responseTooLargeError) ErrorCode() int { return errcodeLimitExceeded }
+
+func (e *responseTooLargeError) Error() string {
+	return fmt.Sprintf("response too large (limit %d bytes)", e.limit)
+}
+
+// the client ran out of request tokens
+type rateLimitError struct{ wait time.Duration }
+
+func (e *rateLimitError) ErrorCode() int { return errcodeLimitExceeded }
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry in %v", e.wait)
+}
+
+// the client did not present valid credentials
+type unauthorizedError struct{ err error }
+
+func (e *unauthorizedError) ErrorCode() int { return errcodeUnauthorized }
+
+func (e *unauthorizedError) Error() string { return "unauthorized: " + e.err.Error() }
+
+// the credentials of the client don't permit calling the method
+type accessDeniedError struct{ method string }
+
+func (e *accessDeniedError) ErrorCode() int { return errcodeAccessDenied }
+
+func (e *accessDeniedError) Error() string {
+	return fmt.Sprintf("access to method %s denied", e.method)
+}
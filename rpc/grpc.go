@@ -0,0 +1,331 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+const (
+	grpcContentType      = "application/grpc+json"
+	grpcStreamPath       = "/jsonrpc.JSONRPC/Stream" // gRPC method path used by clients
+	grpcFrameHeaderSize  = 5
+	grpcMessageSizeLimit = 15 * 1024 * 1024
+)
+
+var (
+	errGRPCClosed     = errors.New("gRPC stream closed")
+	errGRPCCompressed = errors.New("compressed gRPC messages are not supported")
+)
+
+// GRPCHandler returns a handler that serves JSON-RPC over gRPC-compatible HTTP/2
+// streams. Every request opens a bidirectional stream carrying JSON-RPC messages
+// in length-prefixed gRPC frames, which behaves like a WebSocket connection: it
+// supports any number of calls, batches and subscriptions. The request path is
+// not interpreted, clients created by DialGRPC use /jsonrpc.JSONRPC/Stream.
+//
+// The handler requires HTTP/2, so the server must either use TLS or accept
+// unencrypted HTTP/2 (h2c).
+func (s *Server) GRPCHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			http.Error(w, "gRPC streams require HTTP/2", http.StatusHTTPVersionNotSupported)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !IsGRPCRequest(r) {
+			http.Error(w, fmt.Sprintf("invalid content type, only %s is supported", grpcContentType), http.StatusUnsupportedMediaType)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		// Streams live as long as the client, so the server's read and write
+		// timeouts must not apply to them.
+		rc := http.NewResponseController(w)
+		rc.SetReadDeadline(time.Time{})
+		rc.SetWriteDeadline(time.Time{})
+
+		w.Header().Set("content-type", grpcContentType)
+		w.Header().Set("trailer", "grpc-status")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		stream := &grpcStream{
+			reader: r.Body,
+			writer: w,
+			flush:  flusher.Flush,
+			closer: r.Body.Close,
+			remote: r.RemoteAddr,
+		}
+		s.ServeCodec(newGRPCCodec(stream, r), 0)
+
+		// The codec is closed at this point, so nothing else writes to w.
+		w.Header().Set("grpc-status", "0")
+	})
+}
+
+// IsGRPCRequest reports whether the request is meant for a gRPC handler.
+func IsGRPCRequest(r *http.Request) bool {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("content-type"))
+	return err == nil && (mt == grpcContentType || mt == "application/grpc")
+}
+
+// DialGRPCWithClient creates a new RPC client that communicates with a JSON-RPC
+// server over a gRPC-compatible HTTP/2 stream, using the provided HTTP client.
+// The client's transport must speak HTTP/2, and it must not have a timeout set
+// because the stream lives as long as the RPC client. The given header is sent
+// along with the stream request.
+//
+// The context is used for the initial connection establishment. It does not
+// affect subsequent interactions with the client.
+func DialGRPCWithClient(ctx context.Context, endpoint string, client *http.Client, header http.Header) (*Client, error) {
+	endpoint, header, err := grpcClientHeaders(endpoint, header)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(ctx, func(ctx context.Context) (ServerCodec, error) {
+		return dialGRPCStream(ctx, client, endpoint, header)
+	})
+}
+
+// DialGRPC creates a new RPC client that communicates with a JSON-RPC server over
+// a gRPC-compatible HTTP/2 stream. Endpoints with the http or grpc scheme are
+// dialed using unencrypted HTTP/2 (h2c), https and grpcs use TLS.
+//
+// The context is used for the initial connection establishment. It does not
+// affect subsequent interactions with the client.
+func DialGRPC(ctx context.Context, endpoint string) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	transport := &http2.Transport{DisableCompression: true}
+	switch u.Scheme {
+	case "http", "grpc":
+		transport.AllowHTTP = true
+		transport.DialTLS = func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		}
+	case "https", "grpcs":
+	default:
+		return nil, fmt.Errorf("no known gRPC transport for URL scheme %q", u.Scheme)
+	}
+	return DialGRPCWithClient(ctx, endpoint, &http.Client{Transport: transport}, nil)
+}
+
+// grpcClientHeaders normalizes the endpoint URL, defaulting the path to the one of
+// the stream method and moving credentials into the header.
+func grpcClientHeaders(endpoint string, header http.Header) (string, http.Header, error) {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint, nil, err
+	}
+	switch endpointURL.Scheme {
+	case "grpc":
+		endpointURL.Scheme = "http"
+	case "grpcs":
+		endpointURL.Scheme = "https"
+	}
+	if endpointURL.Path == "" || endpointURL.Path == "/" {
+		endpointURL.Path = grpcStreamPath
+	}
+	if header == nil {
+		header = make(http.Header)
+	} else {
+		header = header.Clone()
+	}
+	header.Set("content-type", grpcContentType)
+	header.Set("te", "trailers")
+	if endpointURL.User != nil {
+		b64auth := base64.StdEncoding.EncodeToString([]byte(endpointURL.User.String()))
+		header.Set("authorization", "Basic "+b64auth)
+		endpointURL.User = nil
+	}
+	return endpointURL.String(), header, nil
+}
+
+// dialGRPCStream opens a stream to a gRPC handler.
+func dialGRPCStream(ctx context.Context, client *http.Client, endpoint string, header http.Header) (ServerCodec, error) {
+	// The stream must outlive the dial context, so only cancel it if dialing is
+	// aborted before the server responds. The request body is closed as well, the
+	// transport doesn't give up on the request while it's still being written.
+	streamCtx, cancel := context.WithCancel(context.Background())
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+			pw.CloseWithError(ctx.Err())
+		case <-done:
+		}
+	}()
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodPost, endpoint, pr)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header = header
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("content-type"), "application/grpc") {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		cancel()
+		return nil, HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
+	}
+	stream := &grpcStream{
+		reader: resp.Body,
+		writer: pw,
+		closer: func() error {
+			pw.Close()
+			resp.Body.Close()
+			cancel()
+			return nil
+		},
+		remote: endpoint,
+	}
+	return newGRPCCodec(stream, req), nil
+}
+
+// grpcStream frames JSON-RPC messages on the two directions of an HTTP/2 stream.
+type grpcStream struct {
+	reader io.Reader
+	writer io.Writer
+	flush  func() // pushes written frames out, nil if writes are unbuffered
+	closer func() error
+	remote string
+
+	mu     sync.Mutex // guards writer and closed
+	closed bool
+	header [grpcFrameHeaderSize]byte
+}
+
+// encode writes a message as a single uncompressed gRPC frame.
+func (s *grpcStream) encode(v interface{}) error {
+	blob, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Writing to a server stream is not allowed once the handler returned, so
+	// the closed flag is checked under the lock.
+	if s.closed {
+		return errGRPCClosed
+	}
+	s.header[0] = 0
+	binary.BigEndian.PutUint32(s.header[1:], uint32(len(blob)))
+	if _, err := s.writer.Write(s.header[:]); err != nil {
+		return err
+	}
+	if _, err := s.writer.Write(blob); err != nil {
+		return err
+	}
+	if s.flush != nil {
+		s.flush()
+	}
+	return nil
+}
+
+// decode reads the next gRPC frame and unmarshals the message it contains.
+func (s *grpcStream) decode(v interface{}) error {
+	var header [grpcFrameHeaderSize]byte
+	if _, err := io.ReadFull(s.reader, header[:]); err != nil {
+		return err
+	}
+	if header[0] != 0 {
+		return errGRPCCompressed
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > grpcMessageSizeLimit {
+		return fmt.Errorf("gRPC message too large (%d>%d)", size, grpcMessageSizeLimit)
+	}
+	blob := make([]byte, size)
+	if _, err := io.ReadFull(s.reader, blob); err != nil {
+		return err
+	}
+	return json.Unmarshal(blob, v)
+}
+
+// Close implements deadlineCloser.
+func (s *grpcStream) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return s.closer()
+}
+
+// SetWriteDeadline does nothing and always returns nil, HTTP/2 streams don't
+// support write deadlines.
+func (s *grpcStream) SetWriteDeadline(time.Time) error { return nil }
+
+// RemoteAddr returns the address of the other end of the stream.
+func (s *grpcStream) RemoteAddr() string { return s.remote }
+
+type grpcCodec struct {
+	*jsonCodec
+	info PeerInfo
+}
+
+func newGRPCCodec(stream *grpcStream, r *http.Request) ServerCodec {
+	codec := &grpcCodec{
+		jsonCodec: NewFuncCodec(stream, stream.encode, stream.decode).(*jsonCodec),
+		info: PeerInfo{
+			Transport:  "grpc",
+			RemoteAddr: stream.remote,
+		},
+	}
+	codec.info.HTTP.Version = r.Proto
+	codec.info.HTTP.Host = r.Host
+	codec.info.HTTP.Origin = r.Header.Get("Origin")
+	codec.info.HTTP.UserAgent = r.Header.Get("User-Agent")
+	codec.info.auth = r.Header.Get("Authorization")
+	return codec
+}
+
+func (c *grpcCodec) peerInfo() PeerInfo {
+	return c.info
+}
@@ -0,0 +1,175 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// newGRPCTestServer serves the test services over unencrypted HTTP/2.
+func newGRPCTestServer() (*Server, *httptest.Server) {
+	srv := newTestServer()
+	return srv, httptest.NewServer(h2c.NewHandler(srv.GRPCHandler(), new(http2.Server)))
+}
+
+func TestGRPCCall(t *testing.T) {
+	srv, ts := newGRPCTestServer()
+	defer srv.Stop()
+	defer ts.Close()
+
+	client, err := DialContext(context.Background(), "grpc:"+strings.TrimPrefix(ts.URL, "http:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var result echoResult
+	if err := client.Call(&result, "test_echo", "hello", 10, &echoArgs{"world"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := (echoResult{"hello", 10, &echoArgs{"world"}}); result.String != want.String || result.Int != want.Int || result.Args.S != want.Args.S {
+		t.Errorf("wrong result %+v, want %+v", result, want)
+	}
+	batch := []BatchElem{
+		{Method: "test_echo", Args: []interface{}{"a", 1}, Result: new(echoResult)},
+		{Method: "no_such_method", Result: new(echoResult)},
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatal(err)
+	}
+	if batch[0].Error != nil || batch[0].Result.(*echoResult).String != "a" {
+		t.Errorf("wrong batch result %+v, error %v", batch[0].Result, batch[0].Error)
+	}
+	if batch[1].Error == nil {
+		t.Errorf("missing error for unknown method")
+	}
+	var info PeerInfo
+	if err := client.Call(&info, "test_peerInfo"); err != nil {
+		t.Fatal(err)
+	}
+	if info.Transport != "grpc" || info.HTTP.Version != "HTTP/2.0" || info.RemoteAddr == "" {
+		t.Errorf("wrong peer info %+v", info)
+	}
+}
+
+func TestGRPCSubscription(t *testing.T) {
+	srv, ts := newGRPCTestServer()
+	defer srv.Stop()
+	defer ts.Close()
+
+	client, err := DialGRPC(context.Background(), ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var (
+		ch    = make(chan int)
+		count = 10
+	)
+	sub, err := client.Subscribe(context.Background(), "nftest", ch, "someSubscription", count, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	for i := 0; i < count; i++ {
+		select {
+		case v := <-ch:
+			if v != i {
+				t.Fatalf("wrong notification %d, want %d", v, i)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for notification %d", i)
+		}
+	}
+}
+
+// This test checks that streams outlive the read and write timeouts of the HTTP
+// server.
+func TestGRPCServerTimeouts(t *testing.T) {
+	const timeout = 500 * time.Millisecond
+	srv := newTestServer()
+	defer srv.Stop()
+	ts := httptest.NewUnstartedServer(h2c.NewHandler(srv.GRPCHandler(), new(http2.Server)))
+	ts.Config.ReadTimeout = timeout
+	ts.Config.WriteTimeout = timeout
+	ts.Start()
+	defer ts.Close()
+
+	client, err := DialGRPC(context.Background(), ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ch := make(chan int)
+	sub, err := client.Subscribe(context.Background(), "nftest", ch, "someSubscription", 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	select {
+	case <-ch:
+	case err := <-sub.Err():
+		t.Fatalf("subscription failed: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for notification")
+	}
+
+	// Wait until the timeouts have passed, the stream must still work.
+	time.Sleep(3 * timeout)
+	var result int
+	if err := client.Call(&result, "nftest_echo", 1); err != nil {
+		t.Fatal("call after server timeout failed:", err)
+	}
+	select {
+	case err := <-sub.Err():
+		t.Fatalf("subscription failed: %v", err)
+	default:
+	}
+}
+
+func TestGRPCRejectsHTTP1(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Stop()
+	ts := httptest.NewServer(srv.GRPCHandler())
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+grpcStreamPath, grpcContentType, strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	confirmStatusCode(t, resp.StatusCode, http.StatusHTTPVersionNotSupported)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := DialGRPC(ctx, ts.URL); err == nil {
+		t.Fatal("gRPC stream opened without HTTP/2 support on the server")
+	}
+}
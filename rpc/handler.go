@@ -19,12 +19,15 @@ package rpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/internal/tracing"
 	"github.com/ethereum/go-ethereum/log"
 )
 
@@ -61,17 +64,30 @@ type handler struct {
 	conn           jsonWriter                     // where responses will be sent
 	log            log.Logger
 	allowSubscribe bool
+	config         handlerConfig
+	identity       *clientIdentity // authenticated client, nil if unrestricted
+	client         string          // identity for rate limiting, empty if not throttled
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
 }
 
+// handlerConfig contains the settings of served connections, most notably the
+// bounds of the resources a connection may use.
+type handlerConfig struct {
+	batchItemLimit    int          // maximum number of calls in a batch, zero for unlimited
+	responseSizeLimit int          // maximum number of result bytes, zero for unlimited
+	limiter           *RateLimiter   // throttles the calls of remote clients, nil if disabled
+	access            *AccessControl // authorizes the calls of remote clients, nil if disabled
+	accessLog         bool           // log successful calls at info level
+}
+
 type callProc struct {
 	ctx       context.Context
 	notifiers []*Notifier
 }
 
-func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry) *handler {
+func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry, config handlerConfig) *handler {
 	rootCtx, cancelRoot := context.WithCancel(connCtx)
 	h := &handler{
 		reg:            reg,
@@ -84,10 +100,17 @@ func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *
 		allowSubscribe: true,
 		serverSubs:     make(map[ID]*Subscription),
 		log:            log.Root(),
+		config:         config,
 	}
 	if conn.remoteAddr() != "" {
 		h.log = h.log.New("conn", conn.remoteAddr())
 	}
+	if config.access != nil {
+		h.identity = config.access.identify(PeerInfoFromContext(connCtx))
+	}
+	if config.limiter != nil {
+		h.client = config.limiter.clientKey(PeerInfoFromContext(connCtx), h.identity)
+	}
 	h.unsubscribeCb = newCallback(reflect.Value{}, reflect.ValueOf(h.unsubscribe))
 	return h
 }
@@ -101,6 +124,14 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 		})
 		return
 	}
+	// Reject batches exceeding the item limit as a whole:
+	if limit := h.config.batchItemLimit; limit != 0 && len(msgs) > limit {
+		h.startCallProc(func(cp *callProc) {
+			err := &invalidRequestError{fmt.Sprintf("batch too large (limit %d items)", limit)}
+			h.conn.writeJSON(cp.ctx, errorMessage(err))
+		})
+		return
+	}
 
 	// Handle non-call messages first:
 	calls := make([]*jsonrpcMessage, 0, len(msgs))
@@ -114,10 +145,21 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 	}
 	// Process calls on a goroutine because they may block indefinitely:
 	h.startCallProc(func(cp *callProc) {
-		answers := make([]*jsonrpcMessage, 0, len(msgs))
+		var (
+			answers = make([]*jsonrpcMessage, 0, len(msgs))
+			size    int
+		)
 		for _, msg := range calls {
+			// Once the response limit is hit, don't bother executing the rest
+			if limit := h.config.responseSizeLimit; limit != 0 && size > limit {
+				if msg.isCall() {
+					answers = append(answers, msg.errorResponse(&responseTooLargeError{limit}))
+				}
+				continue
+			}
 			if answer := h.handleCallMsg(cp, msg); answer != nil {
-				answers = append(answers, answer)
+				size += len(answer.Result)
+				answers = append(answers, h.limitResponse(msg, answer, size))
 			}
 		}
 		h.addSubscriptions(cp.notifiers)
@@ -139,7 +181,7 @@ func (h *handler) handleMsg(msg *jsonrpcMessage) {
 		answer := h.handleCallMsg(cp, msg)
 		h.addSubscriptions(cp.notifiers)
 		if answer != nil {
-			h.conn.writeJSON(cp.ctx, answer)
+			h.conn.writeJSON(cp.ctx, h.limitResponse(msg, answer, len(answer.Result)))
 		}
 		for _, n := range cp.notifiers {
 			n.activate()
@@ -147,6 +189,17 @@ func (h *handler) handleMsg(msg *jsonrpcMessage) {
 	})
 }
 
+// limitResponse replaces the answer to a call with an error if the total size of
+// the response exceeds the limit. Subscription answers are kept as is, dropping
+// them would leave the subscription running without the client knowing about it.
+func (h *handler) limitResponse(msg *jsonrpcMessage, answer *jsonrpcMessage, size int) *jsonrpcMessage {
+	limit := h.config.responseSizeLimit
+	if limit == 0 || size <= limit || msg.isSubscribe() || answer.Error != nil {
+		return answer
+	}
+	return msg.errorResponse(&responseTooLargeError{limit})
+}
+
 // close cancels all requests except for inflightReq and waits for
 // call goroutines to shut down.
 func (h *handler) close(err error, inflightReq *requestOp) {
@@ -295,18 +348,20 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 		h.log.Debug("Served "+msg.Method, "duration", time.Since(start))
 		return nil
 	case msg.isCall():
+		// Calls are traced as children of the caller's span, if it sent one. The
+		// span is only visible to the call itself, not to other calls of a batch.
+		parent := ctx.ctx
+		var span *tracing.Span
+		ctx.ctx, span = tracing.StartServer(parent, msg.Method, "rpc.system", "jsonrpc", "rpc.method", msg.Method)
 		resp := h.handleCall(ctx, msg)
-		var ctx []interface{}
-		ctx = append(ctx, "reqid", idForLog{msg.ID}, "duration", time.Since(start))
+		ctx.ctx = parent
+
 		if resp.Error != nil {
-			ctx = append(ctx, "err", resp.Error.Message)
-			if resp.Error.Data != nil {
-				ctx = append(ctx, "errdata", resp.Error.Data)
-			}
-			h.log.Warn("Served "+msg.Method, ctx...)
-		} else {
-			h.log.Debug("Served "+msg.Method, ctx...)
+			span.SetAttributes("rpc.jsonrpc.error_code", resp.Error.Code)
+			span.SetError(errors.New(resp.Error.Message))
 		}
+		span.End()
+		h.logCall(msg, resp, time.Since(start), span)
 		return resp
 	case msg.hasValidID():
 		return msg.errorResponse(&invalidRequestError{"invalid request"})
@@ -315,8 +370,37 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 	}
 }
 
+// logCall writes the access log entry of a served call. Failed calls are logged
+// as warnings, successful ones at debug level unless access logging is enabled.
+func (h *handler) logCall(msg *jsonrpcMessage, resp *jsonrpcMessage, duration time.Duration, span *tracing.Span) {
+	ctx := []interface{}{"reqid", idForLog{msg.ID}, "method", msg.Method, "duration", duration, "size", len(resp.Result)}
+	if h.identity != nil && h.identity.name != "" {
+		ctx = append(ctx, "client", h.identity.name)
+	}
+	if span.Recording() {
+		ctx = append(ctx, "traceid", span.Context().TraceID)
+	}
+	if resp.Error != nil {
+		ctx = append(ctx, "code", resp.Error.Code, "err", resp.Error.Message)
+		if resp.Error.Data != nil {
+			ctx = append(ctx, "errdata", resp.Error.Data)
+		}
+		h.log.Warn("Served "+msg.Method, ctx...)
+	} else if h.config.accessLog {
+		h.log.Info("Served "+msg.Method, ctx...)
+	} else {
+		h.log.Debug("Served "+msg.Method, ctx...)
+	}
+}
+
 // handleCall processes method calls.
 func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
+	if err := h.authorize(msg); err != nil {
+		return msg.errorResponse(err)
+	}
+	if err := h.throttle(msg); err != nil {
+		return msg.errorResponse(err)
+	}
 	if msg.isSubscribe() {
 		return h.handleSubscribe(cp, msg)
 	}
@@ -351,6 +435,28 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 	return answer
 }
 
+// authorize checks the call against the permissions of the client. Unsubscribing
+// is always allowed.
+func (h *handler) authorize(msg *jsonrpcMessage) error {
+	if h.identity == nil || msg.isUnsubscribe() {
+		return nil
+	}
+	return h.identity.authorize(msg.Method)
+}
+
+// throttle charges a call to the rate limit of the client. Unsubscribing is free,
+// so that clients can always release their subscriptions.
+func (h *handler) throttle(msg *jsonrpcMessage) error {
+	if h.client == "" || msg.isUnsubscribe() {
+		return nil
+	}
+	if wait := h.config.limiter.take(h.client, msg.namespace(), h.identity); wait > 0 {
+		rateLimitedMeter.Mark(1)
+		return &rateLimitError{wait.Round(time.Millisecond)}
+	}
+	return nil
+}
+
 // handleSubscribe processes *_subscribe method calls.
 func (h *handler) handleSubscribe(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
 	if !h.allowSubscribe {
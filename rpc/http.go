@@ -29,6 +29,8 @@ import (
 	"net/url"
 	"sync"
 	"time"
+
+	"github.com/ethereum/go-ethereum/internal/tracing"
 )
 
 const (
@@ -186,6 +188,7 @@ func (hc *httpConn) doRequest(ctx context.Context, msg interface{}) (io.ReadClos
 	hc.mu.Lock()
 	req.Header = hc.headers.Clone()
 	hc.mu.Unlock()
+	tracing.Inject(ctx, req.Header)
 
 	// do request
 	resp, err := hc.client.Do(req)
@@ -250,9 +253,14 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	connInfo.HTTP.Host = r.Host
 	connInfo.HTTP.Origin = r.Header.Get("Origin")
 	connInfo.HTTP.UserAgent = r.Header.Get("User-Agent")
+	connInfo.auth = r.Header.Get("Authorization")
 	ctx := r.Context()
 	ctx = context.WithValue(ctx, peerInfoContextKey{}, connInfo)
 
+	// Trace the calls of the request as part of the caller's trace. Streaming
+	// connections don't do this, their calls are unrelated to the opening request.
+	ctx = tracing.Extract(ctx, r.Header)
+
 	// All checks passed, create a codec that reads directly from the request body
 	// until EOF, writes the response to w, and orders the server to process a
 	// single request.
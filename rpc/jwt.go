@@ -0,0 +1,102 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	errJWTMalformed = errors.New("malformed token")
+	errJWTAlgorithm = errors.New("unsupported token algorithm")
+	errJWTSignature = errors.New("invalid token signature")
+	errJWTExpired   = errors.New("token expired")
+	errJWTNotValid  = errors.New("token not valid yet")
+)
+
+// jwtClaims are the claims of a JSON web token the server cares about.
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Expiry    *int64   `json:"exp"`
+	NotBefore *int64   `json:"nbf"`
+	Allow     []string `json:"allow"` // Allowed namespaces and methods, see Credential
+}
+
+// bearerToken extracts the token of a bearer authorization header.
+func bearerToken(header string) string {
+	const prefix = "Bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
+
+// parseJWT verifies a HS256 signed JSON web token with the given secret and
+// returns its claims if the token is valid at the given time.
+func parseJWT(secret []byte, token string, now time.Time) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errJWTMalformed
+	}
+	var header struct {
+		Algorithm string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Algorithm != "HS256" {
+		return nil, errJWTAlgorithm
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errJWTMalformed
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errJWTSignature
+	}
+	claims := new(jwtClaims)
+	if err := decodeJWTPart(parts[1], claims); err != nil {
+		return nil, err
+	}
+	if claims.Expiry != nil && now.Unix() >= *claims.Expiry {
+		return nil, errJWTExpired
+	}
+	if claims.NotBefore != nil && now.Unix() < *claims.NotBefore {
+		return nil, errJWTNotValid
+	}
+	return claims, nil
+}
+
+// decodeJWTPart decodes a base64url encoded JSON segment of a token.
+func decodeJWTPart(part string, v interface{}) error {
+	blob, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errJWTMalformed
+	}
+	if err := json.Unmarshal(blob, v); err != nil {
+		return errJWTMalformed
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+)
+
+// rateLimitSweepInterval is the interval at which the buckets of idle clients
+// are dropped.
+const rateLimitSweepInterval = time.Minute
+
+// RateLimitConfig configures the request throttling of RPC servers.
+type RateLimitConfig struct {
+	Rate  float64            // Tokens credited to each client per second, zero for no limit
+	Burst float64            // Maximum number of tokens a client can accumulate
+	Costs map[string]float64 // Tokens charged per call by namespace, 1 if not listed
+}
+
+// RateLimiter throttles the calls of remote clients with a token bucket per
+// client. Clients which authenticated through the access control are identified
+// by their credentials and may have individual limits, others are identified by
+// their IP address. Calls over IPC and in-process connections are never throttled.
+//
+// A single limiter can be shared by multiple servers, in which case the calls
+// of a client are accounted together across all of them.
+type RateLimiter struct {
+	config RateLimitConfig
+	clock  mclock.Clock
+
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   mclock.AbsTime
+}
+
+// tokenBucket is the request budget of a single client.
+type tokenBucket struct {
+	tokens  float64        // Tokens available at the time of the last update
+	rate    float64        // Tokens credited per second
+	burst   float64        // Maximum number of tokens
+	updated mclock.AbsTime // Time of the last update
+}
+
+// NewRateLimiter creates a rate limiter with the given configuration.
+func NewRateLimiter(config RateLimitConfig) (*RateLimiter, error) {
+	return newRateLimiter(config, mclock.System{})
+}
+
+func newRateLimiter(config RateLimitConfig, clock mclock.Clock) (*RateLimiter, error) {
+	if config.Rate < 0 {
+		return nil, fmt.Errorf("invalid rate limit %v", config.Rate)
+	}
+	if config.Rate > 0 && config.Burst < 1 {
+		return nil, fmt.Errorf("invalid rate limit burst %v", config.Burst)
+	}
+	for namespace, cost := range config.Costs {
+		if cost < 0 || (config.Rate > 0 && cost > config.Burst) {
+			return nil, fmt.Errorf("invalid cost %v for namespace %q, must be between 0 and the burst %v", cost, namespace, config.Burst)
+		}
+	}
+	return &RateLimiter{
+		config:  config,
+		clock:   clock,
+		buckets: make(map[string]*tokenBucket),
+		swept:   clock.Now(),
+	}, nil
+}
+
+// clientKey returns the identity the calls of the given connection are accounted
+// to, or the empty string if they are not throttled.
+func (l *RateLimiter) clientKey(info PeerInfo, id *clientIdentity) string {
+	switch info.Transport {
+	case "http", "ws", "grpc":
+	default:
+		return ""
+	}
+	if id != nil && id.name != "" {
+		return id.name
+	}
+	host, _, err := net.SplitHostPort(info.RemoteAddr)
+	if err != nil {
+		host = info.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// take charges a call in the given namespace to the bucket of a client, using the
+// limits of its identity if it has any. If the client cannot afford the call, the
+// time until it can is returned. Calls costing more than the burst of a client
+// require a full bucket.
+func (l *RateLimiter) take(client string, namespace string, id *clientIdentity) time.Duration {
+	rate, burst := l.config.Rate, l.config.Burst
+	if id != nil && id.rate > 0 {
+		rate, burst = id.rate, id.burst
+	}
+	if rate == 0 {
+		return 0
+	}
+	cost, ok := l.config.Costs[namespace]
+	if !ok {
+		cost = 1
+	}
+	if cost > burst {
+		cost = burst
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.clock.Now()
+	if time.Duration(now-l.swept) >= rateLimitSweepInterval {
+		l.sweep(now)
+	}
+	bucket := l.buckets[client]
+	if bucket == nil {
+		bucket = &tokenBucket{tokens: burst, rate: rate, burst: burst, updated: now}
+		l.buckets[client] = bucket
+	}
+	bucket.rate, bucket.burst = rate, burst
+	bucket.tokens = bucket.refill(now)
+	bucket.updated = now
+
+	if bucket.tokens < cost {
+		missing := (cost - bucket.tokens) / rate
+		return time.Duration(missing * float64(time.Second))
+	}
+	bucket.tokens -= cost
+	return 0
+}
+
+// refill returns the tokens available in the bucket at the given time.
+func (b *tokenBucket) refill(now mclock.AbsTime) float64 {
+	tokens := b.tokens + time.Duration(now-b.updated).Seconds()*b.rate
+	if tokens > b.burst {
+		tokens = b.burst
+	}
+	return tokens
+}
+
+// sweep drops the buckets which have been refilled completely, since they are
+// indistinguishable from the ones of new clients.
+func (l *RateLimiter) sweep(now mclock.AbsTime) {
+	for client, bucket := range l.buckets {
+		if bucket.refill(now) >= bucket.burst {
+			delete(l.buckets, client)
+		}
+	}
+	l.swept = now
+}
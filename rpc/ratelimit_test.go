@@ -0,0 +1,137 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+)
+
+// signTestJWT creates a HS256 token with the given claims.
+func signTestJWT(secret []byte, claims string) string {
+	var (
+		header  = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+		payload = base64.RawURLEncoding.EncodeToString([]byte(claims))
+		mac     = hmac.New(sha256.New, secret)
+	)
+	mac.Write([]byte(header + "." + payload))
+	return header + "." + payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestRateLimiterBuckets(t *testing.T) {
+	clock := new(mclock.Simulated)
+	limiter, err := newRateLimiter(RateLimitConfig{
+		Rate:  1,
+		Burst: 3,
+		Costs: map[string]float64{"debug": 2, "free": 0},
+	}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		advance   time.Duration
+		client    string
+		namespace string
+		wait      time.Duration
+	}{
+		{0, "a", "eth", 0},
+		{0, "a", "debug", 0},
+		{0, "a", "eth", time.Second}, // bucket empty
+		{0, "a", "free", 0},          // free calls always pass
+		{0, "b", "debug", 0},         // separate bucket
+		{500 * time.Millisecond, "a", "debug", 1500 * time.Millisecond},
+		{1500 * time.Millisecond, "a", "debug", 0},
+		{10 * time.Second, "a", "eth", 0}, // refill is capped at the burst
+		{0, "a", "debug", 0},
+		{0, "a", "eth", time.Second},
+	}
+	for i, step := range steps {
+		clock.Run(step.advance)
+		if wait := limiter.take(step.client, step.namespace, nil); wait != step.wait {
+			t.Errorf("step %d: wait mismatch: have %v, want %v", i, wait, step.wait)
+		}
+	}
+	// Idle clients are dropped once their bucket is full again
+	clock.Run(rateLimitSweepInterval)
+	limiter.take("c", "eth", nil)
+	if len(limiter.buckets) != 1 {
+		t.Errorf("idle buckets not swept: %d left", len(limiter.buckets))
+	}
+	if _, err := newRateLimiter(RateLimitConfig{Rate: 1, Burst: 3, Costs: map[string]float64{"debug": 4}}, clock); err == nil {
+		t.Errorf("unaffordable cost accepted")
+	}
+}
+
+func TestRateLimitedHTTP(t *testing.T) {
+	secret := []byte("secret")
+	limiter, err := NewRateLimiter(RateLimitConfig{Rate: 0.001, Burst: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	access, err := NewAccessControl(AccessConfig{
+		JWTSecret:   secret,
+		Credentials: []Credential{{Name: "bulk", APIKey: "bulk-key", Allow: []string{"*"}, Rate: 0.001, Burst: 4}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer()
+	server.SetRateLimiter(limiter)
+	server.SetAccessControl(access)
+	defer server.Stop()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	call := func(auth string) error {
+		client, err := DialHTTP(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		if auth != "" {
+			client.SetHeader("Authorization", "Bearer "+auth)
+		}
+		return client.Call(nil, "test_noArgsRets")
+	}
+	// Each client can make as many calls as its burst allows
+	tests := []struct {
+		auth  string
+		burst int
+	}{
+		{"", 2}, // anonymous, accounted to the address
+		{signTestJWT(secret, `{"sub":"alice"}`), 2}, // token subject
+		{signTestJWT(secret, `{"sub":"bob"}`), 2},   // another token subject
+		{"bulk-key", 4}, // credential with its own limit
+	}
+	for i, test := range tests {
+		for j := 0; j < test.burst; j++ {
+			if err := call(test.auth); err != nil {
+				t.Fatalf("client %d call %d failed: %v", i, j, err)
+			}
+		}
+		err := call(test.auth)
+		if rerr, ok := err.(Error); !ok || rerr.ErrorCode() != errcodeLimitExceeded {
+			t.Fatalf("client %d not throttled: %v", i, err)
+		}
+	}
+}
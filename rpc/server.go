@@ -46,6 +46,7 @@ type Server struct {
 	idgen    func() ID
 	run      int32
 	codecs   mapset.Set
+	config   handlerConfig
 }
 
 // NewServer creates a new server instance with no registered handlers.
@@ -66,6 +67,45 @@ func (s *Server) RegisterName(name string, receiver interface{}) error {
 	return s.services.registerName(name, receiver)
 }
 
+// SetBatchLimits sets limits applied to batch requests and responses. The item
+// limit is the maximum number of calls in a batch, the response size limit is the
+// maximum number of result bytes across all calls of a batch, or of a single call.
+// Zero disables the respective limit.
+//
+// This method should be called before processing any requests via ServeCodec,
+// ServeHTTP, ServeListener etc.
+func (s *Server) SetBatchLimits(itemLimit, maxResponseSize int) {
+	s.config.batchItemLimit = itemLimit
+	s.config.responseSizeLimit = maxResponseSize
+}
+
+// SetRateLimiter sets the limiter throttling the calls of remote clients, nil
+// disables throttling.
+//
+// This method should be called before processing any requests via ServeCodec,
+// ServeHTTP, ServeListener etc.
+func (s *Server) SetRateLimiter(limiter *RateLimiter) {
+	s.config.limiter = limiter
+}
+
+// SetAccessControl sets the access control authorizing the calls of remote
+// clients, nil allows all calls.
+//
+// This method should be called before processing any requests via ServeCodec,
+// ServeHTTP, ServeListener etc.
+func (s *Server) SetAccessControl(access *AccessControl) {
+	s.config.access = access
+}
+
+// SetAccessLog enables logging every served call at info level, including its
+// duration, response size and error code. Failed calls are always logged.
+//
+// This method should be called before processing any requests via ServeCodec,
+// ServeHTTP, ServeListener etc.
+func (s *Server) SetAccessLog(enabled bool) {
+	s.config.accessLog = enabled
+}
+
 // ServeCodec reads incoming requests from codec, calls the appropriate callback and writes
 // the response back using the given codec. It will block until the codec is closed or the
 // server is stopped. In either case the codec is closed.
@@ -83,7 +123,7 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	s.codecs.Add(codec)
 	defer s.codecs.Remove(codec)
 
-	c := initClient(codec, s.idgen, &s.services)
+	c := initClient(codec, s.idgen, &s.services, s.config)
 	<-codec.closed()
 	c.Close()
 }
@@ -97,7 +137,7 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 		return
 	}
 
-	h := newHandler(ctx, codec, s.idgen, &s.services)
+	h := newHandler(ctx, codec, s.idgen, &s.services, s.config)
 	h.allowSubscribe = false
 	defer h.close(io.EOF, nil)
 
@@ -168,6 +208,10 @@ type PeerInfo struct {
 		Origin    string
 		Host      string
 	}
+
+	// Authorization header sent by the client, used to identify it when
+	// throttling requests.
+	auth string
 }
 
 type peerInfoContextKey struct{}
@@ -150,3 +150,51 @@ func TestServerShortLivedConn(t *testing.T) {
 		}
 	}
 }
+
+func TestServerBatchLimits(t *testing.T) {
+	server := newTestServer()
+	server.SetBatchLimits(3, 60)
+	defer server.Stop()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewCodec(serverConn), 0)
+
+	tests := []struct {
+		request, response string
+	}{
+		// Batches over the item limit are rejected as a whole.
+		{
+			`[{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]},{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["x",2]},{"jsonrpc":"2.0","id":3,"method":"test_echo","params":["x",3]},{"jsonrpc":"2.0","id":4,"method":"test_echo","params":["x",4]}]`,
+			`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"batch too large (limit 3 items)"}}`,
+		},
+		// Calls past the response size limit fail.
+		{
+			`[{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]},{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["x",2]},{"jsonrpc":"2.0","id":3,"method":"test_echo","params":["x",3]}]`,
+			`[{"jsonrpc":"2.0","id":1,"result":{"String":"x","Int":1,"Args":null}},{"jsonrpc":"2.0","id":2,"error":{"code":-32005,"message":"response too large (limit 60 bytes)"}},{"jsonrpc":"2.0","id":3,"error":{"code":-32005,"message":"response too large (limit 60 bytes)"}}]`,
+		},
+		// The response size limit also applies to single calls.
+		{
+			`{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["` + strings.Repeat("x", 60) + `",1]}`,
+			`{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"response too large (limit 60 bytes)"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]}`,
+			`{"jsonrpc":"2.0","id":1,"result":{"String":"x","Int":1,"Args":null}}`,
+		},
+	}
+	readbuf := bufio.NewReader(clientConn)
+	for i, test := range tests {
+		clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+		if _, err := io.WriteString(clientConn, test.request+"\n"); err != nil {
+			t.Fatalf("test %d: write error: %v", i, err)
+		}
+		resp, err := readbuf.ReadString('\n')
+		if err != nil {
+			t.Fatalf("test %d: read error: %v", i, err)
+		}
+		if resp = strings.TrimRight(resp, "\r\n"); resp != test.response {
+			t.Errorf("test %d: wrong response\ngot:  %s\nwant: %s", i, resp, test.response)
+		}
+	}
+}
@@ -65,6 +65,14 @@ func (s *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 	})
 }
 
+// WebsocketOriginValidator returns a function that verifies the origin of a
+// websocket upgrade request against the allowed origins, applying the same rules
+// as the RPC websocket endpoint. It can be used as the CheckOrigin function of a
+// websocket.Upgrader.
+func WebsocketOriginValidator(allowedOrigins []string) func(*http.Request) bool {
+	return wsHandshakeValidator(allowedOrigins)
+}
+
 // wsHandshakeValidator returns a handler that verifies the origin during the
 // websocket upgrade process. When a '*' is specified as an allowed origins all
 // connections are accepted.
@@ -260,6 +268,7 @@ func newWebsocketCodec(conn *websocket.Conn, host string, req http.Header) Serve
 	wc.info.HTTP.Host = host
 	wc.info.HTTP.Origin = req.Get("Origin")
 	wc.info.HTTP.UserAgent = req.Get("User-Agent")
+	wc.info.auth = req.Get("Authorization")
 	// Start pinger.
 	wc.wg.Add(1)
 	go wc.pingLoop()
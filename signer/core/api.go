@@ -44,7 +44,7 @@ const (
 	// ExternalAPIVersion -- see extapi_changelog.md
 	ExternalAPIVersion = "6.1.0"
 	// InternalAPIVersion -- see intapi_changelog.md
-	InternalAPIVersion = "7.0.1"
+	InternalAPIVersion = "7.1.0"
 )
 
 // ExternalAPI defines the external API through which signing requests are made.
@@ -96,6 +96,21 @@ type UIClientAPI interface {
 	RegisterUIServer(api *UIServerAPI)
 }
 
+// TxFailureHandler can be implemented by a UI that needs to know when a transaction
+// it approved is not signed after all, because a later stage rejected it or
+// signing failed. This lets the UI release anything it reserved on approval.
+type TxFailureHandler interface {
+	// OnFailedTx is called with the request the UI approved.
+	OnFailedTx(request *SignTxRequest)
+}
+
+// NotifyFailedTx calls OnFailedTx if the UI implements TxFailureHandler.
+func NotifyFailedTx(ui UIClientAPI, request *SignTxRequest) {
+	if handler, ok := ui.(TxFailureHandler); ok {
+		handler.OnFailedTx(request)
+	}
+}
+
 // Validator defines the methods required to validate a transaction against some
 // sanity defaults as well as any underlying 4byte method database.
 //
@@ -117,6 +132,7 @@ type SignerAPI struct {
 	validator   Validator
 	rejectMode  bool
 	credentials storage.Storage
+	simulator   Simulator
 }
 
 // Metadata about a request
@@ -225,6 +241,7 @@ type (
 	SignTxRequest struct {
 		Transaction apitypes.SendTxArgs       `json:"transaction"`
 		Callinfo    []apitypes.ValidationInfo `json:"call_info"`
+		Simulation  *Simulation               `json:"simulation,omitempty"`
 		Meta        Metadata                  `json:"meta"`
 	}
 	// SignTxResponse result from SignTxRequest
@@ -285,7 +302,7 @@ func NewSignerAPI(am *accounts.Manager, chainID int64, noUSB bool, ui UIClientAP
 	if advancedMode {
 		log.Info("Clef is in advanced mode: will warn instead of reject")
 	}
-	signer := &SignerAPI{big.NewInt(chainID), am, ui, validator, !advancedMode, credentials}
+	signer := &SignerAPI{big.NewInt(chainID), am, ui, validator, !advancedMode, credentials, nil}
 	if !noUSB {
 		signer.startUSBListener()
 	}
@@ -568,6 +585,8 @@ func (api *SignerAPI) SignTransaction(ctx context.Context, args apitypes.SendTxA
 		Meta:        MetadataFromContext(ctx),
 		Callinfo:    msgs.Messages,
 	}
+	api.simulate(ctx, &req)
+
 	// Process approval
 	result, err = api.UI.ApproveTx(&req)
 	if err != nil {
@@ -576,6 +595,13 @@ func (api *SignerAPI) SignTransaction(ctx context.Context, args apitypes.SendTxA
 	if !result.Approved {
 		return nil, ErrRequestDenied
 	}
+	// Tell the UI if the approved transaction doesn't get signed
+	signed := false
+	defer func() {
+		if !signed {
+			NotifyFailedTx(api.UI, &req)
+		}
+	}()
 	// Log changes made by the UI to the signing-request
 	logDiff(&req, &result)
 	var (
@@ -609,6 +635,7 @@ func (api *SignerAPI) SignTransaction(ctx context.Context, args apitypes.SendTxA
 	response := ethapi.SignTransactionResult{Raw: data, Tx: signedTx}
 
 	// Finally, send the signed tx to the UI
+	signed = true
 	api.UI.OnApprovedTx(response)
 	// ...and to the external caller
 	return &response, nil
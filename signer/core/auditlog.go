@@ -113,6 +113,12 @@ func (l *AuditLogger) Version(ctx context.Context) (string, error) {
 
 }
 
+// Log returns the logger writing to the audit log, so that decisions taken
+// outside of the external API (e.g. quorum votes) can be recorded too.
+func (l *AuditLogger) Log() log.Logger {
+	return l.log
+}
+
 func NewAuditLogger(path string, api ExternalAPI) (*AuditLogger, error) {
 	l := log.New("api", "signer")
 	handler, err := log.FileHandler(path, log.LogfmtFormat())
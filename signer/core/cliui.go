@@ -24,6 +24,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/console/prompt"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
@@ -93,6 +94,31 @@ func sanitize(txt string, limit int) string {
 	return fmt.Sprintf("%q", txt)
 }
 
+// showSimulation prints the predicted effects of a transaction.
+func showSimulation(sim *Simulation) {
+	fmt.Printf("Simulation:\n")
+	if sim.Reverted {
+		fmt.Printf("  REVERTED: %s\n", sim.RevertReason)
+		return
+	}
+	fmt.Printf("  gas used: %d\n", uint64(sim.GasUsed))
+	asset := func(token *common.Address) string {
+		if token == nil {
+			return "wei"
+		}
+		return "of token " + token.Hex()
+	}
+	for _, t := range sim.Transfers {
+		fmt.Printf("  transfer: %v %s from %v to %v\n", t.Value.ToInt(), asset(t.Token), t.From.Hex(), t.To.Hex())
+	}
+	for _, a := range sim.Approvals {
+		fmt.Printf("  approval: %v %s by %v to %v\n", a.Value.ToInt(), asset(&a.Token), a.Owner.Hex(), a.Spender.Hex())
+	}
+	for _, b := range sim.BalanceDeltas {
+		fmt.Printf("  balance:  %v %+d %s\n", b.Account.Hex(), b.Delta.ToInt(), asset(b.Token))
+	}
+}
+
 func showMetadata(metadata Metadata) {
 	fmt.Printf("Request context:\n\t%v -> %v -> %v\n", metadata.Remote, metadata.Scheme, metadata.Local)
 	fmt.Printf("\nAdditional HTTP header data, provided by the external caller:\n")
@@ -149,6 +175,9 @@ func (ui *CommandlineUI) ApproveTx(request *SignTxRequest) (SignTxResponse, erro
 		fmt.Println()
 
 	}
+	if request.Simulation != nil {
+		showSimulation(request.Simulation)
+	}
 	fmt.Printf("\n")
 	showMetadata(request.Meta)
 	fmt.Printf("-------------------------------------------\n")
@@ -0,0 +1,500 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/signer/storage"
+)
+
+// quorumStorageKey is the storage key under which pending requests are kept.
+const quorumStorageKey = "quorum_pending"
+
+var (
+	// ErrUnknownRequest is returned when voting on a request that is not pending.
+	ErrUnknownRequest = errors.New("unknown or expired request")
+
+	// ErrNotApprover is returned when a vote is not signed by a registered approver.
+	ErrNotApprover = errors.New("vote not signed by an approver")
+
+	// ErrAlreadyVoted is returned when an approver votes twice on the same request.
+	ErrAlreadyVoted = errors.New("approver already voted")
+)
+
+// QuorumConfig contains the settings of an M-of-N approval UI.
+type QuorumConfig struct {
+	Approvers []common.Address // Addresses whose signed votes are accepted
+	Threshold int              // Number of approvals needed to grant a request
+	Timeout   time.Duration    // Time after which a pending request is rejected
+}
+
+// QuorumRequest is a request waiting for the votes of the approvers.
+//
+// The ID commits to the content being approved, see QuorumRequestID, so a vote on
+// the ID can't be used to approve anything else.
+type QuorumRequest struct {
+	ID         common.Hash      `json:"id"`
+	Kind       string           `json:"kind"`    // One of tx, signdata, listing or newaccount
+	Nonce      common.Hash      `json:"nonce"`   // Random salt, so votes can't be replayed on a later request
+	Content    json.RawMessage  `json:"content"` // Content approved by the vote, without metadata
+	Request    json.RawMessage  `json:"request"` // Request as it would be shown to a single operator
+	Created    time.Time        `json:"created"`
+	Deadline   time.Time        `json:"deadline"`
+	Approvals  []common.Address `json:"approvals"`
+	Rejections []common.Address `json:"rejections"`
+}
+
+// pendingRequest is a QuorumRequest along with the state of the callers waiting
+// for its outcome.
+type pendingRequest struct {
+	QuorumRequest
+	updated  chan struct{} // Closed and replaced whenever a vote arrives
+	decided  bool
+	approved bool
+}
+
+// QuorumUI is a UI which holds each approval request until M of N registered
+// approvers signed off on it. Approvers cast votes by signing them with their
+// own keys. Pending requests are persisted, and votes on them survive a restart:
+// if the same request is resubmitted before its deadline, the collected votes
+// still count.
+//
+// Requests are first passed to a filter, usually the rule engines. The filter
+// can reject requests or narrow them down, but requests it approves still need
+// the votes. Everything apart from the approvals is forwarded to the filter,
+// which ends in the UI of the local operator.
+type QuorumUI struct {
+	next    UIClientAPI
+	filter  UIClientAPI
+	storage storage.Storage
+	config  QuorumConfig
+	audit   log.Logger
+
+	pending map[common.Hash]*pendingRequest
+	lock    sync.Mutex
+}
+
+// NewQuorumUI creates an M-of-N approval UI, restoring the pending requests
+// from the storage.
+func NewQuorumUI(next UIClientAPI, storage storage.Storage, config QuorumConfig) (*QuorumUI, error) {
+	if config.Threshold < 1 || config.Threshold > len(config.Approvers) {
+		return nil, fmt.Errorf("invalid approval threshold %d of %d approvers", config.Threshold, len(config.Approvers))
+	}
+	if config.Timeout <= 0 {
+		return nil, fmt.Errorf("invalid approval timeout %v", config.Timeout)
+	}
+	ui := &QuorumUI{
+		next:    next,
+		filter:  quorumPassUI{next},
+		storage: storage,
+		config:  config,
+		audit:   log.New("ui", "quorum"),
+		pending: make(map[common.Hash]*pendingRequest),
+	}
+	if blob, err := storage.Get(quorumStorageKey); err == nil {
+		var requests []QuorumRequest
+		if err := json.Unmarshal([]byte(blob), &requests); err != nil {
+			return nil, fmt.Errorf("corrupt pending requests: %v", err)
+		}
+		for _, req := range requests {
+			if req.ID != QuorumRequestID(req.Kind, req.Content, req.Nonce) {
+				return nil, fmt.Errorf("corrupt pending request %x", req.ID)
+			}
+			if time.Now().After(req.Deadline) {
+				ui.audit.Info("QuorumResult", "type", "timeout", "id", req.ID, "kind", req.Kind)
+				continue
+			}
+			ui.pending[req.ID] = &pendingRequest{QuorumRequest: req, updated: make(chan struct{})}
+		}
+		ui.persist()
+	}
+	return ui, nil
+}
+
+// Filter returns the UI which rule engines configured alongside the quorum should
+// wrap. It approves every request reaching it, leaving the decision to the
+// approvers, and forwards everything else to the operator UI.
+func (ui *QuorumUI) Filter() UIClientAPI {
+	return ui.filter
+}
+
+// SetFilter sets the UI consulted before a request is put to vote, usually a
+// chain of rule engines wrapping Filter. Requests rejected by it are rejected
+// without a vote.
+func (ui *QuorumUI) SetFilter(filter UIClientAPI) {
+	ui.filter = filter
+}
+
+// SetAuditLog sets the logger recording requests, votes and outcomes, usually
+// the one of the AuditLogger.
+func (ui *QuorumUI) SetAuditLog(logger log.Logger) {
+	ui.lock.Lock()
+	defer ui.lock.Unlock()
+
+	ui.audit = logger
+}
+
+// persist writes the pending requests to the storage. The caller must hold the lock.
+func (ui *QuorumUI) persist() {
+	requests := make([]QuorumRequest, 0, len(ui.pending))
+	for _, req := range ui.pending {
+		requests = append(requests, req.QuorumRequest)
+	}
+	blob, err := json.Marshal(requests)
+	if err != nil {
+		log.Error("Failed to encode pending requests", "err", err)
+		return
+	}
+	ui.storage.Put(quorumStorageKey, string(blob))
+}
+
+// open returns the pending request with the given content, creating it if it
+// doesn't exist yet.
+func (ui *QuorumUI) open(kind string, content interface{}, request interface{}) (*pendingRequest, error) {
+	contentBlob, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	requestBlob, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	ui.lock.Lock()
+	defer ui.lock.Unlock()
+
+	for _, req := range ui.pending {
+		if req.Kind == kind && bytes.Equal(req.Content, contentBlob) && !req.decided {
+			ui.audit.Info("QuorumRequest", "type", "resumed", "id", req.ID, "kind", kind, "approvals", len(req.Approvals), "rejections", len(req.Rejections))
+			return req, nil
+		}
+	}
+	var nonce common.Hash
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	id := QuorumRequestID(kind, contentBlob, nonce)
+	now := time.Now()
+	req := &pendingRequest{
+		QuorumRequest: QuorumRequest{
+			ID:       id,
+			Kind:     kind,
+			Nonce:    nonce,
+			Content:  contentBlob,
+			Request:  requestBlob,
+			Created:  now,
+			Deadline: now.Add(ui.config.Timeout),
+		},
+		updated: make(chan struct{}),
+	}
+	ui.pending[id] = req
+	ui.persist()
+
+	ui.audit.Info("QuorumRequest", "type", "pending", "id", id, "kind", kind, "request", string(requestBlob), "deadline", req.Deadline)
+	ui.next.ShowInfo(fmt.Sprintf("Request %x (%s) is waiting for %d of %d approvals", id, kind, ui.config.Threshold, len(ui.config.Approvers)))
+	return req, nil
+}
+
+// decide concludes a pending request. The caller must hold the lock.
+func (ui *QuorumUI) decide(req *pendingRequest, approved bool, reason string) {
+	req.decided, req.approved = true, approved
+	delete(ui.pending, req.ID)
+	ui.persist()
+
+	ui.audit.Info("QuorumResult", "type", reason, "id", req.ID, "kind", req.Kind, "approved", approved, "approvals", req.Approvals, "rejections", req.Rejections)
+}
+
+// tally concludes a pending request if enough votes were cast. The caller must
+// hold the lock.
+func (ui *QuorumUI) tally(req *pendingRequest) {
+	switch {
+	case len(req.Approvals) >= ui.config.Threshold:
+		ui.decide(req, true, "approved")
+	case len(req.Rejections) > len(ui.config.Approvers)-ui.config.Threshold:
+		ui.decide(req, false, "rejected")
+	}
+}
+
+// await blocks until the request described by content gathered enough votes,
+// or until its deadline passed.
+func (ui *QuorumUI) await(kind string, content interface{}, request interface{}) (bool, error) {
+	req, err := ui.open(kind, content, request)
+	if err != nil {
+		return false, err
+	}
+	timer := time.NewTimer(time.Until(req.Deadline))
+	defer timer.Stop()
+
+	for {
+		ui.lock.Lock()
+		if !req.decided {
+			ui.tally(req)
+		}
+		if req.decided {
+			ui.lock.Unlock()
+			return req.approved, nil
+		}
+		updated := req.updated
+		ui.lock.Unlock()
+
+		select {
+		case <-updated:
+		case <-timer.C:
+			ui.lock.Lock()
+			if !req.decided {
+				ui.decide(req, false, "timeout")
+			}
+			ui.lock.Unlock()
+			return req.approved, nil
+		}
+	}
+}
+
+// Pending returns the requests waiting for votes.
+func (ui *QuorumUI) Pending() []QuorumRequest {
+	ui.lock.Lock()
+	defer ui.lock.Unlock()
+
+	requests := make([]QuorumRequest, 0, len(ui.pending))
+	for _, req := range ui.pending {
+		requests = append(requests, req.QuorumRequest)
+	}
+	return requests
+}
+
+// QuorumRequestID returns the identifier of a request, keccak256(kind || content ||
+// nonce), which approvers should recompute from the pending request before
+// signing a vote on it.
+func QuorumRequestID(kind string, content []byte, nonce common.Hash) common.Hash {
+	return crypto.Keccak256Hash([]byte(kind), content, nonce[:])
+}
+
+// QuorumVoteHash returns the hash an approver needs to sign to vote on a request.
+// It is the personal_sign hash of "clef quorum <approve|reject> <id>".
+func QuorumVoteHash(id common.Hash, approve bool) []byte {
+	decision := "reject"
+	if approve {
+		decision = "approve"
+	}
+	return accounts.TextHash([]byte(fmt.Sprintf("clef quorum %s %x", decision, id)))
+}
+
+// Vote records the decision of an approver on a pending request. The signature
+// must be made over QuorumVoteHash, with a V value of 27 or 28.
+func (ui *QuorumUI) Vote(id common.Hash, approve bool, signature []byte) error {
+	if len(signature) != crypto.SignatureLength {
+		return fmt.Errorf("signature must be %d bytes long", crypto.SignatureLength)
+	}
+	sig := common.CopyBytes(signature)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(QuorumVoteHash(id, approve), sig)
+	if err != nil {
+		return err
+	}
+	approver := crypto.PubkeyToAddress(*pub)
+
+	ui.lock.Lock()
+	defer ui.lock.Unlock()
+
+	if !containsAddress(ui.config.Approvers, approver) {
+		ui.audit.Info("QuorumVote", "type", "unauthorized", "id", id, "signer", approver)
+		return ErrNotApprover
+	}
+	req, ok := ui.pending[id]
+	if !ok || time.Now().After(req.Deadline) {
+		return ErrUnknownRequest
+	}
+	if containsAddress(req.Approvals, approver) || containsAddress(req.Rejections, approver) {
+		return ErrAlreadyVoted
+	}
+	if approve {
+		req.Approvals = append(req.Approvals, approver)
+	} else {
+		req.Rejections = append(req.Rejections, approver)
+	}
+	ui.persist()
+	ui.audit.Info("QuorumVote", "type", "vote", "id", id, "approver", approver, "approve", approve)
+
+	close(req.updated)
+	req.updated = make(chan struct{})
+	return nil
+}
+
+// containsAddress reports whether addr is in the list.
+func containsAddress(list []common.Address, addr common.Address) bool {
+	for _, a := range list {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func (ui *QuorumUI) ApproveTx(request *SignTxRequest) (SignTxResponse, error) {
+	resp, err := ui.filter.ApproveTx(request)
+	if err != nil || !resp.Approved {
+		return SignTxResponse{Approved: false}, err
+	}
+	// The approvers vote on the transaction as passed by the filter
+	filtered := *request
+	filtered.Transaction = resp.Transaction
+
+	approved, err := ui.await("tx", filtered.Transaction, &filtered)
+	if err != nil || !approved {
+		NotifyFailedTx(ui.filter, request)
+		return SignTxResponse{Approved: false}, err
+	}
+	return SignTxResponse{Transaction: filtered.Transaction, Approved: true}, nil
+}
+
+func (ui *QuorumUI) ApproveSignData(request *SignDataRequest) (SignDataResponse, error) {
+	if resp, err := ui.filter.ApproveSignData(request); err != nil || !resp.Approved {
+		return SignDataResponse{Approved: false}, err
+	}
+	content := struct {
+		ContentType string
+		Address     common.MixedcaseAddress
+		Hash        hexutil.Bytes
+	}{request.ContentType, request.Address, request.Hash}
+
+	approved, err := ui.await("signdata", content, request)
+	return SignDataResponse{Approved: approved}, err
+}
+
+func (ui *QuorumUI) ApproveListing(request *ListRequest) (ListResponse, error) {
+	resp, err := ui.filter.ApproveListing(request)
+	if err != nil || len(resp.Accounts) == 0 {
+		return ListResponse{}, err
+	}
+	// The approvers vote on the accounts as narrowed down by the filter
+	filtered := *request
+	filtered.Accounts = resp.Accounts
+
+	approved, err := ui.await("listing", filtered.Accounts, &filtered)
+	if err != nil || !approved {
+		return ListResponse{}, err
+	}
+	return ListResponse{Accounts: filtered.Accounts}, nil
+}
+
+func (ui *QuorumUI) ApproveNewAccount(request *NewAccountRequest) (NewAccountResponse, error) {
+	if resp, err := ui.filter.ApproveNewAccount(request); err != nil || !resp.Approved {
+		return NewAccountResponse{Approved: false}, err
+	}
+	approved, err := ui.await("newaccount", nil, request)
+	return NewAccountResponse{Approved: approved}, err
+}
+
+func (ui *QuorumUI) ShowError(message string) {
+	ui.filter.ShowError(message)
+}
+
+func (ui *QuorumUI) ShowInfo(message string) {
+	ui.filter.ShowInfo(message)
+}
+
+func (ui *QuorumUI) OnApprovedTx(tx ethapi.SignTransactionResult) {
+	ui.filter.OnApprovedTx(tx)
+}
+
+// OnFailedTx implements TxFailureHandler, forwarding the notification to the filter.
+func (ui *QuorumUI) OnFailedTx(request *SignTxRequest) {
+	NotifyFailedTx(ui.filter, request)
+}
+
+func (ui *QuorumUI) OnSignerStartup(info StartupInfo) {
+	ui.filter.OnSignerStartup(info)
+}
+
+func (ui *QuorumUI) OnInputRequired(info UserInputRequest) (UserInputResponse, error) {
+	return ui.filter.OnInputRequired(info)
+}
+
+func (ui *QuorumUI) RegisterUIServer(api *UIServerAPI) {
+	ui.filter.RegisterUIServer(api)
+}
+
+// quorumPassUI is the end of the filter chain of a quorum UI. It approves all
+// requests unchanged, and forwards everything else to the operator UI.
+type quorumPassUI struct {
+	UIClientAPI
+}
+
+func (quorumPassUI) ApproveTx(request *SignTxRequest) (SignTxResponse, error) {
+	return SignTxResponse{Transaction: request.Transaction, Approved: true}, nil
+}
+
+func (quorumPassUI) ApproveSignData(request *SignDataRequest) (SignDataResponse, error) {
+	return SignDataResponse{Approved: true}, nil
+}
+
+func (quorumPassUI) ApproveListing(request *ListRequest) (ListResponse, error) {
+	return ListResponse{Accounts: request.Accounts}, nil
+}
+
+func (quorumPassUI) ApproveNewAccount(request *NewAccountRequest) (NewAccountResponse, error) {
+	return NewAccountResponse{Approved: true}, nil
+}
+
+// QuorumAPI is the API through which approvers list and vote on pending
+// requests. It must only be served on local channels, i.e. IPC, and not on the
+// external HTTP endpoint.
+type QuorumAPI struct {
+	ui *QuorumUI
+}
+
+// NewQuorumAPI creates the approver API of a quorum UI.
+func NewQuorumAPI(ui *QuorumUI) *QuorumAPI {
+	return &QuorumAPI{ui}
+}
+
+// Pending returns the requests waiting for votes.
+// Example call
+// {"jsonrpc":"2.0","method":"quorum_pending","params":[], "id":1}
+func (api *QuorumAPI) Pending() []QuorumRequest {
+	return api.ui.Pending()
+}
+
+// Approve casts an approving vote on a pending request. The signature is a
+// personal_sign signature over "clef quorum approve <id>", id in hex without 0x.
+// Example call
+// {"jsonrpc":"2.0","method":"quorum_approve","params":["0x5e6f...", "0x1b2c..."], "id":2}
+func (api *QuorumAPI) Approve(id common.Hash, signature hexutil.Bytes) error {
+	return api.ui.Vote(id, true, signature)
+}
+
+// Reject casts a rejecting vote on a pending request. The signature is a
+// personal_sign signature over "clef quorum reject <id>", id in hex without 0x.
+// Example call
+// {"jsonrpc":"2.0","method":"quorum_reject","params":["0x5e6f...", "0x1b2c..."], "id":3}
+func (api *QuorumAPI) Reject(id common.Hash, signature hexutil.Bytes) error {
+	return api.ui.Vote(id, false, signature)
+}
@@ -0,0 +1,219 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core_test
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/ethereum/go-ethereum/signer/storage"
+)
+
+// newQuorum creates a 2-of-3 quorum UI and the keys of its approvers.
+func newQuorum(t *testing.T, db storage.Storage, timeout time.Duration) (*core.QuorumUI, []*ecdsa.PrivateKey) {
+	var (
+		keys   []*ecdsa.PrivateKey
+		config = core.QuorumConfig{Threshold: 2, Timeout: timeout}
+	)
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		keys = append(keys, key)
+		config.Approvers = append(config.Approvers, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	ui, err := core.NewQuorumUI(&headlessUi{}, db, config)
+	if err != nil {
+		t.Fatalf("failed to create quorum ui: %v", err)
+	}
+	return ui, keys
+}
+
+// vote signs and casts a vote on a pending request.
+func vote(ui *core.QuorumUI, key *ecdsa.PrivateKey, id common.Hash, approve bool) error {
+	sig, err := crypto.Sign(core.QuorumVoteHash(id, approve), key)
+	if err != nil {
+		return err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return ui.Vote(id, approve, sig)
+}
+
+// requestListing starts a listing request in the background, waits for it to
+// become pending and returns its identifier and outcome.
+func requestListing(t *testing.T, ui *core.QuorumUI) (common.Hash, chan bool) {
+	result := make(chan bool, 1)
+	go func() {
+		resp, err := ui.ApproveListing(&core.ListRequest{Accounts: []accounts.Account{{Address: common.Address{0x01}}}})
+		if err != nil {
+			t.Errorf("listing failed: %v", err)
+		}
+		result <- len(resp.Accounts) > 0
+	}()
+	for i := 0; i < 100; i++ {
+		if pending := ui.Pending(); len(pending) > 0 {
+			return pending[0].ID, result
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("request did not become pending")
+	return common.Hash{}, nil
+}
+
+func TestQuorumApproval(t *testing.T) {
+	ui, keys := newQuorum(t, storage.NewEphemeralStorage(), time.Minute)
+	id, result := requestListing(t, ui)
+
+	outsider, _ := crypto.GenerateKey()
+	if err := vote(ui, outsider, id, true); err != core.ErrNotApprover {
+		t.Errorf("outsider vote: have %v, want %v", err, core.ErrNotApprover)
+	}
+	if err := vote(ui, keys[0], id, true); err != nil {
+		t.Fatalf("first vote failed: %v", err)
+	}
+	if err := vote(ui, keys[0], id, false); err != core.ErrAlreadyVoted {
+		t.Errorf("double vote: have %v, want %v", err, core.ErrAlreadyVoted)
+	}
+	if err := vote(ui, keys[1], common.Hash{0xff}, true); err != core.ErrUnknownRequest {
+		t.Errorf("vote on unknown request: have %v, want %v", err, core.ErrUnknownRequest)
+	}
+	select {
+	case <-result:
+		t.Fatalf("request concluded before quorum")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := vote(ui, keys[2], id, true); err != nil {
+		t.Fatalf("second vote failed: %v", err)
+	}
+	if approved := <-result; !approved {
+		t.Errorf("request rejected despite quorum")
+	}
+	if pending := ui.Pending(); len(pending) != 0 {
+		t.Errorf("concluded request still pending")
+	}
+}
+
+func TestQuorumRejection(t *testing.T) {
+	ui, keys := newQuorum(t, storage.NewEphemeralStorage(), time.Minute)
+	id, result := requestListing(t, ui)
+
+	// With two of three rejecting, a quorum of two approvals is impossible
+	vote(ui, keys[0], id, false)
+	vote(ui, keys[1], id, false)
+
+	if approved := <-result; approved {
+		t.Errorf("request approved without quorum")
+	}
+}
+
+func TestQuorumTimeout(t *testing.T) {
+	ui, keys := newQuorum(t, storage.NewEphemeralStorage(), 100*time.Millisecond)
+	id, result := requestListing(t, ui)
+
+	vote(ui, keys[0], id, true)
+	if approved := <-result; approved {
+		t.Errorf("request approved without quorum")
+	}
+	if err := vote(ui, keys[1], id, true); err != core.ErrUnknownRequest {
+		t.Errorf("vote after timeout: have %v, want %v", err, core.ErrUnknownRequest)
+	}
+}
+
+// Tests that votes on pending requests survive a restart, and count towards the
+// same request when it is resubmitted.
+func TestQuorumPersistence(t *testing.T) {
+	db := storage.NewEphemeralStorage()
+	ui, keys := newQuorum(t, db, time.Minute)
+	id, _ := requestListing(t, ui)
+	vote(ui, keys[0], id, true)
+
+	approvers := make([]common.Address, len(keys))
+	for i, key := range keys {
+		approvers[i] = crypto.PubkeyToAddress(key.PublicKey)
+	}
+	restarted, err := core.NewQuorumUI(&headlessUi{}, db, core.QuorumConfig{Approvers: approvers, Threshold: 2, Timeout: time.Minute})
+	if err != nil {
+		t.Fatalf("failed to restart quorum ui: %v", err)
+	}
+	pending := restarted.Pending()
+	if len(pending) != 1 || pending[0].ID != id || len(pending[0].Approvals) != 1 {
+		t.Fatalf("pending requests not restored: %+v", pending)
+	}
+	resumed, result := requestListing(t, restarted)
+	if resumed != id {
+		t.Fatalf("resubmitted request not resumed: have %x, want %x", resumed, id)
+	}
+	if err := vote(restarted, keys[1], id, true); err != nil {
+		t.Fatalf("vote failed: %v", err)
+	}
+	if approved := <-result; !approved {
+		t.Errorf("request rejected despite quorum")
+	}
+}
+
+// Tests that the filter can reject requests without a vote, but that requests
+// approved by it still need the votes of the approvers.
+func TestQuorumFilter(t *testing.T) {
+	ui, keys := newQuorum(t, storage.NewEphemeralStorage(), time.Minute)
+	filter := &headlessUi{approveCh: make(chan string, 1)}
+	ui.SetFilter(filter)
+
+	filter.approveCh <- "N"
+	resp, err := ui.ApproveListing(&core.ListRequest{Accounts: []accounts.Account{{Address: common.Address{0x01}}}})
+	if err != nil || len(resp.Accounts) != 0 {
+		t.Fatalf("request rejected by filter was approved: %v %v", resp, err)
+	}
+	if pending := ui.Pending(); len(pending) != 0 {
+		t.Fatalf("request rejected by filter was put to vote")
+	}
+
+	filter.approveCh <- "A"
+	id, result := requestListing(t, ui)
+	select {
+	case <-result:
+		t.Fatalf("request approved by filter concluded without votes")
+	case <-time.After(50 * time.Millisecond):
+	}
+	vote(ui, keys[0], id, true)
+	vote(ui, keys[1], id, true)
+	if approved := <-result; !approved {
+		t.Errorf("request rejected despite quorum")
+	}
+}
+
+// Tests that the request identifier, which approvers sign, commits to the
+// content of the request.
+func TestQuorumRequestID(t *testing.T) {
+	ui, _ := newQuorum(t, storage.NewEphemeralStorage(), time.Minute)
+	id, _ := requestListing(t, ui)
+
+	req := ui.Pending()[0]
+	if want := core.QuorumRequestID(req.Kind, req.Content, req.Nonce); id != want {
+		t.Fatalf("request id mismatch: have %x, want %x", id, want)
+	}
+	var listed []struct{ Address common.Address }
+	if err := json.Unmarshal(req.Content, &listed); err != nil {
+		t.Fatalf("failed to decode request content: %v", err)
+	}
+	if len(listed) != 1 || listed[0].Address != (common.Address{0x01}) {
+		t.Errorf("wrong request content: %s", req.Content)
+	}
+}
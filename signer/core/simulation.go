@@ -0,0 +1,105 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// simulationTimeout is the time allowed for simulating a transaction before the
+// request is passed on to the UI without a simulation.
+const simulationTimeout = 5 * time.Second
+
+// Simulator predicts the effects of a transaction before it is signed.
+//
+// Use simulation.Simulator as an implementation, which traces the transaction
+// on a node through debug_traceCall.
+type Simulator interface {
+	// SimulateTx executes the transaction on top of the latest state without
+	// committing it, and reports what it would do.
+	SimulateTx(ctx context.Context, args *apitypes.SendTxArgs) (*Simulation, error)
+}
+
+// Simulation contains the predicted effects of a transaction. If the transaction
+// reverts, no transfers, approvals or balance changes are reported.
+type Simulation struct {
+	Reverted      bool                `json:"reverted"`
+	RevertReason  string              `json:"revert_reason,omitempty"`
+	GasUsed       hexutil.Uint64      `json:"gas_used"`
+	Transfers     []SimulatedTransfer `json:"transfers"`
+	Approvals     []SimulatedApproval `json:"approvals"`
+	BalanceDeltas []SimulatedBalance  `json:"balance_deltas"`
+}
+
+// SimulatedTransfer is a movement of ether or tokens. Token is nil for ether.
+type SimulatedTransfer struct {
+	Token *common.Address `json:"token"`
+	From  common.Address  `json:"from"`
+	To    common.Address  `json:"to"`
+	Value *hexutil.Big    `json:"value"`
+}
+
+// SimulatedApproval is an allowance granted on a token.
+type SimulatedApproval struct {
+	Token   common.Address `json:"token"`
+	Owner   common.Address `json:"owner"`
+	Spender common.Address `json:"spender"`
+	Value   *hexutil.Big   `json:"value"`
+}
+
+// SimulatedBalance is the net change of the ether (Token is nil) or token balance
+// of an account, excluding transaction fees.
+type SimulatedBalance struct {
+	Token   *common.Address `json:"token"`
+	Account common.Address  `json:"account"`
+	Delta   *hexutil.Big    `json:"delta"`
+}
+
+// SetSimulator configures the simulator used to predict the effects of the
+// transactions that are about to be signed. If nil, no simulation is done.
+func (api *SignerAPI) SetSimulator(simulator Simulator) {
+	api.simulator = simulator
+}
+
+// simulate runs the transaction through the simulator, if one is configured,
+// and adds its outcome to the request.
+func (api *SignerAPI) simulate(ctx context.Context, req *SignTxRequest) {
+	if api.simulator == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, simulationTimeout)
+	defer cancel()
+
+	sim, err := api.simulator.SimulateTx(ctx, &req.Transaction)
+	if err != nil {
+		req.Callinfo = append(req.Callinfo, apitypes.ValidationInfo{Typ: apitypes.INFO, Message: "Transaction simulation failed: " + err.Error()})
+		return
+	}
+	if sim.Reverted {
+		msg := "Transaction is expected to revert"
+		if sim.RevertReason != "" {
+			msg += ": " + sim.RevertReason
+		}
+		req.Callinfo = append(req.Callinfo, apitypes.ValidationInfo{Typ: apitypes.WARN, Message: msg})
+	}
+	req.Simulation = sim
+}
@@ -0,0 +1,617 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rules
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/ethereum/go-ethereum/signer/storage"
+	"gopkg.in/yaml.v2"
+)
+
+// Action is the outcome of evaluating a request against a policy.
+type Action string
+
+const (
+	// ActionManual forwards the request to the next UI in the chain.
+	ActionManual Action = "manual"
+
+	// ActionApprove approves the request without further interaction.
+	ActionApprove Action = "approve"
+
+	// ActionReject rejects the request without further interaction.
+	ActionReject Action = "reject"
+)
+
+// UnmarshalYAML implements yaml.Unmarshaler, validating the action name.
+func (a *Action) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	switch action := Action(strings.ToLower(s)); action {
+	case ActionManual, ActionApprove, ActionReject:
+		*a = action
+		return nil
+	}
+	return fmt.Errorf("invalid action %q, must be one of manual, approve or reject", s)
+}
+
+// Amount is an amount of wei. In policy files it can be written as a decimal or
+// hex integer of wei, or as a decimal number followed by a "wei", "gwei" or
+// "ether" unit, e.g. "1.5 ether".
+type Amount struct {
+	big.Int
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, parsing the amount and its unit.
+func (a *Amount) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	v, err := parseAmount(s)
+	if err != nil {
+		return err
+	}
+	a.Set(v)
+	return nil
+}
+
+// parseAmount converts a textual amount with an optional unit into wei.
+func parseAmount(s string) (*big.Int, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(fields) == 1 && strings.HasPrefix(fields[0], "0x") {
+		v, ok := new(big.Int).SetString(fields[0][2:], 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid amount %q", s)
+		}
+		return v, nil
+	}
+	unit := big.NewInt(params.Wei)
+	if len(fields) == 2 {
+		switch strings.ToLower(fields[1]) {
+		case "wei":
+		case "gwei":
+			unit = big.NewInt(params.GWei)
+		case "ether", "eth":
+			unit = big.NewInt(params.Ether)
+		default:
+			return nil, fmt.Errorf("invalid amount unit %q", fields[1])
+		}
+	}
+	r, ok := new(big.Rat).SetString(fields[0])
+	if !ok || r.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	r.Mul(r, new(big.Rat).SetInt(unit))
+	if !r.IsInt() {
+		return nil, fmt.Errorf("amount %q is not a whole number of wei", s)
+	}
+	return new(big.Int).Set(r.Num()), nil
+}
+
+// Duration is a time.Duration written in the time.ParseDuration format.
+type Duration time.Duration
+
+// UnmarshalYAML implements yaml.Unmarshaler, parsing the duration.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if v <= 0 {
+		return fmt.Errorf("invalid duration %q, must be positive", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Selector is a 4 byte method selector. In policy files it can be written as
+// hex ("0xa9059cbb") or as a method signature ("transfer(address,uint256)").
+// The special value "0x" stands for transactions without calldata.
+type Selector []byte
+
+// UnmarshalYAML implements yaml.Unmarshaler, parsing the selector.
+func (s *Selector) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var text string
+	if err := unmarshal(&text); err != nil {
+		return err
+	}
+	if strings.Contains(text, "(") {
+		*s = crypto.Keccak256([]byte(strings.ReplaceAll(text, " ", "")))[:4]
+		return nil
+	}
+	blob, err := hex.DecodeString(strings.TrimPrefix(text, "0x"))
+	if err != nil || (len(blob) != 0 && len(blob) != 4) {
+		return fmt.Errorf("invalid method selector %q", text)
+	}
+	*s = blob
+	return nil
+}
+
+// WindowCap limits the total value approved by a rule within a sliding window.
+type WindowCap struct {
+	Window Duration `yaml:"window"`
+	Max    *Amount  `yaml:"max"`
+}
+
+// AccountPolicy contains the limits that apply to every transaction sent from
+// an account, no matter whether it is approved by a rule or manually.
+type AccountPolicy struct {
+	Address    common.Address `yaml:"address"`
+	DailyLimit *Amount        `yaml:"dailyLimit"`
+}
+
+// TxRule approves transactions that match all of its allowlists and that stay
+// within all of its limits. Empty allowlists match everything.
+type TxRule struct {
+	Name      string           `yaml:"name"`
+	From      []common.Address `yaml:"from"`      // Accounts the rule applies to
+	To        []common.Address `yaml:"to"`        // Allowed recipients, contract creations never match
+	Selectors []Selector       `yaml:"selectors"` // Allowed method selectors, "0x" for plain transfers
+	MaxValue  *Amount          `yaml:"maxValue"`  // Cap on the value of a single transaction
+	WindowCap *WindowCap       `yaml:"windowCap"` // Cap on the value approved by this rule in a window
+}
+
+// Policy is a declarative, reviewable set of rules deciding which requests clef
+// may answer automatically.
+type Policy struct {
+	Listing  Action           `yaml:"listing"`  // Action for account listing requests
+	SignData Action           `yaml:"signdata"` // Action for data signing requests
+	Default  Action           `yaml:"default"`  // Action for transactions no rule matches
+	Accounts []*AccountPolicy `yaml:"accounts"`
+	Rules    []*TxRule        `yaml:"rules"`
+
+	// RejectReverting rejects transactions which are expected to revert, if
+	// clef is configured to simulate transactions.
+	RejectReverting bool `yaml:"rejectReverting"`
+}
+
+// ParsePolicy parses a policy from its YAML or JSON representation. Unknown
+// fields are rejected, so that typos cannot silently weaken a policy.
+func ParsePolicy(data []byte) (*Policy, error) {
+	policy := new(Policy)
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, err
+	}
+	if err := policy.sanitize(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// sanitize fills in the defaults and checks the consistency of a policy.
+func (p *Policy) sanitize() error {
+	for _, action := range []*Action{&p.Listing, &p.SignData, &p.Default} {
+		if *action == "" {
+			*action = ActionManual
+		}
+	}
+	if p.Default == ActionApprove {
+		return errors.New("default action may not be approve, use a rule instead")
+	}
+	names := make(map[string]bool)
+	for i, rule := range p.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("rule %d has no name", i)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+
+		if rule.WindowCap != nil && (rule.WindowCap.Window == 0 || rule.WindowCap.Max == nil) {
+			return fmt.Errorf("rule %q: window cap needs both window and max", rule.Name)
+		}
+	}
+	seen := make(map[common.Address]bool)
+	for _, account := range p.Accounts {
+		if seen[account.Address] {
+			return fmt.Errorf("duplicate account %v", account.Address)
+		}
+		seen[account.Address] = true
+	}
+	return nil
+}
+
+// account returns the limits configured for an address, or nil if there are none.
+func (p *Policy) account(addr common.Address) *AccountPolicy {
+	for _, account := range p.Accounts {
+		if account.Address == addr {
+			return account
+		}
+	}
+	return nil
+}
+
+// match returns the first rule whose allowlists match the transaction, or nil
+// if no rule matches.
+func (p *Policy) match(from common.Address, to *common.Address, data []byte) *TxRule {
+	for _, rule := range p.Rules {
+		if rule.matches(from, to, data) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// matches checks whether the transaction fields are allowed by the rule.
+func (r *TxRule) matches(from common.Address, to *common.Address, data []byte) bool {
+	if len(r.From) > 0 && !containsAddress(r.From, from) {
+		return false
+	}
+	if len(r.To) > 0 && (to == nil || !containsAddress(r.To, *to)) {
+		return false
+	}
+	if len(r.Selectors) > 0 {
+		var selector []byte
+		if len(data) > 0 {
+			if len(data) < 4 {
+				return false
+			}
+			selector = data[:4]
+		}
+		for _, allowed := range r.Selectors {
+			if bytes.Equal(allowed, selector) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// containsAddress reports whether addr is in the list.
+func containsAddress(list []common.Address, addr common.Address) bool {
+	for _, a := range list {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// policyUI provides an implementation of UIClientAPI that answers requests
+// according to a declarative policy, forwarding everything the policy leaves to
+// the user to the next UI in the chain.
+//
+// The value of an approved transaction is reserved in the spend ledgers right
+// away, so concurrent requests can't exceed the limits together. The reservation
+// is released if the transaction is not signed after all.
+type policyUI struct {
+	next    core.UIClientAPI // The next handler, for manual processing
+	storage storage.Storage  // Persistent ledger of approved spends
+	policy  *Policy
+
+	now     func() time.Time                           // Clock, replaceable in tests
+	lock    sync.Mutex                                 // Protects the ledgers in storage and pending
+	pending map[*core.SignTxRequest]*policyReservation // Approved requests not signed yet
+}
+
+// NewPolicyEvaluator creates a UI that evaluates requests against the policy,
+// keeping the spend ledgers needed for the value caps in the given storage.
+func NewPolicyEvaluator(next core.UIClientAPI, storage storage.Storage, policy *Policy) *policyUI {
+	return &policyUI{
+		next:    next,
+		storage: storage,
+		policy:  policy,
+		now:     time.Now,
+		pending: make(map[*core.SignTxRequest]*policyReservation),
+	}
+}
+
+// spend is a single approved value transfer in a ledger.
+type spend struct {
+	Time  int64        `json:"time"`
+	Value *hexutil.Big `json:"value"`
+}
+
+// policyReservation holds the ledger entries recorded for an approved transaction
+// until it is signed.
+type policyReservation struct {
+	from    common.Address
+	tx      *types.Transaction
+	entries map[string]spend // ledger key -> recorded entry
+}
+
+// accountLedger and ruleLedger are the storage keys of the spend ledgers.
+func accountLedger(addr common.Address) string { return "policy/account/" + addr.Hex() }
+func ruleLedger(name string) string            { return "policy/rule/" + name }
+
+// spent sums the values in a ledger since the given time.
+func (p *policyUI) spent(key string, since time.Time) *big.Int {
+	total := new(big.Int)
+	for _, entry := range p.ledger(key) {
+		if entry.Time >= since.Unix() {
+			total.Add(total, entry.Value.ToInt())
+		}
+	}
+	return total
+}
+
+// ledger loads a spend ledger from storage.
+func (p *policyUI) ledger(key string) []spend {
+	blob, err := p.storage.Get(key)
+	if err != nil {
+		return nil
+	}
+	var entries []spend
+	if err := json.Unmarshal([]byte(blob), &entries); err != nil {
+		log.Warn("Corrupt policy ledger, resetting", "key", key, "err", err)
+		return nil
+	}
+	return entries
+}
+
+// storeLedger writes a spend ledger to storage.
+func (p *policyUI) storeLedger(key string, entries []spend) {
+	blob, err := json.Marshal(entries)
+	if err != nil {
+		log.Warn("Failed to encode policy ledger", "key", key, "err", err)
+		return
+	}
+	p.storage.Put(key, string(blob))
+}
+
+// record appends a spend to a ledger, dropping entries older than the window.
+// It returns the new entry.
+func (p *policyUI) record(key string, value *big.Int, window time.Duration) spend {
+	now := p.now()
+	entries := []spend{}
+	for _, entry := range p.ledger(key) {
+		if entry.Time >= now.Add(-window).Unix() {
+			entries = append(entries, entry)
+		}
+	}
+	entry := spend{Time: now.Unix(), Value: (*hexutil.Big)(value)}
+	p.storeLedger(key, append(entries, entry))
+	return entry
+}
+
+// unrecord removes a spend from a ledger.
+func (p *policyUI) unrecord(key string, entry spend) {
+	entries := p.ledger(key)
+	for i, e := range entries {
+		if e.Time == entry.Time && e.Value.ToInt().Cmp(entry.Value.ToInt()) == 0 {
+			p.storeLedger(key, append(entries[:i], entries[i+1:]...))
+			return
+		}
+	}
+}
+
+// checkLimits checks a transaction against the daily limit of the sending account
+// and the window cap of the rule it matches, returning the reason for rejecting
+// it or an empty string if it is within the limits.
+func (p *policyUI) checkLimits(from common.Address, tx *types.Transaction) string {
+	value := tx.Value()
+	if account := p.policy.account(from); account != nil && account.DailyLimit != nil {
+		total := p.spent(accountLedger(from), p.now().Add(-24*time.Hour))
+		if total.Add(total, value).Cmp(&account.DailyLimit.Int) > 0 {
+			return fmt.Sprintf("daily limit of account %v exceeded", from)
+		}
+	}
+	if rule := p.policy.match(from, tx.To(), tx.Data()); rule != nil && rule.WindowCap != nil {
+		total := p.spent(ruleLedger(rule.Name), p.now().Add(-time.Duration(rule.WindowCap.Window)))
+		if total.Add(total, value).Cmp(&rule.WindowCap.Max.Int) > 0 {
+			return fmt.Sprintf("window cap of rule %q exceeded", rule.Name)
+		}
+	}
+	return ""
+}
+
+// reserve records the value of an approved transaction in the ledgers it counts
+// against, remembering the entries until the transaction is signed.
+func (p *policyUI) reserve(request *core.SignTxRequest, from common.Address, tx *types.Transaction) {
+	res := &policyReservation{from: from, tx: tx, entries: make(map[string]spend)}
+	if account := p.policy.account(from); account != nil && account.DailyLimit != nil {
+		key := accountLedger(from)
+		res.entries[key] = p.record(key, tx.Value(), 24*time.Hour)
+	}
+	if rule := p.policy.match(from, tx.To(), tx.Data()); rule != nil && rule.WindowCap != nil {
+		key := ruleLedger(rule.Name)
+		res.entries[key] = p.record(key, tx.Value(), time.Duration(rule.WindowCap.Window))
+	}
+	if len(res.entries) > 0 {
+		p.pending[request] = res
+	}
+}
+
+// evaluate decides what to do with a transaction request, returning the action
+// to take and a human readable reason for it. The value of approved transactions
+// is reserved.
+func (p *policyUI) evaluate(request *core.SignTxRequest) (Action, string) {
+	var (
+		from = request.Transaction.From.Address()
+		tx   = request.Transaction.ToTransaction()
+	)
+	if p.policy.RejectReverting && request.Simulation != nil && request.Simulation.Reverted {
+		return ActionReject, "transaction is expected to revert"
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// Account limits apply to every transaction, even ones left to the user
+	if reason := p.checkLimits(from, tx); reason != "" {
+		return ActionReject, reason
+	}
+	rule := p.policy.match(from, tx.To(), tx.Data())
+	if rule == nil {
+		return p.policy.Default, "no matching rule"
+	}
+	if rule.MaxValue != nil && tx.Value().Cmp(&rule.MaxValue.Int) > 0 {
+		return ActionReject, fmt.Sprintf("value exceeds the maximum of rule %q", rule.Name)
+	}
+	p.reserve(request, from, tx)
+	return ActionApprove, fmt.Sprintf("approved by rule %q", rule.Name)
+}
+
+func (p *policyUI) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse, error) {
+	action, reason := p.evaluate(request)
+	switch action {
+	case ActionApprove:
+		log.Info("Policy approved transaction", "reason", reason)
+		return core.SignTxResponse{Transaction: request.Transaction, Approved: true}, nil
+	case ActionReject:
+		log.Info("Policy rejected transaction", "reason", reason)
+		return core.SignTxResponse{Approved: false}, nil
+	}
+	resp, err := p.next.ApproveTx(request)
+	if err != nil || !resp.Approved {
+		return resp, err
+	}
+	// Other requests may have been approved while the user decided, so the
+	// limits are checked again before reserving the value.
+	from, tx := resp.Transaction.From.Address(), resp.Transaction.ToTransaction()
+	p.lock.Lock()
+	reason = p.checkLimits(from, tx)
+	if reason == "" {
+		p.reserve(request, from, tx)
+	}
+	p.lock.Unlock()
+
+	if reason != "" {
+		log.Info("Policy rejected manually approved transaction", "reason", reason)
+		core.NotifyFailedTx(p.next, request)
+		return core.SignTxResponse{Approved: false}, nil
+	}
+	return resp, nil
+}
+
+func (p *policyUI) ApproveSignData(request *core.SignDataRequest) (core.SignDataResponse, error) {
+	switch p.policy.SignData {
+	case ActionApprove:
+		return core.SignDataResponse{Approved: true}, nil
+	case ActionReject:
+		return core.SignDataResponse{Approved: false}, nil
+	}
+	return p.next.ApproveSignData(request)
+}
+
+func (p *policyUI) ApproveListing(request *core.ListRequest) (core.ListResponse, error) {
+	switch p.policy.Listing {
+	case ActionApprove:
+		return core.ListResponse{Accounts: request.Accounts}, nil
+	case ActionReject:
+		return core.ListResponse{}, nil
+	}
+	return p.next.ApproveListing(request)
+}
+
+// OnApprovedTx confirms the reservation of the signed transaction, then notifies
+// the next UI. Transactions without a reservation are recorded in the ledgers now.
+func (p *policyUI) OnApprovedTx(tx ethapi.SignTransactionResult) {
+	defer p.next.OnApprovedTx(tx)
+
+	from, err := types.Sender(types.LatestSignerForChainID(tx.Tx.ChainId()), tx.Tx)
+	if err != nil {
+		log.Warn("Failed to recover sender of approved transaction", "err", err)
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for request, res := range p.pending {
+		if res.from == from && sameTransaction(res.tx, tx.Tx) {
+			delete(p.pending, request)
+			return
+		}
+	}
+	if account := p.policy.account(from); account != nil && account.DailyLimit != nil {
+		p.record(accountLedger(from), tx.Tx.Value(), 24*time.Hour)
+	}
+	if rule := p.policy.match(from, tx.Tx.To(), tx.Tx.Data()); rule != nil && rule.WindowCap != nil {
+		p.record(ruleLedger(rule.Name), tx.Tx.Value(), time.Duration(rule.WindowCap.Window))
+	}
+}
+
+// OnFailedTx implements core.TxFailureHandler, releasing the value reserved for
+// a request which was not signed.
+func (p *policyUI) OnFailedTx(request *core.SignTxRequest) {
+	defer core.NotifyFailedTx(p.next, request)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if res := p.pending[request]; res != nil {
+		for key, entry := range res.entries {
+			p.unrecord(key, entry)
+		}
+		delete(p.pending, request)
+	}
+}
+
+// sameTransaction reports whether a signed transaction carries the same transfer
+// as an approved one.
+func sameTransaction(approved, signed *types.Transaction) bool {
+	if approved.Nonce() != signed.Nonce() || approved.Value().Cmp(signed.Value()) != 0 || !bytes.Equal(approved.Data(), signed.Data()) {
+		return false
+	}
+	if approved.To() == nil || signed.To() == nil {
+		return approved.To() == signed.To()
+	}
+	return *approved.To() == *signed.To()
+}
+
+// ApproveNewAccount is not handled by the policy, it requires setting a password.
+func (p *policyUI) ApproveNewAccount(request *core.NewAccountRequest) (core.NewAccountResponse, error) {
+	return p.next.ApproveNewAccount(request)
+}
+
+// OnInputRequired is not handled by the policy.
+func (p *policyUI) OnInputRequired(info core.UserInputRequest) (core.UserInputResponse, error) {
+	return p.next.OnInputRequired(info)
+}
+
+func (p *policyUI) ShowError(message string) {
+	p.next.ShowError(message)
+}
+
+func (p *policyUI) ShowInfo(message string) {
+	p.next.ShowInfo(message)
+}
+
+func (p *policyUI) OnSignerStartup(info core.StartupInfo) {
+	p.next.OnSignerStartup(info)
+}
+
+func (p *policyUI) RegisterUIServer(api *core.UIServerAPI) {
+	p.next.RegisterUIServer(api)
+}
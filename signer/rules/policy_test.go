@@ -0,0 +1,267 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rules
+
+import (
+	"bytes"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/ethereum/go-ethereum/signer/storage"
+)
+
+var policyKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+const testPolicy = `
+listing: approve
+signdata: reject
+accounts:
+  - address: "0x71562b71999873DB5b286dF957af199Ec94617F7"
+    dailyLimit: 2 ether
+rules:
+  - name: token-transfers
+    to: ["0x00000000000000000000000000000000000000aa"]
+    selectors: ["transfer(address,uint256)"]
+    maxValue: "0"
+  - name: payments
+    to: ["0x000000000000000000000000000000000000dead"]
+    selectors: ["0x"]
+    maxValue: 1 ether
+    windowCap:
+      window: 1h
+      max: 1.5 ether
+`
+
+func policyTx(to string, value *big.Int, data []byte) *core.SignTxRequest {
+	from := common.NewMixedcaseAddress(crypto.PubkeyToAddress(policyKey.PublicKey))
+	recipient := common.NewMixedcaseAddress(common.HexToAddress(to))
+	gasPrice := hexutil.Big(*big.NewInt(2000000))
+	input := hexutil.Bytes(data)
+
+	return &core.SignTxRequest{
+		Transaction: apitypes.SendTxArgs{
+			From:     from,
+			To:       &recipient,
+			Value:    hexutil.Big(*value),
+			GasPrice: &gasPrice,
+			Gas:      21000,
+			Data:     &input,
+		},
+	}
+}
+
+// approve runs a request through the policy, and on approval signs it and
+// reports it back as OnApprovedTx would be called by clef.
+func approve(t *testing.T, ui *policyUI, request *core.SignTxRequest) bool {
+	resp, err := ui.ApproveTx(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Approved {
+		signer := types.LatestSignerForChainID(big.NewInt(1))
+		tx, err := types.SignTx(request.Transaction.ToTransaction(), signer, policyKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		ui.OnApprovedTx(ethapi.SignTransactionResult{Tx: tx})
+	}
+	return resp.Approved
+}
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	if policy.Default != ActionManual {
+		t.Errorf("default action mismatch: have %v, want %v", policy.Default, ActionManual)
+	}
+	if have, want := policy.Rules[0].Selectors[0], (Selector{0xa9, 0x05, 0x9c, 0xbb}); !bytes.Equal(have, want) {
+		t.Errorf("selector mismatch: have %x, want %x", have, want)
+	}
+	if have, want := policy.Rules[1].WindowCap.Max.String(), "1500000000000000000"; have != want {
+		t.Errorf("window cap mismatch: have %v, want %v", have, want)
+	}
+	// JSON is a subset of YAML, so it must be accepted too
+	if _, err := ParsePolicy([]byte(`{"default": "reject", "rules": [{"name": "a", "maxValue": "0x10"}]}`)); err != nil {
+		t.Errorf("failed to parse json policy: %v", err)
+	}
+	for _, invalid := range []string{
+		"default: approve",
+		"listing: maybe",
+		"unknown: field",
+		"rules: [{maxValue: 1 ether}]",
+		"rules: [{name: a}, {name: a}]",
+		"rules: [{name: a, maxValue: 1 finney}]",
+		"rules: [{name: a, maxValue: 0.1 wei}]",
+		"rules: [{name: a, selectors: ['0x1234']}]",
+		"rules: [{name: a, windowCap: {window: 1h}}]",
+	} {
+		if _, err := ParsePolicy([]byte(invalid)); err == nil {
+			t.Errorf("expected error for policy %q", invalid)
+		}
+	}
+}
+
+func TestPolicyApproval(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	next := &dummyUI{make([]string, 0)}
+	ui := NewPolicyEvaluator(next, storage.NewEphemeralStorage(), policy)
+
+	list := &core.ListRequest{Accounts: []accounts.Account{{Address: common.Address{0x01}}}}
+	if resp, _ := ui.ApproveListing(list); len(resp.Accounts) != 1 {
+		t.Errorf("listing should be approved")
+	}
+	if resp, _ := ui.ApproveSignData(&core.SignDataRequest{}); resp.Approved {
+		t.Errorf("data signing should be rejected")
+	}
+	transfer := append(common.Hex2Bytes("a9059cbb"), make([]byte, 64)...)
+	if !approve(t, ui, policyTx("0xaa", new(big.Int), transfer)) {
+		t.Errorf("token transfer should be approved")
+	}
+	if approve(t, ui, policyTx("0xaa", big.NewInt(1), transfer)) {
+		t.Errorf("token transfer with value should be rejected")
+	}
+	if approve(t, ui, policyTx("0xdead", big.NewInt(2*params.Ether), nil)) {
+		t.Errorf("payment above maximum should be rejected")
+	}
+	// The window cap allows one payment of 1 ether, but not a second one
+	if !approve(t, ui, policyTx("0xdead", big.NewInt(params.Ether), nil)) {
+		t.Errorf("first payment should be approved")
+	}
+	if approve(t, ui, policyTx("0xdead", big.NewInt(params.Ether), nil)) {
+		t.Errorf("second payment should exceed the window cap")
+	}
+	// Once the window passed, payments are allowed again
+	now := time.Now()
+	ui.now = func() time.Time { return now.Add(time.Hour + time.Minute) }
+	if !approve(t, ui, policyTx("0xdead", big.NewInt(params.Ether), nil)) {
+		t.Errorf("payment in the next window should be approved")
+	}
+	// The daily account limit of 2 ether is exhausted now, even for transactions
+	// which would otherwise be passed to the user
+	if approve(t, ui, policyTx("0xbeef", big.NewInt(1), nil)) {
+		t.Errorf("transaction above the daily limit should be rejected")
+	}
+	// Approved transactions must be reported onwards, nothing else reaches the user
+	for _, call := range next.calls {
+		if call != "OnApprovedTx" {
+			t.Errorf("unexpected call to next handler: %s", call)
+		}
+	}
+	if len(next.calls) != 3 {
+		t.Errorf("approved transaction count mismatch: have %d, want 3", len(next.calls))
+	}
+}
+
+// This test checks that concurrent requests can't exceed the limits together,
+// and that the value of requests which are not signed is released.
+func TestPolicyConcurrent(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	ui := NewPolicyEvaluator(&dummyUI{make([]string, 0)}, storage.NewEphemeralStorage(), policy)
+
+	// The window cap of 1.5 ether allows only one of the payments.
+	var (
+		requests = make([]*core.SignTxRequest, 10)
+		approved = make(chan *core.SignTxRequest, len(requests))
+		wg       sync.WaitGroup
+	)
+	for i := range requests {
+		requests[i] = policyTx("0xdead", big.NewInt(params.Ether), nil)
+		wg.Add(1)
+		go func(request *core.SignTxRequest) {
+			defer wg.Done()
+			if resp, _ := ui.ApproveTx(request); resp.Approved {
+				approved <- request
+			}
+		}(requests[i])
+	}
+	wg.Wait()
+	close(approved)
+	if len(approved) != 1 {
+		t.Fatalf("%d concurrent payments approved, want 1", len(approved))
+	}
+	// Signing failed, so the next payment can use the window cap.
+	ui.OnFailedTx(<-approved)
+	if !approve(t, ui, policyTx("0xdead", big.NewInt(params.Ether), nil)) {
+		t.Fatal("payment after failed signing should be approved")
+	}
+	if approve(t, ui, policyTx("0xdead", big.NewInt(params.Ether), nil)) {
+		t.Fatal("second payment should exceed the window cap")
+	}
+	if len(ui.pending) != 0 {
+		t.Errorf("%d reservations left after signing", len(ui.pending))
+	}
+}
+
+func TestPolicyRejectReverting(t *testing.T) {
+	policy, err := ParsePolicy([]byte("rejectReverting: true\nrules: [{name: all}]"))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	ui := NewPolicyEvaluator(&dummyUI{make([]string, 0)}, storage.NewEphemeralStorage(), policy)
+
+	req := policyTx("0xdead", big.NewInt(1), nil)
+	req.Simulation = &core.Simulation{Reverted: true}
+	if approve(t, ui, req) {
+		t.Errorf("reverting transaction should be rejected")
+	}
+	req.Simulation.Reverted = false
+	if !approve(t, ui, req) {
+		t.Errorf("succeeding transaction should be approved")
+	}
+}
+
+func TestPolicyManual(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	next := &dummyUI{make([]string, 0)}
+	ui := NewPolicyEvaluator(next, storage.NewEphemeralStorage(), policy)
+
+	ui.ApproveTx(policyTx("0xbeef", big.NewInt(1), nil))
+	ui.ApproveNewAccount(&core.NewAccountRequest{})
+	ui.ShowInfo("test")
+
+	expected := []string{"ApproveTx", "ApproveNewAccount", "ShowInfo"}
+	if len(next.calls) != len(expected) {
+		t.Fatalf("call count mismatch: have %v, want %v", next.calls, expected)
+	}
+	for i, call := range expected {
+		if next.calls[i] != call {
+			t.Errorf("call %d mismatch: have %s, want %s", i, next.calls[i], call)
+		}
+	}
+}
@@ -195,6 +195,29 @@ func TestSignTxRequest(t *testing.T) {
 	}
 }
 
+// TestSimulatedTxRequest tests that the simulation results of a transaction are
+// available to the rules.
+func TestSimulatedTxRequest(t *testing.T) {
+	js := `
+	function ApproveTx(r){
+		if(r.simulation && !r.simulation.reverted && r.simulation.transfers.length == 0){ return "Approve"}
+		return "Reject"
+	}`
+	r, err := initRuleEngine(js)
+	if err != nil {
+		t.Fatalf("Couldn't create evaluator %v", err)
+	}
+	req := dummyTxWithV(0)
+	req.Simulation = &core.Simulation{Transfers: []core.SimulatedTransfer{}}
+	if resp, _ := r.ApproveTx(req); !resp.Approved {
+		t.Errorf("Expected check to resolve to 'Approve'")
+	}
+	req.Simulation.Reverted = true
+	if resp, _ := r.ApproveTx(req); resp.Approved {
+		t.Errorf("Expected check to resolve to 'Reject'")
+	}
+}
+
 type dummyUI struct {
 	calls []string
 }
@@ -0,0 +1,190 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package simulation predicts the effects of transactions by tracing them on a
+// node with debug_traceCall and the native call tracer.
+//
+// Token transfers and approvals are recognized from the ERC-20 transfer,
+// transferFrom and approve calls made during execution. Tokens moved by other
+// means (e.g. minting, or non-standard methods) are not detected.
+package simulation
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// Method selectors of the ERC-20 calls moving or approving tokens.
+var (
+	transferSelector     = []byte{0xa9, 0x05, 0x9c, 0xbb} // transfer(address,uint256)
+	transferFromSelector = []byte{0x23, 0xb8, 0x72, 0xdd} // transferFrom(address,address,uint256)
+	approveSelector      = []byte{0x09, 0x5e, 0xa7, 0xb3} // approve(address,uint256)
+)
+
+// callFrame is a call as reported by the native callTracer.
+type callFrame struct {
+	Type    string         `json:"type"`
+	From    common.Address `json:"from"`
+	To      common.Address `json:"to"`
+	Value   *hexutil.Big   `json:"value"`
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+	Input   hexutil.Bytes  `json:"input"`
+	Output  hexutil.Bytes  `json:"output"`
+	Error   string         `json:"error"`
+	Calls   []callFrame    `json:"calls"`
+}
+
+// Simulator implements core.Simulator by tracing transactions on a node.
+type Simulator struct {
+	client *rpc.Client
+}
+
+// New creates a simulator using the given node, which needs to expose the debug
+// namespace.
+func New(client *rpc.Client) *Simulator {
+	return &Simulator{client: client}
+}
+
+// SimulateTx implements core.Simulator, tracing the transaction on top of the
+// latest block of the node.
+func (s *Simulator) SimulateTx(ctx context.Context, args *apitypes.SendTxArgs) (*core.Simulation, error) {
+	var frame callFrame
+	config := map[string]interface{}{"tracer": "callTracer"}
+	if err := s.client.CallContext(ctx, &frame, "debug_traceCall", args, "latest", config); err != nil {
+		return nil, err
+	}
+	return analyze(&frame), nil
+}
+
+// analyze derives the transfers, approvals and balance changes from a call trace.
+func analyze(frame *callFrame) *core.Simulation {
+	sim := &core.Simulation{
+		GasUsed:       frame.GasUsed,
+		Transfers:     []core.SimulatedTransfer{},
+		Approvals:     []core.SimulatedApproval{},
+		BalanceDeltas: []core.SimulatedBalance{},
+	}
+	if frame.Error != "" {
+		sim.Reverted = true
+		sim.RevertReason = frame.Error
+		if reason, err := abi.UnpackRevert(frame.Output); err == nil {
+			sim.RevertReason = reason
+		}
+		return sim
+	}
+	collect(frame, sim)
+
+	// Sum up the transfers into the net balance changes
+	type holding struct {
+		token   common.Address // Zero address for ether
+		account common.Address
+	}
+	deltas := make(map[holding]*big.Int)
+	update := func(token *common.Address, account common.Address, delta *big.Int) {
+		var key = holding{account: account}
+		if token != nil {
+			key.token = *token
+		}
+		if deltas[key] == nil {
+			deltas[key] = new(big.Int)
+		}
+		deltas[key].Add(deltas[key], delta)
+	}
+	for _, t := range sim.Transfers {
+		update(t.Token, t.From, new(big.Int).Neg(t.Value.ToInt()))
+		update(t.Token, t.To, t.Value.ToInt())
+	}
+	for key, delta := range deltas {
+		if delta.Sign() == 0 {
+			continue
+		}
+		balance := core.SimulatedBalance{Account: key.account, Delta: (*hexutil.Big)(delta)}
+		if key.token != (common.Address{}) {
+			token := key.token
+			balance.Token = &token
+		}
+		sim.BalanceDeltas = append(sim.BalanceDeltas, balance)
+	}
+	sort.Slice(sim.BalanceDeltas, func(i, j int) bool {
+		a, b := sim.BalanceDeltas[i], sim.BalanceDeltas[j]
+		if (a.Token == nil) != (b.Token == nil) {
+			return a.Token == nil
+		}
+		if a.Token != nil && *a.Token != *b.Token {
+			return bytes.Compare(a.Token[:], b.Token[:]) < 0
+		}
+		return bytes.Compare(a.Account[:], b.Account[:]) < 0
+	})
+	return sim
+}
+
+// collect gathers the transfers and approvals of a successful call frame and
+// its successful subcalls, in execution order.
+func collect(frame *callFrame, sim *core.Simulation) {
+	switch frame.Type {
+	case "CALL", "CREATE", "CREATE2", "SELFDESTRUCT":
+		if frame.Value != nil && frame.Value.ToInt().Sign() > 0 {
+			sim.Transfers = append(sim.Transfers, core.SimulatedTransfer{
+				From:  frame.From,
+				To:    frame.To,
+				Value: frame.Value,
+			})
+		}
+	}
+	if frame.Type == "CALL" && len(frame.Input) >= 4 {
+		token := frame.To
+		args := frame.Input[4:]
+
+		switch selector := frame.Input[:4]; {
+		case bytes.Equal(selector, transferSelector) && len(args) >= 64:
+			sim.Transfers = append(sim.Transfers, core.SimulatedTransfer{
+				Token: &token,
+				From:  frame.From,
+				To:    common.BytesToAddress(args[:32]),
+				Value: (*hexutil.Big)(new(big.Int).SetBytes(args[32:64])),
+			})
+		case bytes.Equal(selector, transferFromSelector) && len(args) >= 96:
+			sim.Transfers = append(sim.Transfers, core.SimulatedTransfer{
+				Token: &token,
+				From:  common.BytesToAddress(args[:32]),
+				To:    common.BytesToAddress(args[32:64]),
+				Value: (*hexutil.Big)(new(big.Int).SetBytes(args[64:96])),
+			})
+		case bytes.Equal(selector, approveSelector) && len(args) >= 64:
+			sim.Approvals = append(sim.Approvals, core.SimulatedApproval{
+				Token:   token,
+				Owner:   frame.From,
+				Spender: common.BytesToAddress(args[:32]),
+				Value:   (*hexutil.Big)(new(big.Int).SetBytes(args[32:64])),
+			})
+		}
+	}
+	for i := range frame.Calls {
+		// Reverted subcalls have no effect, neither have their children
+		if frame.Calls[i].Error == "" {
+			collect(&frame.Calls[i], sim)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// traceSwap is a callTracer result of a contract call paying 1 wei, pulling
+// 0x10 tokens from the sender, approving them and forwarding 0x0c of them.
+// A reverted subcall attempts another transfer, which must be ignored.
+const traceSwap = `{
+	"type": "CALL", "from": "0x00000000000000000000000000000000000000aa", "to": "0x00000000000000000000000000000000000000cc",
+	"value": "0x1", "gas": "0x10000", "gasUsed": "0x5208", "input": "0x12345678",
+	"calls": [
+		{"type": "CALL", "from": "0x00000000000000000000000000000000000000cc", "to": "0x00000000000000000000000000000000000000dd", "gas": "0x100", "gasUsed": "0x100",
+		 "input": "0x23b872dd00000000000000000000000000000000000000000000000000000000000000aa00000000000000000000000000000000000000000000000000000000000000cc0000000000000000000000000000000000000000000000000000000000000010"},
+		{"type": "CALL", "from": "0x00000000000000000000000000000000000000cc", "to": "0x00000000000000000000000000000000000000dd", "gas": "0x100", "gasUsed": "0x100",
+		 "input": "0x095ea7b300000000000000000000000000000000000000000000000000000000000000ee0000000000000000000000000000000000000000000000000000000000000010"},
+		{"type": "CALL", "from": "0x00000000000000000000000000000000000000cc", "to": "0x00000000000000000000000000000000000000dd", "gas": "0x100", "gasUsed": "0x100",
+		 "input": "0xa9059cbb00000000000000000000000000000000000000000000000000000000000000ee000000000000000000000000000000000000000000000000000000000000000c"},
+		{"type": "CALL", "from": "0x00000000000000000000000000000000000000cc", "to": "0x00000000000000000000000000000000000000dd", "gas": "0x100", "gasUsed": "0x100", "error": "execution reverted",
+		 "input": "0xa9059cbb00000000000000000000000000000000000000000000000000000000000000ee000000000000000000000000000000000000000000000000000000000000000c"}
+	]
+}`
+
+// traceRevert is a callTracer result of a call reverting with a reason.
+const traceRevert = `{
+	"type": "CALL", "from": "0x00000000000000000000000000000000000000aa", "to": "0x00000000000000000000000000000000000000cc",
+	"value": "0x1", "gas": "0x10000", "gasUsed": "0x5208", "input": "0x", "error": "execution reverted",
+	"output": "0x08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000b6e6f7420616c6c6f776564000000000000000000000000000000000000000000"
+}`
+
+// debugAPI is a fake debug namespace returning a canned trace.
+type debugAPI struct {
+	trace string
+}
+
+func (api *debugAPI) TraceCall(args json.RawMessage, block string, config map[string]interface{}) (json.RawMessage, error) {
+	return json.RawMessage(api.trace), nil
+}
+
+func simulate(t *testing.T, trace string) *Simulator {
+	server := rpc.NewServer()
+	if err := server.RegisterName("debug", &debugAPI{trace}); err != nil {
+		t.Fatalf("failed to register api: %v", err)
+	}
+	t.Cleanup(server.Stop)
+	return New(rpc.DialInProc(server))
+}
+
+func TestSimulateTransfers(t *testing.T) {
+	sim, err := simulate(t, traceSwap).SimulateTx(context.Background(), &apitypes.SendTxArgs{})
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	if sim.Reverted {
+		t.Fatalf("successful call reported as reverted")
+	}
+	if len(sim.Transfers) != 3 {
+		t.Fatalf("transfer count mismatch: have %d, want 3", len(sim.Transfers))
+	}
+	if sim.Transfers[0].Token != nil || sim.Transfers[0].Value.ToInt().Int64() != 1 {
+		t.Errorf("ether transfer mismatch: %+v", sim.Transfers[0])
+	}
+	if len(sim.Approvals) != 1 || sim.Approvals[0].Spender != common.HexToAddress("0xee") || sim.Approvals[0].Owner != common.HexToAddress("0xcc") {
+		t.Errorf("approval mismatch: %+v", sim.Approvals)
+	}
+	want := []struct {
+		token   bool
+		account string
+		delta   int64
+	}{
+		{false, "0xaa", -1},
+		{false, "0xcc", 1},
+		{true, "0xaa", -0x10},
+		{true, "0xcc", 0x04},
+		{true, "0xee", 0x0c},
+	}
+	if len(sim.BalanceDeltas) != len(want) {
+		t.Fatalf("balance delta count mismatch: have %d, want %d", len(sim.BalanceDeltas), len(want))
+	}
+	for i, w := range want {
+		have := sim.BalanceDeltas[i]
+		if (have.Token != nil) != w.token || have.Account != common.HexToAddress(w.account) || have.Delta.ToInt().Int64() != w.delta {
+			t.Errorf("balance delta %d mismatch: have %v %v %v, want %v", i, have.Token, have.Account, have.Delta, w)
+		}
+	}
+}
+
+func TestSimulateRevert(t *testing.T) {
+	sim, err := simulate(t, traceRevert).SimulateTx(context.Background(), &apitypes.SendTxArgs{})
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	if !sim.Reverted || sim.RevertReason != "not allowed" {
+		t.Errorf("revert mismatch: have %v %q, want true %q", sim.Reverted, sim.RevertReason, "not allowed")
+	}
+	if len(sim.Transfers) != 0 || len(sim.BalanceDeltas) != 0 {
+		t.Errorf("reverted call reported effects")
+	}
+}